@@ -0,0 +1,163 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/xsikor/yai/ai/provider"
+	"github.com/xsikor/yai/attachment"
+)
+
+// DefaultAnalyzeConcurrency is how many parts of an analyzed input are sent
+// to the provider at once
+const DefaultAnalyzeConcurrency = 4
+
+// defaultAnalyzeQuestion is asked when the input is analyzed without prompt
+const defaultAnalyzeQuestion = "What went wrong? Point out the errors and anomalies."
+
+const analyzeChunkSystemPrompt = `You are Yai, analyzing one part of a large input piped by the user, like a log, too large to be read at once. Each line starts with its line number as L<number>.
+Report what answers the user question, and the errors, warnings, crashes and anomalies of this part, as a short list. Cite each finding with its line numbers, like L120 or L120-L134, and quote the relevant text briefly.
+If nothing in this part is relevant, answer "Nothing relevant." only. Do not guess about the other parts.`
+
+// chunkFinding is what the analysis of a part of the input found
+type chunkFinding struct {
+	chunk   attachment.PipeChunk
+	content string
+	err     error
+}
+
+// SetPipeChunks sets a piped input too large to be sent at once, analyzed by
+// the next chat completion: each part is searched separately, at most
+// concurrency at once, and the findings are reduced into the answer.
+func (e *Engine) SetPipeChunks(chunks []attachment.PipeChunk, concurrency int) *Engine {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.pipeChunks = chunks
+	e.concurrency = max(1, concurrency)
+	if len(chunks) > 0 {
+		e.mode = ChatEngineMode
+	}
+
+	return e
+}
+
+// runAnalysis analyzes the parts of the piped input, then streams the report
+// answering the question from their findings. The question and the report
+// are kept in the conversation, for follow-up questions.
+func (e *Engine) runAnalysis(stream *ChatStream, providerInstance provider.Provider, question string, chunks []attachment.PipeChunk, concurrency int) {
+	if strings.TrimSpace(question) == "" {
+		question = defaultAnalyzeQuestion
+	}
+
+	e.mu.Lock()
+	base := e.prepareCompletionRequest(false)
+	e.mu.Unlock()
+
+	findings, err := analyzeChunks(stream.Context(), providerInstance, base, question, chunks, concurrency)
+	if stream.Context().Err() != nil {
+		e.endChatStream(stream, false, nil)
+		return
+	}
+	if err != nil {
+		e.endChatStream(stream, false, err)
+		return
+	}
+
+	e.mu.Lock()
+	e.appendUserMessage(ChatEngineMode, question)
+	req := e.prepareCompletionRequest(true)
+	// Only the request carries the findings, the history keeps the question
+	last := &req.Messages[len(req.Messages)-1]
+	last.Content = prepareAnalysisPrompt(question, findings)
+	e.mu.Unlock()
+
+	e.runChatStream(stream, providerInstance, ChatEngineMode, req)
+}
+
+// analyzeChunks sends the parts of the input to the provider, at most
+// concurrency at once. A failing part is reported in its finding, the
+// analysis only failing if every part does.
+func analyzeChunks(ctx context.Context, providerInstance provider.Provider, base provider.CompletionRequest, question string, chunks []attachment.PipeChunk, concurrency int) ([]chunkFinding, error) {
+	findings := make([]chunkFinding, len(chunks))
+	slots := make(chan struct{}, max(1, concurrency))
+
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		findings[i].chunk = chunk
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				findings[i].err = ctx.Err()
+				return
+			}
+
+			req := base
+			req.Stream = false
+			req.Messages = []provider.Message{
+				{Role: "system", Content: analyzeChunkSystemPrompt},
+				{Role: UserRole, Content: prepareChunkPrompt(question, chunk)},
+			}
+			findings[i].content, findings[i].err = providerInstance.CreateCompletion(ctx, req)
+		}()
+	}
+	wg.Wait()
+
+	for _, finding := range findings {
+		if finding.err == nil {
+			return findings, nil
+		}
+	}
+
+	return nil, fmt.Errorf("could not analyze the input: %w", findings[0].err)
+}
+
+// prepareChunkPrompt returns the question along with the lines of the part,
+// numbered so the findings can refer to them
+func prepareChunkPrompt(question string, chunk attachment.PipeChunk) string {
+	var prompt strings.Builder
+
+	prompt.WriteString(fmt.Sprintf("My question about the whole input: %s\n\n", question))
+	prompt.WriteString(fmt.Sprintf("Here are lines %d to %d of the input:\n\n", chunk.FirstLine, chunk.LastLine))
+	for i, line := range strings.Split(chunk.Content, "\n") {
+		prompt.WriteString(fmt.Sprintf("L%d: %s\n", chunk.FirstLine+i, line))
+	}
+
+	return prompt.String()
+}
+
+// prepareAnalysisPrompt returns the question along with the findings of
+// every part, to be reduced into the final report
+func prepareAnalysisPrompt(question string, findings []chunkFinding) string {
+	var prompt strings.Builder
+
+	first := findings[0].chunk
+	last := findings[len(findings)-1].chunk
+	prompt.WriteString(fmt.Sprintf("I piped an input too large to be read at once. Lines %d to %d were split into %d parts, each one searched separately for what answers my question and for errors and anomalies.", first.FirstLine, last.LastLine, len(findings)))
+	if first.FirstLine > 1 {
+		prompt.WriteString(fmt.Sprintf(" The first %d lines were left out, only the end of the input being analyzed.", first.FirstLine-1))
+	}
+	prompt.WriteString(" Here are the findings of each part:\n\n")
+
+	for _, finding := range findings {
+		prompt.WriteString(fmt.Sprintf("## Lines %d to %d\n\n", finding.chunk.FirstLine, finding.chunk.LastLine))
+		if finding.err != nil {
+			prompt.WriteString(fmt.Sprintf("This part could not be analyzed: %s\n\n", finding.err))
+		} else {
+			prompt.WriteString(strings.TrimSpace(finding.content) + "\n\n")
+		}
+	}
+
+	prompt.WriteString(fmt.Sprintf("Based on these findings, answer my question: %s\n\n", question))
+	prompt.WriteString("Write a markdown report: a short answer first, then the key findings citing their line numbers like L120, the likely cause, and what to do next. Only cite lines given in the findings, and say so if some parts could not be analyzed.")
+
+	return prompt.String()
+}
@@ -0,0 +1,146 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xsikor/yai/ai/provider"
+	"github.com/xsikor/yai/attachment"
+	"github.com/xsikor/yai/internal/testprovider"
+)
+
+// analyzeProvider answers the analysis of each part, recording how many ran
+// at once, and streams the report, recording its request
+type analyzeProvider struct {
+	testprovider.Provider
+	mu         sync.Mutex
+	running    int
+	maxRunning int
+	prompts    []string
+	failing    string
+	report     provider.CompletionRequest
+}
+
+func (p *analyzeProvider) CreateCompletion(ctx context.Context, req provider.CompletionRequest) (string, error) {
+	prompt := req.Messages[1].Content
+
+	p.mu.Lock()
+	p.running++
+	p.maxRunning = max(p.maxRunning, p.running)
+	p.prompts = append(p.prompts, prompt)
+	p.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	p.mu.Lock()
+	p.running--
+	p.mu.Unlock()
+
+	if p.failing != "" && strings.Contains(prompt, p.failing) {
+		return "", errors.New("rate limited")
+	}
+	if strings.Contains(prompt, "panic:") {
+		return "- L5: panic: nil map", nil
+	}
+
+	return "Nothing relevant.", nil
+}
+
+func (p *analyzeProvider) CreateCompletionStream(ctx context.Context, req provider.CompletionRequest) (<-chan provider.CompletionResponse, error) {
+	p.mu.Lock()
+	p.report = req
+	p.mu.Unlock()
+
+	return p.Provider.CreateCompletionStream(ctx, req)
+}
+
+func newTestChunks() []attachment.PipeChunk {
+	return []attachment.PipeChunk{
+		{Content: "starting\nlistening", FirstLine: 1, LastLine: 2},
+		{Content: "request\nrequest", FirstLine: 3, LastLine: 4},
+		{Content: "panic: nil map\nexit 2", FirstLine: 5, LastLine: 6},
+		{Content: "restarting", FirstLine: 7, LastLine: 7},
+		{Content: "starting", FirstLine: 8, LastLine: 8},
+	}
+}
+
+func TestEngineAnalyze(t *testing.T) {
+	p := &analyzeProvider{Provider: testprovider.Provider{Chunks: []string{"It crashed ", "at L5."}}}
+	engine := newTestEngine(ExecEngineMode, p)
+	engine.SetPipeChunks(newTestChunks(), 2)
+	assert.Equal(t, ChatEngineMode, engine.GetMode())
+
+	content, last := readStream(t, engine.ChatStreamCompletion("why is it crashing?"))
+	require.NoError(t, last.GetError())
+	assert.Equal(t, "It crashed at L5.", content)
+
+	// Every part is searched, at most two at once, with numbered lines
+	require.Len(t, p.prompts, 5)
+	assert.Equal(t, 2, p.maxRunning)
+	assert.Contains(t, p.prompts, "My question about the whole input: why is it crashing?\n\nHere are lines 5 to 6 of the input:\n\nL5: panic: nil map\nL6: exit 2\n")
+
+	// The report is asked from the findings, the history keeping the question
+	report := p.report.Messages[len(p.report.Messages)-1].Content
+	assert.Contains(t, report, "Lines 1 to 8 were split into 5 parts")
+	assert.Contains(t, report, "## Lines 5 to 6\n\n- L5: panic: nil map\n\n## Lines 7 to 7\n\nNothing relevant.")
+	assert.Contains(t, report, "answer my question: why is it crashing?")
+	assert.NotContains(t, report, "left out")
+
+	entries := engine.GetConversation().Entries
+	require.Len(t, entries, 2)
+	assert.Equal(t, "why is it crashing?", entries[0].Content)
+	assert.Equal(t, "It crashed at L5.", entries[1].Content)
+
+	// The parts are analyzed once, follow-up questions go on the report
+	p.prompts = nil
+	readStream(t, engine.ChatStreamCompletion("how do I fix it?"))
+	assert.Empty(t, p.prompts)
+}
+
+func TestEngineAnalyzeFailures(t *testing.T) {
+	chunks := newTestChunks()
+	chunks[0].FirstLine = 1000
+
+	p := &analyzeProvider{Provider: testprovider.Provider{Chunks: []string{"Report"}}, failing: "lines 3 to 4"}
+	engine := newTestEngine(ChatEngineMode, p)
+	engine.SetPipeChunks(chunks, 0)
+
+	_, last := readStream(t, engine.ChatStreamCompletion(""))
+	require.NoError(t, last.GetError())
+	assert.Equal(t, 1, p.maxRunning)
+
+	report := p.report.Messages[len(p.report.Messages)-1].Content
+	assert.Contains(t, report, "The first 999 lines were left out")
+	assert.Contains(t, report, "## Lines 3 to 4\n\nThis part could not be analyzed: rate limited\n\n")
+	assert.Contains(t, report, "answer my question: "+defaultAnalyzeQuestion)
+
+	// The analysis fails if no part could be analyzed
+	p = &analyzeProvider{failing: "lines"}
+	engine = newTestEngine(ChatEngineMode, p)
+	engine.SetPipeChunks(newTestChunks(), 4)
+
+	_, last = readStream(t, engine.ChatStreamCompletion("why?"))
+	assert.EqualError(t, last.GetError(), "could not analyze the input: rate limited")
+	assert.Empty(t, engine.GetConversation().Entries)
+}
+
+func TestEngineAnalyzeInterrupt(t *testing.T) {
+	p := &analyzeProvider{}
+	engine := newTestEngine(ChatEngineMode, p)
+	engine.SetPipeChunks(newTestChunks(), 1)
+
+	stream := engine.ChatStreamCompletion("why?")
+	engine.Interrupt()
+
+	_, last := readStream(t, stream)
+	assert.True(t, last.IsInterrupt())
+	assert.NoError(t, last.GetError())
+	assert.Less(t, len(p.prompts), 5)
+}
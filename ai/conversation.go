@@ -0,0 +1,145 @@
+package ai
+
+import (
+	"encoding/json"
+	"regexp"
+	"time"
+)
+
+const (
+	UserRole      = "user"
+	AssistantRole = "assistant"
+	// CommandRole is a command run during the conversation
+	CommandRole = "command"
+)
+
+// ConversationEntry is a message of the conversation, or a command run
+type ConversationEntry struct {
+	Time time.Time `json:"time"`
+	Role string    `json:"role"`
+	Mode string    `json:"mode"`
+	// Content is the text of a message, the explanation of a generated
+	// command, or the output of a command run
+	Content string `json:"content,omitempty"`
+	// Command is the command generated by an exec answer, or run
+	Command  string `json:"command,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	// Images are the paths of the images sent with a prompt
+	Images []string `json:"images,omitempty"`
+}
+
+// Conversation is what was said and run so far, in order, with what is sent
+// along with every request
+type Conversation struct {
+	Provider     string              `json:"provider"`
+	Model        string              `json:"model"`
+	Entries      []ConversationEntry `json:"entries"`
+	SystemPrompt string              `json:"system_prompt,omitempty"`
+	Pipe         string              `json:"pipe,omitempty"`
+	Attachments  []string            `json:"attachments,omitempty"`
+}
+
+// GetConversation returns the conversation since the last reset
+func (e *Engine) GetConversation() Conversation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	conversation := Conversation{
+		Provider:     string(e.config.GetAiConfig().GetProviderType()),
+		Model:        e.config.GetAiConfig().GetModel(),
+		Entries:      make([]ConversationEntry, len(e.conversation)),
+		SystemPrompt: e.prepareSystemPrompt(),
+		Pipe:         e.pipe,
+	}
+	copy(conversation.Entries, e.conversation)
+	for _, file := range e.attachments {
+		conversation.Attachments = append(conversation.Attachments, file.GetPath())
+	}
+
+	return conversation
+}
+
+// AddCommandRun records a command run, with its output if captured, the
+// exit code being -1 if it could not start
+func (e *Engine) AddCommandRun(command string, output string, exitCode int) *Engine {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.conversation = append(e.conversation, ConversationEntry{
+		Time:     time.Now(),
+		Role:     CommandRole,
+		Mode:     e.mode.String(),
+		Content:  output,
+		Command:  command,
+		ExitCode: &exitCode,
+	})
+
+	return e
+}
+
+// GetLastCommandOutput returns the captured output of the last command run,
+// empty if none was run or its output was not captured
+func (e *Engine) GetLastCommandOutput() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i := len(e.conversation) - 1; i >= 0; i-- {
+		if e.conversation[i].Role == CommandRole {
+			return e.conversation[i].Content
+		}
+	}
+
+	return ""
+}
+
+// recordMessage adds a message to the conversation, with the images sent
+// along with a prompt, an exec answer being split into its command and
+// explanation. The caller must hold e.mu.
+func (e *Engine) recordMessage(mode EngineMode, role string, content string) {
+	entry := ConversationEntry{
+		Time:    time.Now(),
+		Role:    role,
+		Mode:    mode.String(),
+		Content: content,
+	}
+	if role == UserRole {
+		for _, image := range e.images {
+			entry.Images = append(entry.Images, image.GetPath())
+		}
+	}
+
+	if mode == ExecEngineMode && role == AssistantRole {
+		if output, err := parseExecOutput(content); err == nil {
+			entry.Command = output.Command
+			entry.Content = output.Explanation
+		}
+	}
+
+	e.conversation = append(e.conversation, entry)
+}
+
+// parseExecOutput reads the JSON of an exec answer, possibly surrounded by
+// text, an answer without JSON being an explanation without command
+func parseExecOutput(content string) (EngineExecOutput, error) {
+	var output EngineExecOutput
+	err := json.Unmarshal([]byte(content), &output)
+	if err == nil {
+		return output, nil
+	}
+
+	match := regexp.MustCompile(`\{.*?\}`).FindString(content)
+	if match == "" {
+		return EngineExecOutput{
+			Command:     "",
+			Explanation: content,
+			Executable:  false,
+		}, nil
+	}
+
+	output = EngineExecOutput{}
+	if err := json.Unmarshal([]byte(match), &output); err != nil {
+		return EngineExecOutput{}, err
+	}
+
+	return output, nil
+}
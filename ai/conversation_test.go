@@ -0,0 +1,72 @@
+package ai
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xsikor/yai/attachment"
+	"github.com/xsikor/yai/internal/testprovider"
+)
+
+func TestEngineConversation(t *testing.T) {
+	engine := newTestEngine(ExecEngineMode, &testprovider.Provider{Chunks: []string{`{"cmd":"ls -la","exp":"list files","exec":true}`}})
+	engine.Attach(attachment.NewFile("main.go", "package main\n"))
+	engine.SetPipe("some input")
+
+	_, err := engine.ExecCompletion("list files")
+	require.NoError(t, err)
+	engine.AddCommandRun("ls -la", "main.go\n", 0)
+
+	engine.provider = &testprovider.Provider{Chunks: []string{"It is ", "a Go file."}}
+	engine.SetMode(ChatEngineMode)
+	readStream(t, engine.ChatStreamCompletion("what is main.go?"))
+
+	conversation := engine.GetConversation()
+	assert.Equal(t, []string{"main.go"}, conversation.Attachments)
+	assert.Equal(t, "some input", conversation.Pipe)
+	assert.Contains(t, conversation.SystemPrompt, "You are Yai")
+
+	entries := conversation.Entries
+	require.Len(t, entries, 5)
+	assert.Equal(t, UserRole, entries[0].Role)
+	assert.Equal(t, "exec", entries[0].Mode)
+	assert.Equal(t, "list files", entries[0].Content)
+
+	// The exec answer is split into its command and explanation
+	assert.Equal(t, AssistantRole, entries[1].Role)
+	assert.Equal(t, "ls -la", entries[1].Command)
+	assert.Equal(t, "list files", entries[1].Content)
+
+	assert.Equal(t, "main.go\n", engine.GetLastCommandOutput())
+
+	assert.Equal(t, CommandRole, entries[2].Role)
+	assert.Equal(t, "main.go\n", entries[2].Content)
+	require.NotNil(t, entries[2].ExitCode)
+	assert.Equal(t, 0, *entries[2].ExitCode)
+
+	assert.Equal(t, "chat", entries[3].Mode)
+	assert.Equal(t, "It is a Go file.", entries[4].Content)
+
+	// The returned entries are a copy
+	entries[0].Content = "changed"
+	assert.Equal(t, "list files", engine.GetConversation().Entries[0].Content)
+
+	engine.Reset()
+	assert.Empty(t, engine.GetConversation().Entries)
+	assert.Empty(t, engine.GetLastCommandOutput())
+}
+
+func TestParseExecOutput(t *testing.T) {
+	output, err := parseExecOutput(`Sure: {"cmd":"df -h","exp":"disk usage","exec":true}`)
+	require.NoError(t, err)
+	assert.Equal(t, EngineExecOutput{Command: "df -h", Explanation: "disk usage", Executable: true}, output)
+
+	output, err = parseExecOutput("I cannot do that.")
+	require.NoError(t, err)
+	assert.Equal(t, EngineExecOutput{Explanation: "I cannot do that."}, output)
+
+	_, err = parseExecOutput("{not json}")
+	assert.Error(t, err)
+}
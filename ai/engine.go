@@ -2,31 +2,56 @@ package ai
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"regexp"
+	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/xsikor/yai/ai/intent"
 	"github.com/xsikor/yai/ai/provider"
+	"github.com/xsikor/yai/attachment"
 	"github.com/xsikor/yai/config"
+	"github.com/xsikor/yai/run"
 	"github.com/xsikor/yai/system"
 )
 
 const noexec = "[noexec]"
 
+// intentTimeout is how long the intent model is waited for, the rules
+// deciding past it
+const intentTimeout = 10 * time.Second
+
+// maxWorkspaceItems is how many Makefile targets or compose services are
+// sent at most
+const maxWorkspaceItems = 20
+
+// Engine is safe for concurrent use: its state is guarded by mu, and every
+// streamed request owns its ChatStream instead of sharing a channel.
 type Engine struct {
+	mu                sync.Mutex
 	mode              EngineMode
 	config            *config.Config
 	provider          provider.Provider
 	execMessages      []provider.Message
 	chatMessages      []provider.Message
-	sharedHistory     []provider.Message // Shared context between modes
-	terminalOutputs   []string           // History of terminal outputs for context
-	maxSharedHistory  int                // Maximum number of messages to keep in shared history
-	maxTerminalOutput int                // Maximum number of terminal outputs to keep
-	channel           chan EngineChatStreamOutput
+	sharedHistory     []provider.Message  // Shared context between modes
+	terminalOutputs   []string            // History of terminal outputs for context
+	conversation      []ConversationEntry // Messages and commands run, in order, to export them
+	maxSharedHistory  int                 // Maximum number of messages to keep in shared history
+	maxTerminalOutput int                 // Maximum number of terminal outputs to keep
+	stream            *ChatStream         // Currently running stream, if any
+	attachments       []attachment.File   // Files sent as context with every request
+	images            []attachment.Image  // Images sent with the next prompt
+	usage             Usage               // Estimated usage of the last completion
 	pipe              string
-	running           bool
+	// Piped input analyzed by the next chat completion, concurrency parts at once
+	pipeChunks  []attachment.PipeChunk
+	concurrency int
+	// Finds the programs of the generated commands, like exec.LookPath
+	lookPath func(file string) (string, error)
 }
 
 func NewEngine(mode EngineMode, config *config.Config) (*Engine, error) {
@@ -39,6 +64,16 @@ func NewEngine(mode EngineMode, config *config.Config) (*Engine, error) {
 		return nil, err
 	}
 
+	return newEngine(mode, config, providerInstance), nil
+}
+
+// NewEngineWithProvider creates an engine sending its requests to the given
+// provider, instead of the one of the config
+func NewEngineWithProvider(mode EngineMode, config *config.Config, providerInstance provider.Provider) *Engine {
+	return newEngine(mode, config, providerInstance)
+}
+
+func newEngine(mode EngineMode, config *config.Config, providerInstance provider.Provider) *Engine {
 	return &Engine{
 		mode:              mode,
 		config:            config,
@@ -49,13 +84,53 @@ func NewEngine(mode EngineMode, config *config.Config) (*Engine, error) {
 		terminalOutputs:   make([]string, 0),
 		maxSharedHistory:  5, // Store the last 5 messages for context
 		maxTerminalOutput: 5, // Store the last 5 terminal outputs
-		channel:           make(chan EngineChatStreamOutput),
+		stream:            nil,
+		attachments:       make([]attachment.File, 0),
 		pipe:              "",
-		running:           false,
-	}, nil
+		lookPath:          exec.LookPath,
+	}
+}
+
+// SetConfig switches the engine to another config, rebuilding its provider.
+// Both conversation histories are kept.
+func (e *Engine) SetConfig(config *config.Config) error {
+	providerInstance, err := provider.CreateProvider(
+		config.GetAiConfig().GetProviderType(),
+		config.GetAiConfig().GetKey(),
+		config.GetAiConfig().GetProxy(),
+	)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.config = config
+	e.provider = providerInstance
+
+	return nil
+}
+
+func (e *Engine) GetConfig() *config.Config {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.config
+}
+
+// GetAvailableModels returns the models of the current provider
+func (e *Engine) GetAvailableModels() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.provider.AvailableModels()
 }
 
 func (e *Engine) SetMode(mode EngineMode) *Engine {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	// If mode is changing, save current context before switching
 	if e.mode != mode {
 		e.updateSharedHistory()
@@ -66,7 +141,8 @@ func (e *Engine) SetMode(mode EngineMode) *Engine {
 	return e
 }
 
-// updateSharedHistory saves recent messages from current mode to shared history
+// updateSharedHistory saves recent messages from current mode to shared history.
+// The caller must hold e.mu.
 func (e *Engine) updateSharedHistory() {
 	var currentMessages []provider.Message
 
@@ -92,11 +168,18 @@ func (e *Engine) updateSharedHistory() {
 }
 
 func (e *Engine) GetMode() EngineMode {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	return e.mode
 }
 
-func (e *Engine) GetChannel() chan EngineChatStreamOutput {
-	return e.channel
+// IsRunning reports whether a completion is currently in progress
+func (e *Engine) IsRunning() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.stream != nil
 }
 
 // AddTerminalOutput adds a terminal output to history
@@ -106,6 +189,9 @@ func (e *Engine) AddTerminalOutput(output string) *Engine {
 		return e
 	}
 
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	// Add new output to the terminal outputs
 	e.terminalOutputs = append(e.terminalOutputs, output)
 
@@ -119,94 +205,168 @@ func (e *Engine) AddTerminalOutput(output string) *Engine {
 
 // GetTerminalOutputs returns all terminal outputs
 func (e *Engine) GetTerminalOutputs() []string {
-	return e.terminalOutputs
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	outputs := make([]string, len(e.terminalOutputs))
+	copy(outputs, e.terminalOutputs)
+
+	return outputs
 }
 
+// SetPipe sets the piped input sent with every request
 func (e *Engine) SetPipe(pipe string) *Engine {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	e.pipe = pipe
 
-	// Auto-detect mode based on piped content if no mode was explicitly set
-	if pipe != "" {
-		// Use isProbablyCommand from ui package to detect if the pipe content is a command
-		if e.detectPipeContentMode(pipe) {
-			e.mode = ExecEngineMode
-		} else {
-			e.mode = ChatEngineMode
-		}
+	return e
+}
+
+// Attach adds files to the context sent with every request, replacing the
+// ones already attached with the same path
+func (e *Engine) Attach(files ...attachment.File) *Engine {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, file := range files {
+		e.attachments = slices.DeleteFunc(e.attachments, func(attached attachment.File) bool {
+			return attached.GetPath() == file.GetPath()
+		})
+		e.attachments = append(e.attachments, file)
 	}
 
 	return e
 }
 
-// detectPipeContentMode determines if piped content is likely a command or chat
-// Returns true if content is likely a command, false otherwise
-func (e *Engine) detectPipeContentMode(input string) bool {
-	// If empty, can't tell
-	if len(input) == 0 {
-		return false
-	}
+// Detach removes the attached files matching the pattern, a path, a
+// directory or a glob, or all of them if empty. It returns how many were removed.
+func (e *Engine) Detach(pattern string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	count := len(e.attachments)
+	e.attachments = slices.DeleteFunc(e.attachments, func(attached attachment.File) bool {
+		return matchAttachment(pattern, attached.GetPath())
+	})
+
+	return count - len(e.attachments)
+}
 
-	// Common command patterns
-	commandRegexps := []string{
-		`^(ls|cd|grep|find|git|docker|kubectl|npm|go|python|pip)\s`,
-		`^[./]`,      // Starts with ./ or /
-		`\|\s*(\w+)`, // Contains pipe operator
-		`sudo\s`,     // Contains sudo
-		`^(cat|less|more|head|tail|vim|nano|mkdir|rmdir|touch|chmod|chown)\s`,
-		`^(apt|yum|brew)\s`,
-		`\s(>|>>|<)\s`, // Contains redirection operators
+func matchAttachment(pattern string, path string) bool {
+	if pattern == "" {
+		return true
 	}
 
-	// Check if input matches any command patterns
-	for _, pattern := range commandRegexps {
-		matched, _ := regexp.MatchString(pattern, input)
-		if matched {
-			return true
-		}
+	pattern = filepath.Clean(pattern)
+	if matched, _ := filepath.Match(pattern, path); matched {
+		return true
 	}
 
-	// Special case: Command-like queries
-	commandQueryPatterns := []string{
-		`(?i)^(what|how|show|get|find|list|display).*(command|run|execute)`,
-		`(?i)^(what|how).*(ip|address|port|url|endpoint)`,
-		`(?i)^(what|how).*(container|pod|instance|server|service)`,
-		`(?i)^(how to|how do I) `,
-		`(?i)command for`,
-		`(?i)command to `,
+	return strings.HasPrefix(path, pattern+string(filepath.Separator))
+}
+
+// GetSystemContext returns the context about the user sent in the system
+// prompt with every request
+func (e *Engine) GetSystemContext() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.prepareSystemPromptContextPart()
+}
+
+// GetAttachments returns a copy of the attached files
+func (e *Engine) GetAttachments() []attachment.File {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return slices.Clone(e.attachments)
+}
+
+// AttachImages adds images to the next prompt, the prompt failing if the
+// model in use does not accept images
+func (e *Engine) AttachImages(images ...attachment.Image) *Engine {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.images = append(e.images, images...)
+
+	return e
+}
+
+// GetImages returns the images waiting for the next prompt
+func (e *Engine) GetImages() []attachment.Image {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return slices.Clone(e.images)
+}
+
+// ClassifyIntent returns the intent of the input from the rules, asking the
+// intent model of the config if they are unsure. A failing model request
+// leaves the unsure result of the rules.
+func (e *Engine) ClassifyIntent(input string) intent.Result {
+	e.mu.Lock()
+	userConfig := e.config.GetUserConfig()
+	providerInstance := e.provider
+	e.mu.Unlock()
+
+	result := intent.NewClassifier(userConfig.GetIntentThreshold()).Classify(input)
+	if result.Confident || userConfig.GetIntentModel() == "" {
+		return result
 	}
 
-	// Check for command-like queries
-	for _, pattern := range commandQueryPatterns {
-		matched, _ := regexp.MatchString(pattern, input)
-		if matched {
-			return true
-		}
+	ctx, cancel := context.WithTimeout(context.Background(), intentTimeout)
+	defer cancel()
+
+	if modelResult, err := intent.NewModelClassifier(providerInstance, userConfig.GetIntentModel()).Classify(ctx, input); err == nil {
+		return modelResult
 	}
 
-	// If text is very short (1-3 words) and doesn't contain question mark, likely a command
-	words := strings.Fields(input)
-	if len(words) <= 3 && !strings.Contains(input, "?") {
-		return true
+	return result
+}
+
+// DetectMode switches to the mode matching the intent of the input, a
+// prompt or piped content, if it is clear. It returns the resulting mode.
+func (e *Engine) DetectMode(input string) EngineMode {
+	result := e.ClassifyIntent(input)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if result.Confident {
+		mode := ChatEngineMode
+		if result.Intent.IsShell() {
+			mode = ExecEngineMode
+		}
+		if e.mode != mode {
+			e.updateSharedHistory()
+		}
+		e.mode = mode
 	}
 
-	// Default to false if no command patterns matched
-	return false
+	return e.mode
 }
 
+// Interrupt cancels the running stream, if any. It never blocks: the stream
+// reports the interruption to its reader through its last output.
 func (e *Engine) Interrupt() *Engine {
-	e.channel <- EngineChatStreamOutput{
-		content:    "[Interrupt]",
-		last:       true,
-		interrupt:  true,
-		executable: false,
-	}
+	e.mu.Lock()
+	stream := e.stream
+	e.mu.Unlock()
 
-	e.running = false
+	if stream != nil {
+		stream.Cancel()
+	}
 
 	return e
 }
 
 func (e *Engine) Clear() *Engine {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	if e.mode == ExecEngineMode {
 		e.execMessages = []provider.Message{}
 	} else {
@@ -217,12 +377,16 @@ func (e *Engine) Clear() *Engine {
 }
 
 func (e *Engine) Reset() *Engine {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	// Save current context before reset
 	e.updateSharedHistory()
 
 	// Clear both message histories
 	e.execMessages = []provider.Message{}
 	e.chatMessages = []provider.Message{}
+	e.conversation = nil
 
 	return e
 }
@@ -230,129 +394,264 @@ func (e *Engine) Reset() *Engine {
 func (e *Engine) ExecCompletion(input string) (*EngineExecOutput, error) {
 	ctx := context.Background()
 
-	e.running = true
-
-	e.appendUserMessage(input)
+	e.mu.Lock()
+	if err := e.checkImages(); err != nil {
+		e.mu.Unlock()
+		return nil, err
+	}
+	mode := e.mode
+	e.appendUserMessage(mode, input)
+	req := e.prepareCompletionRequest(false)
+	providerInstance := e.provider
+	e.mu.Unlock()
 
-	content, err := e.provider.CreateCompletion(
-		ctx,
-		provider.CompletionRequest{
-			Model:       e.config.GetAiConfig().GetModel(),
-			MaxTokens:   e.config.GetAiConfig().GetMaxTokens(),
-			Temperature: e.config.GetAiConfig().GetTemperature(),
-			Messages:    e.prepareCompletionMessages(),
-		},
-	)
+	content, err := providerInstance.CreateCompletion(ctx, req)
 	if err != nil {
 		return nil, err
 	}
+	usage := estimateUsage(req, content)
+
+	output, err := parseExecOutput(content)
+	if err == nil && output.Executable {
+		if invalid := run.ValidateCommand(output.Command, e.lookPath); invalid != nil {
+			var repairUsage Usage
+			content, output, repairUsage = e.repairExecOutput(ctx, providerInstance, req, content, output, invalid)
+			usage.PromptTokens += repairUsage.PromptTokens
+			usage.CompletionTokens += repairUsage.CompletionTokens
+		}
+	}
 
-	e.appendAssistantMessage(content)
+	e.mu.Lock()
+	e.appendAssistantMessage(mode, content)
+	e.usage = usage
+	e.mu.Unlock()
 
-	var output EngineExecOutput
-	err = json.Unmarshal([]byte(content), &output)
 	if err != nil {
-		re := regexp.MustCompile(`\{.*?\}`)
-		match := re.FindString(content)
-		if match != "" {
-			err = json.Unmarshal([]byte(match), &output)
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			output = EngineExecOutput{
-				Command:     "",
-				Explanation: content,
-				Executable:  false,
-			}
-		}
+		return nil, err
 	}
 
 	return &output, nil
 }
 
-func (e *Engine) ChatStreamCompletion(input string) error {
-	ctx := context.Background()
+// ChatStreamCompletion starts a streamed completion for input and returns
+// immediately. The returned stream delivers the answer token by token and is
+// cancelled by Interrupt. If piped input chunks wait to be analyzed, they are
+// first searched for the input, the answer being the resulting report.
+func (e *Engine) ChatStreamCompletion(input string) *ChatStream {
+	stream := newChatStream(context.Background())
 
-	e.running = true
+	e.mu.Lock()
+	if e.stream != nil {
+		e.stream.Cancel()
+	}
+	e.stream = stream
+	mode := e.mode
 
-	e.appendUserMessage(input)
+	if err := e.checkImages(); err != nil {
+		e.mu.Unlock()
+		e.endChatStream(stream, false, err)
 
-	completionReq := provider.CompletionRequest{
-		Model:       e.config.GetAiConfig().GetModel(),
-		MaxTokens:   e.config.GetAiConfig().GetMaxTokens(),
-		Temperature: e.config.GetAiConfig().GetTemperature(),
-		Messages:    e.prepareCompletionMessages(),
-		Stream:      true,
+		return stream
 	}
 
-	stream, err := e.provider.CreateCompletionStream(ctx, completionReq)
-	if err != nil {
-		return err
+	if chunks := e.pipeChunks; len(chunks) > 0 && mode == ChatEngineMode {
+		e.pipeChunks = nil
+		providerInstance := e.provider
+		concurrency := e.concurrency
+		e.mu.Unlock()
+
+		go e.runAnalysis(stream, providerInstance, input, chunks, concurrency)
+
+		return stream
 	}
 
-	var output string
+	e.appendUserMessage(mode, input)
+	req := e.prepareCompletionRequest(true)
+	providerInstance := e.provider
+	e.mu.Unlock()
 
-	for resp := range stream {
-		if !e.running {
-			break
-		}
+	go e.runChatStream(stream, providerInstance, mode, req)
 
-		output += resp.Content
+	return stream
+}
 
-		if resp.Done {
-			executable := false
-			if e.mode == ExecEngineMode {
-				if !strings.HasPrefix(output, noexec) && !strings.Contains(output, "\n") {
-					executable = true
-				}
-			}
+func (e *Engine) runChatStream(stream *ChatStream, providerInstance provider.Provider, mode EngineMode, req provider.CompletionRequest) {
+	var output strings.Builder
 
-			e.channel <- EngineChatStreamOutput{
-				content:    "",
-				last:       true,
-				executable: executable,
+	responses, err := providerInstance.CreateCompletionStream(stream.Context(), req)
+	if err != nil {
+		// An interrupted request fails with the context error
+		if stream.Context().Err() != nil {
+			err = nil
+		}
+		e.endChatStream(stream, false, err)
+		return
+	}
+
+	for resp := range responses {
+		if resp.Err != nil {
+			// Keep what was received so far, the user has already seen it
+			e.mu.Lock()
+			e.appendAssistantMessage(mode, output.String())
+			e.mu.Unlock()
+
+			// The provider fails with the context error once interrupted
+			if stream.Context().Err() != nil {
+				go drainCompletionStream(responses)
+				e.endChatStream(stream, false, nil)
+				return
 			}
-			e.running = false
-			e.appendAssistantMessage(output)
+			e.endChatStream(stream, false, resp.Err)
+			return
+		}
+
+		output.WriteString(resp.Content)
 
-			return nil
+		if resp.Content != "" && !stream.send(EngineChatStreamOutput{content: resp.Content, last: false}) {
+			// Interrupted: let the provider wind down without blocking it
+			go drainCompletionStream(responses)
+			break
 		}
 
-		e.channel <- EngineChatStreamOutput{
-			content: resp.Content,
-			last:    false,
+		if resp.Done {
+			break
 		}
 	}
 
-	// In case the stream closes without a done flag
-	executable := false
-	if e.mode == ExecEngineMode {
-		if !strings.HasPrefix(output, noexec) && !strings.Contains(output, "\n") {
-			executable = true
-		}
+	e.mu.Lock()
+	e.appendAssistantMessage(mode, output.String())
+	e.usage = estimateUsage(req, output.String())
+	e.mu.Unlock()
+
+	executable, invalid := e.validateStreamedOutput(mode, output.String())
+	if invalid != nil && stream.Context().Err() == nil {
+		stream.send(EngineChatStreamOutput{content: fmt.Sprintf("\n\n%s invalid command: %s", noexec, invalid)})
+	}
+
+	e.endChatStream(stream, executable, nil)
+}
+
+// GetLastUsage returns the estimated usage of the last completion
+func (e *Engine) GetLastUsage() Usage {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.usage
+}
+
+func estimateUsage(req provider.CompletionRequest, completion string) Usage {
+	usage := Usage{CompletionTokens: attachment.EstimateTokens(completion)}
+	for _, message := range req.Messages {
+		usage.PromptTokens += attachment.EstimateTokens(message.Content)
 	}
 
-	// Always send a final message to signal completion
-	e.channel <- EngineChatStreamOutput{
-		content:    "",
-		last:       true,
-		executable: executable,
+	return usage
+}
+
+// endChatStream detaches stream from the engine before closing it, so a
+// reader seeing the last output never observes the engine as still running.
+func (e *Engine) endChatStream(stream *ChatStream, executable bool, err error) {
+	e.mu.Lock()
+	if e.stream == stream {
+		e.stream = nil
 	}
+	e.mu.Unlock()
 
-	e.running = false
-	e.appendAssistantMessage(output)
+	stream.finish(executable, err)
+}
 
-	return nil
+func drainCompletionStream(responses <-chan provider.CompletionResponse) {
+	for range responses {
+	}
+}
+
+// validateStreamedOutput tells if a streamed exec answer is a valid command
+// line, returning why a command is not. Being already shown, an invalid
+// command is not repaired, the reason being streamed after it instead.
+func (e *Engine) validateStreamedOutput(mode EngineMode, output string) (bool, error) {
+	if mode != ExecEngineMode || strings.HasPrefix(output, noexec) || strings.Contains(output, "\n") {
+		return false, nil
+	}
+
+	if invalid := run.ValidateCommand(output, e.lookPath); invalid != nil {
+		return false, invalid
+	}
+
+	return true, nil
+}
+
+// prepareCompletionRequest builds a request for the current conversation.
+// The caller must hold e.mu.
+func (e *Engine) prepareCompletionRequest(stream bool) provider.CompletionRequest {
+	// The images sent to another model before a switch are left out of the
+	// history for a model not accepting them
+	messages := e.prepareCompletionMessages()
+	if !e.acceptsImages() {
+		messages = withoutImages(messages)
+	}
+
+	return provider.CompletionRequest{
+		Model:       e.config.GetAiConfig().GetModel(),
+		MaxTokens:   e.config.GetAiConfig().GetMaxTokens(),
+		Temperature: e.config.GetAiConfig().GetTemperature(),
+		Messages:    messages,
+		Stream:      stream,
+	}
+}
+
+// acceptsImages tells if the model in use accepts images. The caller must
+// hold e.mu.
+func (e *Engine) acceptsImages() bool {
+	return provider.AcceptsImages(e.provider.Name(), e.config.GetAiConfig().GetModel())
+}
+
+// checkImages tells that the images waiting for the next prompt cannot be
+// sent to the model in use, dropping them so that the next prompts can be.
+// The caller must hold e.mu.
+func (e *Engine) checkImages() error {
+	if len(e.images) == 0 || e.acceptsImages() {
+		return nil
+	}
+
+	e.images = nil
+
+	return fmt.Errorf("the model %s does not accept images, attach them again once using a model that does", e.config.GetAiConfig().GetModel())
+}
+
+// withoutImages returns the messages without their image parts
+func withoutImages(messages []provider.Message) []provider.Message {
+	result := make([]provider.Message, len(messages))
+	for i, msg := range messages {
+		result[i] = msg
+		if !msg.HasImages() {
+			continue
+		}
+
+		result[i].Parts = nil
+		for _, part := range msg.Parts {
+			if part.Type != provider.ImagePart {
+				result[i].Parts = append(result[i].Parts, part)
+			}
+		}
+	}
+
+	return result
 }
 
-func (e *Engine) appendUserMessage(content string) *Engine {
+// appendUserMessage adds the prompt to the history, along with the images
+// waiting for it
+func (e *Engine) appendUserMessage(mode EngineMode, content string) *Engine {
 	msg := provider.Message{
-		Role:    "user",
+		Role:    UserRole,
 		Content: content,
 	}
+	for _, image := range e.images {
+		msg.Parts = append(msg.Parts, provider.NewImagePart(image.GetData(), image.GetMimeType()))
+	}
+	e.recordMessage(mode, UserRole, content)
+	e.images = nil
 
-	if e.mode == ExecEngineMode {
+	if mode == ExecEngineMode {
 		e.execMessages = append(e.execMessages, msg)
 	} else {
 		e.chatMessages = append(e.chatMessages, msg)
@@ -361,13 +660,14 @@ func (e *Engine) appendUserMessage(content string) *Engine {
 	return e
 }
 
-func (e *Engine) appendAssistantMessage(content string) *Engine {
+func (e *Engine) appendAssistantMessage(mode EngineMode, content string) *Engine {
 	msg := provider.Message{
-		Role:    "assistant",
+		Role:    AssistantRole,
 		Content: content,
 	}
+	e.recordMessage(mode, AssistantRole, content)
 
-	if e.mode == ExecEngineMode {
+	if mode == ExecEngineMode {
 		e.execMessages = append(e.execMessages, msg)
 	} else {
 		e.chatMessages = append(e.chatMessages, msg)
@@ -394,6 +694,16 @@ func (e *Engine) prepareCompletionMessages() []provider.Message {
 		)
 	}
 
+	if len(e.attachments) > 0 {
+		messages = append(
+			messages,
+			provider.Message{
+				Role:    "user",
+				Content: e.prepareAttachmentsPrompt(),
+			},
+		)
+	}
+
 	// Add terminal outputs as context if available
 	if len(e.terminalOutputs) > 0 {
 		var terminalContext strings.Builder
@@ -462,6 +772,17 @@ func (e *Engine) preparePipePrompt() string {
 	return fmt.Sprintf("I will work on the following input: %s", e.pipe)
 }
 
+func (e *Engine) prepareAttachmentsPrompt() string {
+	var prompt strings.Builder
+
+	prompt.WriteString("Here are files I attached for context:\n\n")
+	for _, file := range e.attachments {
+		prompt.WriteString(fmt.Sprintf("File: %s\n```\n%s\n```\n\n", file.GetPath(), strings.TrimRight(file.GetContent(), "\n")))
+	}
+
+	return prompt.String()
+}
+
 func (e *Engine) prepareSystemPrompt() string {
 	var bodyPart string
 	if e.mode == ExecEngineMode {
@@ -504,6 +825,10 @@ func (e *Engine) prepareSystemPromptChatPart() string {
 func (e *Engine) prepareSystemPromptContextPart() string {
 	part := "My context: "
 
+	if e.config.GetSystemConfig() == nil {
+		return part + e.prepareSystemPromptInstructionsPart()
+	}
+
 	if e.config.GetSystemConfig().GetOperatingSystem() != system.UnknownOperatingSystem {
 		part += fmt.Sprintf("my operating system is %s, ", e.config.GetSystemConfig().GetOperatingSystem().String())
 	}
@@ -519,11 +844,94 @@ func (e *Engine) prepareSystemPromptContextPart() string {
 	if e.config.GetSystemConfig().GetShell() != "" {
 		part += fmt.Sprintf("my editor is %s, ", e.config.GetSystemConfig().GetEditor())
 	}
+	part += e.prepareSystemPromptWorkspacePart()
 	part += "take this into account. "
 
-	if e.config.GetUserConfig().GetPreferences() != "" {
-		part += fmt.Sprintf("Also, %s.", e.config.GetUserConfig().GetPreferences())
+	return part + e.prepareSystemPromptInstructionsPart()
+}
+
+// prepareSystemPromptWorkspacePart describes the project of the working
+// directory, if the workspace context is enabled
+func (e *Engine) prepareSystemPromptWorkspacePart() string {
+	workspace := e.config.GetWorkspace()
+	if workspace == nil {
+		return ""
+	}
+
+	part := fmt.Sprintf("my working directory is %s, ", workspace.GetDirectory())
+
+	if workspace.GetGitRoot() != "" {
+		part += fmt.Sprintf("it is in the git repository %s", workspace.GetGitRoot())
+		if workspace.GetGitBranch() != "" {
+			part += fmt.Sprintf(" on branch %s", workspace.GetGitBranch())
+		}
+		if workspace.IsGitDirty() {
+			part += " with uncommitted changes"
+		}
+		part += ", "
+	}
+	if len(workspace.GetProjectTypes()) > 0 {
+		part += fmt.Sprintf("the project type is %s, ", strings.Join(workspace.GetProjectTypes(), ", "))
+	}
+	if len(workspace.GetMakeTargets()) > 0 {
+		part += fmt.Sprintf("the Makefile targets are %s, ", joinLimited(workspace.GetMakeTargets(), maxWorkspaceItems))
+	}
+	if len(workspace.GetComposeServices()) > 0 {
+		part += fmt.Sprintf("the docker compose services are %s, ", joinLimited(workspace.GetComposeServices(), maxWorkspaceItems))
+	}
+	if len(workspace.GetTools()) > 0 {
+		tools := make([]string, 0, len(workspace.GetTools()))
+		for _, tool := range workspace.GetTools() {
+			if tool.GetVersion() != "" {
+				tools = append(tools, fmt.Sprintf("%s (%s)", tool.GetName(), tool.GetVersion()))
+			} else {
+				tools = append(tools, tool.GetName())
+			}
+		}
+		part += fmt.Sprintf("the tools I have are %s, ", strings.Join(tools, ", "))
 	}
 
 	return part
 }
+
+// joinLimited joins the first values, telling how many were left out
+func joinLimited(values []string, limit int) string {
+	if len(values) <= limit {
+		return strings.Join(values, ", ")
+	}
+
+	return fmt.Sprintf("%s and %d more", strings.Join(values[:limit], ", "), len(values)-limit)
+}
+
+// prepareSystemPromptInstructionsPart gathers what the user and project
+// configs ask for: preferences, command policies and extra system prompt
+func (e *Engine) prepareSystemPromptInstructionsPart() string {
+	return e.prepareSystemPromptPreferencesPart() +
+		e.prepareSystemPromptPolicyPart() +
+		e.prepareSystemPromptExtraPart()
+}
+
+func (e *Engine) prepareSystemPromptPolicyPart() string {
+	deny := e.config.GetCommandPolicy().GetDeny()
+	if len(deny) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(" Never generate commands matching: %s.", strings.Join(deny, ", "))
+}
+
+func (e *Engine) prepareSystemPromptExtraPart() string {
+	if e.config.GetUserConfig().GetSystemPrompt() != "" {
+		return "\n" + e.config.GetUserConfig().GetSystemPrompt()
+	}
+
+	return ""
+}
+
+func (e *Engine) prepareSystemPromptPreferencesPart() string {
+	if e.config.GetUserConfig().GetPreferences() != "" {
+		return fmt.Sprintf("Also, %s.", e.config.GetUserConfig().GetPreferences())
+	}
+
+	return ""
+}
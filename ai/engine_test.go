@@ -0,0 +1,305 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xsikor/yai/ai/intent"
+	"github.com/xsikor/yai/ai/provider"
+	"github.com/xsikor/yai/attachment"
+	"github.com/xsikor/yai/config"
+	"github.com/xsikor/yai/internal/testprovider"
+)
+
+func newTestEngine(mode EngineMode, p provider.Provider) *Engine {
+	return newEngine(mode, &config.Config{}, p)
+}
+
+func readStream(t *testing.T, stream *ChatStream) (string, EngineChatStreamOutput) {
+	t.Helper()
+
+	var content strings.Builder
+
+	for {
+		output := stream.Next()
+		content.WriteString(output.GetContent())
+		if output.IsLast() {
+			return content.String(), output
+		}
+	}
+}
+
+func TestEngineChatStreamCompletion(t *testing.T) {
+	engine := newTestEngine(ChatEngineMode, &testprovider.Provider{Chunks: []string{"Hello", ", ", "world"}})
+
+	content, last := readStream(t, engine.ChatStreamCompletion("hi"))
+
+	assert.Equal(t, "Hello, world", content)
+	assert.False(t, last.IsInterrupt())
+	assert.False(t, last.IsExecutable())
+	assert.False(t, engine.IsRunning())
+	assert.Equal(t, 3, engine.GetLastUsage().CompletionTokens, "Usage should be estimated at 4 characters per token.")
+	assert.Positive(t, engine.GetLastUsage().PromptTokens)
+
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	require.Len(t, engine.chatMessages, 2)
+	assert.Equal(t, "hi", engine.chatMessages[0].Content)
+	assert.Equal(t, "Hello, world", engine.chatMessages[1].Content)
+}
+
+func TestEngineChatStreamCompletionExecutable(t *testing.T) {
+	engine := newTestEngine(ExecEngineMode, &testprovider.Provider{Chunks: []string{"ls ", "-la"}})
+
+	_, last := readStream(t, engine.ChatStreamCompletion("list files"))
+	assert.True(t, last.IsExecutable())
+
+	engine = newTestEngine(ExecEngineMode, &testprovider.Provider{Chunks: []string{noexec, " sorry"}})
+
+	_, last = readStream(t, engine.ChatStreamCompletion("hello"))
+	assert.False(t, last.IsExecutable())
+}
+
+func TestEngineChatStreamCompletionInvalidCommand(t *testing.T) {
+	engine := newTestEngine(ExecEngineMode, &testprovider.Provider{Chunks: []string{"echo 'unclosed"}})
+
+	content, last := readStream(t, engine.ChatStreamCompletion("print something"))
+
+	assert.False(t, last.IsExecutable())
+	assert.Equal(t, "echo 'unclosed\n\n"+noexec+" invalid command: the command has an unbalanced ' quote", content)
+
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	require.Len(t, engine.execMessages, 2)
+	assert.Equal(t, "echo 'unclosed", engine.execMessages[1].Content, "The validation error should not be kept in the history.")
+}
+
+func TestEngineChatStreamCompletionError(t *testing.T) {
+	engine := newTestEngine(ChatEngineMode, &testprovider.Provider{Err: errors.New("boom")})
+
+	stream := engine.ChatStreamCompletion("hi")
+	_, last := readStream(t, stream)
+
+	assert.False(t, last.IsInterrupt())
+	assert.EqualError(t, stream.Err(), "boom")
+}
+
+func TestEngineChatStreamCompletionInterruptedByError(t *testing.T) {
+	engine := newTestEngine(ChatEngineMode, &testprovider.Provider{Chunks: []string{"Hel"}, ChunkErr: errors.New("connection reset")})
+
+	stream := engine.ChatStreamCompletion("hi")
+	content, last := readStream(t, stream)
+
+	assert.Equal(t, "Hel", content)
+	assert.False(t, last.IsInterrupt())
+	assert.EqualError(t, last.GetError(), "connection reset")
+	assert.EqualError(t, stream.Err(), "connection reset")
+}
+
+func TestEngineInterruptWithoutReader(t *testing.T) {
+	engine := newTestEngine(ChatEngineMode, &testprovider.Provider{Chunks: []string{"a", "b"}, Block: true})
+
+	stream := engine.ChatStreamCompletion("hi")
+
+	interrupted := make(chan struct{})
+	go func() {
+		engine.Interrupt()
+		close(interrupted)
+	}()
+
+	select {
+	case <-interrupted:
+	case <-time.After(time.Second):
+		t.Fatal("Interrupt blocked without a reader")
+	}
+
+	_, last := readStream(t, stream)
+	assert.True(t, last.IsInterrupt())
+	assert.NoError(t, stream.Err())
+	assert.Eventually(t, func() bool { return !engine.IsRunning() }, time.Second, 10*time.Millisecond)
+}
+
+func TestEngineInterruptProviderError(t *testing.T) {
+	engine := newTestEngine(ChatEngineMode, &testprovider.Provider{Chunks: []string{"Hel"}, Block: true, CancelErr: true})
+
+	stream := engine.ChatStreamCompletion("hi")
+	assert.Equal(t, "Hel", stream.Next().GetContent())
+	engine.Interrupt()
+
+	content, last := readStream(t, stream)
+	assert.Empty(t, content)
+	assert.True(t, last.IsInterrupt())
+	assert.NoError(t, last.GetError())
+	assert.NoError(t, stream.Err())
+	assert.Equal(t, "Hel", engine.chatMessages[1].Content)
+}
+
+func TestEngineInterruptIdle(t *testing.T) {
+	engine := newTestEngine(ChatEngineMode, &testprovider.Provider{})
+
+	assert.NotPanics(t, func() { engine.Interrupt() })
+	assert.False(t, engine.IsRunning())
+}
+
+func TestEngineConcurrentUse(t *testing.T) {
+	engine := newTestEngine(ChatEngineMode, &testprovider.Provider{Chunks: []string{"x", "y", "z"}})
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(4)
+
+		go func(i int) {
+			defer wg.Done()
+			readStream(t, engine.ChatStreamCompletion(fmt.Sprintf("question %d", i)))
+		}(i)
+
+		go func(i int) {
+			defer wg.Done()
+			_, err := engine.ExecCompletion(fmt.Sprintf("command %d", i))
+			assert.NoError(t, err)
+		}(i)
+
+		go func(i int) {
+			defer wg.Done()
+			engine.AddTerminalOutput(fmt.Sprintf("output %d", i))
+			engine.GetTerminalOutputs()
+		}(i)
+
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				engine.SetMode(ExecEngineMode)
+			} else {
+				engine.SetMode(ChatEngineMode)
+			}
+			engine.GetMode()
+			engine.Interrupt()
+		}(i)
+	}
+
+	wg.Wait()
+
+	assert.Eventually(t, func() bool { return !engine.IsRunning() }, time.Second, 10*time.Millisecond)
+	assert.LessOrEqual(t, len(engine.GetTerminalOutputs()), engine.maxTerminalOutput)
+}
+
+func TestEngineAttachments(t *testing.T) {
+	engine := newTestEngine(ChatEngineMode, &testprovider.Provider{})
+
+	engine.Attach(
+		attachment.NewFile("main.go", "package main\n"),
+		attachment.NewFile("ui/ui.go", "package ui\n"),
+		attachment.NewFile("ui/prompt.go", "package ui\n"),
+	)
+	engine.Attach(attachment.NewFile("main.go", "package main // updated\n"))
+
+	attachments := engine.GetAttachments()
+	require.Len(t, attachments, 3, "Attaching a file again should replace it.")
+	assert.Equal(t, "main.go", attachments[2].GetPath())
+
+	messages := engine.prepareCompletionMessages()
+	require.Len(t, messages, 2)
+	assert.Equal(t, "user", messages[1].Role)
+	assert.Contains(t, messages[1].Content, "File: main.go\n```\npackage main // updated\n```")
+	assert.Contains(t, messages[1].Content, "File: ui/ui.go\n```\npackage ui\n```")
+
+	assert.Equal(t, 1, engine.Detach("ui/prompt.go"))
+	assert.Equal(t, 1, engine.Detach("ui"), "A directory should detach the files it contains.")
+	assert.Equal(t, 0, engine.Detach("*.md"))
+	assert.Equal(t, 1, engine.Detach(""), "No pattern should detach all files.")
+	assert.Empty(t, engine.GetAttachments())
+	assert.Len(t, engine.prepareCompletionMessages(), 1)
+}
+
+func TestEngineImages(t *testing.T) {
+	engine := newTestEngine(ChatEngineMode, &testprovider.Provider{Chunks: []string{"A dashboard"}})
+
+	image, err := attachment.NewImage("screenshot.png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"))
+	require.NoError(t, err)
+	engine.AttachImages(image)
+	assert.Len(t, engine.GetImages(), 1)
+
+	readStream(t, engine.ChatStreamCompletion("what is this?"))
+	assert.Empty(t, engine.GetImages(), "The images should only be sent with the next prompt.")
+
+	messages := engine.prepareCompletionMessages()
+	require.Len(t, messages, 3)
+	assert.Equal(t, "what is this?", messages[1].Content)
+	assert.Equal(t, []provider.Part{provider.NewImagePart(image.GetData(), "image/png")}, messages[1].Parts)
+	assert.False(t, messages[2].HasImages())
+
+	assert.Equal(t, []string{"screenshot.png"}, engine.GetConversation().Entries[0].Images)
+}
+
+func TestEngineImagesUnsupported(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "yai.json"), []byte(`{"AI_MODEL": "gpt-3.5-turbo"}`), 0o600))
+	viper.Reset()
+	viper.AddConfigPath(dir)
+	t.Cleanup(viper.Reset)
+	t.Setenv("OPENAI_API_KEY", "key")
+
+	cfg, err := config.NewConfig()
+	require.NoError(t, err)
+
+	p := &testprovider.Provider{Chunks: []string{"A dashboard"}}
+	engine := newEngine(ChatEngineMode, cfg, p)
+
+	image, err := attachment.NewImage("screenshot.png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"))
+	require.NoError(t, err)
+
+	// The images are not sent to a model not accepting them
+	engine.AttachImages(image)
+	_, output := readStream(t, engine.ChatStreamCompletion("what is this?"))
+	assert.ErrorContains(t, output.GetError(), "gpt-3.5-turbo does not accept images")
+	assert.Empty(t, engine.GetImages(), "The images should be dropped.")
+
+	engine.SetMode(ExecEngineMode)
+	engine.AttachImages(image)
+	_, err = engine.ExecCompletion("what is this?")
+	assert.ErrorContains(t, err, "gpt-3.5-turbo does not accept images")
+
+	// The next prompts are sent, without the images sent before to another
+	// model
+	engine.SetMode(ChatEngineMode)
+	engine.chatMessages = append(engine.chatMessages, provider.Message{
+		Role:    UserRole,
+		Content: "what is this?",
+		Parts:   []provider.Part{provider.NewImagePart(image.GetData(), "image/png")},
+	})
+	_, output = readStream(t, engine.ChatStreamCompletion("and this?"))
+	require.NoError(t, output.GetError())
+	for _, msg := range p.LastRequest().Messages {
+		assert.False(t, msg.HasImages())
+	}
+}
+
+func TestEngineDetectMode(t *testing.T) {
+	engine := newTestEngine(ChatEngineMode, &testprovider.Provider{})
+
+	assert.Equal(t, ExecEngineMode, engine.DetectMode("delete all stopped containers"))
+	assert.Equal(t, ExecEngineMode, engine.GetMode())
+
+	// An unclear intent keeps the mode
+	assert.Equal(t, ExecEngineMode, engine.DetectMode("nginx"))
+	assert.Equal(t, ChatEngineMode, engine.DetectMode("explain this error"))
+
+	// The piped content no longer switches the mode by itself
+	engine.SetPipe("ls -la")
+	assert.Equal(t, ChatEngineMode, engine.GetMode())
+
+	result := engine.ClassifyIntent("what is using port 8080")
+	assert.Equal(t, intent.QueryIntent, result.Intent)
+	assert.Equal(t, intent.RulesSource, result.Source)
+}
@@ -0,0 +1,108 @@
+package intent
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Sample is a prompt labeled with its intent
+type Sample struct {
+	Input  string
+	Intent Intent
+}
+
+// LoadCorpus reads labeled prompts, one per line as the intent and the
+// prompt separated by a tab, \n being a line break of the prompt. Blank
+// lines and # comments are skipped.
+func LoadCorpus(r io.Reader) ([]Sample, error) {
+	var samples []Sample
+
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		label, input, found := strings.Cut(line, "\t")
+		if !found {
+			return nil, fmt.Errorf("line %d: expected an intent and a prompt separated by a tab", number)
+		}
+		intent, err := ParseIntent(strings.TrimSpace(label))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+		input = strings.ReplaceAll(strings.TrimSpace(input), `\n`, "\n")
+		samples = append(samples, Sample{Input: input, Intent: intent})
+	}
+
+	return samples, scanner.Err()
+}
+
+// Evaluation measures a classifier on labeled prompts
+type Evaluation struct {
+	Total int
+	// Correct counts the prompts classified with their intent
+	Correct int
+	// Confident counts the confident classifications, and ConfidentWrong
+	// the ones getting the mode wrong, chat against shell intents
+	Confident      int
+	ConfidentWrong int
+	// Mistakes are the prompts classified with another intent
+	Mistakes []Mistake
+}
+
+// Mistake is a prompt classified with another intent than its label
+type Mistake struct {
+	Sample
+	Result Result
+}
+
+// Evaluate classifies every sample, a failing classification counting as a
+// mistake
+func Evaluate(samples []Sample, classify func(input string) (Result, error)) Evaluation {
+	evaluation := Evaluation{Total: len(samples)}
+
+	for _, sample := range samples {
+		result, err := classify(sample.Input)
+		if err != nil {
+			evaluation.Mistakes = append(evaluation.Mistakes, Mistake{Sample: sample})
+			continue
+		}
+
+		if result.Intent == sample.Intent {
+			evaluation.Correct++
+		} else {
+			evaluation.Mistakes = append(evaluation.Mistakes, Mistake{Sample: sample, Result: result})
+		}
+		if result.Confident {
+			evaluation.Confident++
+			if result.Intent.IsShell() != sample.Intent.IsShell() {
+				evaluation.ConfidentWrong++
+			}
+		}
+	}
+
+	return evaluation
+}
+
+// Accuracy returns the share of prompts classified with their intent
+func (e Evaluation) Accuracy() float64 {
+	if e.Total == 0 {
+		return 0
+	}
+
+	return float64(e.Correct) / float64(e.Total)
+}
+
+// Precision returns the share of confident classifications picking the
+// right mode
+func (e Evaluation) Precision() float64 {
+	if e.Confident == 0 {
+		return 0
+	}
+
+	return float64(e.Confident-e.ConfidentWrong) / float64(e.Confident)
+}
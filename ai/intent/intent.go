@@ -0,0 +1,270 @@
+package intent
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Intent is what a prompt asks yai for
+type Intent string
+
+const (
+	// ChatIntent asks for an explanation, advice or text
+	ChatIntent Intent = "chat"
+	// CommandIntent asks for a shell command changing something
+	CommandIntent Intent = "command"
+	// QueryIntent asks for a shell command only reading information, like
+	// the process using a port
+	QueryIntent Intent = "query"
+)
+
+// Intents are the intents, the first one winning ties
+var Intents = []Intent{ChatIntent, CommandIntent, QueryIntent}
+
+// ParseIntent returns the intent of the name, like "chat"
+func ParseIntent(name string) (Intent, error) {
+	for _, intent := range Intents {
+		if strings.EqualFold(name, string(intent)) {
+			return intent, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown intent: %s, use chat, command or query", name)
+}
+
+// IsShell tells if the intent asks for a shell command, answered in exec
+// mode
+func (i Intent) IsShell() bool {
+	return i == CommandIntent || i == QueryIntent
+}
+
+// Source tells how an intent was found
+type Source string
+
+const (
+	RulesSource Source = "rules"
+	ModelSource Source = "model"
+)
+
+// DefaultThreshold is how much the score of a shell intent must beat the
+// chat one, or the other way around, for the classification to be trusted
+const DefaultThreshold = 1.5
+
+// Result is the intent of a prompt, along with how it was found
+type Result struct {
+	Intent Intent
+	// Scores are the summed weights of the matched features, by intent
+	Scores map[Intent]float64
+	// Features are the names of the matched features
+	Features []string
+	// Confident is set if the intent won by at least the threshold, chat
+	// against the shell intents
+	Confident bool
+	Source    Source
+}
+
+// Feature is a trait of a prompt, adding its weights to the score of the
+// intents when it matches the lower cased prompt
+type Feature struct {
+	Name    string
+	Match   func(text string) bool
+	Weights map[Intent]float64
+}
+
+// pattern returns a matcher of the regular expression
+func pattern(expr string) func(string) bool {
+	return regexp.MustCompile(expr).MatchString
+}
+
+// Features are the traits the rules classify prompts with. The weights are
+// tuned on the labeled prompts of testdata/corpus.tsv.
+var Features = []Feature{
+	// A command line, rather than a request
+	{
+		Name:    "shell-binary",
+		Match:   pattern(`^(sudo\s+)?(ls|cd|grep|rg|find|git|docker|kubectl|helm|npm|yarn|go|python3?|pip3?|cat|less|head|tail|vim|nano|mkdir|rmdir|rm|cp|mv|touch|chmod|chown|ln|apt(-get)?|yum|dnf|brew|systemctl|journalctl|curl|wget|tar|unzip|ssh|scp|rsync|ps|kill|pkill|df|du|top|htop|free|make|awk|sed|xargs|echo|export|which|terraform|aws|gcloud)(\s|$)`),
+		Weights: map[Intent]float64{CommandIntent: 2, QueryIntent: 1},
+	},
+	{
+		Name:    "read-only-binary",
+		Match:   pattern(`^(sudo\s+)?(ls|ll|cat|less|head|tail|grep|rg|find|ps|df|du|top|htop|free|which|whereis|uname|uptime|whoami|id|env|printenv|lsof|netstat|ss|dig|nslookup|git (status|log|diff|show|branch|remote)|docker (ps|images|logs|inspect|stats)|kubectl (get|describe|logs|top)|systemctl status|journalctl)(\s|$)`),
+		Weights: map[Intent]float64{QueryIntent: 2},
+	},
+	{
+		Name:    "shell-flag",
+		Match:   pattern(`(^|\s)--?[a-z][\w-]*(\s|=|$)`),
+		Weights: map[Intent]float64{CommandIntent: 1, QueryIntent: 1},
+	},
+	{
+		Name:    "shell-operator",
+		Match:   pattern("\\|\\s*[a-z]|&&|;\\s*[a-z]|\\s>>?\\s*\\S|\\$\\(|`[^`]+`"),
+		Weights: map[Intent]float64{CommandIntent: 1.5, QueryIntent: 1.5},
+	},
+	{
+		Name:    "path",
+		Match:   pattern(`(^|\s)(\.{1,2}/|~/|/[a-z])\S*`),
+		Weights: map[Intent]float64{CommandIntent: 1, QueryIntent: 0.5},
+	},
+	{
+		Name:    "sudo",
+		Match:   pattern(`\bsudo\b`),
+		Weights: map[Intent]float64{CommandIntent: 1.5},
+	},
+
+	// A request for a command
+	{
+		Name:    "action-verb",
+		Match:   pattern(`^(please\s+)?(create|make|delete|remove|install|uninstall|reinstall|kill|stop|start|restart|reload|rename|move|copy|compress|zip|unzip|extract|archive|download|upload|deploy|update|upgrade|downgrade|change|set|add|run|execute|convert|clone|push|pull|commit|checkout|switch|merge|rebase|squash|revert|undo|reset|build|mount|unmount|format|replace|backup|back up|sync|resize|enable|disable|free up|clean|clear|empty|truncate|append|schedule|forward|expose|tag|stage|unstage|prune|drop|flush|open|edit|send|generate an? (ssh|gpg) key|give (me )?permission|make .* executable)\b`),
+		Weights: map[Intent]float64{CommandIntent: 3},
+	},
+	{
+		// A change asked on what a search finds, like "find the logs and
+		// delete them", never a mere query
+		Name:    "then-change",
+		Match:   pattern(`\b(and|then|to)\s+(also\s+)?(delete|remove|rm|kill|stop|restart|uninstall|drop|purge|wipe|erase|clean( up)?|truncate|overwrite|move|mv|rename|chmod|chown|compress|archive|replace|reset|edit|update|fix)\b`),
+		Weights: map[Intent]float64{CommandIntent: 3, QueryIntent: -3},
+	},
+	{
+		Name:    "read-verb",
+		Match:   pattern(`^(please\s+)?(list|show|display|print|get|find|search|count|check|look up|locate|view|watch|monitor|ping|test if)\b`),
+		Weights: map[Intent]float64{QueryIntent: 3},
+	},
+	{
+		Name:    "how-to",
+		Match:   pattern(`^(how (do|can|should|would) i|how to|what('s| is) the command|what command|which command)\b`),
+		Weights: map[Intent]float64{CommandIntent: 2.5},
+	},
+	{
+		Name:    "command-word",
+		Match:   pattern(`\b(command|one-liner|oneliner|in bash|in the terminal|from the terminal|using (the )?cli)\b`),
+		Weights: map[Intent]float64{CommandIntent: 1.5},
+	},
+	{
+		Name:    "system-question",
+		Match:   pattern(`^(what|which|how much|how many|is|are|where|who)\b.*\b(ports?|ip|ips|address|disk|space|memory|ram|cpu|load|process|processes|pid|uptime|kernel|version|installed|running|listening|containers?|pods?|services?|branch|branches|commits?|files?|director(y|ies)|folders?|size|users?|hostname|binary|executable|packages?|distro|gpu|interfaces?|dns|partitions?|mounted|logged in|free|used|using|open)\b`),
+		Weights: map[Intent]float64{QueryIntent: 3},
+	},
+	{
+		Name:    "my-resource",
+		Match:   pattern(`\bmy (public |local )?(ip|disk|memory|ram|cpu|hostname|username|kernel|os|shell|path|branch|current branch|processes|ports|gpu|uptime|mac address)\b`),
+		Weights: map[Intent]float64{QueryIntent: 1.5},
+	},
+
+	// A request for an explanation
+	{
+		Name:    "chat-opening",
+		Match:   pattern(`^(why|explain|describe|compare|summari[sz]e|tell me (about|a|an|more)|what('s| is| are) (a|an|the (difference|point|purpose|meaning|best|advantage|benefit|role))|what('s| is| are) (an? )?[\w.-]+\s*\??$|what does .* (mean|do)\b|what do you|how does|how do (\w+ )+work|how is|how are|should i|should we|is it (better|possible|safe|a good|bad|worth)|can you (explain|tell|help me understand)|help me understand|write (a|an|me)|give me (an? )?(example|idea|overview|summary|advice)|when should|when to|what happens)`),
+		Weights: map[Intent]float64{ChatIntent: 3.5},
+	},
+	{
+		Name:    "chat-words",
+		Match:   pattern(`\b(explain|explanation|difference|differences|meaning|concept|best practices?|pros and cons|recommend|recommendation|opinion|advice|versus|vs|why|tradeoffs?|trade-offs?|history|ideas?|examples?|understand|learn|tutorial|poem|story|joke|essay|email)\b`),
+		Weights: map[Intent]float64{ChatIntent: 1.5},
+	},
+	{
+		Name:    "question-mark",
+		Match:   pattern(`\?\s*$`),
+		Weights: map[Intent]float64{ChatIntent: 0.5},
+	},
+	{
+		Name: "long-prose",
+		Match: func(text string) bool {
+			return len(strings.Fields(text)) > 15
+		},
+		Weights: map[Intent]float64{ChatIntent: 1.5},
+	},
+	{
+		Name: "multi-line",
+		Match: func(text string) bool {
+			return strings.Count(text, "\n") >= 2
+		},
+		Weights: map[Intent]float64{ChatIntent: 3},
+	},
+	{
+		Name:    "code-or-trace",
+		Match:   pattern("```|traceback|exception|stack trace|panic:|segmentation fault|\\berror:"),
+		Weights: map[Intent]float64{ChatIntent: 2},
+	},
+	{
+		Name:    "greeting",
+		Match:   pattern(`^(hi|hello|hey|thanks|thank you|good (morning|evening))\b`),
+		Weights: map[Intent]float64{ChatIntent: 3},
+	},
+}
+
+// Classifier finds the intent of prompts from weighted features. An unsure
+// classification leaves the choice to the caller, like the default mode.
+type Classifier struct {
+	features  []Feature
+	threshold float64
+}
+
+// NewClassifier returns a classifier with the default features, threshold
+// being the default one if not positive
+func NewClassifier(threshold float64) *Classifier {
+	return NewClassifierWithFeatures(Features, threshold)
+}
+
+// NewClassifierWithFeatures returns a classifier with its own features
+func NewClassifierWithFeatures(features []Feature, threshold float64) *Classifier {
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+
+	return &Classifier{
+		features:  features,
+		threshold: threshold,
+	}
+}
+
+// IsQuery tells if the input clearly only asks for information: classified
+// as a query with confidence, the query score also beating the command one
+// by the threshold
+func (c *Classifier) IsQuery(input string) bool {
+	result := c.Classify(input)
+
+	return result.Intent == QueryIntent && result.Confident &&
+		result.Scores[QueryIntent]-result.Scores[CommandIntent] >= c.threshold
+}
+
+// Classify scores the intents of the input with the matching features
+func (c *Classifier) Classify(input string) Result {
+	text := strings.ToLower(strings.TrimSpace(input))
+
+	result := Result{
+		Intent: ChatIntent,
+		Scores: map[Intent]float64{},
+		Source: RulesSource,
+	}
+	for _, intent := range Intents {
+		result.Scores[intent] = 0
+	}
+	if text == "" {
+		return result
+	}
+
+	for _, feature := range c.features {
+		if !feature.Match(text) {
+			continue
+		}
+		result.Features = append(result.Features, feature.Name)
+		for intent, weight := range feature.Weights {
+			result.Scores[intent] += weight
+		}
+	}
+
+	for _, intent := range Intents {
+		if result.Scores[intent] > result.Scores[result.Intent] {
+			result.Intent = intent
+		}
+	}
+
+	margin := result.Scores[ChatIntent] - max(result.Scores[CommandIntent], result.Scores[QueryIntent])
+	if result.Intent.IsShell() {
+		margin = -margin
+	}
+	result.Confident = margin >= c.threshold
+
+	return result
+}
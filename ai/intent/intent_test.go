@@ -0,0 +1,230 @@
+package intent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xsikor/yai/ai/provider"
+	"github.com/xsikor/yai/internal/testprovider"
+)
+
+func TestIntent(t *testing.T) {
+	t.Run("ParseIntent", testParseIntent)
+	t.Run("Classify", testClassify)
+	t.Run("Threshold", testThreshold)
+	t.Run("CustomFeatures", testCustomFeatures)
+	t.Run("Corpus", testCorpus)
+	t.Run("HoldoutAccuracy", testHoldoutAccuracy)
+	t.Run("IsQuery", testIsQuery)
+	t.Run("LoadCorpus", testLoadCorpus)
+	t.Run("ModelClassifier", testModelClassifier)
+}
+
+func testParseIntent(t *testing.T) {
+	intent, err := ParseIntent("Query")
+	require.NoError(t, err)
+	assert.Equal(t, QueryIntent, intent)
+	assert.True(t, intent.IsShell())
+	assert.False(t, ChatIntent.IsShell())
+
+	_, err = ParseIntent("shell")
+	assert.EqualError(t, err, "unknown intent: shell, use chat, command or query")
+}
+
+func testClassify(t *testing.T) {
+	classifier := NewClassifier(0)
+
+	for input, expected := range map[string]Intent{
+		"delete all stopped containers":           CommandIntent,
+		"what is using port 8080":                 QueryIntent,
+		"how does the kernel schedule processes?": ChatIntent,
+		"ps aux | grep nginx":                     QueryIntent,
+		// These used to be taken as commands, being three words or fewer,
+		// or containing "how"
+		"explain this":        ChatIntent,
+		"what is kubernetes":  ChatIntent,
+		"tell me a joke":      ChatIntent,
+		"however you like it": ChatIntent,
+	} {
+		assert.Equal(t, expected, classifier.Classify(input).Intent, input)
+	}
+
+	result := classifier.Classify("restart the nginx service")
+	assert.Equal(t, CommandIntent, result.Intent)
+	assert.True(t, result.Confident)
+	assert.Equal(t, RulesSource, result.Source)
+	assert.Equal(t, []string{"action-verb"}, result.Features)
+	assert.Equal(t, 3.0, result.Scores[CommandIntent])
+
+	// Nothing to tell the intent from
+	result = classifier.Classify("nginx")
+	assert.Equal(t, ChatIntent, result.Intent)
+	assert.False(t, result.Confident)
+	assert.Empty(t, result.Features)
+
+	assert.False(t, classifier.Classify("").Confident)
+}
+
+func testThreshold(t *testing.T) {
+	// A question mark barely tips the scale
+	input := "is nginx running?"
+	assert.True(t, NewClassifier(0).Classify(input).Confident)
+	assert.False(t, NewClassifier(5).Classify(input).Confident)
+	assert.Equal(t, QueryIntent, NewClassifier(5).Classify(input).Intent)
+}
+
+func testCustomFeatures(t *testing.T) {
+	classifier := NewClassifierWithFeatures([]Feature{
+		{Name: "deploy", Match: pattern(`^ship\b`), Weights: map[Intent]float64{CommandIntent: 2}},
+	}, 1)
+
+	result := classifier.Classify("Ship it")
+	assert.Equal(t, CommandIntent, result.Intent)
+	assert.True(t, result.Confident)
+	assert.Equal(t, []string{"deploy"}, result.Features)
+}
+
+func loadTestCorpus(t *testing.T, path string) []Sample {
+	t.Helper()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	samples, err := LoadCorpus(file)
+	require.NoError(t, err)
+
+	return samples
+}
+
+// evaluateRules classifies the samples with the rules, logging the mistakes
+// and checking that no command prompt is taken as a query to run unasked
+func evaluateRules(t *testing.T, samples []Sample) Evaluation {
+	t.Helper()
+
+	classifier := NewClassifier(0)
+	evaluation := Evaluate(samples, func(input string) (Result, error) {
+		return classifier.Classify(input), nil
+	})
+
+	t.Logf("rules: accuracy %.2f, %d/%d confident, mode precision %.2f", evaluation.Accuracy(), evaluation.Confident, evaluation.Total, evaluation.Precision())
+	for _, mistake := range evaluation.Mistakes {
+		t.Logf("%s classified %s %v: %q", mistake.Intent, mistake.Result.Intent, mistake.Result.Features, mistake.Input)
+	}
+
+	for _, sample := range samples {
+		if sample.Intent != QueryIntent {
+			assert.False(t, classifier.IsQuery(sample.Input), "%s prompt taken as a query: %q", sample.Intent, sample.Input)
+		}
+	}
+
+	return evaluation
+}
+
+// testCorpus checks the rules on the prompts their weights were tuned on,
+// against regressions: it does not measure their accuracy
+func testCorpus(t *testing.T) {
+	samples := loadTestCorpus(t, "testdata/corpus.tsv")
+	require.GreaterOrEqual(t, len(samples), 150)
+
+	evaluation := evaluateRules(t, samples)
+	assert.GreaterOrEqual(t, evaluation.Accuracy(), 0.95)
+	assert.GreaterOrEqual(t, evaluation.Precision(), 0.98)
+}
+
+// testHoldoutAccuracy measures the rules on prompts held out from their
+// tuning
+func testHoldoutAccuracy(t *testing.T) {
+	samples := loadTestCorpus(t, "testdata/holdout.tsv")
+	require.GreaterOrEqual(t, len(samples), 60)
+
+	evaluation := evaluateRules(t, samples)
+	assert.GreaterOrEqual(t, evaluation.Accuracy(), 0.9)
+	// A confident classification picks the mode without asking, it must
+	// hardly ever be wrong
+	assert.GreaterOrEqual(t, evaluation.Precision(), 0.98)
+	assert.GreaterOrEqual(t, float64(evaluation.Confident)/float64(evaluation.Total), 0.85)
+}
+
+func testIsQuery(t *testing.T) {
+	classifier := NewClassifier(0)
+
+	assert.True(t, classifier.IsQuery("what is using port 8080"))
+	assert.True(t, classifier.IsQuery("show the disk usage of my home folder"))
+	for _, input := range []string{
+		"find all .log files and delete them",
+		"get all pods and delete the failing ones",
+		"search for node_modules folders and rm them",
+		"delete all stopped containers",
+		"what is kubernetes",
+	} {
+		assert.False(t, classifier.IsQuery(input), input)
+	}
+}
+
+func testLoadCorpus(t *testing.T) {
+	samples, err := LoadCorpus(strings.NewReader("# comment\n\nchat\twhy\\nnot?\ncommand\tls\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []Sample{{Input: "why\nnot?", Intent: ChatIntent}, {Input: "ls", Intent: CommandIntent}}, samples)
+
+	_, err = LoadCorpus(strings.NewReader("chat why\n"))
+	assert.EqualError(t, err, "line 1: expected an intent and a prompt separated by a tab")
+
+	_, err = LoadCorpus(strings.NewReader("\nshell\tls\n"))
+	assert.EqualError(t, err, "line 2: unknown intent: shell, use chat, command or query")
+}
+
+func testModelClassifier(t *testing.T) {
+	p := &testprovider.Provider{Chunks: []string{"Query."}}
+	classifier := NewModelClassifier(p, "small-model")
+
+	result, err := classifier.Classify(context.Background(), strings.Repeat("é", maxModelInput))
+	require.NoError(t, err)
+	assert.Equal(t, Result{Intent: QueryIntent, Confident: true, Source: ModelSource}, result)
+	assert.Equal(t, "small-model", p.LastRequest().Model)
+	assert.Equal(t, 5, p.LastRequest().MaxTokens)
+	assert.Len(t, p.LastRequest().Messages[1].Content, maxModelInput)
+
+	p.Chunks = []string{"I would say this is a shell command"}
+	_, err = classifier.Classify(context.Background(), "ls")
+	assert.EqualError(t, err, "unknown intent: i, use chat, command or query")
+
+	p.Chunks = []string{""}
+	_, err = classifier.Classify(context.Background(), "ls")
+	assert.EqualError(t, err, "the model gave no intent")
+
+	p.Err = errors.New("rate limited")
+	_, err = classifier.Classify(context.Background(), "ls")
+	assert.EqualError(t, err, "rate limited")
+}
+
+// TestModelAccuracy measures a model on the corpus, with a provider, a model
+// and an API key given as YAI_INTENT_PROVIDER, YAI_INTENT_MODEL and
+// YAI_INTENT_KEY
+func TestModelAccuracy(t *testing.T) {
+	providerName := os.Getenv("YAI_INTENT_PROVIDER")
+	if providerName == "" {
+		t.Skip("YAI_INTENT_PROVIDER is not set")
+	}
+
+	providerType, err := provider.ParseProviderType(providerName)
+	require.NoError(t, err)
+	providerInstance, err := provider.CreateProvider(providerType, os.Getenv("YAI_INTENT_KEY"), "")
+	require.NoError(t, err)
+
+	classifier := NewModelClassifier(providerInstance, os.Getenv("YAI_INTENT_MODEL"))
+	evaluation := Evaluate(loadTestCorpus(t, "testdata/corpus.tsv"), func(input string) (Result, error) {
+		return classifier.Classify(context.Background(), input)
+	})
+
+	t.Logf("%s: accuracy %.2f, mode precision %.2f", providerName, evaluation.Accuracy(), evaluation.Precision())
+	for _, mistake := range evaluation.Mistakes {
+		t.Logf("%s classified %s: %q", mistake.Intent, mistake.Result.Intent, mistake.Input)
+	}
+}
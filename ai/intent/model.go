@@ -0,0 +1,67 @@
+package intent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/xsikor/yai/ai/provider"
+)
+
+// maxModelInput is how much of the prompt is sent to classify it, enough to
+// tell its intent
+const maxModelInput = 2000
+
+const modelSystemPrompt = `You classify the requests made to a terminal assistant. Answer with a single word:
+- command: the user wants a shell command changing something, like creating, deleting, installing or restarting
+- query: the user wants a shell command only reading information, like listing files or finding the process using a port
+- chat: the user wants an explanation, advice, a discussion or some text, rather than a command to run`
+
+// ModelClassifier asks a model for the intent of prompts, a cheap request
+// for the prompts the rules are unsure about
+type ModelClassifier struct {
+	provider provider.Provider
+	model    string
+}
+
+func NewModelClassifier(providerInstance provider.Provider, model string) *ModelClassifier {
+	return &ModelClassifier{
+		provider: providerInstance,
+		model:    model,
+	}
+}
+
+// Classify asks the model for the intent of the input
+func (c *ModelClassifier) Classify(ctx context.Context, input string) (Result, error) {
+	if len(input) > maxModelInput {
+		input = strings.ToValidUTF8(input[:maxModelInput], "")
+	}
+
+	answer, err := c.provider.CreateCompletion(ctx, provider.CompletionRequest{
+		Model:       c.model,
+		MaxTokens:   5,
+		Temperature: 0,
+		Messages: []provider.Message{
+			{Role: "system", Content: modelSystemPrompt},
+			{Role: "user", Content: input},
+		},
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	words := strings.Fields(strings.ToLower(answer))
+	if len(words) == 0 {
+		return Result{}, fmt.Errorf("the model gave no intent")
+	}
+	intent, err := ParseIntent(strings.Trim(words[0], ".,:;\"'`*"))
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Intent:    intent,
+		Confident: true,
+		Source:    ModelSource,
+	}, nil
+}
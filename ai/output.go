@@ -18,11 +18,19 @@ func (eo EngineExecOutput) IsExecutable() bool {
 	return eo.Executable
 }
 
+// Usage is the approximate number of tokens of a completion. It is estimated
+// from the length of the messages, as not all providers report it.
+type Usage struct {
+	PromptTokens     int `json:"prompt"`
+	CompletionTokens int `json:"completion"`
+}
+
 type EngineChatStreamOutput struct {
 	content    string
 	last       bool
 	interrupt  bool
 	executable bool
+	err        error
 }
 
 func (co EngineChatStreamOutput) GetContent() string {
@@ -40,3 +48,9 @@ func (co EngineChatStreamOutput) IsInterrupt() bool {
 func (co EngineChatStreamOutput) IsExecutable() bool {
 	return co.executable
 }
+
+// GetError returns the error that cut the stream short, if any. Only the last
+// output of a stream can carry an error.
+func (co EngineChatStreamOutput) GetError() error {
+	return co.err
+}
@@ -1,6 +1,7 @@
 package ai
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -54,3 +55,10 @@ func TestEngineChatStreamOutputIsExecutable(t *testing.T) {
 
 	assert.True(t, result)
 }
+
+func TestEngineChatStreamOutputGetError(t *testing.T) {
+	co := EngineChatStreamOutput{err: errors.New("testError")}
+	result := co.GetError()
+
+	assert.EqualError(t, result, "testError")
+}
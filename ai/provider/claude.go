@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,11 +15,11 @@ import (
 )
 
 const claudeAPIEndpoint = "https://api.anthropic.com/v1/messages"
-const claudeStreamAPIEndpoint = "https://api.anthropic.com/v1/messages"
 
 type ClaudeProvider struct {
-	apiKey string
-	client *http.Client
+	apiKey   string
+	endpoint string
+	client   *http.Client
 }
 
 func NewClaudeProvider(apiKey string) (*ClaudeProvider, error) {
@@ -27,7 +28,8 @@ func NewClaudeProvider(apiKey string) (*ClaudeProvider, error) {
 	}
 
 	return &ClaudeProvider{
-		apiKey: apiKey,
+		apiKey:   apiKey,
+		endpoint: claudeAPIEndpoint,
 		client: &http.Client{
 			Timeout: time.Second * 120,
 		},
@@ -54,8 +56,21 @@ func (p *ClaudeProvider) DefaultModel() string {
 }
 
 type claudeMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string               `json:"role"`
+	Content []claudeContentBlock `json:"content"`
+}
+
+// claudeContentBlock is a text block, or an image block with its source
+type claudeContentBlock struct {
+	Type   string             `json:"type"`
+	Text   string             `json:"text,omitempty"`
+	Source *claudeImageSource `json:"source,omitempty"`
+}
+
+type claudeImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
 }
 
 type claudeRequest struct {
@@ -84,6 +99,16 @@ type claudeStreamResponse struct {
 		Type  string `json:"type"`
 		Text  string `json:"text"`
 	} `json:"delta"`
+	Error *claudeError `json:"error"`
+}
+
+type claudeError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+func (e claudeError) Error() string {
+	return fmt.Sprintf("Claude API stream error: %s - %s", e.Type, e.Message)
 }
 
 func (p *ClaudeProvider) convertMessagesToClaudeMessages(messages []Message) []claudeMessage {
@@ -105,7 +130,7 @@ func (p *ClaudeProvider) convertMessagesToClaudeMessages(messages []Message) []c
 		if role != "" {
 			result = append(result, claudeMessage{
 				Role:    role,
-				Content: msg.Content,
+				Content: convertPartsToClaudeBlocks(msg.GetParts()),
 			})
 		}
 	}
@@ -123,7 +148,11 @@ func (p *ClaudeProvider) convertMessagesToClaudeMessages(messages []Message) []c
 		for i, msg := range result {
 			if msg.Role == "user" {
 				// Prepend system message to first user message
-				result[i].Content = fmt.Sprintf("%s\n\n%s", systemContent, msg.Content)
+				if len(msg.Content) > 0 && msg.Content[0].Type == "text" {
+					result[i].Content[0].Text = fmt.Sprintf("%s\n\n%s", systemContent, msg.Content[0].Text)
+				} else {
+					result[i].Content = append([]claudeContentBlock{{Type: "text", Text: systemContent}}, msg.Content...)
+				}
 				break
 			}
 		}
@@ -132,6 +161,32 @@ func (p *ClaudeProvider) convertMessagesToClaudeMessages(messages []Message) []c
 	return result
 }
 
+// convertPartsToClaudeBlocks returns the content blocks of the parts, empty
+// text blocks being refused by the API
+func convertPartsToClaudeBlocks(parts []Part) []claudeContentBlock {
+	blocks := make([]claudeContentBlock, 0, len(parts))
+
+	for _, part := range parts {
+		switch part.Type {
+		case TextPart:
+			if part.Text != "" {
+				blocks = append(blocks, claudeContentBlock{Type: "text", Text: part.Text})
+			}
+		case ImagePart:
+			blocks = append(blocks, claudeContentBlock{
+				Type: "image",
+				Source: &claudeImageSource{
+					Type:      "base64",
+					MediaType: part.MimeType,
+					Data:      base64.StdEncoding.EncodeToString(part.Data),
+				},
+			})
+		}
+	}
+
+	return blocks
+}
+
 func (p *ClaudeProvider) CreateCompletion(ctx context.Context, req CompletionRequest) (string, error) {
 	claudeMessages := p.convertMessagesToClaudeMessages(req.Messages)
 	
@@ -152,7 +207,7 @@ func (p *ClaudeProvider) CreateCompletion(ctx context.Context, req CompletionReq
 		return "", err
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", claudeAPIEndpoint, bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.endpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", err
 	}
@@ -208,7 +263,7 @@ func (p *ClaudeProvider) CreateCompletionStream(ctx context.Context, req Complet
 		return nil, err
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", claudeStreamAPIEndpoint, bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.endpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
@@ -223,7 +278,7 @@ func (p *ClaudeProvider) CreateCompletionStream(ctx context.Context, req Complet
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
+		defer resp.Body.Close()
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("Claude API returned error: %s - %s", resp.Status, string(bodyBytes))
 	}
@@ -234,67 +289,69 @@ func (p *ClaudeProvider) CreateCompletionStream(ctx context.Context, req Complet
 		defer resp.Body.Close()
 		defer close(responseChan)
 
-		reader := bufio.NewReader(resp.Body)
-		
-		for {
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				if err != io.EOF {
-					// Error occurred, make sure to send a done signal before returning
-					responseChan <- CompletionResponse{
-						Content: "",
-						Done:    true,
-					}
-					return
-				}
-				// EOF reached
-				break
+		if err := p.readStream(resp.Body, responseChan); err != nil {
+			responseChan <- CompletionResponse{
+				Content: "",
+				Done:    true,
+				Err:     err,
 			}
+			return
+		}
 
-			// Skip empty lines
-			line = strings.TrimSpace(line)
-			if line == "" {
-				continue
-			}
+		responseChan <- CompletionResponse{
+			Content: "",
+			Done:    true,
+		}
+	}()
 
-			// SSE messages start with "data: "
-			if !strings.HasPrefix(line, "data: ") {
-				continue
-			}
+	return responseChan, nil
+}
 
-			// Extract the JSON data
-			data := strings.TrimPrefix(line, "data: ")
-			if data == "[DONE]" {
-				responseChan <- CompletionResponse{
-					Content: "",
-					Done:    true,
-				}
-				return
+// readStream forwards the text deltas of an Anthropic SSE stream until the
+// message_stop event. Any other way for the stream to end is an error, so a
+// truncated answer is never mistaken for a complete one.
+func (p *ClaudeProvider) readStream(body io.Reader, responseChan chan<- CompletionResponse) error {
+	reader := bufio.NewReader(body)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("Claude API stream ended unexpectedly: %w", io.ErrUnexpectedEOF)
 			}
+			return fmt.Errorf("Claude API stream failed: %w", err)
+		}
 
-			var streamResp claudeStreamResponse
-			if err := json.Unmarshal([]byte(data), &streamResp); err != nil {
-				// Skip malformed messages, but don't close the connection
-				continue
-			}
+		// Skip empty lines and event names, the data payload carries its type
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
 
-			// Only process content stream events
-			if streamResp.Type != "content_block_delta" {
-				continue
-			}
+		// Extract the JSON data
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return nil
+		}
 
+		var streamResp claudeStreamResponse
+		if err := json.Unmarshal([]byte(data), &streamResp); err != nil {
+			return fmt.Errorf("Claude API sent a malformed event: %w", err)
+		}
+
+		switch streamResp.Type {
+		case "content_block_delta":
 			responseChan <- CompletionResponse{
 				Content: streamResp.Delta.Text,
 				Done:    false,
 			}
+		case "message_stop":
+			return nil
+		case "error":
+			if streamResp.Error == nil {
+				return errors.New("Claude API sent an error event without details")
+			}
+			return *streamResp.Error
 		}
-
-		// In case we didn't get a [DONE] message
-		responseChan <- CompletionResponse{
-			Content: "",
-			Done:    true,
-		}
-	}()
-
-	return responseChan, nil
-}
\ No newline at end of file
+	}
+}
@@ -0,0 +1,163 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newClaudeTestProvider(t *testing.T, handler http.HandlerFunc) *ClaudeProvider {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	p, err := NewClaudeProvider("fake-key")
+	require.NoError(t, err)
+	p.endpoint = server.URL
+
+	return p
+}
+
+func claudeSSE(events ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprint(w, event)
+		}
+	}
+}
+
+func claudeDelta(text string) string {
+	return fmt.Sprintf("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":%q}}\n\n", text)
+}
+
+const claudeStop = "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+
+func claudeTestRequest() CompletionRequest {
+	return CompletionRequest{
+		Model:    "claude-3-haiku-20240307",
+		Messages: []Message{{Role: "user", Content: "hi"}},
+		Stream:   true,
+	}
+}
+
+func TestClaudeStream(t *testing.T) {
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		content     string
+		expectedErr string
+	}{
+		{
+			name: "complete",
+			handler: claudeSSE(
+				"event: message_start\ndata: {\"type\":\"message_start\"}\n\n",
+				"event: ping\ndata: {\"type\": \"ping\"}\n\n",
+				claudeDelta("Hello"),
+				claudeDelta(" world"),
+				claudeStop,
+			),
+			content: "Hello world",
+		},
+		{
+			name: "error event",
+			handler: claudeSSE(
+				claudeDelta("Hel"),
+				"event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n",
+			),
+			content:     "Hel",
+			expectedErr: "overloaded_error - Overloaded",
+		},
+		{
+			name: "malformed event",
+			handler: claudeSSE(
+				claudeDelta("Hel"),
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\n\n",
+				claudeDelta("lo"),
+				claudeStop,
+			),
+			content:     "Hel",
+			expectedErr: "malformed event",
+		},
+		{
+			name: "connection dropped",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "1000")
+				fmt.Fprint(w, claudeDelta("Hel"))
+			},
+			content:     "Hel",
+			expectedErr: "stream failed",
+		},
+		{
+			name:        "ended without message_stop",
+			handler:     claudeSSE(claudeDelta("Hel")),
+			content:     "Hel",
+			expectedErr: "ended unexpectedly",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newClaudeTestProvider(t, test.handler)
+
+			stream, err := p.CreateCompletionStream(context.Background(), claudeTestRequest())
+			require.NoError(t, err)
+
+			content, last := collectStream(t, stream)
+			assert.Equal(t, test.content, content)
+
+			if test.expectedErr == "" {
+				assert.NoError(t, last.Err)
+			} else {
+				require.Error(t, last.Err)
+				assert.Contains(t, last.Err.Error(), test.expectedErr)
+			}
+		})
+	}
+}
+
+func TestClaudeStreamHTTPError(t *testing.T) {
+	p := newClaudeTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"type":"error","error":{"type":"authentication_error"}}`, http.StatusUnauthorized)
+	})
+
+	_, err := p.CreateCompletionStream(context.Background(), claudeTestRequest())
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "authentication_error"))
+}
+
+func TestClaudeImages(t *testing.T) {
+	var body claudeRequest
+	p := newClaudeTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"A chart"}]}`)
+	})
+
+	content, err := p.CreateCompletion(context.Background(), CompletionRequest{
+		Model: "claude-3-haiku-20240307",
+		Messages: []Message{
+			{Role: "system", Content: "You are Yai"},
+			{Role: "user", Parts: []Part{NewImagePart([]byte("png"), "image/png")}},
+			{Role: "assistant", Content: "A chart"},
+			{Role: "user", Content: "of what?"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "A chart", content)
+
+	require.Len(t, body.Messages, 3)
+	// The system prompt goes before the image, without an empty text block
+	assert.Equal(t, []claudeContentBlock{
+		{Type: "text", Text: "You are Yai"},
+		{Type: "image", Source: &claudeImageSource{Type: "base64", MediaType: "image/png", Data: "cG5n"}},
+	}, body.Messages[0].Content)
+	assert.Equal(t, []claudeContentBlock{{Type: "text", Text: "of what?"}}, body.Messages[2].Content)
+}
@@ -1,6 +1,9 @@
 package provider
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // CreateProvider creates and returns the appropriate provider based on the type
 func CreateProvider(providerType ProviderType, apiKey string, proxyURL string) (Provider, error) {
@@ -14,4 +17,20 @@ func CreateProvider(providerType ProviderType, apiKey string, proxyURL string) (
 	default:
 		return nil, fmt.Errorf("unsupported provider type: %s", providerType)
 	}
-}
\ No newline at end of file
+}
+
+// ProviderTypes returns all supported provider types
+func ProviderTypes() []ProviderType {
+	return []ProviderType{ProviderOpenAI, ProviderClaude, ProviderGemini}
+}
+
+// ParseProviderType returns the provider type matching the given name, ignoring case
+func ParseProviderType(name string) (ProviderType, error) {
+	for _, providerType := range ProviderTypes() {
+		if strings.EqualFold(name, string(providerType)) {
+			return providerType, nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported provider type: %s", name)
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
@@ -16,8 +17,12 @@ type GeminiProvider struct {
 }
 
 func NewGeminiProvider(apiKey string) (*GeminiProvider, error) {
+	return newGeminiProvider(option.WithAPIKey(apiKey))
+}
+
+func newGeminiProvider(opts ...option.ClientOption) (*GeminiProvider, error) {
 	ctx := context.Background()
-	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+	client, err := genai.NewClient(ctx, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -69,7 +74,9 @@ func (p *GeminiProvider) convertMessagesToGeminiParts(messages []Message) ([]gen
 		prompt.WriteString("\n\n")
 	}
 
-	// Add the conversation history
+	// Add the conversation history, the images being sent as blobs where
+	// they appear in it
+	var parts []genai.Part
 	for _, msg := range messages {
 		role := strings.ToLower(msg.Role)
 		if role != "system" {
@@ -78,7 +85,18 @@ func (p *GeminiProvider) convertMessagesToGeminiParts(messages []Message) ([]gen
 			} else if role == "assistant" {
 				prompt.WriteString("Assistant: ")
 			}
-			prompt.WriteString(msg.Content)
+			for _, part := range msg.GetParts() {
+				switch part.Type {
+				case TextPart:
+					prompt.WriteString(part.Text)
+				case ImagePart:
+					if prompt.Len() > 0 {
+						parts = append(parts, genai.Text(prompt.String()))
+						prompt.Reset()
+					}
+					parts = append(parts, genai.Blob{MIMEType: part.MimeType, Data: part.Data})
+				}
+			}
 			prompt.WriteString("\n\n")
 		}
 	}
@@ -86,7 +104,7 @@ func (p *GeminiProvider) convertMessagesToGeminiParts(messages []Message) ([]gen
 	// Add a final prompt for the AI to continue
 	prompt.WriteString("Assistant: ")
 
-	return []genai.Part{genai.Text(prompt.String())}, nil
+	return append(parts, genai.Text(prompt.String())), nil
 }
 
 func (p *GeminiProvider) CreateCompletion(ctx context.Context, req CompletionRequest) (string, error) {
@@ -137,10 +155,15 @@ func (p *GeminiProvider) CreateCompletionStream(ctx context.Context, req Complet
 	go func() {
 		defer close(responseChan)
 
+		// Some Go versions make the REST stream reader fail on the closing
+		// bracket, so a candidate that already reported why it finished
+		// marks the stream as complete whatever error comes after it.
+		finished := false
+
 		for {
 			resp, err := iter.Next()
 			if err != nil {
-				if err == io.EOF {
+				if finished || errors.Is(err, iterator.Done) || errors.Is(err, io.EOF) {
 					// Send final token with done flag
 					responseChan <- CompletionResponse{
 						Content: "",
@@ -148,15 +171,20 @@ func (p *GeminiProvider) CreateCompletionStream(ctx context.Context, req Complet
 					}
 					return
 				}
-				// Other error occurred, but still send a done signal to prevent hanging
+				// Other error occurred, still send a done signal to prevent hanging
 				responseChan <- CompletionResponse{
 					Content: "",
 					Done:    true,
+					Err:     err,
 				}
 				return
 			}
 
-			if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
+			if len(resp.Candidates) > 0 && resp.Candidates[0].FinishReason != genai.FinishReasonUnspecified {
+				finished = true
+			}
+
+			if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
 				continue
 			}
 
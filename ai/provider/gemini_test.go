@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+)
+
+func newGeminiTestProvider(t *testing.T, handler http.HandlerFunc) *GeminiProvider {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	p, err := newGeminiProvider(option.WithAPIKey("fake-key"), option.WithEndpoint(server.URL))
+	require.NoError(t, err)
+
+	return p
+}
+
+func geminiChunk(text string) string {
+	return fmt.Sprintf("{\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":%q}]}}]}", text)
+}
+
+func geminiLastChunk(text string) string {
+	return fmt.Sprintf("{\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":%q}]},\"finishReason\":1}]}", text)
+}
+
+func TestGeminiStream(t *testing.T) {
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		content     string
+		expectedErr bool
+	}{
+		{
+			name: "complete",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, "[%s,\n%s]", geminiChunk("Hello"), geminiLastChunk(" world"))
+			},
+			content: "Hello world",
+		},
+		{
+			name: "connection dropped",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Length", "1000")
+				fmt.Fprintf(w, "[%s,\n", geminiChunk("Hel"))
+			},
+			content:     "Hel",
+			expectedErr: true,
+		},
+		{
+			name: "malformed chunk",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, "[%s,\n{\"candidates\":[}]", geminiChunk("Hel"))
+			},
+			content:     "Hel",
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newGeminiTestProvider(t, test.handler)
+
+			stream, err := p.CreateCompletionStream(context.Background(), CompletionRequest{
+				Model:    "gemini-2.0-flash",
+				Messages: []Message{{Role: "user", Content: "hi"}},
+				Stream:   true,
+			})
+			require.NoError(t, err)
+
+			content, last := collectStream(t, stream)
+			assert.Equal(t, test.content, content)
+
+			if test.expectedErr {
+				assert.Error(t, last.Err)
+			} else {
+				assert.NoError(t, last.Err)
+			}
+		})
+	}
+}
+
+func TestGeminiImages(t *testing.T) {
+	p, err := NewGeminiProvider("fake-key")
+	require.NoError(t, err)
+
+	parts, err := p.convertMessagesToGeminiParts([]Message{
+		{Role: "system", Content: "You are Yai"},
+		{Role: "user", Content: "what is this?", Parts: []Part{NewImagePart([]byte("png"), "image/png")}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []genai.Part{
+		genai.Text("System Instructions: You are Yai\n\nUser: what is this?"),
+		genai.Blob{MIMEType: "image/png", Data: []byte("png")},
+		genai.Text("\n\nAssistant: "),
+	}, parts)
+}
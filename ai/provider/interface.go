@@ -2,6 +2,8 @@ package provider
 
 import (
 	"context"
+	"path"
+	"strings"
 )
 
 type ProviderType string
@@ -12,9 +14,80 @@ const (
 	ProviderGemini ProviderType = "gemini"
 )
 
+type PartType string
+
+const (
+	TextPart  PartType = "text"
+	ImagePart PartType = "image"
+)
+
+// Part is a typed piece of a message: text, or an image with its MIME type
+type Part struct {
+	Type     PartType
+	Text     string
+	Data     []byte
+	MimeType string
+}
+
+func NewTextPart(text string) Part {
+	return Part{Type: TextPart, Text: text}
+}
+
+func NewImagePart(data []byte, mimeType string) Part {
+	return Part{Type: ImagePart, Data: data, MimeType: mimeType}
+}
+
+// Message is a message of the conversation. Its content is the text of
+// Content, followed by Parts, only models accepting images being sent image
+// parts, as told by AcceptsImages.
 type Message struct {
 	Role    string
 	Content string
+	Parts   []Part
+}
+
+// GetParts returns the content of the message as parts, Content being the
+// first text part
+func (m Message) GetParts() []Part {
+	if m.Content == "" {
+		return m.Parts
+	}
+
+	return append([]Part{NewTextPart(m.Content)}, m.Parts...)
+}
+
+// HasImages tells if the message has image parts
+func (m Message) HasImages() bool {
+	for _, part := range m.Parts {
+		if part.Type == ImagePart {
+			return true
+		}
+	}
+
+	return false
+}
+
+// textOnlyModels match the models of each provider not accepting images,
+// the other ones, like the models named vision, accepting them
+var textOnlyModels = map[ProviderType][]string{
+	ProviderOpenAI: {"gpt-3.5-*", "gpt-4", "gpt-4-32k*", "gpt-4-0*", "gpt-4-1106-preview", "o1-mini*", "o3-mini*", "text-*"},
+	ProviderClaude: {"claude-2*", "claude-instant*"},
+	ProviderGemini: {"gemini-pro", "gemini-1.0-*", "*embedding*", "imagen-*", "text-*"},
+}
+
+// AcceptsImages tells if a model of a provider accepts image parts
+func AcceptsImages(providerType ProviderType, model string) bool {
+	if strings.Contains(model, "vision") {
+		return true
+	}
+
+	for _, pattern := range textOnlyModels[providerType] {
+		if matched, _ := path.Match(pattern, model); matched {
+			return false
+		}
+	}
+
+	return true
 }
 
 type CompletionRequest struct {
@@ -25,10 +98,13 @@ type CompletionRequest struct {
 	Stream      bool
 }
 
+// CompletionResponse is a chunk of a streamed completion. The last chunk has
+// Done set; if the stream broke before the model finished, it also has Err.
 type CompletionResponse struct {
 	Content    string
 	Done       bool
 	Executable bool
+	Err        error
 }
 
 type Provider interface {
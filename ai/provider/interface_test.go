@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -95,4 +96,67 @@ func TestProviderFactory(t *testing.T) {
 	if err == nil {
 		t.Error("Factory did not return error for invalid provider type")
 	}
-}
\ No newline at end of file
+}
+
+// collectStream reads a completion stream to the end and returns the joined
+// content along with the final chunk.
+func collectStream(t *testing.T, stream <-chan CompletionResponse) (string, CompletionResponse) {
+	t.Helper()
+
+	var content string
+	var last CompletionResponse
+
+	for resp := range stream {
+		content += resp.Content
+		last = resp
+	}
+
+	if !last.Done {
+		t.Fatal("stream closed without a done chunk")
+	}
+
+	return content, last
+}
+
+func TestParseProviderType(t *testing.T) {
+	for _, name := range []string{"openai", "Claude", "GEMINI"} {
+		providerType, err := ParseProviderType(name)
+		if err != nil {
+			t.Errorf("Expected %s to be a supported provider, got %s", name, err)
+		}
+		if !strings.EqualFold(string(providerType), name) {
+			t.Errorf("Expected %s to parse to its provider type, got %s", name, providerType)
+		}
+	}
+
+	if _, err := ParseProviderType("unknown"); err == nil {
+		t.Error("Expected unknown provider to be rejected")
+	}
+}
+
+func TestAcceptsImages(t *testing.T) {
+	testCases := []struct {
+		providerType ProviderType
+		model        string
+		accepts      bool
+	}{
+		{ProviderOpenAI, "gpt-3.5-turbo", false},
+		{ProviderOpenAI, "gpt-4", false},
+		{ProviderOpenAI, "gpt-4-0613", false},
+		{ProviderOpenAI, "gpt-4-turbo", true},
+		{ProviderOpenAI, "gpt-4-vision-preview", true},
+		{ProviderOpenAI, "gpt-4o-mini", true},
+		{ProviderClaude, "claude-2.1", false},
+		{ProviderClaude, "claude-3-haiku-20240307", true},
+		{ProviderGemini, "gemini-pro", false},
+		{ProviderGemini, "gemini-pro-vision", true},
+		{ProviderGemini, "gemini-embedding-exp", false},
+		{ProviderGemini, "gemini-2.0-flash", true},
+	}
+
+	for _, tc := range testCases {
+		if accepts := AcceptsImages(tc.providerType, tc.model); accepts != tc.accepts {
+			t.Errorf("Expected %s %s accepting images to be %t, got %t", tc.providerType, tc.model, tc.accepts, accepts)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package provider
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
@@ -61,15 +63,49 @@ func (p *OpenAIProvider) DefaultModel() string {
 	return "gpt-3.5-turbo"
 }
 
-func (p *OpenAIProvider) CreateCompletion(ctx context.Context, req CompletionRequest) (string, error) {
-	messages := make([]openai.ChatCompletionMessage, len(req.Messages))
-	for i, msg := range req.Messages {
-		messages[i] = openai.ChatCompletionMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
+// convertMessagesToOpenAIMessages sends the messages with parts as multi
+// part content, images being inlined as data URLs
+func convertMessagesToOpenAIMessages(messages []Message) []openai.ChatCompletionMessage {
+	result := make([]openai.ChatCompletionMessage, len(messages))
+	for i, msg := range messages {
+		if len(msg.Parts) == 0 {
+			result[i] = openai.ChatCompletionMessage{
+				Role:    msg.Role,
+				Content: msg.Content,
+			}
+			continue
+		}
+
+		var parts []openai.ChatMessagePart
+		for _, part := range msg.GetParts() {
+			switch part.Type {
+			case TextPart:
+				parts = append(parts, openai.ChatMessagePart{
+					Type: openai.ChatMessagePartTypeText,
+					Text: part.Text,
+				})
+			case ImagePart:
+				parts = append(parts, openai.ChatMessagePart{
+					Type: openai.ChatMessagePartTypeImageURL,
+					ImageURL: &openai.ChatMessageImageURL{
+						URL:    fmt.Sprintf("data:%s;base64,%s", part.MimeType, base64.StdEncoding.EncodeToString(part.Data)),
+						Detail: openai.ImageURLDetailAuto,
+					},
+				})
+			}
+		}
+		result[i] = openai.ChatCompletionMessage{
+			Role:         msg.Role,
+			MultiContent: parts,
 		}
 	}
 
+	return result
+}
+
+func (p *OpenAIProvider) CreateCompletion(ctx context.Context, req CompletionRequest) (string, error) {
+	messages := convertMessagesToOpenAIMessages(req.Messages)
+
 	resp, err := p.client.CreateChatCompletion(
 		ctx,
 		openai.ChatCompletionRequest{
@@ -83,17 +119,15 @@ func (p *OpenAIProvider) CreateCompletion(ctx context.Context, req CompletionReq
 		return "", err
 	}
 
+	if len(resp.Choices) == 0 {
+		return "", errors.New("no content generated")
+	}
+
 	return resp.Choices[0].Message.Content, nil
 }
 
 func (p *OpenAIProvider) CreateCompletionStream(ctx context.Context, req CompletionRequest) (<-chan CompletionResponse, error) {
-	messages := make([]openai.ChatCompletionMessage, len(req.Messages))
-	for i, msg := range req.Messages {
-		messages[i] = openai.ChatCompletionMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
-		}
-	}
+	messages := convertMessagesToOpenAIMessages(req.Messages)
 
 	stream, err := p.client.CreateChatCompletionStream(
 		ctx,
@@ -130,10 +164,15 @@ func (p *OpenAIProvider) CreateCompletionStream(ctx context.Context, req Complet
 				responseChan <- CompletionResponse{
 					Content: "",
 					Done:    true,
+					Err:     err,
 				}
 				return
 			}
 
+			if len(resp.Choices) == 0 {
+				continue
+			}
+
 			delta := resp.Choices[0].Delta.Content
 			responseChan <- CompletionResponse{
 				Content: delta,
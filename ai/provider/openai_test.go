@@ -0,0 +1,118 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newOpenAITestProvider(t *testing.T, handler http.HandlerFunc) *OpenAIProvider {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	clientConfig := openai.DefaultConfig("fake-key")
+	clientConfig.BaseURL = server.URL + "/v1"
+
+	return &OpenAIProvider{
+		client: openai.NewClientWithConfig(clientConfig),
+	}
+}
+
+func openAIDelta(text string) string {
+	return fmt.Sprintf("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", text)
+}
+
+func TestOpenAIStream(t *testing.T) {
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		content     string
+		expectedErr string
+	}{
+		{
+			name: "complete",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprint(w, openAIDelta("Hello"), openAIDelta(" world"), "data: [DONE]\n\n")
+			},
+			content: "Hello world",
+		},
+		{
+			name: "error event",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprint(w, openAIDelta("Hel"), "data: {\"error\":{\"message\":\"server overloaded\",\"type\":\"server_error\"}}\n\n")
+			},
+			content:     "Hel",
+			expectedErr: "server overloaded",
+		},
+		{
+			name: "connection dropped",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Header().Set("Content-Length", "1000")
+				fmt.Fprint(w, openAIDelta("Hel"))
+			},
+			content:     "Hel",
+			expectedErr: "unexpected EOF",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newOpenAITestProvider(t, test.handler)
+
+			stream, err := p.CreateCompletionStream(context.Background(), CompletionRequest{
+				Model:    "gpt-3.5-turbo",
+				Messages: []Message{{Role: "user", Content: "hi"}},
+				Stream:   true,
+			})
+			require.NoError(t, err)
+
+			content, last := collectStream(t, stream)
+			assert.Equal(t, test.content, content)
+
+			if test.expectedErr == "" {
+				assert.NoError(t, last.Err)
+			} else {
+				require.Error(t, last.Err)
+				assert.Contains(t, last.Err.Error(), test.expectedErr)
+			}
+		})
+	}
+}
+
+func TestOpenAIImages(t *testing.T) {
+	var body map[string]any
+	p := newOpenAITestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"A chart"}}]}`)
+	})
+
+	content, err := p.CreateCompletion(context.Background(), CompletionRequest{
+		Model: "gpt-4o",
+		Messages: []Message{
+			{Role: "system", Content: "You are Yai"},
+			{Role: "user", Content: "what is this?", Parts: []Part{NewImagePart([]byte("png"), "image/png")}},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "A chart", content)
+
+	messages := body["messages"].([]any)
+	assert.Equal(t, "You are Yai", messages[0].(map[string]any)["content"])
+	assert.Equal(t, []any{
+		map[string]any{"type": "text", "text": "what is this?"},
+		map[string]any{"type": "image_url", "image_url": map[string]any{"url": "data:image/png;base64,cG5n", "detail": "auto"}},
+	}, messages[1].(map[string]any)["content"])
+}
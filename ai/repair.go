@@ -0,0 +1,44 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/xsikor/yai/ai/provider"
+	"github.com/xsikor/yai/run"
+)
+
+const repairPrompt = "The command `%s` cannot run: %s.\n" +
+	"Fix it and answer with the same json structure only. If no installed program can do it, set exec to false and tell what to install in exp."
+
+// repairExecOutput asks the model once to fix a command failing validation,
+// along with the answer it gave. A command still failing it is made not
+// executable, the explanation telling why. It returns the answer to keep in
+// the history, the output and the usage of the repair request.
+func (e *Engine) repairExecOutput(ctx context.Context, providerInstance provider.Provider, req provider.CompletionRequest, content string, output EngineExecOutput, invalid error) (string, EngineExecOutput, Usage) {
+	repair := req
+	repair.Messages = append(slices.Clone(req.Messages),
+		provider.Message{Role: AssistantRole, Content: content},
+		provider.Message{Role: UserRole, Content: fmt.Sprintf(repairPrompt, output.Command, invalid)},
+	)
+
+	repaired, err := providerInstance.CreateCompletion(ctx, repair)
+	usage := estimateUsage(repair, repaired)
+	if err == nil {
+		if fixed, err := parseExecOutput(repaired); err == nil {
+			if !fixed.Executable {
+				return repaired, fixed, usage
+			}
+			if invalid = run.ValidateCommand(fixed.Command, e.lookPath); invalid == nil {
+				return repaired, fixed, usage
+			}
+			content, output = repaired, fixed
+		}
+	}
+
+	output.Executable = false
+	output.Explanation = fmt.Sprintf("Invalid command `%s`: %s", output.Command, invalid)
+
+	return content, output, usage
+}
@@ -0,0 +1,116 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xsikor/yai/ai/provider"
+	"github.com/xsikor/yai/internal/testprovider"
+)
+
+// repairProvider gives its answers in turn, recording the requests
+type repairProvider struct {
+	testprovider.Provider
+	answers  []string
+	requests []provider.CompletionRequest
+}
+
+func (p *repairProvider) CreateCompletion(ctx context.Context, req provider.CompletionRequest) (string, error) {
+	p.requests = append(p.requests, req)
+	if len(p.requests) > len(p.answers) {
+		return "", errors.New("no more answers")
+	}
+
+	return p.answers[len(p.requests)-1], nil
+}
+
+// newRepairEngine returns an exec engine finding the programs ls and grep only
+func newRepairEngine(answers ...string) (*Engine, *repairProvider) {
+	p := &repairProvider{answers: answers}
+	engine := newTestEngine(ExecEngineMode, p)
+	engine.lookPath = func(file string) (string, error) {
+		if file == "ls" || file == "grep" {
+			return "/bin/" + file, nil
+		}
+		return "", errors.New("not found")
+	}
+
+	return engine, p
+}
+
+func TestEngineExecCompletionRepair(t *testing.T) {
+	// A valid command is not repaired
+	engine, p := newRepairEngine(`{"cmd":"ls -la | grep go","exp":"list go files","exec":true}`)
+	output, err := engine.ExecCompletion("list go files")
+	require.NoError(t, err)
+	assert.True(t, output.IsExecutable())
+	assert.Len(t, p.requests, 1)
+
+	// An unbalanced quote is repaired, the model seeing what went wrong
+	engine, p = newRepairEngine(
+		`{"cmd":"grep -r 'TODO .","exp":"find todos","exec":true}`,
+		`{"cmd":"grep -r 'TODO' .","exp":"find todos","exec":true}`,
+	)
+	output, err = engine.ExecCompletion("find todos")
+	require.NoError(t, err)
+	assert.True(t, output.IsExecutable())
+	assert.Equal(t, "grep -r 'TODO' .", output.GetCommand())
+
+	require.Len(t, p.requests, 2)
+	messages := p.requests[1].Messages
+	assert.Equal(t, `{"cmd":"grep -r 'TODO .","exp":"find todos","exec":true}`, messages[len(messages)-2].Content)
+	assert.Contains(t, messages[len(messages)-1].Content, "The command `grep -r 'TODO .` cannot run: the command has an unbalanced ' quote.")
+
+	// The history keeps the repaired answer only
+	assert.Len(t, engine.execMessages, 2)
+	assert.Equal(t, "grep -r 'TODO' .", engine.GetConversation().Entries[1].Command)
+	assert.Greater(t, engine.GetLastUsage().PromptTokens, estimateUsage(p.requests[1], "").PromptTokens)
+
+	// The model may give up on a missing program
+	engine, _ = newRepairEngine(
+		`{"cmd":"jq .name package.json","exp":"print the name","exec":true}`,
+		`{"cmd":"","exp":"install jq first","exec":false}`,
+	)
+	output, err = engine.ExecCompletion("print the package name")
+	require.NoError(t, err)
+	assert.False(t, output.IsExecutable())
+	assert.Equal(t, "install jq first", output.GetExplanation())
+}
+
+func TestEngineExecCompletionRepairFailures(t *testing.T) {
+	// A command still invalid once repaired is not executable
+	engine, p := newRepairEngine(
+		`{"cmd":"jq .name package.json","exp":"print the name","exec":true}`,
+		`{"cmd":"ls | jq .","exp":"print the name","exec":true}`,
+	)
+	output, err := engine.ExecCompletion("print the package name")
+	require.NoError(t, err)
+	assert.Len(t, p.requests, 2)
+	assert.False(t, output.IsExecutable())
+	assert.Equal(t, "ls | jq .", output.GetCommand())
+	assert.Equal(t, "Invalid command `ls | jq .`: the command runs programs not found in the PATH: jq", output.GetExplanation())
+
+	// A failing repair request leaves the first command, not executable
+	engine, _ = newRepairEngine(`{"cmd":"ls (","exp":"list","exec":true}`)
+	output, err = engine.ExecCompletion("list files")
+	require.NoError(t, err)
+	assert.False(t, output.IsExecutable())
+	assert.Equal(t, "ls (", output.GetCommand())
+	assert.Contains(t, output.GetExplanation(), "Invalid command `ls (`: the command is not valid shell syntax: ")
+}
+
+func TestEngineChatStreamCompletionInvalid(t *testing.T) {
+	engine, _ := newRepairEngine()
+	engine.provider = &testprovider.Provider{Chunks: []string{"grep 'TODO", " ."}}
+
+	_, last := readStream(t, engine.ChatStreamCompletion("find todos"))
+	assert.False(t, last.IsExecutable())
+
+	engine.provider = &testprovider.Provider{Chunks: []string{"jq", " ."}}
+	_, last = readStream(t, engine.ChatStreamCompletion("format json"))
+	assert.False(t, last.IsExecutable())
+}
@@ -0,0 +1,88 @@
+package ai
+
+import (
+	"context"
+	"sync"
+)
+
+// ChatStream is a single streamed chat completion. Each request gets its own
+// stream, so an interrupted or abandoned stream never leaks into the next one.
+type ChatStream struct {
+	ctx        context.Context
+	cancel     context.CancelFunc
+	outputs    chan EngineChatStreamOutput
+	mu         sync.Mutex
+	err        error
+	executable bool
+	done       bool
+}
+
+func newChatStream(parent context.Context) *ChatStream {
+	ctx, cancel := context.WithCancel(parent)
+
+	return &ChatStream{
+		ctx:     ctx,
+		cancel:  cancel,
+		outputs: make(chan EngineChatStreamOutput, 16),
+	}
+}
+
+// Next blocks until the next output is available. Once the stream is over it
+// always returns a last output, so callers can keep reading without hanging.
+func (s *ChatStream) Next() EngineChatStreamOutput {
+	output, ok := <-s.outputs
+	if ok {
+		return output
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return EngineChatStreamOutput{
+		content:    "",
+		last:       true,
+		interrupt:  s.ctx.Err() != nil && s.err == nil && !s.done,
+		executable: s.executable,
+		err:        s.err,
+	}
+}
+
+// Cancel interrupts the stream. It never blocks, even if nobody is reading.
+func (s *ChatStream) Cancel() {
+	s.cancel()
+}
+
+// Err returns the error that ended the stream, if any.
+func (s *ChatStream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+// Context returns the context the stream's request is bound to.
+func (s *ChatStream) Context() context.Context {
+	return s.ctx
+}
+
+// send delivers a content output, giving up if the stream was cancelled.
+func (s *ChatStream) send(output EngineChatStreamOutput) bool {
+	select {
+	case s.outputs <- output:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// finish records the outcome of the stream and closes it.
+func (s *ChatStream) finish(executable bool, err error) {
+	s.mu.Lock()
+	s.executable = executable
+	s.err = err
+	s.done = err == nil && s.ctx.Err() == nil
+	s.mu.Unlock()
+
+	close(s.outputs)
+	s.cancel()
+}
@@ -0,0 +1,215 @@
+package attachment
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxFileSize is the size above which a file is not attached
+	MaxFileSize = 100 * 1024
+	// MaxTotalSize is the size above which no more files are attached at once
+	MaxTotalSize = 500 * 1024
+	// binarySniffSize is how much of a file is looked at to detect binaries
+	binarySniffSize = 8000
+)
+
+// File is a file attached to the conversation
+type File struct {
+	path    string
+	content string
+}
+
+func NewFile(path string, content string) File {
+	return File{
+		path:    path,
+		content: content,
+	}
+}
+
+func (f File) GetPath() string {
+	return f.path
+}
+
+func (f File) GetContent() string {
+	return f.content
+}
+
+// GetTokens returns the approximate number of tokens of the file, counting
+// about 4 characters per token
+func (f File) GetTokens() int {
+	return EstimateTokens(f.content)
+}
+
+// EstimateTokens returns the approximate number of tokens of a text
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// Load reads the files matching a path: a file, a directory read
+// recursively, or a glob pattern. Files ignored by git are skipped in
+// directories and globs, as are binary and oversized files. Warnings tell
+// which files were skipped and why, except for ignored files and binary
+// files found in directories.
+func Load(pattern string) ([]File, []string, error) {
+	loader := &loader{
+		ignore: newIgnoreMatcher(),
+	}
+
+	if info, err := os.Stat(pattern); err == nil {
+		if !info.IsDir() {
+			loader.add(pattern, info, true)
+			return loader.files, loader.warnings, nil
+		}
+
+		return loader.files, loader.warnings, loader.walk(pattern)
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+	}
+	if len(matches) == 0 {
+		return nil, nil, fmt.Errorf("no such file: %s", pattern)
+	}
+
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			return nil, nil, err
+		}
+		if loader.ignore.Ignored(match, info.IsDir()) {
+			continue
+		}
+
+		if info.IsDir() {
+			if err := loader.walk(match); err != nil {
+				return nil, nil, err
+			}
+		} else {
+			loader.add(match, info, false)
+		}
+	}
+
+	return loader.files, loader.warnings, nil
+}
+
+type loader struct {
+	ignore    *ignoreMatcher
+	files     []File
+	warnings  []string
+	totalSize int64
+}
+
+func (l *loader) walk(root string) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if path != root && (entry.Name() == ".git" || l.ignore.Ignored(path, true)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !entry.Type().IsRegular() || l.ignore.Ignored(path, false) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		l.add(path, info, false)
+
+		return nil
+	})
+}
+
+// add reads a file unless it is too big or binary. Binary files are only
+// reported when explicitly asked for, not when found in a directory.
+func (l *loader) add(path string, info fs.FileInfo, explicit bool) {
+	if info.Size() > MaxFileSize {
+		l.warnings = append(l.warnings, fmt.Sprintf("%s skipped: larger than %d KiB", path, MaxFileSize/1024))
+		return
+	}
+	if l.totalSize+info.Size() > MaxTotalSize {
+		l.warnings = append(l.warnings, fmt.Sprintf("%s skipped: more than %d KiB attached at once", path, MaxTotalSize/1024))
+		return
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		l.warnings = append(l.warnings, fmt.Sprintf("%s skipped: %s", path, err))
+		return
+	}
+
+	if isBinary(content) {
+		if explicit {
+			l.warnings = append(l.warnings, fmt.Sprintf("%s skipped: binary file", path))
+		}
+		return
+	}
+
+	l.totalSize += info.Size()
+	l.files = append(l.files, NewFile(filepath.Clean(path), string(content)))
+}
+
+func isBinary(content []byte) bool {
+	sniff := content
+	if len(sniff) > binarySniffSize {
+		sniff = sniff[:binarySniffSize]
+	}
+
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		return true
+	}
+
+	// A multi-byte character may be cut at the end of the sniffed part
+	for cut := 0; cut < utf8.UTFMax && cut <= len(sniff); cut++ {
+		if utf8.Valid(sniff[:len(sniff)-cut]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParseMentions returns the paths mentioned in an input as @path/to/file,
+// without any trailing punctuation. The @ must start the input or follow a
+// space, so emails and decorators are not mentions, and the path must exist
+// or be a glob matching files.
+func ParseMentions(input string) []string {
+	var mentions []string
+	for _, word := range strings.Fields(input) {
+		if !strings.HasPrefix(word, "@") {
+			continue
+		}
+
+		mention := strings.TrimRight(word[1:], ".,;:!?)\"'")
+		if mention != "" && pathExists(mention) {
+			mentions = append(mentions, mention)
+		}
+	}
+
+	return mentions
+}
+
+// pathExists tells if a path exists, or if a glob pattern matches files
+func pathExists(path string) bool {
+	if _, err := os.Stat(path); err == nil {
+		return true
+	}
+
+	matches, err := filepath.Glob(path)
+
+	return err == nil && len(matches) > 0
+}
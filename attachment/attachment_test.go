@@ -0,0 +1,207 @@
+package attachment
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachment(t *testing.T) {
+	t.Run("LoadFile", testLoadFile)
+	t.Run("LoadDirectory", testLoadDirectory)
+	t.Run("LoadGlob", testLoadGlob)
+	t.Run("LoadGlobInIgnoredDirectory", testLoadGlobInIgnoredDirectory)
+	t.Run("LoadLimits", testLoadLimits)
+	t.Run("LoadMissing", testLoadMissing)
+	t.Run("ParseMentions", testParseMentions)
+	t.Run("IsBinary", testIsBinary)
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+}
+
+func paths(files []File) []string {
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.GetPath())
+	}
+
+	return paths
+}
+
+func testLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.go": "package main\n"})
+
+	files, warnings, err := Load(filepath.Join(dir, "main.go"))
+	require.NoError(t, err)
+
+	assert.Empty(t, warnings)
+	require.Len(t, files, 1)
+	assert.Equal(t, filepath.Join(dir, "main.go"), files[0].GetPath())
+	assert.Equal(t, "package main\n", files[0].GetContent())
+	assert.Equal(t, 4, files[0].GetTokens())
+}
+
+func testLoadDirectory(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, ".git"), 0o700))
+	writeFiles(t, dir, map[string]string{
+		".gitignore":        "*.log\n/build/\n!keep.log\n# comment\n",
+		"main.go":           "package main",
+		"debug.log":         "ignored",
+		"keep.log":          "kept",
+		"build/out.txt":     "ignored",
+		"docs/build/a.md":   "kept, /build is anchored",
+		"docs/.gitignore":   "*.tmp\n",
+		"docs/notes.tmp":    "ignored",
+		"docs/image.png":    "\x89PNG\x00\x00",
+		".git/HEAD":         "ref: refs/heads/main",
+		"vendor/lib/lib.go": "package lib",
+	})
+
+	files, warnings, err := Load(dir)
+	require.NoError(t, err)
+
+	assert.Empty(t, warnings, "Binary files found in directories should be skipped silently.")
+	assert.ElementsMatch(
+		t,
+		[]string{
+			filepath.Join(dir, ".gitignore"),
+			filepath.Join(dir, "main.go"),
+			filepath.Join(dir, "keep.log"),
+			filepath.Join(dir, "docs/.gitignore"),
+			filepath.Join(dir, "docs/build/a.md"),
+			filepath.Join(dir, "vendor/lib/lib.go"),
+		},
+		paths(files),
+	)
+}
+
+func testLoadGlob(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, ".git"), 0o700))
+	writeFiles(t, dir, map[string]string{
+		".gitignore":   "generated.go\n",
+		"main.go":      "package main",
+		"generated.go": "package main",
+		"README.md":    "# readme",
+	})
+
+	files, _, err := Load(filepath.Join(dir, "*.go"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{filepath.Join(dir, "main.go")}, paths(files))
+}
+
+func testLoadGlobInIgnoredDirectory(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, ".git"), 0o700))
+	writeFiles(t, dir, map[string]string{
+		".gitignore":        "build/\n!build/keep.go\n",
+		"main.go":           "package main",
+		"build/out.go":      "package build",
+		"build/keep.go":     "package build",
+		"build/gen/deep.go": "package gen",
+	})
+
+	// Files matched in an ignored directory are ignored, even if a rule
+	// negates them, as in git
+	files, _, err := Load(filepath.Join(dir, "build", "*.go"))
+	require.NoError(t, err)
+	assert.Empty(t, paths(files))
+
+	files, _, err = Load(filepath.Join(dir, "build", "*", "*.go"))
+	require.NoError(t, err)
+	assert.Empty(t, paths(files))
+
+	files, _, err = Load(filepath.Join(dir, "*.go"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "main.go")}, paths(files))
+}
+
+func testLoadLimits(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"big.txt":    strings.Repeat("a", MaxFileSize+1),
+		"binary.bin": "\x00\x01\x02",
+	})
+
+	files, warnings, err := Load(filepath.Join(dir, "big.txt"))
+	require.NoError(t, err)
+	assert.Empty(t, files)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "larger than")
+
+	files, warnings, err = Load(filepath.Join(dir, "binary.bin"))
+	require.NoError(t, err)
+	assert.Empty(t, files)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "binary file")
+
+	// The total size is limited too
+	for i := 0; i < MaxTotalSize/MaxFileSize+1; i++ {
+		writeFiles(t, dir, map[string]string{
+			filepath.Join("many", strings.Repeat("f", i+1)+".txt"): strings.Repeat("a", MaxFileSize),
+		})
+	}
+
+	files, warnings, err = Load(filepath.Join(dir, "many"))
+	require.NoError(t, err)
+	assert.Len(t, files, MaxTotalSize/MaxFileSize)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "attached at once")
+}
+
+func testLoadMissing(t *testing.T) {
+	_, _, err := Load(filepath.Join(t.TempDir(), "missing.go"))
+	assert.ErrorContains(t, err, "no such file")
+}
+
+func testParseMentions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.go":      "package main",
+		"docs/a.md":    "# a",
+		"ui/ui.go":     "package ui",
+		"ui/prompt.go": "package ui",
+	})
+	main := filepath.Join(dir, "main.go")
+	docs := filepath.Join(dir, "docs") + "/"
+	glob := filepath.Join(dir, "ui", "*.go")
+
+	assert.Equal(
+		t,
+		[]string{main, docs, glob},
+		ParseMentions("why does @"+main+" fail, given @"+docs+" and @"+glob+"? mail me at me@example.com @"),
+	)
+	assert.Nil(t, ParseMentions("no mention"))
+
+	// The @ must start a word
+	assert.Nil(t, ParseMentions("see (@"+main+") and x@"+main))
+
+	// Mentions of missing files are left in the prompt as is
+	assert.Nil(t, ParseMentions("@"+filepath.Join(dir, "missing.go")+" @Override @username @"+filepath.Join(dir, "*.py")))
+}
+
+func testIsBinary(t *testing.T) {
+	assert.False(t, isBinary(nil))
+	assert.False(t, isBinary([]byte("héllo")))
+	assert.True(t, isBinary([]byte("a\x00b")))
+	assert.True(t, isBinary([]byte{0xff, 0xfe, 0xfd, 0xfc, 0xfb}))
+
+	// A multi-byte character cut by the sniffed size is not a binary
+	content := []byte(strings.Repeat("a", binarySniffSize-1) + "é")
+	assert.False(t, isBinary(content))
+}
@@ -0,0 +1,157 @@
+package attachment
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is a pattern of a .gitignore file
+type ignoreRule struct {
+	base     string
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// ignoreMatcher tells if a path is ignored by the .gitignore files of its
+// directory and of its parents, up to the root of the git repository. It
+// supports the common patterns: wildcards, negation, directory-only and
+// anchored patterns.
+type ignoreMatcher struct {
+	rules map[string][]ignoreRule
+}
+
+func newIgnoreMatcher() *ignoreMatcher {
+	return &ignoreMatcher{
+		rules: make(map[string][]ignoreRule),
+	}
+}
+
+// Ignored tells if the path is ignored, the last matching rule winning as in
+// git. As in git too, a path in an ignored directory is ignored whatever its
+// own rules, like a file matched by a glob in a directory-only pattern.
+func (m *ignoreMatcher) Ignored(path string, isDir bool) bool {
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	dirs := parentDirectories(filepath.Dir(absolute))
+	for i := 1; i < len(dirs); i++ {
+		if m.matchRules(dirs[:i], dirs[i], true) {
+			return true
+		}
+	}
+
+	return m.matchRules(dirs, absolute, isDir)
+}
+
+// matchRules tells if the rules of the .gitignore files of dirs ignore the
+// path, the last matching rule winning
+func (m *ignoreMatcher) matchRules(dirs []string, path string, isDir bool) bool {
+	ignored := false
+	for _, dir := range dirs {
+		for _, rule := range m.load(dir) {
+			if rule.matches(path, isDir) {
+				ignored = !rule.negate
+			}
+		}
+	}
+
+	return ignored
+}
+
+// load reads the rules of the .gitignore file of a directory, once
+func (m *ignoreMatcher) load(dir string) []ignoreRule {
+	if rules, ok := m.rules[dir]; ok {
+		return rules
+	}
+
+	var rules []ignoreRule
+
+	file, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err == nil {
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if rule, ok := parseIgnoreRule(dir, scanner.Text()); ok {
+				rules = append(rules, rule)
+			}
+		}
+	}
+
+	m.rules[dir] = rules
+
+	return rules
+}
+
+func parseIgnoreRule(base string, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " ")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: base}
+
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	line = strings.TrimPrefix(line, "**/")
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	// ** spanning directories is approximated by a single level wildcard
+	rule.pattern = strings.ReplaceAll(line, "**", "*")
+
+	return rule, rule.pattern != ""
+}
+
+func (r ignoreRule) matches(path string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+
+	relative, err := filepath.Rel(r.base, path)
+	if err != nil || strings.HasPrefix(relative, "..") {
+		return false
+	}
+	relative = filepath.ToSlash(relative)
+
+	if r.anchored {
+		matched, _ := filepath.Match(r.pattern, relative)
+		return matched
+	}
+
+	matched, _ := filepath.Match(r.pattern, filepath.Base(path))
+	return matched
+}
+
+// parentDirectories returns the directories from the root of the git
+// repository containing dir, or the filesystem root, down to dir
+func parentDirectories(dir string) []string {
+	var dirs []string
+	for {
+		dirs = append([]string{dir}, dirs...)
+
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dirs
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dirs
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,90 @@
+package attachment
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// MaxImageSize is the size above which an image is not attached, the
+// smallest limit of the providers
+const MaxImageSize = 5 * 1024 * 1024
+
+// imageTypes are the MIME types of the images all providers accept
+var imageTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// Image is an image sent along with the next prompt
+type Image struct {
+	path     string
+	mimeType string
+	data     []byte
+}
+
+// NewImage returns the image of the data, named after path, failing if it
+// is not a supported image or is too big
+func NewImage(path string, data []byte) (Image, error) {
+	mimeType := DetectImageType(data)
+	if mimeType == "" {
+		return Image{}, fmt.Errorf("%s is not a PNG, JPEG, GIF or WebP image", path)
+	}
+	if len(data) > MaxImageSize {
+		return Image{}, fmt.Errorf("%s is larger than %d MiB", path, MaxImageSize/1024/1024)
+	}
+
+	return Image{
+		path:     path,
+		mimeType: mimeType,
+		data:     data,
+	}, nil
+}
+
+// LoadImage reads an image file
+func LoadImage(path string) (Image, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Image{}, err
+	}
+	if info.IsDir() {
+		return Image{}, fmt.Errorf("%s is a directory", path)
+	}
+	if info.Size() > MaxImageSize {
+		return Image{}, fmt.Errorf("%s is larger than %d MiB", path, MaxImageSize/1024/1024)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Image{}, err
+	}
+
+	return NewImage(filepath.Clean(path), data)
+}
+
+// DetectImageType returns the MIME type of the image from its first bytes,
+// empty if it is not a supported image
+func DetectImageType(data []byte) string {
+	mimeType := http.DetectContentType(data)
+	if slices.Contains(imageTypes, mimeType) {
+		return mimeType
+	}
+
+	return ""
+}
+
+func (i Image) GetPath() string {
+	return i.path
+}
+
+func (i Image) GetMimeType() string {
+	return i.mimeType
+}
+
+func (i Image) GetData() []byte {
+	return i.data
+}
+
+// GetSize returns the size of the image in KiB, rounded up
+func (i Image) GetSize() int {
+	return (len(i.data) + 1023) / 1024
+}
@@ -0,0 +1,62 @@
+package attachment
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pngHeader is the signature and first chunk of a PNG image
+const pngHeader = "\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"
+
+func TestImage(t *testing.T) {
+	t.Run("DetectImageType", testDetectImageType)
+	t.Run("LoadImage", testLoadImage)
+	t.Run("LoadImageErrors", testLoadImageErrors)
+}
+
+func testDetectImageType(t *testing.T) {
+	assert.Equal(t, "image/png", DetectImageType([]byte(pngHeader)))
+	assert.Equal(t, "image/jpeg", DetectImageType([]byte("\xff\xd8\xff\xe0")))
+	assert.Equal(t, "image/gif", DetectImageType([]byte("GIF89a")))
+	assert.Equal(t, "image/webp", DetectImageType([]byte("RIFF\x00\x00\x00\x00WEBPVP")))
+	assert.Empty(t, DetectImageType([]byte("package main\n")))
+	assert.Empty(t, DetectImageType([]byte("BM\x00\x00")), "BMP is not accepted by every provider")
+	assert.Empty(t, DetectImageType(nil))
+}
+
+func testLoadImage(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"screenshot.png": pngHeader})
+
+	image, err := LoadImage(filepath.Join(dir, ".", "screenshot.png"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "screenshot.png"), image.GetPath())
+	assert.Equal(t, "image/png", image.GetMimeType())
+	assert.Equal(t, []byte(pngHeader), image.GetData())
+	assert.Equal(t, 1, image.GetSize())
+}
+
+func testLoadImageErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.go": "package main\n"})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "big.png"), make([]byte, MaxImageSize+1), 0o600))
+
+	_, err := LoadImage(filepath.Join(dir, "main.go"))
+	assert.EqualError(t, err, filepath.Join(dir, "main.go")+" is not a PNG, JPEG, GIF or WebP image")
+
+	_, err = LoadImage(filepath.Join(dir, "big.png"))
+	assert.EqualError(t, err, filepath.Join(dir, "big.png")+" is larger than 5 MiB")
+
+	_, err = LoadImage(dir)
+	assert.EqualError(t, err, dir+" is a directory")
+
+	_, err = LoadImage(filepath.Join(dir, "missing.png"))
+	assert.Error(t, err)
+
+	_, err = NewImage("stdin", make([]byte, MaxImageSize+1))
+	assert.Error(t, err)
+}
@@ -0,0 +1,279 @@
+package attachment
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// MaxPipeSize is the size of piped text sent as is, about 32k tokens. A
+// larger input is cut down to its first and last lines.
+const MaxPipeSize = 128 * 1024
+
+// pipeHeadShare is the share of the size kept from the start of a truncated
+// input, the end of a log usually mattering most
+const pipeHeadShare = 4
+
+// pipeChunkSize is the size of the reads of the piped input
+const pipeChunkSize = 64 * 1024
+
+// PipeChunkSize is the size of the parts of an analyzed input, about 8k
+// tokens, small enough for every model
+const PipeChunkSize = 32 * 1024
+
+// MaxPipeChunks is how many parts of an analyzed input are kept at most, the
+// last ones, each costing a request
+const MaxPipeChunks = 100
+
+// Pipe is the input piped into yai: text, cut down to its first and last
+// lines if too large, or an image
+type Pipe struct {
+	content   string
+	image     *Image
+	size      int64
+	lines     int
+	headLines int
+	tailLines int
+	truncated bool
+}
+
+// ReadPipe reads the piped input as a stream, keeping at most limit bytes of
+// text: the first quarter and the last three quarters, at line boundaries,
+// with a marker telling what was omitted in between. An image is read whole,
+// and any other binary input is refused.
+func ReadPipe(r io.Reader, limit int) (Pipe, error) {
+	reader := bufio.NewReaderSize(r, pipeChunkSize)
+
+	sniff, err := reader.Peek(binarySniffSize)
+	if err != nil && err != io.EOF {
+		return Pipe{}, fmt.Errorf("could not read the piped input: %w", err)
+	}
+
+	if DetectImageType(sniff) != "" {
+		data, err := io.ReadAll(io.LimitReader(reader, MaxImageSize+1))
+		if err != nil {
+			return Pipe{}, fmt.Errorf("could not read the piped input: %w", err)
+		}
+		image, err := NewImage("stdin", data)
+		if err != nil {
+			return Pipe{}, err
+		}
+		return Pipe{image: &image, size: int64(len(data))}, nil
+	}
+
+	if isBinary(sniff) {
+		return Pipe{}, fmt.Errorf("the piped input is binary data (%s), only text and images can be piped", http.DetectContentType(sniff))
+	}
+
+	headSize := limit / pipeHeadShare
+	tailSize := limit - headSize
+
+	var head, tail []byte
+	var size int64
+	var newlines int
+	var last byte
+	chunk := make([]byte, pipeChunkSize)
+	for {
+		n, err := reader.Read(chunk)
+		data := chunk[:n]
+		if n > 0 {
+			size += int64(n)
+			newlines += bytes.Count(data, []byte{'\n'})
+			last = data[n-1]
+		}
+
+		if room := headSize - len(head); room > 0 {
+			taken := min(room, len(data))
+			head = append(head, data[:taken]...)
+			data = data[taken:]
+		}
+		tail = append(tail, data...)
+		// Only the end of the input is kept, trimmed once in a while
+		if len(tail) > 2*tailSize {
+			tail = append(tail[:0], tail[len(tail)-tailSize:]...)
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Pipe{}, fmt.Errorf("could not read the piped input: %w", err)
+		}
+	}
+
+	lines := newlines
+	if size > 0 && last != '\n' {
+		lines++
+	}
+
+	if size <= int64(limit) {
+		return Pipe{
+			content: strings.TrimSpace(string(append(head, tail...))),
+			size:    size,
+			lines:   lines,
+		}, nil
+	}
+
+	if len(tail) > tailSize {
+		tail = tail[len(tail)-tailSize:]
+	}
+	// The cut lines are left out, unless the input is a single long line
+	if i := bytes.LastIndexByte(head, '\n'); i >= 0 {
+		head = head[:i+1]
+	}
+	if i := bytes.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	}
+
+	headLines := bytes.Count(head, []byte{'\n'})
+	tailLines := bytes.Count(tail, []byte{'\n'})
+	if last != '\n' {
+		tailLines++
+	}
+	omittedLines := max(0, lines-headLines-tailLines)
+	omittedSize := size - int64(len(head)) - int64(len(tail))
+
+	// A cut may split a multi-byte character
+	content := strings.TrimLeft(strings.ToValidUTF8(string(head), ""), " \t\r\n") +
+		fmt.Sprintf("\n[... %d lines (%s) omitted, the input being larger than %s ...]\n\n", omittedLines, formatSize(omittedSize), formatSize(int64(limit))) +
+		strings.TrimRight(strings.ToValidUTF8(string(tail), ""), " \t\r\n")
+
+	return Pipe{
+		content:   content,
+		size:      size,
+		lines:     lines,
+		headLines: headLines,
+		tailLines: tailLines,
+		truncated: true,
+	}, nil
+}
+
+// PipeChunk is a part of an analyzed input, made of whole lines unless a
+// line is longer than a part
+type PipeChunk struct {
+	Content   string
+	FirstLine int
+	LastLine  int
+}
+
+// ReadPipeChunks reads the piped text as a stream of parts of about size
+// bytes, cut at line boundaries, keeping the last maxChunks ones: the end of
+// a log usually mattering most. Images and binary input are refused.
+func ReadPipeChunks(r io.Reader, size int, maxChunks int) ([]PipeChunk, error) {
+	reader := bufio.NewReaderSize(r, pipeChunkSize)
+
+	sniff, err := reader.Peek(binarySniffSize)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("could not read the piped input: %w", err)
+	}
+	if DetectImageType(sniff) != "" {
+		return nil, fmt.Errorf("the piped input is an image, only text can be analyzed")
+	}
+	if isBinary(sniff) {
+		return nil, fmt.Errorf("the piped input is binary data (%s), only text can be analyzed", http.DetectContentType(sniff))
+	}
+
+	var chunks []PipeChunk
+	var current []byte
+	// line is the number of lines read whole, partial is set while in a line
+	line, firstLine := 0, 1
+	partial := false
+
+	flush := func() {
+		if len(bytes.TrimSpace(current)) > 0 {
+			lastLine := line
+			if partial {
+				lastLine++
+			}
+			chunks = append(chunks, PipeChunk{
+				Content:   strings.ToValidUTF8(string(bytes.TrimRight(current, "\n")), ""),
+				FirstLine: firstLine,
+				LastLine:  lastLine,
+			})
+			if len(chunks) > maxChunks {
+				chunks = chunks[1:]
+			}
+		}
+		current = current[:0]
+		firstLine = line + 1
+	}
+
+	for {
+		piece, err := reader.ReadSlice('\n')
+		if len(piece) > 0 {
+			if len(current) > 0 && len(current)+len(piece) > size {
+				flush()
+			}
+			current = append(current, piece...)
+			if piece[len(piece)-1] == '\n' {
+				line++
+				partial = false
+			} else {
+				partial = true
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			return nil, fmt.Errorf("could not read the piped input: %w", err)
+		}
+	}
+	flush()
+
+	return chunks, nil
+}
+
+// GetContent returns the piped text, empty for an image
+func (p Pipe) GetContent() string {
+	return p.content
+}
+
+// GetImage returns the piped image, if the input is one
+func (p Pipe) GetImage() (Image, bool) {
+	if p.image == nil {
+		return Image{}, false
+	}
+
+	return *p.image, true
+}
+
+// GetSize returns the size of the whole input, in bytes
+func (p Pipe) GetSize() int64 {
+	return p.size
+}
+
+// GetLines returns the number of lines of the whole input
+func (p Pipe) GetLines() int {
+	return p.lines
+}
+
+// IsTruncated tells if the input was cut down to its first and last lines
+func (p Pipe) IsTruncated() bool {
+	return p.truncated
+}
+
+// GetTruncation tells what was kept of a truncated input, empty otherwise
+func (p Pipe) GetTruncation() string {
+	if !p.truncated {
+		return ""
+	}
+
+	return fmt.Sprintf("input of %s truncated to its first %d and last %d lines, out of %d", formatSize(p.size), p.headLines, p.tailLines, p.lines)
+}
+
+// formatSize returns a size in bytes in a readable unit
+func formatSize(size int64) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1f MiB", float64(size)/1024/1024)
+	case size >= 1024:
+		return fmt.Sprintf("%.1f KiB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%d bytes", size)
+	}
+}
@@ -0,0 +1,166 @@
+package attachment
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipe(t *testing.T) {
+	t.Run("ReadText", testReadPipeText)
+	t.Run("ReadTruncated", testReadPipeTruncated)
+	t.Run("ReadLongLine", testReadPipeLongLine)
+	t.Run("ReadImage", testReadPipeImage)
+	t.Run("ReadErrors", testReadPipeErrors)
+	t.Run("ReadChunks", testReadPipeChunks)
+	t.Run("ReadChunksErrors", testReadPipeChunksErrors)
+}
+
+// numberedLines returns the lines "line 1" to "line n"
+func numberedLines(n int) string {
+	var sb strings.Builder
+	for i := 1; i <= n; i++ {
+		sb.WriteString(fmt.Sprintf("line %d\n", i))
+	}
+
+	return sb.String()
+}
+
+func testReadPipeText(t *testing.T) {
+	pipe, err := ReadPipe(strings.NewReader("\n  some input\nsecond line\n"), 1024)
+	require.NoError(t, err)
+	assert.Equal(t, "some input\nsecond line", pipe.GetContent())
+	assert.Equal(t, 3, pipe.GetLines())
+	assert.EqualValues(t, 26, pipe.GetSize())
+	assert.False(t, pipe.IsTruncated())
+	assert.Empty(t, pipe.GetTruncation())
+	_, ok := pipe.GetImage()
+	assert.False(t, ok)
+
+	pipe, err = ReadPipe(strings.NewReader(""), 1024)
+	require.NoError(t, err)
+	assert.Empty(t, pipe.GetContent())
+	assert.Equal(t, 0, pipe.GetLines())
+}
+
+func testReadPipeTruncated(t *testing.T) {
+	// Read in small chunks, the tail being trimmed many times
+	input := numberedLines(100000)
+	pipe, err := ReadPipe(iotest.HalfReader(strings.NewReader(input)), 4096)
+	require.NoError(t, err)
+
+	content := pipe.GetContent()
+	assert.True(t, pipe.IsTruncated())
+	assert.Equal(t, 100000, pipe.GetLines())
+	assert.EqualValues(t, len(input), pipe.GetSize())
+	assert.Less(t, len(content), 4096+200)
+
+	// The kept lines are whole, from the start and the end of the input
+	assert.True(t, strings.HasPrefix(content, "line 1\nline 2\n"))
+	assert.True(t, strings.HasSuffix(content, "\nline 99999\nline 100000"))
+	assert.Contains(t, content, " lines (")
+	assert.Contains(t, content, " omitted, the input being larger than 4.0 KiB ...]")
+
+	head, tail, found := strings.Cut(content, "\n[... ")
+	require.True(t, found)
+	assert.True(t, strings.HasSuffix(head, "\n"))
+	tail = tail[strings.Index(tail, "]\n\n")+3:]
+	headLines := strings.Count(head, "\n")
+	tailLines := strings.Count(tail, "\n") + 1
+	assert.Contains(t, content, fmt.Sprintf("[... %d lines", 100000-headLines-tailLines))
+	assert.Equal(t, fmt.Sprintf("line %d", 100000-tailLines+1), tail[:strings.Index(tail, "\n")])
+	assert.Equal(t, fmt.Sprintf("input of 1.0 MiB truncated to its first %d and last %d lines, out of 100000", headLines, tailLines), pipe.GetTruncation())
+}
+
+func testReadPipeLongLine(t *testing.T) {
+	// A single line is cut anywhere, without splitting a character
+	pipe, err := ReadPipe(strings.NewReader(strings.Repeat("é", 10000)), 1001)
+	require.NoError(t, err)
+
+	content := pipe.GetContent()
+	assert.True(t, pipe.IsTruncated())
+	assert.Equal(t, 1, pipe.GetLines())
+	assert.True(t, strings.HasPrefix(content, strings.Repeat("é", 125)+"\n[... 0 lines ("))
+	assert.True(t, strings.HasSuffix(content, "]\n\n"+strings.Repeat("é", 375)))
+}
+
+func testReadPipeImage(t *testing.T) {
+	pipe, err := ReadPipe(strings.NewReader(pngHeader+strings.Repeat("\x00", 10000)), 1024)
+	require.NoError(t, err)
+
+	image, ok := pipe.GetImage()
+	require.True(t, ok)
+	assert.Equal(t, "stdin", image.GetPath())
+	assert.Equal(t, "image/png", image.GetMimeType())
+	assert.Len(t, image.GetData(), len(pngHeader)+10000)
+	assert.Empty(t, pipe.GetContent())
+	assert.False(t, pipe.IsTruncated())
+
+	_, err = ReadPipe(io.MultiReader(strings.NewReader(pngHeader), strings.NewReader(strings.Repeat("\x00", MaxImageSize))), 1024)
+	assert.EqualError(t, err, "stdin is larger than 5 MiB")
+}
+
+func testReadPipeErrors(t *testing.T) {
+	_, err := ReadPipe(strings.NewReader("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\x03"), 1024)
+	assert.EqualError(t, err, "the piped input is binary data (application/x-gzip), only text and images can be piped")
+
+	failure := errors.New("connection reset")
+	_, err = ReadPipe(io.MultiReader(strings.NewReader(numberedLines(10000)), iotest.ErrReader(failure)), 1024)
+	assert.ErrorIs(t, err, failure)
+	assert.EqualError(t, err, "could not read the piped input: connection reset")
+
+	_, err = ReadPipe(iotest.ErrReader(failure), 1024)
+	assert.ErrorIs(t, err, failure)
+}
+
+func testReadPipeChunks(t *testing.T) {
+	// Each line is 7 or 8 bytes long, a part holding at most 3 of them
+	chunks, err := ReadPipeChunks(iotest.OneByteReader(strings.NewReader(numberedLines(10))), 24, 100)
+	require.NoError(t, err)
+	assert.Equal(t, []PipeChunk{
+		{Content: "line 1\nline 2\nline 3", FirstLine: 1, LastLine: 3},
+		{Content: "line 4\nline 5\nline 6", FirstLine: 4, LastLine: 6},
+		{Content: "line 7\nline 8\nline 9", FirstLine: 7, LastLine: 9},
+		{Content: "line 10", FirstLine: 10, LastLine: 10},
+	}, chunks)
+
+	// Only the last parts are kept
+	chunks, err = ReadPipeChunks(strings.NewReader(numberedLines(10000)), 1024, 3)
+	require.NoError(t, err)
+	require.Len(t, chunks, 3)
+	assert.Equal(t, 10000, chunks[2].LastLine)
+	assert.Equal(t, chunks[0].LastLine+1, chunks[1].FirstLine)
+	assert.True(t, strings.HasPrefix(chunks[0].Content, fmt.Sprintf("line %d\n", chunks[0].FirstLine)))
+
+	// A line longer than a part is split, without a trailing line break
+	chunks, err = ReadPipeChunks(strings.NewReader("short\n"+strings.Repeat("x", 100000)), 1024, 100)
+	require.NoError(t, err)
+	require.Len(t, chunks, 3)
+	assert.Equal(t, PipeChunk{Content: "short", FirstLine: 1, LastLine: 1}, chunks[0])
+	assert.Equal(t, 2, chunks[1].FirstLine)
+	assert.Equal(t, 2, chunks[1].LastLine)
+	assert.Equal(t, 2, chunks[2].FirstLine)
+	assert.Len(t, chunks[1].Content+chunks[2].Content, 100000)
+
+	chunks, err = ReadPipeChunks(strings.NewReader("\n\n"), 1024, 100)
+	require.NoError(t, err)
+	assert.Empty(t, chunks)
+}
+
+func testReadPipeChunksErrors(t *testing.T) {
+	_, err := ReadPipeChunks(strings.NewReader(pngHeader), 1024, 100)
+	assert.EqualError(t, err, "the piped input is an image, only text can be analyzed")
+
+	_, err = ReadPipeChunks(strings.NewReader("a\x00b"), 1024, 100)
+	assert.EqualError(t, err, "the piped input is binary data (application/octet-stream), only text can be analyzed")
+
+	failure := errors.New("connection reset")
+	_, err = ReadPipeChunks(io.MultiReader(strings.NewReader(numberedLines(10000)), iotest.ErrReader(failure)), 1024, 100)
+	assert.EqualError(t, err, "could not read the piped input: connection reset")
+}
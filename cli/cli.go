@@ -0,0 +1,310 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+
+	"github.com/spf13/viper"
+
+	"github.com/xsikor/yai/ai"
+	"github.com/xsikor/yai/config"
+	"github.com/xsikor/yai/run"
+	"github.com/xsikor/yai/ui"
+)
+
+// Exit codes of a non-interactive run
+const (
+	// ExitSuccess means the answer or command was printed
+	ExitSuccess = 0
+	// ExitError means the run failed: invalid config, provider error...
+	ExitError = 1
+	// ExitUsage means the flags were invalid, as the flag package does
+	ExitUsage = 2
+	// ExitRefused means no command can be run: the AI could not generate
+	// one, or a command policy denies it
+	ExitRefused = 3
+	// ExitCommandFailed means the command run with -yes failed, its exit
+	// status being printed on stderr
+	ExitCommandFailed = 4
+	// ExitInterrupted means the run was interrupted with ctrl+c
+	ExitInterrupted = 130
+)
+
+// ExecResult is the JSON output of an exec mode run
+type ExecResult struct {
+	Command       string   `json:"cmd"`
+	Explanation   string   `json:"exp"`
+	Executable    bool     `json:"exec"`
+	Provider      string   `json:"provider"`
+	Model         string   `json:"model"`
+	Tokens        ai.Usage `json:"tokens"`
+	Risk          string   `json:"risk"`
+	Policy        string   `json:"policy"`
+	PolicyPattern string   `json:"policy_pattern,omitempty"`
+}
+
+// ChatEvent is a line of the NDJSON output of a chat mode run
+type ChatEvent struct {
+	Type     string    `json:"type"`
+	Provider string    `json:"provider,omitempty"`
+	Model    string    `json:"model,omitempty"`
+	Content  string    `json:"content,omitempty"`
+	Tokens   *ai.Usage `json:"tokens,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Chat event types
+const (
+	StartEvent     = "start"
+	DeltaEvent     = "delta"
+	DoneEvent      = "done"
+	InterruptEvent = "interrupt"
+	ErrorEvent     = "error"
+)
+
+// Runner answers a single prompt without the terminal UI, printing the
+// result in a format scripts can rely on. Without a terminal to confirm
+// with, generated commands only run if asked for with SetYes, or allowed by
+// a command policy.
+type Runner struct {
+	engine *ai.Engine
+	config *config.Config
+	format ui.OutputFormat
+	yes    bool
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func NewRunner(engine *ai.Engine, config *config.Config, format ui.OutputFormat, stdout io.Writer, stderr io.Writer) *Runner {
+	return &Runner{
+		engine: engine,
+		config: config,
+		format: format,
+		stdout: stdout,
+		stderr: stderr,
+	}
+}
+
+// SetYes makes the generated command run without confirmation, unless a
+// command policy denies it
+func (r *Runner) SetYes(yes bool) *Runner {
+	r.yes = yes
+
+	return r
+}
+
+// Run loads the config and answers the prompt of the input, returning the
+// exit code
+func Run(input *ui.UiInput) int {
+	format := input.GetOutputFormat()
+
+	cfg, err := config.NewConfigWithOverrides(input.GetOverrides())
+	if err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
+			err = errors.New("no config file found, run yai once interactively to create it")
+		}
+		return printError(format, os.Stdout, os.Stderr, err)
+	}
+
+	promptMode := input.GetPromptMode()
+	if promptMode == ui.DefaultPromptMode {
+		promptMode = ui.GetPromptModeFromString(cfg.GetUserConfig().GetDefaultPromptMode())
+	}
+
+	engineMode := ai.ChatEngineMode
+	if promptMode == ui.ExecPromptMode {
+		engineMode = ai.ExecEngineMode
+	}
+
+	engine, err := ai.NewEngine(engineMode, cfg)
+	if err != nil {
+		return printError(format, os.Stdout, os.Stderr, err)
+	}
+	if input.GetPipe() != "" {
+		engine.SetPipe(input.GetPipe())
+	}
+	if truncation := input.GetPipeTruncation(); truncation != "" {
+		fmt.Fprintf(os.Stderr, "[pipe] %s\n", truncation)
+	}
+	engine.AttachImages(input.GetPipeImages()...)
+	engine.SetPipeChunks(input.GetPipeChunks(), input.GetConcurrency())
+	// Without -e nor -c, the intent of the prompt decides the mode if clear
+	if input.GetPromptMode() == ui.DefaultPromptMode {
+		engine.DetectMode(input.GetModeInput())
+	}
+
+	// ctrl+c interrupts a streamed answer instead of killing the process, so
+	// the output is properly ended
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	go func() {
+		for range interrupts {
+			if !engine.IsRunning() {
+				os.Exit(ExitInterrupted)
+			}
+			engine.Interrupt()
+		}
+	}()
+
+	return NewRunner(engine, cfg, format, os.Stdout, os.Stderr).
+		SetYes(input.GetYes()).
+		Run(input.GetArgs())
+}
+
+// Run answers the prompt in the mode of the engine, returning the exit code
+func (r *Runner) Run(prompt string) int {
+	if r.engine.GetMode() == ai.ExecEngineMode {
+		return r.runExec(prompt)
+	}
+
+	return r.runChat(prompt)
+}
+
+func (r *Runner) runExec(prompt string) int {
+	output, err := r.engine.ExecCompletion(prompt)
+	if err != nil {
+		return printError(r.format, r.stdout, r.stderr, err)
+	}
+
+	verdict, pattern := r.config.GetCommandPolicy().Check(output.GetCommand())
+	refused := !output.IsExecutable() || verdict == config.PolicyDeny
+
+	if r.format == ui.JsonOutputFormat {
+		r.printJSON(ExecResult{
+			Command:       output.GetCommand(),
+			Explanation:   output.GetExplanation(),
+			Executable:    output.IsExecutable(),
+			Provider:      string(r.config.GetAiConfig().GetProviderType()),
+			Model:         r.config.GetAiConfig().GetModel(),
+			Tokens:        r.engine.GetLastUsage(),
+			Risk:          run.AssessRisk(output.GetCommand()).String(),
+			Policy:        verdict.String(),
+			PolicyPattern: pattern,
+		})
+
+		if refused {
+			return ExitRefused
+		}
+		return ExitSuccess
+	}
+
+	switch {
+	case !output.IsExecutable():
+		fmt.Fprintln(r.stderr, output.GetExplanation())
+		return ExitRefused
+	case verdict == config.PolicyDeny:
+		fmt.Fprintf(r.stderr, "[denied] %s matches the command policy %s\n", output.GetCommand(), pattern)
+		return ExitRefused
+	case verdict.SkipsConfirmation(r.yes):
+		return r.execCommand(output.GetCommand())
+	}
+
+	fmt.Fprintln(r.stdout, output.GetCommand())
+	if r.format == ui.TextOutputFormat {
+		fmt.Fprintln(r.stderr, output.GetExplanation())
+		if verdict == config.PolicyConfirm {
+			fmt.Fprintf(r.stderr, "[not executed] the command matches the policy %s, which always asks for confirmation, even with -yes\n", pattern)
+		} else {
+			fmt.Fprintln(r.stderr, "[not executed] pass -yes to run it")
+		}
+	}
+
+	return ExitSuccess
+}
+
+// execCommand runs a generated command, telling on stderr how it failed if
+// it did
+func (r *Runner) execCommand(command string) int {
+	fmt.Fprintf(r.stderr, "$ %s\n", command)
+
+	cmd := run.PrepareCommand(command)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = r.stdout
+	cmd.Stderr = r.stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			fmt.Fprintf(r.stderr, "[failed] %s\n", exitErr)
+			return ExitCommandFailed
+		}
+		return printError(r.format, r.stdout, r.stderr, err)
+	}
+
+	return ExitSuccess
+}
+
+func (r *Runner) runChat(prompt string) int {
+	aiConfig := r.config.GetAiConfig()
+	if r.format == ui.JsonOutputFormat {
+		r.printJSON(ChatEvent{Type: StartEvent, Provider: string(aiConfig.GetProviderType()), Model: aiConfig.GetModel()})
+	}
+
+	stream := r.engine.ChatStreamCompletion(prompt)
+
+	var content string
+	for {
+		output := stream.Next()
+		content += output.GetContent()
+
+		if output.GetContent() != "" {
+			if r.format == ui.JsonOutputFormat {
+				r.printJSON(ChatEvent{Type: DeltaEvent, Content: output.GetContent()})
+			} else {
+				fmt.Fprint(r.stdout, output.GetContent())
+			}
+		}
+
+		if !output.IsLast() {
+			continue
+		}
+
+		if r.format != ui.JsonOutputFormat && content != "" {
+			fmt.Fprintln(r.stdout)
+		}
+
+		switch {
+		case output.GetError() != nil:
+			return printError(r.format, r.stdout, r.stderr, output.GetError())
+		case output.IsInterrupt():
+			if r.format == ui.JsonOutputFormat {
+				r.printJSON(ChatEvent{Type: InterruptEvent})
+			}
+			return ExitInterrupted
+		default:
+			if r.format == ui.JsonOutputFormat {
+				usage := r.engine.GetLastUsage()
+				r.printJSON(ChatEvent{Type: DoneEvent, Content: content, Tokens: &usage})
+			}
+			return ExitSuccess
+		}
+	}
+}
+
+func (r *Runner) printJSON(value any) {
+	printJSON(r.stdout, value)
+}
+
+func printJSON(w io.Writer, value any) {
+	// Values are plain structs, encoding them cannot fail
+	encoded, _ := json.Marshal(value)
+	fmt.Fprintln(w, string(encoded))
+}
+
+// printError reports an error, as a JSON error event on stdout in JSON
+// format so parsers always get JSON, or on stderr otherwise
+func printError(format ui.OutputFormat, stdout io.Writer, stderr io.Writer, err error) int {
+	if format == ui.JsonOutputFormat {
+		printJSON(stdout, ChatEvent{Type: ErrorEvent, Error: err.Error()})
+	} else {
+		fmt.Fprintf(stderr, "[error] %s\n", err)
+	}
+
+	return ExitError
+}
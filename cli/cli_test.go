@@ -0,0 +1,230 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xsikor/yai/ai"
+	"github.com/xsikor/yai/ai/provider"
+	"github.com/xsikor/yai/config"
+	"github.com/xsikor/yai/internal/testprovider"
+	"github.com/xsikor/yai/ui"
+)
+
+func TestRunner(t *testing.T) {
+	t.Run("ExecJSON", testExecJSON)
+	t.Run("ExecRaw", testExecRaw)
+	t.Run("ExecRefused", testExecRefused)
+	t.Run("ExecHeadless", testExecHeadless)
+	t.Run("ExecYes", testExecYes)
+	t.Run("ExecPolicy", testExecPolicy)
+	t.Run("ChatJSON", testChatJSON)
+	t.Run("ChatRaw", testChatRaw)
+	t.Run("Error", testError)
+}
+
+func runPrompt(mode ai.EngineMode, format ui.OutputFormat, p provider.Provider) (int, string, string) {
+	return runPromptWithYes(mode, format, p, false)
+}
+
+func runPromptWithYes(mode ai.EngineMode, format ui.OutputFormat, p provider.Provider, yes bool) (int, string, string) {
+	return runPromptWithConfig(mode, format, p, yes, &config.Config{})
+}
+
+func runPromptWithConfig(mode ai.EngineMode, format ui.OutputFormat, p provider.Provider, yes bool, cfg *config.Config) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+
+	engine := ai.NewEngineWithProvider(mode, cfg, p)
+	code := NewRunner(engine, cfg, format, &stdout, &stderr).
+		SetYes(yes).
+		Run("list files")
+
+	return code, stdout.String(), stderr.String()
+}
+
+// loadConfig loads the config from a config file with the given content
+func loadConfig(t *testing.T, content string) *config.Config {
+	t.Helper()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "yai.json"), []byte(content), 0o600))
+	viper.Reset()
+	viper.AddConfigPath(dir)
+	t.Cleanup(viper.Reset)
+	t.Setenv("OPENAI_API_KEY", "key")
+
+	cfg, err := config.NewConfig()
+	require.NoError(t, err)
+
+	return cfg
+}
+
+func testExecJSON(t *testing.T) {
+	p := &testprovider.Provider{Chunks: []string{`{"cmd":"rm -rf build", "exp": "remove the build directory", "exec": true}`}}
+
+	code, stdout, stderr := runPrompt(ai.ExecEngineMode, ui.JsonOutputFormat, p)
+	assert.Equal(t, ExitSuccess, code)
+	assert.Empty(t, stderr)
+
+	var result ExecResult
+	require.NoError(t, json.Unmarshal([]byte(stdout), &result))
+	assert.Equal(t, "rm -rf build", result.Command)
+	assert.Equal(t, "remove the build directory", result.Explanation)
+	assert.True(t, result.Executable)
+	assert.Equal(t, "high", result.Risk)
+	assert.Equal(t, "allow", result.Policy)
+	assert.Positive(t, result.Tokens.PromptTokens)
+	assert.Positive(t, result.Tokens.CompletionTokens)
+}
+
+func testExecRaw(t *testing.T) {
+	p := &testprovider.Provider{Chunks: []string{`{"cmd":"ls -la", "exp": "list files", "exec": true}`}}
+
+	code, stdout, stderr := runPrompt(ai.ExecEngineMode, ui.RawOutputFormat, p)
+	assert.Equal(t, ExitSuccess, code)
+	assert.Equal(t, "ls -la\n", stdout)
+	assert.Empty(t, stderr)
+}
+
+func testExecRefused(t *testing.T) {
+	p := &testprovider.Provider{Chunks: []string{`{"cmd":"", "exp": "use the chat mode to discuss", "exec": false}`}}
+
+	code, stdout, stderr := runPrompt(ai.ExecEngineMode, ui.RawOutputFormat, p)
+	assert.Equal(t, ExitRefused, code)
+	assert.Empty(t, stdout)
+	assert.Equal(t, "use the chat mode to discuss\n", stderr)
+
+	code, stdout, _ = runPrompt(ai.ExecEngineMode, ui.JsonOutputFormat, p)
+	assert.Equal(t, ExitRefused, code)
+	assert.Contains(t, stdout, `"exec":false`)
+}
+
+func testExecHeadless(t *testing.T) {
+	p := &testprovider.Provider{Chunks: []string{`{"cmd":"echo hello", "exp": "say hello", "exec": true}`}}
+
+	code, stdout, stderr := runPrompt(ai.ExecEngineMode, ui.TextOutputFormat, p)
+	assert.Equal(t, ExitSuccess, code)
+	assert.Equal(t, "echo hello\n", stdout, "The command should be printed, not run.")
+	assert.Equal(t, "say hello\n[not executed] pass -yes to run it\n", stderr)
+}
+
+func testExecYes(t *testing.T) {
+	p := &testprovider.Provider{Chunks: []string{`{"cmd":"echo hello", "exp": "say hello", "exec": true}`}}
+
+	code, stdout, stderr := runPromptWithYes(ai.ExecEngineMode, ui.TextOutputFormat, p, true)
+	assert.Equal(t, ExitSuccess, code)
+	assert.Equal(t, "hello\n", stdout)
+	assert.Equal(t, "$ echo hello\n", stderr)
+
+	// The exit code of a failed command is not mistaken for the ones of yai
+	p = &testprovider.Provider{Chunks: []string{`{"cmd":"echo failing >&2; exit 3", "exp": "fail", "exec": true}`}}
+
+	code, stdout, stderr = runPromptWithYes(ai.ExecEngineMode, ui.RawOutputFormat, p, true)
+	assert.Equal(t, ExitCommandFailed, code)
+	assert.Empty(t, stdout)
+	assert.Equal(t, "$ echo failing >&2; exit 3\nfailing\n[failed] exit status 3\n", stderr)
+}
+
+func testExecPolicy(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "ran")
+	cfg := loadConfig(t, `{"COMMAND_POLICIES": {"DENY": ["rm"], "CONFIRM": ["mkdir"], "ALLOW": ["echo", "touch"]}}`)
+
+	// Only the allowed commands run without -yes, wherever they hide
+	p := &testprovider.Provider{Chunks: []string{`{"cmd":"echo hi", "exp": "say hi", "exec": true}`}}
+	code, stdout, _ := runPromptWithConfig(ai.ExecEngineMode, ui.RawOutputFormat, p, false, cfg)
+	assert.Equal(t, ExitSuccess, code)
+	assert.Equal(t, "hi\n", stdout)
+
+	for _, command := range []string{
+		"echo hi & mkdir " + marker,
+		"echo $(mkdir " + marker + ")",
+		"echo hi\nmkdir " + marker,
+		"echo hi > " + marker,
+	} {
+		content, err := json.Marshal(map[string]any{"cmd": command, "exp": "say hi", "exec": true})
+		require.NoError(t, err)
+
+		code, stdout, _ := runPromptWithConfig(ai.ExecEngineMode, ui.RawOutputFormat, &testprovider.Provider{Chunks: []string{string(content)}}, false, cfg)
+		assert.Equal(t, ExitSuccess, code, command)
+		assert.Equal(t, command+"\n", stdout, "The command should be printed, not run.")
+		_, err = os.Stat(marker)
+		assert.True(t, os.IsNotExist(err), command)
+	}
+
+	// The commands to confirm are not run, even with -yes
+	p = &testprovider.Provider{Chunks: []string{`{"cmd":"mkdir ` + marker + `", "exp": "make it", "exec": true}`}}
+	code, stdout, stderr := runPromptWithConfig(ai.ExecEngineMode, ui.TextOutputFormat, p, true, cfg)
+	assert.Equal(t, ExitSuccess, code)
+	assert.Equal(t, "mkdir "+marker+"\n", stdout)
+	assert.Contains(t, stderr, "[not executed] the command matches the policy mkdir")
+	assert.NoDirExists(t, marker)
+
+	// Denied commands are not run, even with -yes
+	for _, command := range []string{"touch " + marker + " & rm -rf " + dir, "echo $(rm -rf " + dir + ")", "touch " + marker + "\nrm -rf " + dir} {
+		content, err := json.Marshal(map[string]any{"cmd": command, "exp": "clean", "exec": true})
+		require.NoError(t, err)
+
+		code, _, stderr := runPromptWithConfig(ai.ExecEngineMode, ui.TextOutputFormat, &testprovider.Provider{Chunks: []string{string(content)}}, true, cfg)
+		assert.Equal(t, ExitRefused, code, command)
+		assert.Contains(t, stderr, "[denied]")
+		assert.NoFileExists(t, marker, command)
+		assert.DirExists(t, dir)
+	}
+}
+
+func testChatJSON(t *testing.T) {
+	p := &testprovider.Provider{Chunks: []string{"Hello", " world"}}
+
+	code, stdout, stderr := runPrompt(ai.ChatEngineMode, ui.JsonOutputFormat, p)
+	assert.Equal(t, ExitSuccess, code)
+	assert.Empty(t, stderr)
+
+	var events []ChatEvent
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		var event ChatEvent
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+		events = append(events, event)
+	}
+
+	require.Len(t, events, 4)
+	assert.Equal(t, StartEvent, events[0].Type)
+	assert.Equal(t, ChatEvent{Type: DeltaEvent, Content: "Hello"}, events[1])
+	assert.Equal(t, ChatEvent{Type: DeltaEvent, Content: " world"}, events[2])
+	assert.Equal(t, DoneEvent, events[3].Type)
+	assert.Equal(t, "Hello world", events[3].Content)
+	require.NotNil(t, events[3].Tokens)
+	assert.Equal(t, 3, events[3].Tokens.CompletionTokens)
+}
+
+func testChatRaw(t *testing.T) {
+	p := &testprovider.Provider{Chunks: []string{"# Title\n", "Some *markdown*"}}
+
+	code, stdout, stderr := runPrompt(ai.ChatEngineMode, ui.RawOutputFormat, p)
+	assert.Equal(t, ExitSuccess, code)
+	assert.Equal(t, "# Title\nSome *markdown*\n", stdout)
+	assert.Empty(t, stderr)
+}
+
+func testError(t *testing.T) {
+	p := &testprovider.Provider{Err: errors.New("invalid API key")}
+
+	code, stdout, stderr := runPrompt(ai.ExecEngineMode, ui.JsonOutputFormat, p)
+	assert.Equal(t, ExitError, code)
+	assert.Equal(t, `{"type":"error","error":"invalid API key"}`+"\n", stdout)
+	assert.Empty(t, stderr)
+
+	code, stdout, stderr = runPrompt(ai.ChatEngineMode, ui.RawOutputFormat, p)
+	assert.Equal(t, ExitError, code)
+	assert.Empty(t, stdout)
+	assert.Equal(t, "[error] invalid API key\n", stderr)
+}
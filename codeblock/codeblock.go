@@ -0,0 +1,184 @@
+package codeblock
+
+import (
+	"strings"
+)
+
+// shellLanguages are the languages of the blocks that can be run in a shell,
+// no language meaning a shell snippet as well
+var shellLanguages = []string{"", "sh", "bash", "shell", "zsh", "console", "shell-session", "terminal"}
+
+// Block is a fenced code block of a markdown answer
+type Block struct {
+	language string
+	code     string
+}
+
+func NewBlock(language string, code string) Block {
+	return Block{
+		language: language,
+		code:     code,
+	}
+}
+
+func (b Block) GetLanguage() string {
+	return b.language
+}
+
+func (b Block) GetCode() string {
+	return b.code
+}
+
+// IsShell tells if the block is a shell snippet that can be run
+func (b Block) IsShell() bool {
+	for _, language := range shellLanguages {
+		if b.language == language {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetCommand returns the command line to run the block. Console sessions
+// keep only the lines typed after a "$ " prompt, dropping their output.
+func (b Block) GetCommand() string {
+	if b.language != "console" && b.language != "shell-session" && b.language != "terminal" {
+		return b.code
+	}
+
+	var commands []string
+	for _, line := range strings.Split(b.code, "\n") {
+		if command, ok := strings.CutPrefix(strings.TrimLeft(line, " "), "$ "); ok {
+			commands = append(commands, command)
+		}
+	}
+	if len(commands) == 0 {
+		return b.code
+	}
+
+	return strings.Join(commands, "\n")
+}
+
+// GetSummary returns the first line of the block, to tell blocks apart
+func (b Block) GetSummary() string {
+	summary, _, multiline := strings.Cut(b.GetCommand(), "\n")
+	if multiline {
+		summary += " ..."
+	}
+
+	return summary
+}
+
+// Extract returns the non empty fenced code blocks of markdown content, in
+// order. A block left open at the end of the content runs until the end, as
+// in CommonMark.
+func Extract(content string) []Block {
+	var blocks []Block
+
+	var fence, language string
+	var indent int
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		if fence == "" {
+			fence, language, indent = openingFence(line)
+			lines = nil
+			continue
+		}
+
+		if IsClosingFence(line, fence) {
+			blocks = appendBlock(blocks, language, lines)
+			fence = ""
+			continue
+		}
+		lines = append(lines, trimIndent(line, indent))
+	}
+
+	if fence != "" {
+		blocks = appendBlock(blocks, language, lines)
+	}
+
+	return blocks
+}
+
+// appendBlock adds the block made of the lines, without the blank lines
+// around, unless it is empty as there is nothing to do with it
+func appendBlock(blocks []Block, language string, lines []string) []Block {
+	code := strings.TrimRight(strings.Join(lines, "\n"), " \t\n")
+	code = strings.TrimLeft(code, "\n")
+	if strings.TrimSpace(code) == "" {
+		return blocks
+	}
+
+	return append(blocks, NewBlock(language, code))
+}
+
+// OpeningFence returns the fence opened by the line, like "```" or "~~~",
+// or an empty string if the line does not open a code block
+func OpeningFence(line string) string {
+	fence, _, _ := openingFence(line)
+
+	return fence
+}
+
+// openingFence returns the fence opening a block on the line, "```" or
+// "~~~" possibly longer, the language of the block and the indentation of
+// the fence, or an empty fence if the line does not open a block
+func openingFence(line string) (string, string, int) {
+	trimmed := strings.TrimLeft(line, " ")
+	indent := len(line) - len(trimmed)
+	if indent > 3 {
+		return "", "", 0
+	}
+
+	fence := fenceOf(trimmed)
+	if fence == "" {
+		return "", "", 0
+	}
+
+	info := strings.TrimSpace(trimmed[len(fence):])
+	// Backtick fences cannot have backticks in their info string
+	if fence[0] == '`' && strings.Contains(info, "`") {
+		return "", "", 0
+	}
+
+	language, _, _ := strings.Cut(info, " ")
+
+	return fence, strings.ToLower(language), indent
+}
+
+// trimIndent removes up to indent leading spaces of the line, the content
+// of an indented block being indented as its fence
+func trimIndent(line string, indent int) string {
+	for i := 0; i < indent && strings.HasPrefix(line, " "); i++ {
+		line = line[1:]
+	}
+
+	return line
+}
+
+// IsClosingFence tells if the line closes the block opened by fence: the
+// same character, at least as many times, and nothing else
+func IsClosingFence(line string, fence string) bool {
+	trimmed := strings.TrimSpace(line)
+	closing := fenceOf(trimmed)
+
+	return closing != "" && closing == trimmed && closing[0] == fence[0] && len(closing) >= len(fence)
+}
+
+// fenceOf returns the fence starting the text, if any
+func fenceOf(text string) string {
+	if len(text) < 3 || (text[0] != '`' && text[0] != '~') {
+		return ""
+	}
+
+	end := 0
+	for end < len(text) && text[end] == text[0] {
+		end++
+	}
+	if end < 3 {
+		return ""
+	}
+
+	return text[:end]
+}
@@ -0,0 +1,83 @@
+package codeblock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodeBlock(t *testing.T) {
+	t.Run("Extract", testExtract)
+	t.Run("ExtractFences", testExtractFences)
+	t.Run("ExtractUnclosed", testExtractUnclosed)
+	t.Run("IsShell", testIsShell)
+	t.Run("GetCommand", testGetCommand)
+	t.Run("GetSummary", testGetSummary)
+}
+
+func testExtract(t *testing.T) {
+	content := "Check the pod first:\n\n```bash\nkubectl get pods\n```\n\nThen its logs:\n\n```\nkubectl logs my-pod\nkubectl describe pod my-pod\n```\n\nThe manifest:\n\n```yaml\napiVersion: v1\n```\n"
+
+	blocks := Extract(content)
+	require.Len(t, blocks, 3)
+
+	assert.Equal(t, NewBlock("bash", "kubectl get pods"), blocks[0])
+	assert.Equal(t, NewBlock("", "kubectl logs my-pod\nkubectl describe pod my-pod"), blocks[1])
+	assert.Equal(t, NewBlock("yaml", "apiVersion: v1"), blocks[2])
+
+	assert.Empty(t, Extract("no code here, only `inline` code"))
+
+	// Indentation is kept, blank lines around are not
+	blocks = Extract("```yaml\n\n  name: web\n  image: nginx\n\n```")
+	require.Len(t, blocks, 1)
+	assert.Equal(t, "  name: web\n  image: nginx", blocks[0].GetCode())
+}
+
+func testExtractFences(t *testing.T) {
+	// A longer fence can hold a shorter one, tildes can hold backticks
+	blocks := Extract("````markdown\n```sh\nls\n```\n````\n~~~ Shell title\necho ```\n~~~\n")
+	require.Len(t, blocks, 2)
+	assert.Equal(t, NewBlock("markdown", "```sh\nls\n```"), blocks[0])
+	assert.Equal(t, NewBlock("shell", "echo ```"), blocks[1])
+
+	// Indented by 4 spaces, the fence is code, not a fence
+	assert.Empty(t, Extract("    ```sh\n    ls\n    ```\n"))
+
+	// Up to 3 spaces are fine
+	blocks = Extract("  ```sh\n  ls\n  ```\n")
+	require.Len(t, blocks, 1)
+	assert.Equal(t, "ls", blocks[0].GetCode())
+}
+
+func testExtractUnclosed(t *testing.T) {
+	blocks := Extract("Run this:\n\n```sh\nmake build\nmake test")
+	require.Len(t, blocks, 1)
+	assert.Equal(t, NewBlock("sh", "make build\nmake test"), blocks[0])
+
+	assert.Empty(t, Extract("```sh\n"))
+}
+
+func testIsShell(t *testing.T) {
+	assert.True(t, NewBlock("", "ls").IsShell())
+	assert.True(t, NewBlock("bash", "ls").IsShell())
+	assert.True(t, NewBlock("console", "$ ls").IsShell())
+	assert.False(t, NewBlock("python", "print(1)").IsShell())
+	assert.False(t, NewBlock("yaml", "a: b").IsShell())
+}
+
+func testGetCommand(t *testing.T) {
+	assert.Equal(t, "ls\npwd", NewBlock("bash", "ls\npwd").GetCommand())
+
+	console := NewBlock("console", "$ kubectl get pods\nNAME    READY\nweb-1   1/1\n  $ kubectl logs web-1")
+	assert.Equal(t, "kubectl get pods\nkubectl logs web-1", console.GetCommand())
+
+	// Without prompts, the whole console block is the command
+	assert.Equal(t, "ls", NewBlock("console", "ls").GetCommand())
+}
+
+func testGetSummary(t *testing.T) {
+	assert.Equal(t, "ls", NewBlock("sh", "ls").GetSummary())
+	assert.Equal(t, "make build ...", NewBlock("sh", "make build\nmake test").GetSummary())
+	assert.Equal(t, "ls", NewBlock("console", "$ ls\nmain.go").GetSummary())
+}
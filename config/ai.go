@@ -26,6 +26,7 @@ type AiConfig struct {
 	proxy        string
 	temperature  float64
 	maxTokens    int
+	sources      map[string]string
 }
 
 func (c AiConfig) GetProviderType() provider.ProviderType {
@@ -36,6 +37,11 @@ func (c AiConfig) GetKey() string {
 	return c.key
 }
 
+// GetKeySource describes where the API key came from, without revealing it
+func (c AiConfig) GetKeySource() string {
+	return c.GetSource(SettingKey)
+}
+
 func (c AiConfig) GetModel() string {
 	return c.model
 }
@@ -51,3 +57,8 @@ func (c AiConfig) GetTemperature() float64 {
 func (c AiConfig) GetMaxTokens() int {
 	return c.maxTokens
 }
+
+// GetSource describes where the effective value of a setting came from
+func (c AiConfig) GetSource(setting string) string {
+	return c.sources[setting]
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// Key of the custom slash commands, and of their settings
+	commands            = "COMMANDS"
+	command_description = "DESCRIPTION"
+	command_template    = "TEMPLATE"
+	command_mode        = "MODE"
+
+	// Directory holding one custom command per markdown file
+	commands_directory = "commands"
+)
+
+// CustomCommand is a user-defined slash command expanding into a prompt
+type CustomCommand struct {
+	name        string
+	description string
+	template    string
+	mode        string
+}
+
+func (c CustomCommand) GetName() string {
+	return c.name
+}
+
+func (c CustomCommand) GetDescription() string {
+	return c.description
+}
+
+// GetTemplate returns the prompt template, with {{input}}, {{pipe}}, {{cwd}}
+// and {{last_output}} placeholders
+func (c CustomCommand) GetTemplate() string {
+	return c.template
+}
+
+// GetMode returns the mode the prompt is run in, "chat" or "exec", or empty
+// to keep the current one
+func (c CustomCommand) GetMode() string {
+	return c.mode
+}
+
+// loadCustomCommands reads the commands of the config file, then the ones of
+// the commands directory, then the ones of the project config, each taking
+// precedence over the previous ones. They are sorted by name.
+func loadCustomCommands(directory string, project *viper.Viper) ([]CustomCommand, error) {
+	byName := make(map[string]CustomCommand)
+
+	if err := readConfigCommands(viper.GetViper(), byName); err != nil {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(directory, commands_directory, "*.md"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		command, err := readCustomCommandFile(path)
+		if err != nil {
+			return nil, err
+		}
+		byName[command.name] = command
+	}
+
+	if project != nil {
+		if err := readConfigCommands(project, byName); err != nil {
+			return nil, err
+		}
+	}
+
+	customCommands := make([]CustomCommand, 0, len(byName))
+	for _, command := range byName {
+		customCommands = append(customCommands, command)
+	}
+
+	sort.Slice(customCommands, func(i, j int) bool {
+		return customCommands[i].name < customCommands[j].name
+	})
+
+	return customCommands, nil
+}
+
+// readConfigCommands reads the commands defined under the COMMANDS key
+func readConfigCommands(reader *viper.Viper, byName map[string]CustomCommand) error {
+	for name := range reader.GetStringMap(commands) {
+		key := fmt.Sprintf("%s.%s.", commands, name)
+
+		command, err := newCustomCommand(
+			name,
+			reader.GetString(key+command_description),
+			reader.GetString(key+command_template),
+			reader.GetString(key+command_mode),
+		)
+		if err != nil {
+			return err
+		}
+		byName[command.name] = command
+	}
+
+	return nil
+}
+
+func newCustomCommand(name string, description string, template string, mode string) (CustomCommand, error) {
+	name = strings.ToLower(name)
+	mode = strings.ToLower(strings.TrimSpace(mode))
+
+	if name == "" || strings.ContainsAny(name, " /") {
+		return CustomCommand{}, fmt.Errorf("invalid custom command name: %q", name)
+	}
+	if strings.TrimSpace(template) == "" {
+		return CustomCommand{}, fmt.Errorf("custom command /%s has an empty template", name)
+	}
+	if mode != "" && mode != "chat" && mode != "exec" {
+		return CustomCommand{}, fmt.Errorf("custom command /%s has an unsupported mode: %s", name, mode)
+	}
+	if description == "" {
+		description = "Custom command"
+	}
+
+	return CustomCommand{
+		name:        name,
+		description: description,
+		template:    template,
+		mode:        mode,
+	}, nil
+}
+
+// readCustomCommandFile reads a command named after its file, whose content
+// is the template, optionally preceded by a front matter such as:
+//
+//	---
+//	description: Triage a failing pod
+//	mode: chat
+//	---
+func readCustomCommandFile(path string) (CustomCommand, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return CustomCommand{}, fmt.Errorf("custom command %s: %w", path, err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	description, mode := "", ""
+	template := string(content)
+
+	if rest, ok := strings.CutPrefix(template, "---\n"); ok {
+		frontMatter, body, found := strings.Cut(rest, "\n---\n")
+		if !found {
+			return CustomCommand{}, fmt.Errorf("custom command %s: unterminated front matter", path)
+		}
+
+		scanner := bufio.NewScanner(strings.NewReader(frontMatter))
+		for scanner.Scan() {
+			key, value, _ := strings.Cut(scanner.Text(), ":")
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "description":
+				description = strings.TrimSpace(value)
+			case "mode":
+				mode = strings.TrimSpace(value)
+			}
+		}
+		template = body
+	}
+
+	return newCustomCommand(name, description, strings.TrimSpace(template), mode)
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomCommands(t *testing.T) {
+	t.Run("FromConfig", testCustomCommandsFromConfig)
+	t.Run("FromFiles", testCustomCommandsFromFiles)
+	t.Run("Invalid", testInvalidCustomCommands)
+}
+
+func writeCommandFile(t *testing.T, directory string, name string, content string) {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(directory, commands_directory), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(directory, commands_directory, name), []byte(content), 0o600))
+}
+
+func testCustomCommandsFromConfig(t *testing.T) {
+	setupProfiles(t, `{
+		"COMMANDS": {
+			"Review": {"DESCRIPTION": "Review a diff", "TEMPLATE": "Review this:\n{{pipe}}", "MODE": "chat"},
+			"explain": {"TEMPLATE": "Explain {{input}}"}
+		}
+	}`)
+	viper.SetConfigName("yai")
+	require.NoError(t, viper.ReadInConfig())
+
+	customCommands, err := loadCustomCommands(t.TempDir(), nil)
+	require.NoError(t, err)
+	require.Len(t, customCommands, 2)
+
+	assert.Equal(t, "explain", customCommands[0].GetName())
+	assert.Equal(t, "Custom command", customCommands[0].GetDescription())
+	assert.Equal(t, "", customCommands[0].GetMode())
+
+	assert.Equal(t, "review", customCommands[1].GetName())
+	assert.Equal(t, "Review a diff", customCommands[1].GetDescription())
+	assert.Equal(t, "Review this:\n{{pipe}}", customCommands[1].GetTemplate())
+	assert.Equal(t, "chat", customCommands[1].GetMode())
+}
+
+func testCustomCommandsFromFiles(t *testing.T) {
+	setupProfiles(t, `{"COMMANDS": {"triage": {"TEMPLATE": "from config"}}}`)
+	viper.SetConfigName("yai")
+	require.NoError(t, viper.ReadInConfig())
+
+	directory := t.TempDir()
+	writeCommandFile(t, directory, "triage.md", "---\ndescription: Triage a failing pod\nmode: exec\n---\nFind why {{input}} fails\n")
+	writeCommandFile(t, directory, "logs.md", "Analyze these logs:\n{{last_output}}\n")
+	writeCommandFile(t, directory, "notes.txt", "not a command")
+
+	customCommands, err := loadCustomCommands(directory, nil)
+	require.NoError(t, err)
+	require.Len(t, customCommands, 2)
+
+	assert.Equal(t, "logs", customCommands[0].GetName())
+	assert.Equal(t, "Analyze these logs:\n{{last_output}}", customCommands[0].GetTemplate())
+
+	assert.Equal(t, "triage", customCommands[1].GetName(), "Files should take precedence over the config.")
+	assert.Equal(t, "Triage a failing pod", customCommands[1].GetDescription())
+	assert.Equal(t, "Find why {{input}} fails", customCommands[1].GetTemplate())
+	assert.Equal(t, "exec", customCommands[1].GetMode())
+}
+
+func testInvalidCustomCommands(t *testing.T) {
+	setupProfiles(t, `{}`)
+
+	testCases := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"mode.md", "---\nmode: shell\n---\nprompt", "unsupported mode: shell"},
+		{"empty.md", "---\ndescription: nothing\n---\n", "empty template"},
+		{"open.md", "---\ndescription: never closed\nprompt", "unterminated front matter"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			directory := t.TempDir()
+			writeCommandFile(t, directory, tc.name, tc.content)
+
+			_, err := loadCustomCommands(directory, nil)
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+}
@@ -12,9 +12,26 @@ import (
 )
 
 type Config struct {
-	ai     AiConfig
-	user   UserConfig
-	system *system.Analysis
+	profile   string
+	overrides Overrides
+	ai        AiConfig
+	user      UserConfig
+	commands  []CustomCommand
+	system    *system.Analysis
+	workspace *system.Workspace
+	project   string
+	policy    CommandPolicy
+}
+
+// GetProfile returns the name of the profile this config was loaded from
+func (c *Config) GetProfile() string {
+	return c.profile
+}
+
+// GetOverrides returns the overrides this config was loaded with, so it can
+// be reloaded the same way
+func (c *Config) GetOverrides() Overrides {
+	return c.overrides
 }
 
 func (c *Config) GetAiConfig() AiConfig {
@@ -25,11 +42,48 @@ func (c *Config) GetUserConfig() UserConfig {
 	return c.user
 }
 
+// GetCustomCommands returns the user-defined slash commands, sorted by name
+func (c *Config) GetCustomCommands() []CustomCommand {
+	return c.commands
+}
+
 func (c *Config) GetSystemConfig() *system.Analysis {
 	return c.system
 }
 
+// GetProjectFile returns the path of the project config file merged over the
+// user config, or empty if there is none
+func (c *Config) GetProjectFile() string {
+	return c.project
+}
+
+// GetCommandPolicy returns the restrictions on the commands generated in
+// exec mode, from the user and project configs
+func (c *Config) GetCommandPolicy() CommandPolicy {
+	return c.policy
+}
+
+// GetWorkspace returns the project of the working directory, or nil if the
+// workspace context is disabled
+func (c *Config) GetWorkspace() *system.Workspace {
+	return c.workspace
+}
+
+// NewConfig loads the config for the profile selected by the YAI_PROFILE env
+// var or the DEFAULT_PROFILE key, falling back to the default profile.
 func NewConfig() (*Config, error) {
+	return NewConfigWithOverrides(Overrides{})
+}
+
+// NewConfigForProfile loads the config for the given profile. An empty name
+// selects the profile the same way NewConfig does.
+func NewConfigForProfile(name string) (*Config, error) {
+	return NewConfigWithOverrides(Overrides{Profile: name})
+}
+
+// NewConfigWithOverrides loads the config in layers: defaults, then the
+// config file for the selected profile, then env vars, then the overrides.
+func NewConfigWithOverrides(overrides Overrides) (*Config, error) {
 	system := system.Analyse()
 
 	viper.SetConfigName(strings.ToLower(system.GetApplicationName()))
@@ -39,80 +93,179 @@ func NewConfig() (*Config, error) {
 		return nil, err
 	}
 
-	// Check for provider configuration
-	var providerType provider.ProviderType
-	if viper.IsSet(ai_provider) {
-		providerType = provider.ProviderType(viper.GetString(ai_provider))
-	} else {
-		// Default to OpenAI for backward compatibility
-		providerType = provider.ProviderOpenAI
+	name := resolveProfileName(overrides.Profile)
+	reader, err := newProfileReader(name)
+	if err != nil {
+		return nil, err
 	}
 
-	// Get API key based on provider
-	var apiKey string
-	if viper.IsSet(ai_key) {
-		apiKey = viper.GetString(ai_key)
-	} else {
-		// Fall back to legacy OpenAI key for backward compatibility
-		apiKey = viper.GetString(openai_key)
+	aiConfig, err := resolveAiConfig(name, reader, overrides)
+	if err != nil {
+		return nil, err
 	}
 
-	// Get model based on provider
-	var model string
-	if viper.IsSet(ai_model) {
-		model = viper.GetString(ai_model)
-	} else {
-		// Fall back to legacy OpenAI model for backward compatibility
-		model = viper.GetString(openai_model)
+	project, err := loadProjectConfig()
+	if err != nil {
+		return nil, err
 	}
 
-	// Get other settings with new keys, falling back to legacy keys
-	var proxy string
-	if viper.IsSet(ai_proxy) {
-		proxy = viper.GetString(ai_proxy)
-	} else {
-		proxy = viper.GetString(openai_proxy)
+	customCommands, err := loadCustomCommands(system.GetConfigDirectory(), project)
+	if err != nil {
+		return nil, err
 	}
 
-	var temperature float64
-	if viper.IsSet(ai_temperature) {
-		temperature = viper.GetFloat64(ai_temperature)
-	} else {
-		temperature = viper.GetFloat64(openai_temperature)
+	userConfig, err := mergeProjectUserConfig(UserConfig{
+		defaultPromptMode: reader.GetString(user_default_prompt_mode),
+		preferences:       reader.GetString(user_preferences),
+		workspaceContext:  reader.GetBool(user_workspace_context),
+		fullscreen:        reader.GetBool(user_fullscreen),
+		theme:             reader.GetString(user_theme),
+		intentThreshold:   reader.GetFloat64(user_intent_threshold),
+		intentModel:       reader.GetString(user_intent_model),
+		systemPrompt:      reader.GetString(system_prompt),
+	}, project)
+	if err != nil {
+		return nil, err
 	}
 
-	var maxTokens int
-	if viper.IsSet(ai_max_tokens) {
-		maxTokens = viper.GetInt(ai_max_tokens)
-	} else {
-		maxTokens = viper.GetInt(openai_max_tokens)
+	projectFile := ""
+	if project != nil {
+		projectFile = project.ConfigFileUsed()
 	}
 
 	return &Config{
-		ai: AiConfig{
-			providerType: providerType,
-			key:          apiKey,
-			model:        model,
-			proxy:        proxy,
-			temperature:  temperature,
-			maxTokens:    maxTokens,
-		},
-		user: UserConfig{
-			defaultPromptMode: viper.GetString(user_default_prompt_mode),
-			preferences:       viper.GetString(user_preferences),
-		},
-		system: system,
+		profile:   name,
+		overrides: overrides,
+		ai:        aiConfig,
+		user:      userConfig,
+		commands:  customCommands,
+		system:    system,
+		workspace: loadWorkspace(userConfig),
+		project:   projectFile,
+		policy:    readCommandPolicy(viper.GetViper()).merge(readCommandPolicy(project)),
 	}, nil
 }
 
+// WithModel returns the config using another model for the rest of the
+// session, keeping the other overrides.
+func (c *Config) WithModel(model string) (*Config, error) {
+	overrides := c.overrides.withSource(SettingModel, "/model")
+	overrides.Model = model
+
+	return NewConfigWithOverrides(overrides)
+}
+
+// WithProvider returns the config using another provider for the rest of the
+// session. The model is reset to the one configured for that provider, or
+// its default one.
+func (c *Config) WithProvider(providerType provider.ProviderType) (*Config, error) {
+	overrides := c.overrides.withSource(SettingProvider, "/provider")
+	overrides.ProviderType = providerType
+	overrides.Model = ""
+
+	return NewConfigWithOverrides(overrides)
+}
+
+// Persist writes the provider and model in use to the current profile of the
+// config file, and returns the config reloaded without overriding them.
+func (c *Config) Persist() (*Config, error) {
+	reader, err := newProfileReader(c.profile)
+	if err != nil {
+		return nil, err
+	}
+
+	// The file's key belongs to the file's provider, it must not be reused
+	// for another one
+	fileProvider := provider.ProviderType(reader.GetString(ai_provider))
+	if fileProvider == "" {
+		fileProvider = provider.ProviderOpenAI
+	}
+	if c.ai.providerType != fileProvider && hasFileKey(reader) {
+		return nil, fmt.Errorf(
+			"the config file holds the API key of %s, add a profile for %s instead",
+			fileProvider,
+			c.ai.providerType,
+		)
+	}
+
+	prefix := ""
+	if reader.profile != nil {
+		prefix = fmt.Sprintf("%s.%s.", profiles, c.profile)
+	}
+
+	viper.Set(prefix+ai_provider, string(c.ai.providerType))
+	viper.Set(prefix+ai_model, c.ai.model)
+
+	// Set legacy config for backward compatibility
+	if prefix == "" && c.ai.providerType == provider.ProviderOpenAI {
+		viper.Set(openai_model, c.ai.model)
+	}
+
+	if err := viper.WriteConfig(); err != nil {
+		return nil, err
+	}
+
+	overrides := c.overrides
+	overrides.ProviderType = ""
+	overrides.Model = ""
+
+	return NewConfigWithOverrides(overrides)
+}
+
 func WriteConfig(providerType provider.ProviderType, key string, model string, write bool) (*Config, error) {
+	// Set AI key, the rest is shared with configs written without it
+	viper.Set(ai_key, key)
+
+	// Set legacy config for backward compatibility
+	if providerType == provider.ProviderOpenAI {
+		viper.Set(openai_key, key)
+	}
+
+	if err := writeConfig(providerType, model, write); err != nil {
+		return nil, err
+	}
+
+	return NewConfig()
+}
+
+// WriteConfigWithKeyring writes the config like WriteConfig, the API key
+// being stored in the OS keyring instead of the file
+func WriteConfigWithKeyring(providerType provider.ProviderType, key string, model string, write bool) (*Config, error) {
+	account, err := storeKeyringKey(providerType, key)
+	if err != nil {
+		return nil, err
+	}
+	viper.Set(ai_key_keyring, account)
+
+	if err := writeConfig(providerType, model, write); err != nil {
+		return nil, err
+	}
+
+	return NewConfig()
+}
+
+// WriteConfigWithoutKey writes the config like WriteConfig, but never puts
+// the API key in the file. The key is then resolved from the environment or
+// a key command, unless a session key is given to be kept in memory only.
+func WriteConfigWithoutKey(providerType provider.ProviderType, sessionKey string, model string, write bool) (*Config, error) {
+	if err := writeConfig(providerType, model, write); err != nil {
+		return nil, err
+	}
+
+	if sessionKey != "" {
+		sessionKeys[providerType] = sessionKey
+	}
+
+	return NewConfig()
+}
+
+func writeConfig(providerType provider.ProviderType, model string, write bool) error {
 	system := system.Analyse()
 
 	// Set provider type
 	viper.Set(ai_provider, string(providerType))
 
 	// Set AI config values
-	viper.Set(ai_key, key)
 	viper.Set(ai_model, model)
 	viper.SetDefault(ai_proxy, "")
 	viper.SetDefault(ai_temperature, 0.2)
@@ -120,22 +273,23 @@ func WriteConfig(providerType provider.ProviderType, key string, model string, w
 
 	// Set legacy config for backward compatibility
 	if providerType == provider.ProviderOpenAI {
-		viper.Set(openai_key, key)
 		viper.Set(openai_model, model)
 	}
 
 	// user defaults - chat mode is the default
 	viper.SetDefault(user_default_prompt_mode, "chat")
 	viper.SetDefault(user_preferences, "")
+	viper.SetDefault(user_workspace_context, false)
+	viper.SetDefault(user_fullscreen, false)
 
 	if write {
-		err := viper.SafeWriteConfigAs(system.GetConfigFile())
-		if err != nil {
-			return nil, err
-		}
+		// The file may hold secrets, keep it private
+		viper.SetConfigPermissions(0o600)
+
+		return viper.SafeWriteConfigAs(system.GetConfigFile())
 	}
 
-	return NewConfig()
+	return nil
 }
 
 // Helper method to get default model for a provider
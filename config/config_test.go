@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -9,6 +10,7 @@ import (
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/zalando/go-keyring"
 
 	"github.com/xsikor/yai/ai/provider"
 	"github.com/xsikor/yai/system"
@@ -17,6 +19,13 @@ import (
 func TestConfig(t *testing.T) {
 	t.Run("NewConfig", testNewConfig)
 	t.Run("WriteConfig", testWriteConfig)
+	t.Run("WriteConfigWithoutKey", testWriteConfigWithoutKey)
+	t.Run("WriteConfigWithKeyring", testWriteConfigWithKeyring)
+	t.Run("WithModel", testWithModel)
+	t.Run("WithProvider", testWithProvider)
+	t.Run("Persist", testPersist)
+	t.Run("PersistOtherProvider", testPersistOtherProvider)
+	t.Run("WorkspaceContext", testWorkspaceContext)
 }
 
 func setupViper(t *testing.T) {
@@ -32,6 +41,8 @@ func setupViper(t *testing.T) {
 	viper.Set(openai_max_tokens, 2000)
 	viper.Set(user_default_prompt_mode, "exec")
 	viper.Set(user_preferences, "test_preferences")
+	viper.Set(user_intent_threshold, 2.5)
+	viper.Set(user_intent_model, "gpt-4o-mini")
 
 	require.NoError(t, viper.SafeWriteConfigAs("/tmp/yai.json"))
 }
@@ -55,8 +66,12 @@ func testNewConfig(t *testing.T) {
 	assert.Equal(t, 2000, cfg.GetAiConfig().GetMaxTokens())
 	assert.Equal(t, "exec", cfg.GetUserConfig().GetDefaultPromptMode())
 	assert.Equal(t, "test_preferences", cfg.GetUserConfig().GetPreferences())
+	assert.False(t, cfg.GetUserConfig().IsWorkspaceContext())
+	assert.Equal(t, 2.5, cfg.GetUserConfig().GetIntentThreshold())
+	assert.Equal(t, "gpt-4o-mini", cfg.GetUserConfig().GetIntentModel())
 
 	assert.NotNil(t, cfg.GetSystemConfig())
+	assert.Nil(t, cfg.GetWorkspace())
 }
 
 func testWriteConfig(t *testing.T) {
@@ -85,3 +100,139 @@ func testWriteConfig(t *testing.T) {
 	assert.Equal(t, "exec", viper.GetString(user_default_prompt_mode))
 	assert.Equal(t, "test_preferences", viper.GetString(user_preferences))
 }
+
+func testWriteConfigWithoutKey(t *testing.T) {
+	setupProfiles(t, `{"AI_PROVIDER": "claude"}`)
+	t.Cleanup(func() { delete(sessionKeys, provider.ProviderClaude) })
+
+	cfg, err := WriteConfigWithoutKey(provider.ProviderClaude, "session_key", "claude-3-opus-20240229", false)
+	require.NoError(t, err)
+
+	assert.Equal(t, "session_key", cfg.GetAiConfig().GetKey())
+	assert.Equal(t, "session", cfg.GetAiConfig().GetKeySource())
+	assert.False(t, viper.IsSet(ai_key), "The session key should never reach the config file.")
+}
+
+func testWriteConfigWithKeyring(t *testing.T) {
+	keyring.MockInit()
+	setupProfiles(t, `{"AI_PROVIDER": "claude"}`)
+	t.Cleanup(func() { delete(resolvedKeys, "keyring:claude") })
+
+	cfg, err := WriteConfigWithKeyring(provider.ProviderClaude, "keyring_key", "claude-3-opus-20240229", false)
+	require.NoError(t, err)
+
+	assert.Equal(t, "keyring_key", cfg.GetAiConfig().GetKey())
+	assert.Equal(t, "keyring claude", cfg.GetAiConfig().GetKeySource())
+	assert.Equal(t, "claude", viper.GetString(ai_key_keyring))
+	assert.False(t, viper.IsSet(ai_key), "The keyring key should never reach the config file.")
+
+	key, err := keyring.Get(KeyringService, "claude")
+	require.NoError(t, err)
+	assert.Equal(t, "keyring_key", key)
+}
+
+func testWithModel(t *testing.T) {
+	setupProfiles(t, profilesConfig)
+
+	cfg, err := NewConfigForProfile("")
+	require.NoError(t, err)
+
+	cfg, err = cfg.WithModel("gpt-4-turbo")
+	require.NoError(t, err)
+
+	assert.Equal(t, "gpt-4-turbo", cfg.GetAiConfig().GetModel())
+	assert.Equal(t, "/model", cfg.GetAiConfig().GetSource(SettingModel))
+	assert.Equal(t, "openai_key", cfg.GetAiConfig().GetKey())
+}
+
+func testWithProvider(t *testing.T) {
+	setupProfiles(t, profilesConfig)
+	t.Setenv("GEMINI_API_KEY", "gemini_key")
+
+	cfg, err := NewConfigForProfile("")
+	require.NoError(t, err)
+
+	cfg, err = cfg.WithProvider(provider.ProviderGemini)
+	require.NoError(t, err)
+
+	assert.Equal(t, provider.ProviderGemini, cfg.GetAiConfig().GetProviderType())
+	assert.Equal(t, "/provider", cfg.GetAiConfig().GetSource(SettingProvider))
+	assert.Equal(t, GetDefaultModelForProvider(provider.ProviderGemini), cfg.GetAiConfig().GetModel())
+	assert.Equal(t, "gemini_key", cfg.GetAiConfig().GetKey())
+
+	// Switching back uses the file's model and key again
+	cfg, err = cfg.WithProvider(provider.ProviderOpenAI)
+	require.NoError(t, err)
+
+	assert.Equal(t, "gpt-4", cfg.GetAiConfig().GetModel())
+	assert.Equal(t, "openai_key", cfg.GetAiConfig().GetKey())
+}
+
+func testPersist(t *testing.T) {
+	setupProfiles(t, profilesConfig)
+
+	cfg, err := NewConfigForProfile("local")
+	require.NoError(t, err)
+
+	cfg, err = cfg.WithModel("gpt-4-turbo")
+	require.NoError(t, err)
+
+	cfg, err = cfg.Persist()
+	require.NoError(t, err)
+
+	assert.Equal(t, "gpt-4-turbo", cfg.GetAiConfig().GetModel())
+	assert.Equal(t, "config file, profile local", cfg.GetAiConfig().GetSource(SettingModel))
+
+	// The file is read again from scratch
+	file := viper.ConfigFileUsed()
+	viper.Reset()
+	viper.AddConfigPath(filepath.Dir(file))
+
+	cfg, err = NewConfigForProfile("local")
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4-turbo", cfg.GetAiConfig().GetModel())
+
+	cfg, err = NewConfigForProfile("")
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4", cfg.GetAiConfig().GetModel(), "The other profiles should be left untouched.")
+}
+
+func testPersistOtherProvider(t *testing.T) {
+	setupProfiles(t, profilesConfig)
+	t.Setenv("GEMINI_API_KEY", "gemini_key")
+
+	cfg, err := NewConfigForProfile("")
+	require.NoError(t, err)
+
+	cfg, err = cfg.WithProvider(provider.ProviderGemini)
+	require.NoError(t, err)
+
+	_, err = cfg.Persist()
+	assert.ErrorContains(t, err, "holds the API key of openai")
+}
+
+func testWorkspaceContext(t *testing.T) {
+	setupViper(t)
+	defer cleanup(t)
+
+	viper.Set(user_workspace_context, true)
+	t.Cleanup(func() { viper.Set(user_workspace_context, false) })
+
+	cfg, err := NewConfig()
+	require.NoError(t, err)
+
+	directory, err := os.Getwd()
+	require.NoError(t, err)
+
+	assert.True(t, cfg.GetUserConfig().IsWorkspaceContext())
+	require.NotNil(t, cfg.GetWorkspace())
+	assert.Equal(t, directory, cfg.GetWorkspace().GetDirectory())
+
+	// The analysis is reused when reloading the config, with a fresh git
+	// status
+	reloaded, err := cfg.WithModel(openai.GPT4)
+	require.NoError(t, err)
+	assert.NotSame(t, cfg.GetWorkspace(), reloaded.GetWorkspace())
+	assert.Equal(t, cfg.GetWorkspace().GetTools(), reloaded.GetWorkspace().GetTools())
+	assert.Equal(t, cfg.GetWorkspace().GetGitRoot(), reloaded.GetWorkspace().GetGitRoot())
+}
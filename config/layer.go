@@ -0,0 +1,195 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/xsikor/yai/ai/provider"
+)
+
+const (
+	// Environment variables overriding the config file
+	provider_env    = "YAI_PROVIDER"
+	model_env       = "YAI_MODEL"
+	proxy_env       = "YAI_PROXY"
+	temperature_env = "YAI_TEMPERATURE"
+	max_tokens_env  = "YAI_MAX_TOKENS"
+
+	// Defaults used when no layer sets a value
+	default_temperature = 0.2
+	default_max_tokens  = 1000
+)
+
+// Names of the AI settings, used to tell where their value came from
+const (
+	SettingProvider    = "provider"
+	SettingKey         = "key"
+	SettingModel       = "model"
+	SettingProxy       = "proxy"
+	SettingTemperature = "temperature"
+	SettingMaxTokens   = "max_tokens"
+)
+
+// Overrides are per-invocation settings, typically from CLI flags. They take
+// precedence over the environment and the config file, and are never written.
+type Overrides struct {
+	Profile      string
+	ProviderType provider.ProviderType
+	Model        string
+	Temperature  *float64
+	MaxTokens    *int
+
+	// sources tells where overrides set after startup come from, the others
+	// being CLI flags
+	sources map[string]string
+}
+
+// withSource returns a copy of the overrides recording the source of a setting
+func (o Overrides) withSource(setting string, source string) Overrides {
+	sources := make(map[string]string, len(o.sources)+1)
+	for key, value := range o.sources {
+		sources[key] = value
+	}
+	sources[setting] = source
+	o.sources = sources
+
+	return o
+}
+
+func (o Overrides) source(setting string, flag string) string {
+	if source, ok := o.sources[setting]; ok {
+		return source
+	}
+
+	return "flag " + flag
+}
+
+// layer ranks where a value comes from, each layer overriding the ones below
+type layer int
+
+const (
+	defaultLayer layer = iota
+	fileLayer
+	envLayer
+	flagLayer
+)
+
+type setting struct {
+	value  string
+	layer  layer
+	source string
+}
+
+func (s setting) override(value string, l layer, source string) setting {
+	if value == "" {
+		return s
+	}
+
+	return setting{value: value, layer: l, source: source}
+}
+
+// resolveAiConfig layers the defaults, the config file as seen through the
+// profile reader, the environment and the overrides into an AiConfig.
+func resolveAiConfig(profile string, reader profileReader, overrides Overrides) (AiConfig, error) {
+	fileSource := "config file"
+	if reader.profile != nil {
+		fileSource = fmt.Sprintf("config file, profile %s", profile)
+	}
+
+	// Provider, remembering its value at each layer
+	var providers [flagLayer + 1]provider.ProviderType
+	providerSetting := setting{value: string(provider.ProviderOpenAI), layer: defaultLayer, source: "default"}
+	providers[defaultLayer] = provider.ProviderType(providerSetting.value)
+	providerSetting = providerSetting.override(reader.GetString(ai_provider), fileLayer, fileSource)
+	providers[fileLayer] = provider.ProviderType(providerSetting.value)
+	providerSetting = providerSetting.override(os.Getenv(provider_env), envLayer, "env "+provider_env)
+	providers[envLayer] = provider.ProviderType(providerSetting.value)
+	providerSetting = providerSetting.override(string(overrides.ProviderType), flagLayer, overrides.source(SettingProvider, "-p"))
+	providers[flagLayer] = provider.ProviderType(providerSetting.value)
+	providerType := providers[flagLayer]
+
+	// Model, falling back to legacy OpenAI model for backward compatibility
+	fileModel := reader.GetString(ai_model)
+	if !reader.IsSet(ai_model) {
+		fileModel = reader.GetString(openai_model)
+	}
+	// A profile picking its own provider must not inherit another provider's model
+	if reader.profile != nil && reader.profile.IsSet(ai_provider) && !reader.profile.IsSet(ai_model) {
+		fileModel = ""
+	}
+	modelSetting := setting{value: "", layer: defaultLayer, source: "default"}
+	modelSetting = modelSetting.override(fileModel, fileLayer, fileSource)
+	modelSetting = modelSetting.override(os.Getenv(model_env), envLayer, "env "+model_env)
+	modelSetting = modelSetting.override(overrides.Model, flagLayer, overrides.source(SettingModel, "-model"))
+	// A model chosen for another provider than the effective one is dropped
+	if modelSetting.value == "" || providers[modelSetting.layer] != providerType {
+		modelSetting = setting{value: GetDefaultModelForProvider(providerType), layer: defaultLayer, source: "default"}
+	}
+
+	// Proxy, falling back to legacy OpenAI proxy
+	fileProxy := reader.GetString(ai_proxy)
+	if !reader.IsSet(ai_proxy) {
+		fileProxy = reader.GetString(openai_proxy)
+	}
+	proxySetting := setting{value: "", layer: defaultLayer, source: "default"}
+	proxySetting = proxySetting.override(fileProxy, fileLayer, fileSource)
+	proxySetting = proxySetting.override(os.Getenv(proxy_env), envLayer, "env "+proxy_env)
+
+	// Temperature, falling back to legacy OpenAI temperature
+	temperatureSetting := setting{value: strconv.FormatFloat(default_temperature, 'f', -1, 64), layer: defaultLayer, source: "default"}
+	if reader.IsSet(ai_temperature) {
+		temperatureSetting = temperatureSetting.override(reader.GetString(ai_temperature), fileLayer, fileSource)
+	} else if reader.IsSet(openai_temperature) {
+		temperatureSetting = temperatureSetting.override(reader.GetString(openai_temperature), fileLayer, fileSource)
+	}
+	temperatureSetting = temperatureSetting.override(os.Getenv(temperature_env), envLayer, "env "+temperature_env)
+	if overrides.Temperature != nil {
+		temperatureSetting = temperatureSetting.override(strconv.FormatFloat(*overrides.Temperature, 'f', -1, 64), flagLayer, overrides.source(SettingTemperature, "-temperature"))
+	}
+	temperature, err := strconv.ParseFloat(temperatureSetting.value, 64)
+	if err != nil {
+		return AiConfig{}, fmt.Errorf("invalid temperature from %s: %s", temperatureSetting.source, temperatureSetting.value)
+	}
+
+	// Max tokens, falling back to legacy OpenAI max tokens
+	maxTokensSetting := setting{value: strconv.Itoa(default_max_tokens), layer: defaultLayer, source: "default"}
+	if reader.IsSet(ai_max_tokens) {
+		maxTokensSetting = maxTokensSetting.override(reader.GetString(ai_max_tokens), fileLayer, fileSource)
+	} else if reader.IsSet(openai_max_tokens) {
+		maxTokensSetting = maxTokensSetting.override(reader.GetString(openai_max_tokens), fileLayer, fileSource)
+	}
+	maxTokensSetting = maxTokensSetting.override(os.Getenv(max_tokens_env), envLayer, "env "+max_tokens_env)
+	if overrides.MaxTokens != nil {
+		maxTokensSetting = maxTokensSetting.override(strconv.Itoa(*overrides.MaxTokens), flagLayer, overrides.source(SettingMaxTokens, "-max-tokens"))
+	}
+	maxTokens, err := strconv.Atoi(maxTokensSetting.value)
+	if err != nil {
+		return AiConfig{}, fmt.Errorf("invalid max tokens from %s: %s", maxTokensSetting.source, maxTokensSetting.value)
+	}
+
+	// Get API key based on provider, without requiring it in plaintext. The
+	// file's key belongs to the file's provider, so it is skipped when a
+	// higher layer switched to another one.
+	apiKey, keySource, err := resolveKey(reader, providerType, providerType == providers[fileLayer])
+	if err != nil {
+		return AiConfig{}, err
+	}
+
+	return AiConfig{
+		providerType: providerType,
+		key:          apiKey,
+		model:        modelSetting.value,
+		proxy:        proxySetting.value,
+		temperature:  temperature,
+		maxTokens:    maxTokens,
+		sources: map[string]string{
+			SettingProvider:    providerSetting.source,
+			SettingKey:         keySource,
+			SettingModel:       modelSetting.source,
+			SettingProxy:       proxySetting.source,
+			SettingTemperature: temperatureSetting.source,
+			SettingMaxTokens:   maxTokensSetting.source,
+		},
+	}, nil
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xsikor/yai/ai/provider"
+)
+
+const layersConfig = `{
+	"AI_PROVIDER": "openai",
+	"AI_KEY": "openai_key",
+	"AI_MODEL": "gpt-4",
+	"AI_TEMPERATURE": 0.5
+}`
+
+func TestLayers(t *testing.T) {
+	t.Run("FileLayer", testFileLayer)
+	t.Run("EnvOverridesFile", testEnvOverridesFile)
+	t.Run("FlagsOverrideEnv", testFlagsOverrideEnv)
+	t.Run("ProviderOverrideResetsModel", testProviderOverrideResetsModel)
+	t.Run("InvalidEnvValue", testInvalidEnvValue)
+}
+
+func testFileLayer(t *testing.T) {
+	setupProfiles(t, layersConfig)
+
+	cfg, err := NewConfigWithOverrides(Overrides{})
+	require.NoError(t, err)
+
+	ai := cfg.GetAiConfig()
+	assert.Equal(t, "gpt-4", ai.GetModel())
+	assert.Equal(t, "config file", ai.GetSource(SettingModel))
+	assert.Equal(t, 0.5, ai.GetTemperature())
+	assert.Equal(t, "config file", ai.GetSource(SettingTemperature))
+	assert.Equal(t, 1000, ai.GetMaxTokens())
+	assert.Equal(t, "default", ai.GetSource(SettingMaxTokens))
+}
+
+func testEnvOverridesFile(t *testing.T) {
+	setupProfiles(t, layersConfig)
+	t.Setenv(model_env, "gpt-4o")
+	t.Setenv(temperature_env, "0.9")
+	t.Setenv(proxy_env, "http://localhost:8080")
+
+	cfg, err := NewConfigWithOverrides(Overrides{})
+	require.NoError(t, err)
+
+	ai := cfg.GetAiConfig()
+	assert.Equal(t, "gpt-4o", ai.GetModel())
+	assert.Equal(t, "env YAI_MODEL", ai.GetSource(SettingModel))
+	assert.Equal(t, 0.9, ai.GetTemperature())
+	assert.Equal(t, "http://localhost:8080", ai.GetProxy())
+	assert.Equal(t, "env YAI_PROXY", ai.GetSource(SettingProxy))
+	assert.Equal(t, "openai_key", ai.GetKey())
+}
+
+func testFlagsOverrideEnv(t *testing.T) {
+	setupProfiles(t, layersConfig)
+	t.Setenv(model_env, "gpt-4o")
+	t.Setenv(max_tokens_env, "2000")
+
+	temperature := 0.0
+	maxTokens := 500
+	cfg, err := NewConfigWithOverrides(Overrides{
+		Model:       "gpt-4-turbo",
+		Temperature: &temperature,
+		MaxTokens:   &maxTokens,
+	})
+	require.NoError(t, err)
+
+	ai := cfg.GetAiConfig()
+	assert.Equal(t, "gpt-4-turbo", ai.GetModel())
+	assert.Equal(t, "flag -model", ai.GetSource(SettingModel))
+	assert.Equal(t, 0.0, ai.GetTemperature())
+	assert.Equal(t, "flag -temperature", ai.GetSource(SettingTemperature))
+	assert.Equal(t, 500, ai.GetMaxTokens())
+	assert.Equal(t, "flag -max-tokens", ai.GetSource(SettingMaxTokens))
+}
+
+func testProviderOverrideResetsModel(t *testing.T) {
+	setupProfiles(t, layersConfig)
+	t.Setenv("ANTHROPIC_API_KEY", "env_claude_key")
+
+	cfg, err := NewConfigWithOverrides(Overrides{ProviderType: provider.ProviderClaude})
+	require.NoError(t, err)
+
+	ai := cfg.GetAiConfig()
+	assert.Equal(t, provider.ProviderClaude, ai.GetProviderType())
+	assert.Equal(t, "flag -p", ai.GetSource(SettingProvider))
+	assert.Equal(t, GetDefaultModelForProvider(provider.ProviderClaude), ai.GetModel(), "The file's OpenAI model should not be used for Claude.")
+	assert.Equal(t, "default", ai.GetSource(SettingModel))
+	assert.Equal(t, "env_claude_key", ai.GetKey(), "The file's OpenAI key should not be used for Claude.")
+	assert.Equal(t, "env ANTHROPIC_API_KEY", ai.GetKeySource())
+
+	cfg, err = NewConfigWithOverrides(Overrides{ProviderType: provider.ProviderClaude, Model: "claude-3-opus"})
+	require.NoError(t, err)
+	assert.Equal(t, "claude-3-opus", cfg.GetAiConfig().GetModel())
+}
+
+func testInvalidEnvValue(t *testing.T) {
+	setupProfiles(t, layersConfig)
+	t.Setenv(max_tokens_env, "many")
+
+	_, err := NewConfigWithOverrides(Overrides{})
+	assert.ErrorContains(t, err, "env YAI_MAX_TOKENS")
+}
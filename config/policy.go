@@ -0,0 +1,348 @@
+package config
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+	"mvdan.cc/sh/v3/syntax"
+
+	"github.com/xsikor/yai/run"
+)
+
+const (
+	// Key of the command policies, and of their lists of patterns
+	command_policies = "COMMAND_POLICIES"
+	policy_deny      = "DENY"
+	policy_confirm   = "CONFIRM"
+	policy_allow     = "ALLOW"
+)
+
+// PolicyVerdict tells what to do with a generated command
+type PolicyVerdict int
+
+const (
+	// PolicyAllow lets the command run as usual
+	PolicyAllow PolicyVerdict = iota
+	// PolicyConfirm always asks before running the command
+	PolicyConfirm
+	// PolicyDeny never runs the command
+	PolicyDeny
+	// PolicyRun runs the command without asking, even without a terminal
+	PolicyRun
+)
+
+func (v PolicyVerdict) String() string {
+	switch v {
+	case PolicyConfirm:
+		return "confirm"
+	case PolicyDeny:
+		return "deny"
+	case PolicyRun:
+		return "run"
+	default:
+		return "allow"
+	}
+}
+
+// SkipsConfirmation tells if a command with this verdict runs without
+// asking. yes, as given by -yes, only skips the confirmation of the commands
+// no policy asks to confirm.
+func (v PolicyVerdict) SkipsConfirmation(yes bool) bool {
+	return v == PolicyRun || yes && v == PolicyAllow
+}
+
+// shellRunners run the command line given with -c
+var shellRunners = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "fish": true,
+}
+
+// Reasons for a command line to be confirmed whatever the patterns
+const (
+	unparsedReason     = "unparsed command line"
+	redirectionReason  = "redirection"
+	substitutionReason = "command substitution"
+	dynamicReason      = "dynamic command"
+)
+
+// CommandPolicy restricts the commands generated in exec mode, or lets some
+// of them run without asking. Patterns are a command name like "go",
+// matching any of its invocations, or a glob like "kubectl delete *".
+type CommandPolicy struct {
+	deny    []string
+	confirm []string
+	allow   []string
+}
+
+func (p CommandPolicy) GetDeny() []string {
+	return p.deny
+}
+
+func (p CommandPolicy) GetConfirm() []string {
+	return p.confirm
+}
+
+func (p CommandPolicy) GetAllow() []string {
+	return p.allow
+}
+
+// Check returns the verdict for a command line, and the pattern it matched.
+// The line is parsed as bash would, and every command it runs is checked,
+// including the ones of subshells, background jobs, command substitutions
+// and wrappers like sudo or bash -c, the strictest verdict winning: the line
+// only runs without asking if all its commands are allowed. A line with
+// redirections or substitutions, or running commands only known once run,
+// is always confirmed, the pattern telling why.
+func (p CommandPolicy) Check(commandLine string) (PolicyVerdict, string) {
+	check := policyCheck{policy: p, verdict: PolicyAllow, allowed: len(p.allow) > 0}
+	check.line(commandLine)
+
+	if check.verdict == PolicyAllow && check.allowed && check.allowedPattern != "" {
+		return PolicyRun, check.allowedPattern
+	}
+
+	return check.verdict, check.matched
+}
+
+// policyCheck is the state of the check of a command line, the commands
+// being checked as they are found
+type policyCheck struct {
+	policy         CommandPolicy
+	verdict        PolicyVerdict
+	matched        string
+	allowed        bool
+	allowedPattern string
+}
+
+// confirm asks for confirmation unless a stricter verdict was given
+func (c *policyCheck) confirm(reason string) {
+	c.allowed = false
+	if c.verdict == PolicyAllow {
+		c.verdict, c.matched = PolicyConfirm, reason
+	}
+}
+
+// line checks every command of a command line
+func (c *policyCheck) line(commandLine string) {
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(commandLine), "")
+	if err != nil {
+		c.confirm(unparsedReason)
+		return
+	}
+
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if c.verdict == PolicyDeny {
+			return false
+		}
+
+		switch node := node.(type) {
+		case *syntax.CallExpr:
+			if len(node.Args) > 0 {
+				c.command(node.Args)
+			}
+		case *syntax.Redirect:
+			if !isHarmlessRedirect(node) {
+				c.confirm(redirectionReason)
+			}
+		case *syntax.CmdSubst, *syntax.ProcSubst:
+			c.confirm(substitutionReason)
+		}
+		return true
+	})
+}
+
+// command checks a simple command: as written, and without the wrappers
+// running another command. When the command run by the wrappers is
+// ambiguous, every later word it may start at is checked.
+func (c *policyCheck) command(args []*syntax.Word) {
+	words := make([]string, len(args))
+	for i, arg := range args {
+		value, ok := wordValue(arg)
+		if i == 0 && !ok {
+			c.confirm(dynamicReason)
+		}
+		words[i] = value
+	}
+
+	forms := [][]string{words}
+	start, ambiguous := run.UnwrapCommand(words)
+	if start > 0 {
+		for i := start; i < len(words); i++ {
+			forms = append(forms, words[i:])
+			if !ambiguous {
+				break
+			}
+		}
+	}
+
+	for _, form := range forms {
+		command := strings.Join(form, " ")
+		if pattern, ok := matchAny(c.policy.deny, command); ok {
+			c.verdict, c.matched = PolicyDeny, pattern
+			return
+		}
+		if pattern, ok := matchAny(c.policy.confirm, command); ok && c.verdict == PolicyAllow {
+			c.verdict, c.matched = PolicyConfirm, pattern
+		}
+	}
+
+	// The allowed commands must be unambiguous: as written, or unwrapped
+	allowed := false
+	for _, form := range forms[:min(len(forms), 2)] {
+		if ambiguous && len(form) < len(words) {
+			break
+		}
+		if pattern, ok := matchAny(c.policy.allow, strings.Join(form, " ")); ok {
+			allowed, c.allowedPattern = true, pattern
+			break
+		}
+	}
+	if !allowed {
+		c.allowed = false
+	}
+
+	// A shell given a command line runs its commands, checked as well
+	if start < len(words) {
+		c.nested(words[start:], args[start:])
+	}
+}
+
+// nested checks the command line run by a shell with -c, or by eval
+func (c *policyCheck) nested(words []string, args []*syntax.Word) {
+	var script []*syntax.Word
+	switch {
+	case words[0] == "eval":
+		script = args[1:]
+	case shellRunners[words[0]]:
+		for i, word := range words {
+			if word == "-c" && i+1 < len(words) {
+				script = args[i+1 : i+2]
+				break
+			}
+		}
+		if script == nil && len(words) == 1 {
+			// The commands would be read from the input
+			c.confirm(dynamicReason)
+		}
+	}
+	if script == nil {
+		return
+	}
+
+	var lines []string
+	for _, arg := range script {
+		value, ok := wordValue(arg)
+		if !ok {
+			c.confirm(dynamicReason)
+			return
+		}
+		lines = append(lines, value)
+	}
+	c.line(strings.Join(lines, " "))
+}
+
+// wordValue returns a word without its quotes, and whether it is known
+// before running the command. A word holding expansions is returned as
+// written.
+func wordValue(word *syntax.Word) (string, bool) {
+	var value strings.Builder
+	known := true
+	for _, part := range word.Parts {
+		switch part := part.(type) {
+		case *syntax.Lit:
+			value.WriteString(part.Value)
+		case *syntax.SglQuoted:
+			value.WriteString(part.Value)
+		case *syntax.DblQuoted:
+			for _, inner := range part.Parts {
+				if lit, ok := inner.(*syntax.Lit); ok {
+					value.WriteString(lit.Value)
+				} else {
+					known = false
+				}
+			}
+		default:
+			known = false
+		}
+	}
+	if known {
+		return value.String(), true
+	}
+
+	var source strings.Builder
+	syntax.NewPrinter().Print(&source, word)
+
+	return source.String(), false
+}
+
+// isHarmlessRedirect tells if a redirection only discards an output or
+// merges two of them, like 2>/dev/null or 2>&1
+func isHarmlessRedirect(redirect *syntax.Redirect) bool {
+	if redirect.Word == nil {
+		return false
+	}
+	target, ok := wordValue(redirect.Word)
+	if !ok {
+		return false
+	}
+
+	switch redirect.Op {
+	case syntax.DplOut, syntax.DplIn:
+		return target == "-" || strings.Trim(target, "0123456789") == ""
+	case syntax.RdrOut, syntax.AppOut, syntax.RdrAll, syntax.AppAll:
+		return target == "/dev/null"
+	}
+
+	return false
+}
+
+// merge adds the restrictions of a project policy. Its allowed commands are
+// left out: a repository must not be able to run commands unattended.
+func (p CommandPolicy) merge(project CommandPolicy) CommandPolicy {
+	return CommandPolicy{
+		deny:    append(append([]string{}, p.deny...), project.deny...),
+		confirm: append(append([]string{}, p.confirm...), project.confirm...),
+		allow:   p.allow,
+	}
+}
+
+func readCommandPolicy(reader *viper.Viper) CommandPolicy {
+	if reader == nil {
+		return CommandPolicy{}
+	}
+
+	return CommandPolicy{
+		deny:    reader.GetStringSlice(command_policies + "." + policy_deny),
+		confirm: reader.GetStringSlice(command_policies + "." + policy_confirm),
+		allow:   reader.GetStringSlice(command_policies + "." + policy_allow),
+	}
+}
+
+func matchAny(patterns []string, command string) (string, bool) {
+	for _, pattern := range patterns {
+		if matchCommand(pattern, command) {
+			return pattern, true
+		}
+	}
+
+	return "", false
+}
+
+func matchCommand(pattern string, command string) bool {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return false
+	}
+
+	if command == pattern || strings.HasPrefix(command, pattern+" ") {
+		return true
+	}
+
+	expression := regexp.QuoteMeta(pattern)
+	expression = strings.ReplaceAll(expression, `\*`, `.*`)
+	expression = strings.ReplaceAll(expression, `\?`, `.`)
+
+	matched, _ := regexp.MatchString("^"+expression+"$", command)
+
+	return matched
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandPolicy(t *testing.T) {
+	policy := CommandPolicy{
+		deny:    []string{"go", "rm -rf /*"},
+		confirm: []string{"kubectl delete *", "terraform apply"},
+		allow:   []string{"ls", "kubectl get *", "kubectl delete pod *"},
+	}
+
+	testCases := []struct {
+		command string
+		verdict PolicyVerdict
+		pattern string
+	}{
+		{"ls -la", PolicyRun, "ls"},
+		{"ls -la | wc -l", PolicyAllow, ""},
+		{"kubectl get pods && ls", PolicyRun, "ls"},
+		{"pwd", PolicyAllow, ""},
+		{"go build ./...", PolicyDeny, "go"},
+		{"gofmt -l .", PolicyAllow, ""},
+		{"make build && go test ./...", PolicyDeny, "go"},
+		{"sudo rm -rf /var/lib/app", PolicyDeny, "rm -rf /*"},
+		{"kubectl get pods", PolicyRun, "kubectl get *"},
+		{"kubectl delete pod web-1", PolicyConfirm, "kubectl delete *"},
+		{"terraform apply | tee plan.log", PolicyConfirm, "terraform apply"},
+		{"kubectl delete pod web-1; go vet", PolicyDeny, "go"},
+		{"ls 2>/dev/null || ls -a 2>&1", PolicyRun, "ls"},
+		{"ls | (", PolicyConfirm, unparsedReason},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.command, func(t *testing.T) {
+			verdict, pattern := policy.Check(tc.command)
+			assert.Equal(t, tc.verdict, verdict)
+			assert.Equal(t, tc.pattern, pattern)
+		})
+	}
+}
+
+func TestCommandPolicyShell(t *testing.T) {
+	policy := CommandPolicy{
+		deny:  []string{"rm"},
+		allow: []string{"ls", "echo", "sudo systemctl status *"},
+	}
+
+	testCases := []struct {
+		command string
+		verdict PolicyVerdict
+		pattern string
+	}{
+		// Every command run is checked, wherever it is
+		{"ls & rm -rf ~", PolicyDeny, "rm"},
+		{"echo `rm -rf ~`", PolicyDeny, "rm"},
+		{"echo $(rm -rf ~)", PolicyDeny, "rm"},
+		{"ls\nrm -rf ~", PolicyDeny, "rm"},
+		{"(cd /tmp && rm -rf cache)", PolicyDeny, "rm"},
+		{"{ ls; rm -f notes; }", PolicyDeny, "rm"},
+		{"ls <(rm -rf ~)", PolicyDeny, "rm"},
+		{"FILES=$(rm -rf ~) ls", PolicyDeny, "rm"},
+		{"if ls; then rm -f x; fi", PolicyDeny, "rm"},
+		{"'r'\"m\" -rf ~", PolicyDeny, "rm"},
+		// Wrappers and shells run the commands they are given
+		{"sudo rm -rf /", PolicyDeny, "rm"},
+		{"sudo -u root rm -rf /", PolicyDeny, "rm"},
+		{"env HOME=/ nohup rm -rf ~", PolicyDeny, "rm"},
+		{"ls | xargs rm", PolicyDeny, "rm"},
+		{"timeout 5 rm -rf ~", PolicyDeny, "rm"},
+		{"doas -u root timeout -s KILL 5 rm -rf ~", PolicyDeny, "rm"},
+		{"bash -c 'ls; rm -rf ~'", PolicyDeny, "rm"},
+		{"sh -c \"echo hi && rm -rf ~\"", PolicyDeny, "rm"},
+		{"eval 'rm -rf ~'", PolicyDeny, "rm"},
+		{"bash -c \"$SCRIPT\"", PolicyConfirm, dynamicReason},
+		{"echo rm -rf ~ | bash", PolicyConfirm, dynamicReason},
+		{"$CMD -rf ~", PolicyConfirm, dynamicReason},
+		// Redirections and substitutions are never run without asking
+		{"ls > ~/.bashrc", PolicyConfirm, redirectionReason},
+		{"echo hi >> notes.txt", PolicyConfirm, redirectionReason},
+		{"ls < files.txt", PolicyConfirm, redirectionReason},
+		{"echo $(ls)", PolicyConfirm, substitutionReason},
+		{"echo `ls`", PolicyConfirm, substitutionReason},
+		{"ls -la 2>/dev/null", PolicyRun, "ls"},
+		{"ls & echo done", PolicyRun, "echo"},
+		{"sudo systemctl status nginx", PolicyRun, "sudo systemctl status *"},
+		{"sudo -u root ls", PolicyAllow, ""},
+		{"bash -c 'ls'", PolicyAllow, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.command, func(t *testing.T) {
+			verdict, pattern := policy.Check(tc.command)
+			assert.Equal(t, tc.verdict.String(), verdict.String())
+			assert.Equal(t, tc.pattern, pattern)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// Keys for named profiles
+	profiles        = "PROFILES"
+	default_profile = "DEFAULT_PROFILE"
+
+	// Environment variable selecting the active profile
+	profile_env = "YAI_PROFILE"
+
+	// DefaultProfile is the profile made of the top-level keys, so legacy
+	// flat configs keep working unchanged
+	DefaultProfile = "default"
+)
+
+// profileReader looks keys up in a named profile first, then falls back to
+// the top-level keys for anything the profile does not set.
+type profileReader struct {
+	profile *viper.Viper
+}
+
+func newProfileReader(name string) (profileReader, error) {
+	profile := viper.Sub(fmt.Sprintf("%s.%s", profiles, name))
+	if profile == nil {
+		if name == DefaultProfile {
+			return profileReader{}, nil
+		}
+		return profileReader{}, fmt.Errorf("unknown profile: %s", name)
+	}
+
+	return profileReader{profile: profile}, nil
+}
+
+func (r profileReader) IsSet(key string) bool {
+	return (r.profile != nil && r.profile.IsSet(key)) || viper.IsSet(key)
+}
+
+func (r profileReader) source(key string) *viper.Viper {
+	if r.profile != nil && r.profile.IsSet(key) {
+		return r.profile
+	}
+
+	return viper.GetViper()
+}
+
+func (r profileReader) GetString(key string) string {
+	return r.source(key).GetString(key)
+}
+
+func (r profileReader) GetFloat64(key string) float64 {
+	return r.source(key).GetFloat64(key)
+}
+
+func (r profileReader) GetInt(key string) int {
+	return r.source(key).GetInt(key)
+}
+
+func (r profileReader) GetBool(key string) bool {
+	return r.source(key).GetBool(key)
+}
+
+// resolveProfileName picks the profile to use: the explicit name if any,
+// then the YAI_PROFILE env var, then the DEFAULT_PROFILE key.
+func resolveProfileName(name string) string {
+	if name == "" {
+		name = os.Getenv(profile_env)
+	}
+	if name == "" {
+		name = viper.GetString(default_profile)
+	}
+	if name == "" {
+		name = DefaultProfile
+	}
+
+	return strings.ToLower(name)
+}
+
+// GetProfileNames returns the default profile followed by the named profiles
+// of the loaded config, sorted alphabetically.
+func GetProfileNames() []string {
+	var names []string
+	for name := range viper.GetStringMap(profiles) {
+		if name != DefaultProfile {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return append([]string{DefaultProfile}, names...)
+}
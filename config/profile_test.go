@@ -0,0 +1,125 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xsikor/yai/ai/provider"
+)
+
+const profilesConfig = `{
+	"AI_PROVIDER": "openai",
+	"AI_KEY": "openai_key",
+	"AI_MODEL": "gpt-4",
+	"AI_TEMPERATURE": 0.2,
+	"AI_MAX_TOKENS": 1000,
+	"USER_PREFERENCES": "be concise",
+	"PROFILES": {
+		"work-claude": {
+			"AI_PROVIDER": "claude",
+			"AI_KEY": "claude_key",
+			"AI_TEMPERATURE": 0.5,
+			"USER_PREFERENCES": "use kubectl"
+		},
+		"local": {
+			"AI_MODEL": "llama",
+			"AI_PROXY": "http://localhost:8080"
+		}
+	}
+}`
+
+func TestProfiles(t *testing.T) {
+	t.Run("DefaultProfile", testDefaultProfile)
+	t.Run("NamedProfile", testNamedProfile)
+	t.Run("ProfileInheritance", testProfileInheritance)
+	t.Run("ProfileFromEnv", testProfileFromEnv)
+	t.Run("UnknownProfile", testUnknownProfile)
+	t.Run("GetProfileNames", testGetProfileNames)
+}
+
+func setupProfiles(t *testing.T, content string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "yai.json"), []byte(content), 0o600))
+
+	viper.Reset()
+	viper.AddConfigPath(dir)
+	t.Cleanup(viper.Reset)
+}
+
+func testDefaultProfile(t *testing.T) {
+	setupProfiles(t, profilesConfig)
+
+	cfg, err := NewConfigForProfile("")
+	require.NoError(t, err)
+
+	assert.Equal(t, DefaultProfile, cfg.GetProfile())
+	assert.Equal(t, provider.ProviderOpenAI, cfg.GetAiConfig().GetProviderType())
+	assert.Equal(t, "openai_key", cfg.GetAiConfig().GetKey())
+	assert.Equal(t, "gpt-4", cfg.GetAiConfig().GetModel())
+}
+
+func testNamedProfile(t *testing.T) {
+	setupProfiles(t, profilesConfig)
+
+	cfg, err := NewConfigForProfile("Work-Claude")
+	require.NoError(t, err)
+
+	assert.Equal(t, "work-claude", cfg.GetProfile())
+	assert.Equal(t, provider.ProviderClaude, cfg.GetAiConfig().GetProviderType())
+	assert.Equal(t, "claude_key", cfg.GetAiConfig().GetKey())
+	// The profile picks its own provider, so it gets that provider's default model
+	assert.Equal(t, GetDefaultModelForProvider(provider.ProviderClaude), cfg.GetAiConfig().GetModel())
+	assert.Equal(t, 0.5, cfg.GetAiConfig().GetTemperature())
+	assert.Equal(t, "use kubectl", cfg.GetUserConfig().GetPreferences())
+}
+
+func testProfileInheritance(t *testing.T) {
+	setupProfiles(t, profilesConfig)
+
+	cfg, err := NewConfigForProfile("local")
+	require.NoError(t, err)
+
+	assert.Equal(t, provider.ProviderOpenAI, cfg.GetAiConfig().GetProviderType())
+	assert.Equal(t, "openai_key", cfg.GetAiConfig().GetKey())
+	assert.Equal(t, "llama", cfg.GetAiConfig().GetModel())
+	assert.Equal(t, "http://localhost:8080", cfg.GetAiConfig().GetProxy())
+	assert.Equal(t, 1000, cfg.GetAiConfig().GetMaxTokens())
+	assert.Equal(t, "be concise", cfg.GetUserConfig().GetPreferences())
+}
+
+func testProfileFromEnv(t *testing.T) {
+	setupProfiles(t, profilesConfig)
+	t.Setenv(profile_env, "local")
+
+	cfg, err := NewConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "local", cfg.GetProfile())
+
+	// An explicit profile wins over the env var
+	cfg, err = NewConfigForProfile("work-claude")
+	require.NoError(t, err)
+	assert.Equal(t, "work-claude", cfg.GetProfile())
+}
+
+func testUnknownProfile(t *testing.T) {
+	setupProfiles(t, profilesConfig)
+
+	_, err := NewConfigForProfile("nope")
+	assert.EqualError(t, err, "unknown profile: nope")
+}
+
+func testGetProfileNames(t *testing.T) {
+	setupProfiles(t, profilesConfig)
+
+	_, err := NewConfig()
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{DefaultProfile, "local", "work-claude"}, GetProfileNames())
+}
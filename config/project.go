@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// project_file is the per-directory config, shared by everyone working in a
+// project. It can only set what is safe to take from a repository: it never
+// sets the AI provider, key or proxy.
+const project_file = ".yai.yaml"
+
+// findProjectFile returns the closest project config file from the directory
+// up to the filesystem root, or empty if there is none
+func findProjectFile(directory string) string {
+	dir := directory
+	for {
+		path := filepath.Join(dir, project_file)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadProjectConfig reads the project config file of the working directory,
+// returning a nil reader if there is none
+func loadProjectConfig() (*viper.Viper, error) {
+	directory, err := os.Getwd()
+	if err != nil {
+		return nil, nil
+	}
+
+	path := findProjectFile(directory)
+	if path == "" {
+		return nil, nil
+	}
+
+	reader := viper.New()
+	reader.SetConfigFile(path)
+	reader.SetConfigType("yaml")
+	if err := reader.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("project config %s: %w", path, err)
+	}
+
+	return reader, nil
+}
+
+// mergeProjectUserConfig applies the project settings on top of the user
+// ones. Preferences and system prompts add up, the project ones coming last.
+func mergeProjectUserConfig(user UserConfig, project *viper.Viper) (UserConfig, error) {
+	if project == nil {
+		return user, nil
+	}
+
+	if project.IsSet(user_default_prompt_mode) {
+		mode := strings.ToLower(project.GetString(user_default_prompt_mode))
+		if mode != "chat" && mode != "exec" {
+			return user, fmt.Errorf("project config %s: unsupported %s: %s", project.ConfigFileUsed(), user_default_prompt_mode, mode)
+		}
+		user.defaultPromptMode = mode
+	}
+	if project.IsSet(user_workspace_context) {
+		user.workspaceContext = project.GetBool(user_workspace_context)
+	}
+
+	user.preferences = joinNonEmpty("; ", user.preferences, project.GetString(user_preferences))
+	user.systemPrompt = joinNonEmpty("\n", user.systemPrompt, project.GetString(system_prompt))
+
+	return user, nil
+}
+
+func joinNonEmpty(separator string, values ...string) string {
+	var parts []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			parts = append(parts, value)
+		}
+	}
+
+	return strings.Join(parts, separator)
+}
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/zalando/go-keyring"
+
+	"github.com/xsikor/yai/ai/provider"
+	"github.com/xsikor/yai/run"
+)
+
+const (
+	// Keys for resolving the API key without storing it in plaintext
+	ai_key_command = "AI_KEY_COMMAND"
+	ai_key_file    = "AI_KEY_FILE"
+	ai_key_keyring = "AI_KEY_KEYRING"
+)
+
+// KeyringService is the service the API keys are stored under in the OS
+// keyring, each key being stored for an account named after its provider
+const KeyringService = "yai"
+
+// sessionKeys holds the keys entered at setup but not stored in the config
+// file, so they are never written by a later config write either
+var sessionKeys = map[provider.ProviderType]string{}
+
+// resolvedKeys caches the keys printed by key commands and read from the
+// keyring, by command or account, as configs are reloaded on every model,
+// provider or profile switch
+var (
+	resolvedKeysMu sync.Mutex
+	resolvedKeys   = map[string]string{}
+)
+
+// GetKeyEnvVar returns the conventional environment variable holding the API
+// key of a provider, as used by the providers' own SDKs.
+func GetKeyEnvVar(providerType provider.ProviderType) string {
+	switch providerType {
+	case provider.ProviderClaude:
+		return "ANTHROPIC_API_KEY"
+	case provider.ProviderGemini:
+		return "GEMINI_API_KEY"
+	default:
+		return "OPENAI_API_KEY"
+	}
+}
+
+// resolveKey looks the API key up, from the most to the least explicit
+// source: a key command, a key file, the OS keyring, the plaintext key of
+// the config file,
+// then a key entered for this session, and finally the provider's environment
+// variable. The config file sources are only used if fromFile is set. It
+// returns the key along with a description of where it came from, never
+// including the key itself.
+func resolveKey(reader profileReader, providerType provider.ProviderType, fromFile bool) (string, string, error) {
+	if fromFile {
+		key, source, err := resolveKeyFromFile(reader)
+		if err != nil || key != "" {
+			return key, source, err
+		}
+	}
+
+	if key := sessionKeys[providerType]; key != "" {
+		return key, "session", nil
+	}
+
+	return resolveKeyFromEnv(providerType)
+}
+
+func resolveKeyFromFile(reader profileReader) (string, string, error) {
+	if reader.IsSet(ai_key_command) && reader.GetString(ai_key_command) != "" {
+		command := reader.GetString(ai_key_command)
+
+		key, err := cachedKey("command:"+command, func() (string, error) {
+			output, err := run.RunCommand("sh", "-c", command)
+			if err != nil {
+				return "", fmt.Errorf("%s failed: %w", ai_key_command, err)
+			}
+			return strings.TrimSpace(output), nil
+		})
+		if err != nil {
+			return "", "", err
+		}
+
+		return key, fmt.Sprintf("command `%s`", command), nil
+	}
+
+	if reader.IsSet(ai_key_file) && reader.GetString(ai_key_file) != "" {
+		path := reader.GetString(ai_key_file)
+
+		key, err := readKeyFile(path)
+		if err != nil {
+			return "", "", err
+		}
+
+		return key, fmt.Sprintf("file %s", path), nil
+	}
+
+	if reader.IsSet(ai_key_keyring) && reader.GetString(ai_key_keyring) != "" {
+		account := reader.GetString(ai_key_keyring)
+
+		key, err := cachedKey("keyring:"+account, func() (string, error) {
+			key, err := keyring.Get(KeyringService, account)
+			if err != nil {
+				return "", fmt.Errorf("%s %s: could not read the key from the keyring: %w", ai_key_keyring, account, err)
+			}
+			return key, nil
+		})
+		if err != nil {
+			return "", "", err
+		}
+
+		return key, fmt.Sprintf("keyring %s", account), nil
+	}
+
+	if reader.IsSet(ai_key) && reader.GetString(ai_key) != "" {
+		return reader.GetString(ai_key), "config file", nil
+	}
+
+	if reader.IsSet(openai_key) && reader.GetString(openai_key) != "" {
+		return reader.GetString(openai_key), "config file", nil
+	}
+
+	return "", "", nil
+}
+
+// hasFileKey tells if the config file holds a key source
+func hasFileKey(reader profileReader) bool {
+	for _, key := range []string{ai_key_command, ai_key_file, ai_key_keyring, ai_key, openai_key} {
+		if reader.IsSet(key) && reader.GetString(key) != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cachedKey returns the key cached under name, resolving it the first time.
+// Failures are not cached, so a fixed key source is tried again.
+func cachedKey(name string, resolve func() (string, error)) (string, error) {
+	resolvedKeysMu.Lock()
+	defer resolvedKeysMu.Unlock()
+
+	if key, ok := resolvedKeys[name]; ok {
+		return key, nil
+	}
+
+	key, err := resolve()
+	if err != nil {
+		return "", err
+	}
+	resolvedKeys[name] = key
+
+	return key, nil
+}
+
+// storeKeyringKey stores the key of a provider in the OS keyring, returning
+// the account it is stored for
+func storeKeyringKey(providerType provider.ProviderType, key string) (string, error) {
+	account := string(providerType)
+	if err := keyring.Set(KeyringService, account, key); err != nil {
+		return "", fmt.Errorf("could not store the key in the keyring: %w", err)
+	}
+
+	resolvedKeysMu.Lock()
+	resolvedKeys["keyring:"+account] = key
+	resolvedKeysMu.Unlock()
+
+	return account, nil
+}
+
+func resolveKeyFromEnv(providerType provider.ProviderType) (string, string, error) {
+	envVar := GetKeyEnvVar(providerType)
+	if key := os.Getenv(envVar); key != "" {
+		return key, fmt.Sprintf("env %s", envVar), nil
+	}
+
+	return "", "", nil
+}
+
+// readKeyFile reads a key from a file, refusing files other users can access.
+func readKeyFile(path string) (string, error) {
+	expanded, err := homedir.Expand(path)
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %w", ai_key_file, path, err)
+	}
+
+	info, err := os.Stat(expanded)
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %w", ai_key_file, path, err)
+	}
+
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		return "", fmt.Errorf(
+			"%s %s is accessible by other users (mode %04o), restrict it with: chmod 600 %s",
+			ai_key_file,
+			path,
+			info.Mode().Perm(),
+			path,
+		)
+	}
+
+	content, err := os.ReadFile(expanded)
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %w", ai_key_file, path, err)
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
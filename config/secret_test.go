@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zalando/go-keyring"
+
+	"github.com/xsikor/yai/ai/provider"
+)
+
+func TestSecrets(t *testing.T) {
+	t.Run("KeyFromConfig", testKeyFromConfig)
+	t.Run("KeyFromEnv", testKeyFromEnv)
+	t.Run("KeyFromCommand", testKeyFromCommand)
+	t.Run("KeyFromFailingCommand", testKeyFromFailingCommand)
+	t.Run("KeyCommandCached", testKeyCommandCached)
+	t.Run("KeyFromFile", testKeyFromFile)
+	t.Run("KeyFromUnsafeFile", testKeyFromUnsafeFile)
+	t.Run("KeyFromKeyring", testKeyFromKeyring)
+	t.Run("KeyFromMissingKeyring", testKeyFromMissingKeyring)
+	t.Run("GetKeyEnvVar", testGetKeyEnvVar)
+}
+
+func testKeyFromConfig(t *testing.T) {
+	setupProfiles(t, `{"AI_PROVIDER": "claude", "AI_KEY": "config_key"}`)
+	t.Setenv("ANTHROPIC_API_KEY", "env_key")
+
+	cfg, err := NewConfig()
+	require.NoError(t, err)
+
+	assert.Equal(t, "config_key", cfg.GetAiConfig().GetKey())
+	assert.Equal(t, "config file", cfg.GetAiConfig().GetKeySource())
+}
+
+func testKeyFromEnv(t *testing.T) {
+	setupProfiles(t, `{"AI_PROVIDER": "claude"}`)
+	t.Setenv("ANTHROPIC_API_KEY", "env_key")
+
+	cfg, err := NewConfig()
+	require.NoError(t, err)
+
+	assert.Equal(t, "env_key", cfg.GetAiConfig().GetKey())
+	assert.Equal(t, "env ANTHROPIC_API_KEY", cfg.GetAiConfig().GetKeySource())
+}
+
+func testKeyFromCommand(t *testing.T) {
+	setupProfiles(t, `{"AI_KEY": "config_key", "AI_KEY_COMMAND": "echo ' command_key '"}`)
+
+	cfg, err := NewConfig()
+	require.NoError(t, err)
+
+	assert.Equal(t, "command_key", cfg.GetAiConfig().GetKey())
+	assert.Equal(t, "command `echo ' command_key '`", cfg.GetAiConfig().GetKeySource())
+}
+
+func testKeyFromFailingCommand(t *testing.T) {
+	setupProfiles(t, `{"AI_KEY_COMMAND": "exit 3"}`)
+
+	_, err := NewConfig()
+	assert.ErrorContains(t, err, "AI_KEY_COMMAND failed")
+}
+
+func testKeyCommandCached(t *testing.T) {
+	runs := filepath.Join(t.TempDir(), "runs")
+	command := fmt.Sprintf("echo run >> %s; echo command_key", runs)
+	setupProfiles(t, fmt.Sprintf(`{"AI_KEY_COMMAND": %q}`, command))
+	t.Cleanup(func() { delete(resolvedKeys, "command:"+command) })
+
+	for range 3 {
+		cfg, err := NewConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "command_key", cfg.GetAiConfig().GetKey())
+	}
+
+	content, err := os.ReadFile(runs)
+	require.NoError(t, err)
+	assert.Equal(t, "run\n", string(content), "The key command should run once for all the configs.")
+}
+
+func testKeyFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(path, []byte("file_key\n"), 0o600))
+	setupProfiles(t, fmt.Sprintf(`{"AI_KEY_FILE": %q}`, path))
+
+	cfg, err := NewConfig()
+	require.NoError(t, err)
+
+	assert.Equal(t, "file_key", cfg.GetAiConfig().GetKey())
+	assert.Equal(t, "file "+path, cfg.GetAiConfig().GetKeySource())
+}
+
+func testKeyFromUnsafeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(path, []byte("file_key\n"), 0o600))
+	require.NoError(t, os.Chmod(path, 0o644))
+	setupProfiles(t, fmt.Sprintf(`{"AI_KEY_FILE": %q}`, path))
+
+	_, err := NewConfig()
+	assert.ErrorContains(t, err, "accessible by other users")
+}
+
+func testKeyFromKeyring(t *testing.T) {
+	keyring.MockInit()
+	require.NoError(t, keyring.Set(KeyringService, "work", "keyring_key"))
+	setupProfiles(t, `{"AI_KEY": "config_key", "AI_KEY_KEYRING": "work"}`)
+	t.Cleanup(func() { delete(resolvedKeys, "keyring:work") })
+
+	cfg, err := NewConfig()
+	require.NoError(t, err)
+
+	assert.Equal(t, "keyring_key", cfg.GetAiConfig().GetKey())
+	assert.Equal(t, "keyring work", cfg.GetAiConfig().GetKeySource())
+}
+
+func testKeyFromMissingKeyring(t *testing.T) {
+	keyring.MockInit()
+	setupProfiles(t, `{"AI_KEY_KEYRING": "missing"}`)
+
+	_, err := NewConfig()
+	assert.ErrorIs(t, err, keyring.ErrNotFound)
+	assert.ErrorContains(t, err, "AI_KEY_KEYRING missing")
+}
+
+func testGetKeyEnvVar(t *testing.T) {
+	assert.Equal(t, "OPENAI_API_KEY", GetKeyEnvVar(provider.ProviderOpenAI))
+	assert.Equal(t, "ANTHROPIC_API_KEY", GetKeyEnvVar(provider.ProviderClaude))
+	assert.Equal(t, "GEMINI_API_KEY", GetKeyEnvVar(provider.ProviderGemini))
+}
@@ -3,11 +3,23 @@ package config
 const (
 	user_default_prompt_mode = "USER_DEFAULT_PROMPT_MODE"
 	user_preferences         = "USER_PREFERENCES"
+	user_workspace_context   = "USER_WORKSPACE_CONTEXT"
+	user_fullscreen          = "USER_FULLSCREEN"
+	user_theme               = "USER_THEME"
+	user_intent_threshold    = "USER_INTENT_THRESHOLD"
+	user_intent_model        = "USER_INTENT_MODEL"
+	system_prompt            = "SYSTEM_PROMPT"
 )
 
 type UserConfig struct {
 	defaultPromptMode string
 	preferences       string
+	workspaceContext  bool
+	fullscreen        bool
+	theme             string
+	intentThreshold   float64
+	intentModel       string
+	systemPrompt      string
 }
 
 func (c UserConfig) GetDefaultPromptMode() string {
@@ -17,3 +29,39 @@ func (c UserConfig) GetDefaultPromptMode() string {
 func (c UserConfig) GetPreferences() string {
 	return c.preferences
 }
+
+// GetSystemPrompt returns the text added to the system prompt, for
+// instructions that do not fit in preferences
+func (c UserConfig) GetSystemPrompt() string {
+	return c.systemPrompt
+}
+
+// IsWorkspaceContext tells if the git repository, project type and tools of
+// the working directory are sent to the AI
+func (c UserConfig) IsWorkspaceContext() bool {
+	return c.workspaceContext
+}
+
+// IsFullscreen tells if the REPL shows the whole transcript in a scrollable
+// full screen view
+func (c UserConfig) IsFullscreen() bool {
+	return c.fullscreen
+}
+
+// GetTheme returns the theme of the terminal UI, a built-in theme, a theme
+// file name or path, or empty for the default one
+func (c UserConfig) GetTheme() string {
+	return c.theme
+}
+
+// GetIntentThreshold returns how much an intent must win by for the mode of a
+// piped prompt to be picked from it, 0 for the default
+func (c UserConfig) GetIntentThreshold() float64 {
+	return c.intentThreshold
+}
+
+// GetIntentModel returns the model asked for the intent of the prompts the
+// rules are unsure about, empty to only use the rules
+func (c UserConfig) GetIntentModel() string {
+	return c.intentModel
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"os"
+	"sync"
+
+	"github.com/xsikor/yai/system"
+)
+
+// workspaces caches the analysis of the working directories, the tools
+// being slow to report their version, and the config reloaded on every
+// switch of profile, provider or model. The git status is read again on
+// every load, as commands change it.
+var (
+	workspacesMu sync.Mutex
+	workspaces   = map[string]*system.Workspace{}
+)
+
+// loadWorkspace analyses the working directory, if the workspace context is
+// enabled
+func loadWorkspace(user UserConfig) *system.Workspace {
+	if !user.IsWorkspaceContext() {
+		return nil
+	}
+
+	directory, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+
+	workspacesMu.Lock()
+	workspace, ok := workspaces[directory]
+	if !ok {
+		workspace = system.AnalyseWorkspace(directory)
+		workspaces[directory] = workspace
+	}
+	workspacesMu.Unlock()
+
+	if ok {
+		return workspace.RefreshGitStatus()
+	}
+
+	return workspace
+}
@@ -0,0 +1,204 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+
+	"github.com/xsikor/yai/ai"
+)
+
+type Format string
+
+const (
+	MarkdownFormat Format = "md"
+	JsonFormat     Format = "json"
+	HtmlFormat     Format = "html"
+)
+
+// Formats are the export formats, the first one being the default
+var Formats = []Format{MarkdownFormat, JsonFormat, HtmlFormat}
+
+// ParseFormat returns the format of the name, like "md" or "markdown"
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "md", "markdown":
+		return MarkdownFormat, nil
+	case "json":
+		return JsonFormat, nil
+	case "html", "htm":
+		return HtmlFormat, nil
+	default:
+		return "", fmt.Errorf("unknown export format: %s, use md, json or html", name)
+	}
+}
+
+// FormatOf returns the format matching the extension of the path, markdown
+// for an unknown extension
+func FormatOf(path string) Format {
+	if format, err := ParseFormat(strings.TrimPrefix(filepath.Ext(path), ".")); err == nil {
+		return format
+	}
+
+	return MarkdownFormat
+}
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	switch f {
+	case JsonFormat:
+		return "application/json"
+	case HtmlFormat:
+		return "text/html; charset=utf-8"
+	default:
+		return "text/markdown; charset=utf-8"
+	}
+}
+
+type Options struct {
+	// Context includes the system prompt, the piped input and the attached
+	// files sent with every request
+	Context bool
+}
+
+// Render writes the conversation in the format
+func Render(conversation ai.Conversation, format Format, options Options) ([]byte, error) {
+	if !options.Context {
+		conversation.SystemPrompt = ""
+		conversation.Pipe = ""
+		conversation.Attachments = nil
+	}
+
+	switch format {
+	case JsonFormat:
+		if conversation.Entries == nil {
+			conversation.Entries = []ai.ConversationEntry{}
+		}
+		content, err := json.MarshalIndent(conversation, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(content, '\n'), nil
+	case HtmlFormat:
+		return renderHtml(conversation)
+	default:
+		return []byte(renderMarkdown(conversation)), nil
+	}
+}
+
+func renderMarkdown(conversation ai.Conversation) string {
+	var sb strings.Builder
+
+	sb.WriteString("# yai conversation\n\n")
+	sb.WriteString(fmt.Sprintf("- Provider: %s\n", conversation.Provider))
+	sb.WriteString(fmt.Sprintf("- Model: %s\n", conversation.Model))
+	if len(conversation.Entries) > 0 {
+		sb.WriteString(fmt.Sprintf("- Started: %s\n", conversation.Entries[0].Time.Format("2006-01-02 15:04:05 MST")))
+	}
+
+	if conversation.SystemPrompt != "" || conversation.Pipe != "" || len(conversation.Attachments) > 0 {
+		sb.WriteString("\n## Context\n")
+		if conversation.SystemPrompt != "" {
+			sb.WriteString("\n### System prompt\n\n")
+			sb.WriteString(fence("text", conversation.SystemPrompt))
+		}
+		if conversation.Pipe != "" {
+			sb.WriteString("\n### Piped input\n\n")
+			sb.WriteString(fence("text", conversation.Pipe))
+		}
+		if len(conversation.Attachments) > 0 {
+			sb.WriteString("\n### Attached files\n\n")
+			for _, path := range conversation.Attachments {
+				sb.WriteString(fmt.Sprintf("- `%s`\n", path))
+			}
+		}
+	}
+
+	sb.WriteString("\n## Conversation\n")
+	if len(conversation.Entries) == 0 {
+		sb.WriteString("\nNothing was said yet.\n")
+	}
+
+	for _, entry := range conversation.Entries {
+		at := entry.Time.Format("15:04:05")
+
+		switch entry.Role {
+		case ai.UserRole:
+			sb.WriteString(fmt.Sprintf("\n### You, %s · %s\n\n", entry.Mode, at))
+			sb.WriteString(strings.TrimSpace(entry.Content) + "\n")
+			if len(entry.Images) > 0 {
+				sb.WriteString(fmt.Sprintf("\nImages: `%s`\n", strings.Join(entry.Images, "`, `")))
+			}
+		case ai.AssistantRole:
+			sb.WriteString(fmt.Sprintf("\n### yai, %s · %s\n\n", entry.Mode, at))
+			if entry.Command != "" {
+				sb.WriteString(fence("sh", entry.Command))
+				if entry.Content != "" {
+					sb.WriteString("\n")
+				}
+			}
+			if entry.Content != "" {
+				sb.WriteString(strings.TrimSpace(entry.Content) + "\n")
+			}
+		case ai.CommandRole:
+			sb.WriteString(fmt.Sprintf("\n### Command run · %s\n\n", at))
+			sb.WriteString(fence("sh", "$ "+entry.Command))
+			if entry.ExitCode != nil {
+				sb.WriteString(fmt.Sprintf("\nExit code: %d\n", *entry.ExitCode))
+			}
+			if entry.Content != "" {
+				sb.WriteString("\n")
+				sb.WriteString(fence("text", entry.Content))
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// fence returns the content as a fenced code block, the fence being longer
+// than any backtick run of the content
+func fence(language string, content string) string {
+	longest, run := 0, 0
+	for _, r := range content {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	marker := strings.Repeat("`", max(3, longest+1))
+
+	return fmt.Sprintf("%s%s\n%s\n%s\n", marker, language, strings.TrimRight(content, "\n"), marker)
+}
+
+const htmlStyle = `body{font-family:-apple-system,"Segoe UI",Helvetica,Arial,sans-serif;line-height:1.5;max-width:960px;margin:2em auto;padding:0 1em;color:#1f2328}
+pre{background:#f6f8fa;padding:1em;overflow:auto;border-radius:6px}
+code{font-family:ui-monospace,Menlo,Consolas,monospace;font-size:90%}
+h3{border-top:1px solid #d0d7de;padding-top:1em}
+table{border-collapse:collapse}td,th{border:1px solid #d0d7de;padding:.3em .6em}`
+
+// renderHtml renders the markdown export as a self-contained page, the raw
+// HTML of the answers being left out
+func renderHtml(conversation ai.Conversation) ([]byte, error) {
+	var body bytes.Buffer
+	if err := goldmark.New(goldmark.WithExtensions(extension.GFM)).Convert([]byte(renderMarkdown(conversation)), &body); err != nil {
+		return nil, err
+	}
+
+	var page bytes.Buffer
+	page.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	page.WriteString(fmt.Sprintf("<title>yai conversation, %s</title>\n", html.EscapeString(conversation.Model)))
+	page.WriteString("<style>\n" + htmlStyle + "\n</style>\n</head>\n<body>\n")
+	page.Write(body.Bytes())
+	page.WriteString("</body>\n</html>\n")
+
+	return page.Bytes(), nil
+}
@@ -0,0 +1,144 @@
+package export
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xsikor/yai/ai"
+)
+
+func TestExport(t *testing.T) {
+	t.Run("ParseFormat", testParseFormat)
+	t.Run("Markdown", testMarkdown)
+	t.Run("Json", testJson)
+	t.Run("Html", testHtml)
+	t.Run("Fence", testFence)
+	t.Run("LastConversation", testLastConversation)
+}
+
+func newTestConversation() ai.Conversation {
+	at := time.Date(2024, 3, 1, 14, 30, 0, 0, time.UTC)
+	exitCode := 1
+
+	return ai.Conversation{
+		Provider: "openai",
+		Model:    "gpt-4o",
+		Entries: []ai.ConversationEntry{
+			{Time: at, Role: ai.UserRole, Mode: "exec", Content: "why is nginx down", Images: []string{"error.png"}},
+			{Time: at, Role: ai.AssistantRole, Mode: "exec", Command: "systemctl status nginx", Content: "show the nginx status"},
+			{Time: at, Role: ai.CommandRole, Mode: "exec", Command: "systemctl status nginx", Content: "nginx.service failed\n", ExitCode: &exitCode},
+			{Time: at.Add(time.Minute), Role: ai.AssistantRole, Mode: "chat", Content: "Check the config with `nginx -t`.\n\n<script>alert(1)</script>"},
+		},
+		SystemPrompt: "You are Yai",
+		Pipe:         "error.log content",
+		Attachments:  []string{"/etc/nginx/nginx.conf"},
+	}
+}
+
+func testParseFormat(t *testing.T) {
+	for name, expected := range map[string]Format{"md": MarkdownFormat, "Markdown": MarkdownFormat, "json": JsonFormat, "htm": HtmlFormat} {
+		format, err := ParseFormat(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, format, name)
+	}
+
+	_, err := ParseFormat("pdf")
+	assert.EqualError(t, err, "unknown export format: pdf, use md, json or html")
+
+	assert.Equal(t, HtmlFormat, FormatOf("incident.html"))
+	assert.Equal(t, JsonFormat, FormatOf("out/session.JSON"))
+	assert.Equal(t, MarkdownFormat, FormatOf("notes.txt"))
+	assert.Equal(t, MarkdownFormat, FormatOf("notes"))
+}
+
+func testMarkdown(t *testing.T) {
+	content, err := Render(newTestConversation(), MarkdownFormat, Options{})
+	require.NoError(t, err)
+
+	markdown := string(content)
+	assert.Contains(t, markdown, "- Provider: openai\n- Model: gpt-4o\n- Started: 2024-03-01 14:30:00 UTC\n")
+	assert.Contains(t, markdown, "### You, exec · 14:30:00\n\nwhy is nginx down\n\nImages: `error.png`\n")
+	assert.Contains(t, markdown, "### yai, exec · 14:30:00\n\n```sh\nsystemctl status nginx\n```\n\nshow the nginx status\n")
+	assert.Contains(t, markdown, "### Command run · 14:30:00\n\n```sh\n$ systemctl status nginx\n```\n\nExit code: 1\n\n```text\nnginx.service failed\n```\n")
+	assert.Contains(t, markdown, "### yai, chat · 14:31:00\n\nCheck the config")
+	assert.NotContains(t, markdown, "## Context")
+	assert.NotContains(t, markdown, "You are Yai")
+
+	content, err = Render(newTestConversation(), MarkdownFormat, Options{Context: true})
+	require.NoError(t, err)
+	markdown = string(content)
+	assert.Contains(t, markdown, "### System prompt\n\n```text\nYou are Yai\n```\n")
+	assert.Contains(t, markdown, "### Piped input\n\n```text\nerror.log content\n```\n")
+	assert.Contains(t, markdown, "### Attached files\n\n- `/etc/nginx/nginx.conf`\n")
+
+	content, err = Render(ai.Conversation{Provider: "openai", Model: "gpt-4o"}, MarkdownFormat, Options{})
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "Nothing was said yet.")
+}
+
+func testJson(t *testing.T) {
+	content, err := Render(newTestConversation(), JsonFormat, Options{})
+	require.NoError(t, err)
+
+	var conversation ai.Conversation
+	require.NoError(t, json.Unmarshal(content, &conversation))
+	assert.Len(t, conversation.Entries, 4)
+	assert.Equal(t, 1, *conversation.Entries[2].ExitCode)
+	assert.Empty(t, conversation.SystemPrompt)
+	assert.NotContains(t, string(content), "system_prompt")
+
+	content, err = Render(newTestConversation(), JsonFormat, Options{Context: true})
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(content, &conversation))
+	assert.Equal(t, "You are Yai", conversation.SystemPrompt)
+	assert.Equal(t, []string{"/etc/nginx/nginx.conf"}, conversation.Attachments)
+
+	content, err = Render(ai.Conversation{}, JsonFormat, Options{})
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `"entries": []`)
+}
+
+func testHtml(t *testing.T) {
+	content, err := Render(newTestConversation(), HtmlFormat, Options{})
+	require.NoError(t, err)
+
+	page := string(content)
+	assert.Contains(t, page, "<!DOCTYPE html>")
+	assert.Contains(t, page, "<title>yai conversation, gpt-4o</title>")
+	assert.Contains(t, page, `<code class="language-sh">$ systemctl status nginx`)
+	assert.Contains(t, page, "<code>nginx -t</code>")
+	// The raw HTML of the answers is left out
+	assert.NotContains(t, page, "<script>")
+}
+
+func testFence(t *testing.T) {
+	assert.Equal(t, "```text\necho\n```\n", fence("text", "echo\n"))
+	assert.Equal(t, "````md\n```sh\nls\n```\n````\n", fence("md", "```sh\nls\n```"))
+}
+
+func testLastConversation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "yai", "last_conversation.json")
+
+	_, err := loadLastConversation(path)
+	assert.ErrorContains(t, err, "no REPL conversation")
+
+	conversation := newTestConversation()
+	require.NoError(t, saveLastConversation(path, conversation))
+
+	// An empty conversation keeps the last one
+	require.NoError(t, saveLastConversation(path, ai.Conversation{Provider: "openai"}))
+
+	loaded, err := loadLastConversation(path)
+	require.NoError(t, err)
+	assert.Equal(t, conversation, loaded)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
@@ -0,0 +1,66 @@
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/xsikor/yai/ai"
+	"github.com/xsikor/yai/system"
+)
+
+// GetLastConversationFile returns the file keeping the last conversation of
+// the REPL, for yai export -last
+func GetLastConversationFile() string {
+	return filepath.Join(system.GetConfigDirectory(), "last_conversation.json")
+}
+
+// SaveLastConversation keeps the conversation of the REPL, with its context,
+// until the next one. A conversation without entries keeps the previous one.
+func SaveLastConversation(conversation ai.Conversation) error {
+	return saveLastConversation(GetLastConversationFile(), conversation)
+}
+
+// LoadLastConversation returns the last conversation of the REPL
+func LoadLastConversation() (ai.Conversation, error) {
+	return loadLastConversation(GetLastConversationFile())
+}
+
+func saveLastConversation(path string, conversation ai.Conversation) error {
+	if len(conversation.Entries) == 0 {
+		return nil
+	}
+
+	content, err := json.Marshal(conversation)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// The conversation may hold what was piped or attached, only readable
+	// by the user
+	return os.WriteFile(path, content, 0o600)
+}
+
+func loadLastConversation(path string) (ai.Conversation, error) {
+	var conversation ai.Conversation
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return conversation, errors.New("no REPL conversation was saved yet")
+	}
+	if err != nil {
+		return conversation, err
+	}
+
+	if err := json.Unmarshal(content, &conversation); err != nil {
+		return conversation, fmt.Errorf("invalid last conversation %s: %w", path, err)
+	}
+
+	return conversation, nil
+}
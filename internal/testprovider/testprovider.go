@@ -0,0 +1,93 @@
+// Package testprovider is a fake AI provider for the tests, answering with
+// canned chunks instead of calling an API.
+package testprovider
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/xsikor/yai/ai/provider"
+)
+
+// Provider answers every completion with its chunks, joined when the
+// completion is not streamed, and records the requests
+type Provider struct {
+	Chunks []string
+	// Err fails the completions
+	Err error
+	// ChunkErr ends the stream after the chunks
+	ChunkErr error
+	// Block holds the stream open after the chunks until it is cancelled
+	Block bool
+	// CancelErr fails a blocked stream with the context error once
+	// cancelled, as the real providers do
+	CancelErr bool
+
+	mu       sync.Mutex
+	requests []provider.CompletionRequest
+}
+
+func (p *Provider) Name() provider.ProviderType { return provider.ProviderOpenAI }
+
+func (p *Provider) AvailableModels() []string { return []string{"fake"} }
+
+func (p *Provider) DefaultModel() string { return "fake" }
+
+func (p *Provider) CreateCompletion(ctx context.Context, req provider.CompletionRequest) (string, error) {
+	p.record(req)
+	if p.Err != nil {
+		return "", p.Err
+	}
+
+	return strings.Join(p.Chunks, ""), nil
+}
+
+func (p *Provider) CreateCompletionStream(ctx context.Context, req provider.CompletionRequest) (<-chan provider.CompletionResponse, error) {
+	p.record(req)
+	if p.Err != nil {
+		return nil, p.Err
+	}
+
+	responses := make(chan provider.CompletionResponse)
+
+	go func() {
+		defer close(responses)
+
+		for _, chunk := range p.Chunks {
+			responses <- provider.CompletionResponse{Content: chunk}
+		}
+
+		if p.Block {
+			<-ctx.Done()
+			if p.CancelErr {
+				responses <- provider.CompletionResponse{Err: ctx.Err()}
+				return
+			}
+		}
+
+		responses <- provider.CompletionResponse{Done: true, Err: p.ChunkErr}
+	}()
+
+	return responses, nil
+}
+
+// LastRequest returns the last completion request, a zero one if none was
+// made
+func (p *Provider) LastRequest() provider.CompletionRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.requests) == 0 {
+		return provider.CompletionRequest{}
+	}
+
+	return p.requests[len(p.requests)-1]
+}
+
+func (p *Provider) record(req provider.CompletionRequest) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.requests = append(p.requests, req)
+}
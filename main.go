@@ -4,40 +4,62 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
+	"os"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 
+	"github.com/xsikor/yai/cli"
 	"github.com/xsikor/yai/config"
+	"github.com/xsikor/yai/server"
 	"github.com/xsikor/yai/ui"
 )
 
 func main() {
 	rand.Seed(time.Now().UnixNano())
 
+	if server.IsServeCommand(os.Args[1:]) {
+		os.Exit(server.Run(os.Args[2:]))
+	}
+	if server.IsExportCommand(os.Args[1:]) {
+		os.Exit(server.RunExport(os.Args[2:]))
+	}
+
 	input, err := ui.NewUIInput()
 	if err != nil {
-		log.Fatal(err)
+		log.Print(err)
+		os.Exit(cli.ExitUsage)
 	}
 
 	// Check if we should show model info
 	if input.GetShowModel() {
-		showModelInfo()
+		showModelInfo(input.GetOverrides())
 		return
 	}
 
-	if _, err := tea.NewProgram(ui.NewUi(input)).Run(); err != nil {
+	// Scripts get a plain output and an exit code, without the terminal UI
+	if input.IsHeadless() {
+		os.Exit(cli.Run(input))
+	}
+
+	model, err := tea.NewProgram(ui.NewUi(input)).Run()
+	if err != nil {
 		log.Fatal(err)
 	}
+
+	if err := model.(*ui.Ui).SaveConversation(); err != nil {
+		log.Printf("the conversation could not be saved: %s", err)
+	}
 }
 
-func showModelInfo() {
-	cfg, err := config.NewConfig()
+func showModelInfo(overrides config.Overrides) {
+	cfg, err := config.NewConfigWithOverrides(overrides)
 	if err != nil {
 		fmt.Println("Config not found or invalid.")
 		return
 	}
 
-	fmt.Printf("Current provider: %s\n", cfg.GetAiConfig().GetProviderType())
-	fmt.Printf("Current model: %s\n", cfg.GetAiConfig().GetModel())
+	fmt.Printf("Current profile: %s\n", cfg.GetProfile())
+	fmt.Printf("Current provider: %s (%s)\n", cfg.GetAiConfig().GetProviderType(), cfg.GetAiConfig().GetSource(config.SettingProvider))
+	fmt.Printf("Current model: %s (%s)\n", cfg.GetAiConfig().GetModel(), cfg.GetAiConfig().GetSource(config.SettingModel))
 }
@@ -0,0 +1,70 @@
+package run
+
+import (
+	"strings"
+	"sync"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// maxCapturedOutput bounds the output kept of a command, its end being kept
+const maxCapturedOutput = 64 * 1024
+
+// terminalPrograms draw on the terminal, and misbehave when their output is
+// not one
+var terminalPrograms = map[string]bool{
+	"vi": true, "vim": true, "nvim": true, "nano": true, "emacs": true,
+	"less": true, "more": true, "most": true, "man": true, "top": true,
+	"htop": true, "btop": true, "watch": true, "ssh": true, "tmux": true,
+	"screen": true, "mc": true, "ranger": true, "fzf": true, "tig": true,
+	"lazygit": true, "k9s": true,
+}
+
+// CapturedOutput keeps the end of the output of a command, while it is
+// written to the terminal as well
+type CapturedOutput struct {
+	mu     sync.Mutex
+	output []byte
+}
+
+func (o *CapturedOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.output = append(o.output, p...)
+	if len(o.output) > maxCapturedOutput {
+		o.output = o.output[len(o.output)-maxCapturedOutput:]
+	}
+
+	return len(p), nil
+}
+
+// String returns the captured output, without the blank lines around it
+func (o *CapturedOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return strings.Trim(string(o.output), "\n")
+}
+
+// NeedsTerminal tells if a command line runs a program drawing on the
+// terminal, like an editor or a pager, whose output must not be captured. A
+// line that cannot be parsed is assumed to need one.
+func NeedsTerminal(command string) bool {
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(command), "")
+	if err != nil {
+		return true
+	}
+
+	needed := false
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if call, ok := node.(*syntax.CallExpr); ok {
+			for _, program := range commandPrograms(call.Args) {
+				needed = needed || terminalPrograms[program]
+			}
+		}
+		return !needed
+	})
+
+	return needed
+}
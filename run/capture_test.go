@@ -0,0 +1,41 @@
+package run
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapturedOutput(t *testing.T) {
+	var output CapturedOutput
+	fmt.Fprint(&output, "\n\nmain.go\n")
+	fmt.Fprint(&output, "go.mod\n\n\n")
+
+	assert.Equal(t, "main.go\ngo.mod", output.String())
+
+	// Only the end of a long output is kept
+	fmt.Fprint(&output, strings.Repeat("x", maxCapturedOutput)+"end")
+	assert.Len(t, output.String(), maxCapturedOutput)
+	assert.True(t, strings.HasSuffix(output.String(), "xend"))
+}
+
+func TestNeedsTerminal(t *testing.T) {
+	tests := []struct {
+		command  string
+		expected bool
+	}{
+		{"ls -la", false},
+		{"git log | grep fix", false},
+		{"vim main.go", true},
+		{"sudo nano /etc/hosts", true},
+		{"git log | less", true},
+		{"cd /tmp && htop", true},
+		{"echo 'unclosed", true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, NeedsTerminal(test.command), test.command)
+	}
+}
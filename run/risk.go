@@ -0,0 +1,78 @@
+package run
+
+import (
+	"regexp"
+	"strings"
+)
+
+type Risk int
+
+const (
+	// LowRisk commands only read, like ls or kubectl get
+	LowRisk Risk = iota
+	// MediumRisk commands change things that can be restored, like mv or
+	// apt install
+	MediumRisk
+	// HighRisk commands can destroy data or systems, like rm -rf or
+	// kubectl delete
+	HighRisk
+)
+
+func (r Risk) String() string {
+	switch r {
+	case MediumRisk:
+		return "medium"
+	case HighRisk:
+		return "high"
+	default:
+		return "low"
+	}
+}
+
+var highRiskPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\brm\s+(-\w*[rf]\w*\s+)*-\w*[rf]`),
+	regexp.MustCompile(`\b(dd|mkfs(\.\w+)?|fdisk|parted|wipefs|shred)\b`),
+	regexp.MustCompile(`>\s*/dev/(sd|nvme|hd|disk)`),
+	regexp.MustCompile(`\bchmod\s+(-\w+\s+)*777\b|\bch(mod|own)\s+-R\b`),
+	regexp.MustCompile(`\bgit\s+(push\s+.*(--force|-f)\b|reset\s+--hard|clean\s+-\w*f)`),
+	regexp.MustCompile(`\bkubectl\s+(delete|drain|replace\s+--force)\b`),
+	regexp.MustCompile(`\bterraform\s+(destroy|apply\s+.*-auto-approve)\b`),
+	regexp.MustCompile(`\bdocker\s+(system|volume|image|container)\s+prune\b`),
+	regexp.MustCompile(`(?i)\b(drop\s+(table|database)|truncate\s+table)\b`),
+	regexp.MustCompile(`\b(shutdown|reboot|halt|poweroff)\b`),
+	regexp.MustCompile(`\b(curl|wget)\b.*\|\s*(sudo\s+)?(ba|z)?sh\b`),
+	regexp.MustCompile(`:\(\)\s*\{`),
+}
+
+var mediumRiskPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\bsudo\b`),
+	regexp.MustCompile(`\b(rm|rmdir|mv|kill|killall|pkill|chmod|chown|ln|truncate)\b`),
+	regexp.MustCompile(`\b(apt|apt-get|yum|dnf|brew|pacman|pip|npm|gem|cargo)\s+(install|remove|uninstall|upgrade|update)\b`),
+	regexp.MustCompile(`\bgit\s+(push|reset|rebase|checkout|merge|commit|stash)\b`),
+	regexp.MustCompile(`\bkubectl\s+(apply|create|edit|patch|scale|rollout|set|label|annotate|exec)\b`),
+	regexp.MustCompile(`\bdocker\s+(rm|rmi|stop|kill|run|exec)\b`),
+	regexp.MustCompile(`\bterraform\s+apply\b`),
+	regexp.MustCompile(`\bsystemctl\s+(start|stop|restart|enable|disable)\b`),
+	regexp.MustCompile(`\bsed\s+(-\w+\s+)*-i`),
+	regexp.MustCompile(`[^2&]>\s*[^&\s]`),
+}
+
+// AssessRisk tells how much harm a command line can do if run by mistake.
+// It is a heuristic meant to decide how much care to ask for, not a
+// security boundary.
+func AssessRisk(command string) Risk {
+	command = strings.TrimSpace(command)
+
+	for _, pattern := range highRiskPatterns {
+		if pattern.MatchString(command) {
+			return HighRisk
+		}
+	}
+	for _, pattern := range mediumRiskPatterns {
+		if pattern.MatchString(command) {
+			return MediumRisk
+		}
+	}
+
+	return LowRisk
+}
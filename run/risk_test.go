@@ -0,0 +1,36 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssessRisk(t *testing.T) {
+	testCases := []struct {
+		command string
+		risk    Risk
+	}{
+		{"ls -la ~", LowRisk},
+		{"kubectl get pods --all-namespaces", LowRisk},
+		{"grep -r TODO . | wc -l", LowRisk},
+		{"find . -name '*.go' 2>/dev/null", LowRisk},
+		{"mv notes.txt archive/", MediumRisk},
+		{"sudo apt install htop", MediumRisk},
+		{"echo hello > greeting.txt", MediumRisk},
+		{"kubectl apply -f deploy.yaml", MediumRisk},
+		{"rm -rf build/", HighRisk},
+		{"rm -f -r /tmp/cache", HighRisk},
+		{"kubectl delete pod web-1", HighRisk},
+		{"git push origin main --force", HighRisk},
+		{"dd if=/dev/zero of=/dev/sda bs=1M", HighRisk},
+		{"curl -fsSL https://example.com/install.sh | sh", HighRisk},
+		{"psql -c 'DROP TABLE users'", HighRisk},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.command, func(t *testing.T) {
+			assert.Equal(t, tc.risk.String(), AssessRisk(tc.command).String())
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package run
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
@@ -15,14 +17,42 @@ func RunCommand(cmd string, arg ...string) (string, error) {
 	return string(out), nil
 }
 
+// PrepareInteractiveCommand returns the command running a command line in
+// bash between blank lines, exiting with the status of the command line
 func PrepareInteractiveCommand(input string) *exec.Cmd {
 	return exec.Command(
 		"bash",
 		"-c",
-		fmt.Sprintf("echo \"\n\";%s; echo \"\n\";", strings.TrimRight(input, ";")),
+		fmt.Sprintf("echo \"\n\";%s; yai_status=$?; echo \"\n\"; exit $yai_status", strings.TrimRight(input, ";")),
 	)
 }
 
+// PrepareCommand returns the command running a command line in bash, as is
+func PrepareCommand(input string) *exec.Cmd {
+	return exec.Command("bash", "-c", input)
+}
+
+// PrepareCommandContext is like PrepareCommand, the command being killed
+// once the context is done
+func PrepareCommandContext(ctx context.Context, input string) *exec.Cmd {
+	return exec.CommandContext(ctx, "bash", "-c", input)
+}
+
+// ExitCode returns the exit code of a command from its error, -1 if it did
+// not exit on its own
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	return -1
+}
+
 func PrepareEditSettingsCommand(input string) *exec.Cmd {
 	return exec.Command(
 		"bash",
@@ -1,6 +1,7 @@
 package run
 
 import (
+	"errors"
 	"os/exec"
 	"testing"
 
@@ -11,6 +12,7 @@ import (
 func TestRun(t *testing.T) {
 	t.Run("RunCommand", testRunCommand)
 	t.Run("PrepareInteractiveCommand", testPrepareInteractiveCommand)
+	t.Run("PrepareCommand", testPrepareCommand)
 	t.Run("PrepareEditSettingsCommand", testPrepareEditSettingsCommand)
 }
 
@@ -27,10 +29,24 @@ func testPrepareInteractiveCommand(t *testing.T) {
 	expectedCmd := exec.Command(
 		"bash",
 		"-c",
-		"echo \"\n\";echo 'Hello, World!'; echo \"\n\";",
+		"echo \"\n\";echo 'Hello, World!'; yai_status=$?; echo \"\n\"; exit $yai_status",
 	)
 
 	assert.Equal(t, expectedCmd.Args, cmd.Args, "The command arguments should be the same.")
+
+	// The exit code is the one of the command line, not of the last echo
+	err := PrepareInteractiveCommand("exit 3").Run()
+	assert.Equal(t, 3, ExitCode(err))
+	assert.Equal(t, 0, ExitCode(PrepareInteractiveCommand("true").Run()))
+	assert.Equal(t, -1, ExitCode(errors.New("not started")))
+}
+
+func testPrepareCommand(t *testing.T) {
+	cmd := PrepareCommand("echo 'Hello, World!' | wc -c")
+
+	expectedCmd := exec.Command("bash", "-c", "echo 'Hello, World!' | wc -c")
+
+	assert.Equal(t, expectedCmd.Args, cmd.Args, "The command arguments should be the same.")
 }
 
 func testPrepareEditSettingsCommand(t *testing.T) {
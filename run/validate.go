@@ -0,0 +1,138 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// shellBuiltins are the bash builtins and keywords, run without a program
+var shellBuiltins = map[string]bool{
+	".": true, ":": true, "[": true, "alias": true, "bg": true, "bind": true,
+	"break": true, "builtin": true, "caller": true, "cd": true, "command": true,
+	"compgen": true, "complete": true, "continue": true, "declare": true,
+	"dirs": true, "disown": true, "echo": true, "enable": true, "eval": true,
+	"exec": true, "exit": true, "export": true, "false": true, "fc": true,
+	"fg": true, "getopts": true, "hash": true, "help": true, "history": true,
+	"jobs": true, "kill": true, "let": true, "local": true, "logout": true,
+	"mapfile": true, "popd": true, "printf": true, "pushd": true, "pwd": true,
+	"read": true, "readarray": true, "readonly": true, "return": true,
+	"set": true, "shift": true, "shopt": true, "source": true, "suspend": true,
+	"test": true, "time": true, "times": true, "trap": true, "true": true,
+	"type": true, "typeset": true, "ulimit": true, "umask": true,
+	"unalias": true, "unset": true, "wait": true,
+}
+
+// commandWrappers run the command following their options, like sudo, and
+// the number of arguments they take before it, like the duration of timeout
+var commandWrappers = map[string]int{
+	"sudo": 0, "doas": 0, "env": 0, "nohup": 0, "nice": 0, "time": 0,
+	"timeout": 1, "exec": 0, "command": 0, "builtin": 0, "xargs": 0,
+	"watch": 0, "stdbuf": 0,
+}
+
+// UnwrapCommand returns where the command run by the wrappers starting a
+// simple command begins, like rm in sudo -E rm, 0 if it is not wrapped. As
+// the options of a wrapper may take a value, the command is ambiguous once a
+// wrapper is given options: it may then begin at any later word.
+func UnwrapCommand(words []string) (start int, ambiguous bool) {
+	for start < len(words) {
+		arguments, ok := commandWrappers[words[start]]
+		if !ok {
+			break
+		}
+
+		// env takes assignments before the command
+		env := words[start] == "env"
+		start++
+		for start < len(words) && (strings.HasPrefix(words[start], "-") || env && strings.Contains(words[start], "=")) {
+			ambiguous = ambiguous || strings.HasPrefix(words[start], "-")
+			start++
+		}
+		start = min(start+arguments, len(words))
+	}
+
+	return start, ambiguous
+}
+
+// ValidateCommand checks that a command line parses, as run by bash, and that
+// the programs it runs are found by lookPath, like exec.LookPath. Programs
+// given with a path, or from expansions like $EDITOR, are not checked.
+func ValidateCommand(command string, lookPath func(file string) (string, error)) error {
+	if strings.TrimSpace(command) == "" {
+		return errors.New("the command is empty")
+	}
+
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(command), "")
+	if err != nil {
+		var parseErr syntax.ParseError
+		if errors.As(err, &parseErr) && strings.HasPrefix(parseErr.Text, "reached EOF without closing quote") {
+			return fmt.Errorf("the command has an unbalanced %s quote", parseErr.Text[len(parseErr.Text)-1:])
+		}
+		return fmt.Errorf("the command is not valid shell syntax: %w", err)
+	}
+
+	functions := map[string]bool{}
+	var programs []string
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.FuncDecl:
+			functions[node.Name.Value] = true
+		case *syntax.CallExpr:
+			programs = append(programs, commandPrograms(node.Args)...)
+		}
+		return true
+	})
+
+	var missing []string
+	for _, program := range programs {
+		if functions[program] || shellBuiltins[program] || strings.Contains(program, "/") {
+			continue
+		}
+		if _, err := lookPath(program); err != nil && !slices.Contains(missing, program) {
+			missing = append(missing, program)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the command runs programs not found in the PATH: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// commandPrograms returns the programs a simple command runs: its name, and
+// the command given to a wrapper like sudo, unless the wrapper is given
+// options. Words holding expansions are left out, their value being unknown.
+func commandPrograms(args []*syntax.Word) []string {
+	var words []string
+	for _, arg := range args {
+		name := arg.Lit()
+		if name == "" {
+			break
+		}
+		words = append(words, name)
+	}
+	if len(words) == 0 {
+		return nil
+	}
+
+	start, ambiguous := UnwrapCommand(words)
+	if start == 0 {
+		return words[:1]
+	}
+
+	var programs []string
+	for _, word := range words[:start] {
+		if _, ok := commandWrappers[word]; ok {
+			programs = append(programs, word)
+		}
+	}
+	if !ambiguous && start < len(words) {
+		programs = append(programs, words[start])
+	}
+
+	return programs
+}
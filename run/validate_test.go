@@ -0,0 +1,78 @@
+package run
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeLookPath finds a few common programs only
+func fakeLookPath(file string) (string, error) {
+	switch file {
+	case "ls", "grep", "git", "kubectl", "psql", "sudo", "env", "nohup":
+		return "/usr/bin/" + file, nil
+	}
+
+	return "", errors.New("executable file not found in $PATH")
+}
+
+func TestUnwrapCommand(t *testing.T) {
+	testCases := []struct {
+		command   string
+		start     int
+		ambiguous bool
+	}{
+		{"ls -la", 0, false},
+		{"sudo rm -rf /", 1, false},
+		{"env LANG=C nohup kubectl get pods", 3, false},
+		{"timeout 5 make test", 2, false},
+		{"timeout -s KILL 5 make test", 3, true},
+		{"sudo -u postgres psql", 2, true},
+		{"sudo", 1, false},
+	}
+
+	for _, tc := range testCases {
+		start, ambiguous := UnwrapCommand(strings.Fields(tc.command))
+		assert.Equal(t, tc.start, start, tc.command)
+		assert.Equal(t, tc.ambiguous, ambiguous, tc.command)
+	}
+}
+
+func TestValidateCommand(t *testing.T) {
+	testCases := []struct {
+		command string
+		err     string
+	}{
+		{"ls -la ~", ""},
+		{"git log --oneline | grep fix && echo done", ""},
+		{"for f in *.go; do grep -c TODO \"$f\"; done", ""},
+		{"[[ -d build ]] || ls $(git rev-parse --show-toplevel)", ""},
+		{"count() { ls | grep -c .; }; count", ""},
+		{"FOO=1 sudo -u postgres psql -c 'SELECT 1'", ""},
+		{"sudo env LANG=C kubectl get pods", ""},
+		{"./build.sh && $EDITOR notes.txt", ""},
+		{"", "the command is empty"},
+		{"grep 'unclosed *.go", "the command has an unbalanced ' quote"},
+		{"echo \"unclosed", "the command has an unbalanced \" quote"},
+		{"ls |", "the command is not valid shell syntax: 1:4: | must be followed by a statement"},
+		{"if true; then ls", "the command is not valid shell syntax: 1:1: if statement must end with \"fi\""},
+		{"lsof -i :8080 | grep LISTEN", "the command runs programs not found in the PATH: lsof"},
+		{"sudo htop || sudo nohup htop; kubectl get pods", "the command runs programs not found in the PATH: htop"},
+		{"timeout 5 nonexistent-cmd", "the command runs programs not found in the PATH: timeout, nonexistent-cmd"},
+		{"ls | xargs -0 rm", "the command runs programs not found in the PATH: xargs"},
+		{"git status && jq . <(docker ps --format json)", "the command runs programs not found in the PATH: jq, docker"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.command, func(t *testing.T) {
+			err := ValidateCommand(tc.command, fakeLookPath)
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
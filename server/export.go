@@ -0,0 +1,185 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xsikor/yai/cli"
+	"github.com/xsikor/yai/export"
+)
+
+const exportTimeout = 30 * time.Second
+
+// exportUsage tells what can be exported: the REPL only saves its last
+// conversation, when it ends
+const exportUsage = `Usage: yai export -session <id> [flags]
+       yai export -last [flags]
+
+Writes the conversation of a session of a running "yai serve" server, listed
+by GET /sessions, or the last conversation of the REPL, saved when it ends.
+The current REPL conversation is exported from the REPL with /export <path>.
+
+Flags:
+`
+
+// RunExport parses the export command flags, and writes the conversation of
+// a session of a running server, returning the exit code
+func RunExport(args []string) int {
+	return runExport(args, os.Stdout, os.Stderr)
+}
+
+// exportFlags are the flags of the export command
+type exportFlags struct {
+	sessionID   string
+	format      string
+	output      string
+	serverURL   string
+	token       string
+	withContext bool
+	last        bool
+}
+
+func newExportFlagSet(flags *exportFlags) *flag.FlagSet {
+	flagSet := flag.NewFlagSet("yai export", flag.ContinueOnError)
+	flagSet.StringVar(&flags.sessionID, "session", "", "id of the session to export, see GET /sessions")
+	flagSet.StringVar(&flags.format, "format", "", "md, json or html, defaults to the extension of -o, or md")
+	flagSet.StringVar(&flags.output, "o", "", "file to write, defaults to the standard output")
+	flagSet.StringVar(&flags.serverURL, "url", "http://"+DefaultListen, "address of the yai serve server")
+	flagSet.StringVar(&flags.token, "token", os.Getenv(TokenEnv), "bearer token of the server, defaults to $"+TokenEnv)
+	flagSet.BoolVar(&flags.withContext, "context", false, "include the system prompt, piped input and attached files")
+	flagSet.BoolVar(&flags.last, "last", false, "export the last conversation of the REPL instead of a session")
+
+	return flagSet
+}
+
+// IsExportCommand tells if the arguments, without the program name, run the
+// export command rather than a prompt starting with export, like "export
+// PATH to include ~/bin"
+func IsExportCommand(args []string) bool {
+	return isSubcommand(args, "export", newExportFlagSet(&exportFlags{}))
+}
+
+func runExport(args []string, stdout io.Writer, stderr io.Writer) int {
+	var flags exportFlags
+	flagSet := newExportFlagSet(&flags)
+	flagSet.SetOutput(stderr)
+	flagSet.Usage = func() {
+		fmt.Fprint(stderr, exportUsage)
+		flagSet.PrintDefaults()
+	}
+
+	if err := flagSet.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return cli.ExitSuccess
+		}
+		return cli.ExitUsage
+	}
+	if flagSet.NArg() > 0 {
+		fmt.Fprintf(stderr, "[error] unexpected arguments: %s\n", strings.Join(flagSet.Args(), " "))
+		return cli.ExitUsage
+	}
+	sessionID, output := flags.sessionID, flags.output
+	if sessionID == "" && !flags.last {
+		fmt.Fprintln(stderr, "[error] the -session flag is required, or -last for the last REPL conversation")
+		return cli.ExitUsage
+	}
+	if sessionID != "" && flags.last {
+		fmt.Fprintln(stderr, "[error] -session and -last cannot be used together")
+		return cli.ExitUsage
+	}
+
+	format := export.MarkdownFormat
+	if output != "" {
+		format = export.FormatOf(output)
+	}
+	if flags.format != "" {
+		parsed, err := export.ParseFormat(flags.format)
+		if err != nil {
+			fmt.Fprintf(stderr, "[error] %s\n", err)
+			return cli.ExitUsage
+		}
+		format = parsed
+	}
+
+	var content []byte
+	var err error
+	if flags.last {
+		content, err = renderLastConversation(format, flags.withContext)
+	} else {
+		content, err = fetchExport(flags.serverURL, flags.token, sessionID, format, flags.withContext)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "[error] %s\n", err)
+		return cli.ExitError
+	}
+
+	if output == "" {
+		_, err = stdout.Write(content)
+	} else {
+		err = os.WriteFile(output, content, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "[error] %s\n", err)
+		return cli.ExitError
+	}
+
+	return cli.ExitSuccess
+}
+
+// renderLastConversation renders the last conversation saved by the REPL
+func renderLastConversation(format export.Format, withContext bool) ([]byte, error) {
+	conversation, err := export.LoadLastConversation()
+	if err != nil {
+		return nil, err
+	}
+
+	return export.Render(conversation, format, export.Options{Context: withContext})
+}
+
+// fetchExport asks the server for the export of the session
+func fetchExport(serverURL string, token string, sessionID string, format export.Format, withContext bool) ([]byte, error) {
+	query := url.Values{}
+	query.Set("format", string(format))
+	if withContext {
+		query.Set("context", "true")
+	}
+	endpoint := fmt.Sprintf("%s/sessions/%s/export?%s", strings.TrimRight(serverURL, "/"), url.PathEscape(sessionID), query.Encode())
+
+	request, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: exportTimeout}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	content, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		var body errorResponse
+		if json.Unmarshal(content, &body) == nil && body.Error != "" {
+			return nil, fmt.Errorf("%s: %s", response.Status, body.Error)
+		}
+		return nil, errors.New(response.Status)
+	}
+
+	return content, nil
+}
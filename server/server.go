@@ -0,0 +1,691 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/xsikor/yai/ai"
+	"github.com/xsikor/yai/ai/provider"
+	"github.com/xsikor/yai/cli"
+	"github.com/xsikor/yai/config"
+	"github.com/xsikor/yai/export"
+	"github.com/xsikor/yai/run"
+)
+
+const (
+	// DefaultListen is the address served on if none is given, only
+	// reachable from the local machine
+	DefaultListen = "127.0.0.1:8765"
+	// TokenEnv is the environment variable giving the bearer token, so it
+	// does not show up in the process list as the flag would
+	TokenEnv = "YAI_SERVE_TOKEN"
+
+	maxRequestBytes = 1 << 20
+	maxOutputBytes  = 1 << 20
+	execTimeout     = 60 * time.Second
+	shutdownTimeout = 5 * time.Second
+)
+
+var (
+	errExecDisabled  = errors.New("running commands is disabled, start the server with --allow-exec")
+	errExecNeedToken = errors.New("running commands needs a token, start the server with --token or $" + TokenEnv)
+	errEmptyPrompt   = errors.New("the prompt is empty")
+	errCrossOrigin   = errors.New("requests from web pages are refused")
+	errContentType   = errors.New("the request body must be application/json")
+	errInvalidHost   = errors.New("invalid host, use the address the server listens on")
+)
+
+// ExecRequest is the body of POST /exec
+type ExecRequest struct {
+	Prompt  string `json:"prompt"`
+	Session string `json:"session,omitempty"`
+	Pipe    string `json:"pipe,omitempty"`
+	// Run asks the server to run the generated command, only honoured if
+	// the server allows it and no command policy denies the command
+	Run bool `json:"run,omitempty"`
+}
+
+// ExecResponse is the answer to POST /exec, with the output of the command
+// if it was run
+type ExecResponse struct {
+	cli.ExecResult
+	Output   *string `json:"output,omitempty"`
+	ExitCode *int    `json:"exit_code,omitempty"`
+}
+
+// ChatRequest is the body of POST /chat
+type ChatRequest struct {
+	Prompt  string `json:"prompt"`
+	Session string `json:"session,omitempty"`
+	Pipe    string `json:"pipe,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server exposes the engine over HTTP. Requests without a session are
+// answered by a fresh engine, requests with one continue its conversation.
+// Generated commands are only returned, unless running them is allowed with
+// SetAllowExec. Requests from web pages are refused, browsers sending them
+// with an Origin header, and so are the ones for another host than the
+// listen address, as a page rebinding its domain to it would send.
+type Server struct {
+	config    *config.Config
+	token     string
+	allowExec bool
+	listen    string
+	sessions  *sessions
+	newEngine func(mode ai.EngineMode) (*ai.Engine, error)
+}
+
+func NewServer(cfg *config.Config) *Server {
+	return &Server{
+		config:   cfg,
+		listen:   DefaultListen,
+		sessions: newSessions(),
+		newEngine: func(mode ai.EngineMode) (*ai.Engine, error) {
+			return ai.NewEngine(mode, cfg)
+		},
+	}
+}
+
+// SetToken makes every request need the "Authorization: Bearer <token>"
+// header, no token meaning no check
+func (s *Server) SetToken(token string) *Server {
+	s.token = token
+
+	return s
+}
+
+// SetListen sets the address the server listens on, the only host requests
+// are accepted for
+func (s *Server) SetListen(listen string) *Server {
+	s.listen = listen
+
+	return s
+}
+
+// SetAllowExec lets clients ask for the generated commands to be run on the
+// server, only done if a token is set as well
+func (s *Server) SetAllowExec(allowExec bool) *Server {
+	s.allowExec = allowExec
+
+	return s
+}
+
+// Handler returns the HTTP handler of the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", s.handleHealth)
+	mux.HandleFunc("POST /exec", s.handleExec)
+	mux.HandleFunc("POST /chat", s.handleChat)
+	mux.HandleFunc("GET /sessions", s.handleListSessions)
+	mux.HandleFunc("POST /sessions", s.handleCreateSession)
+	mux.HandleFunc("DELETE /sessions/{id}", s.handleDeleteSession)
+	mux.HandleFunc("POST /sessions/{id}/reset", s.handleResetSession)
+	mux.HandleFunc("GET /sessions/{id}/export", s.handleExportSession)
+
+	return s.guard(s.authenticate(mux))
+}
+
+// serveFlags are the flags of the serve command
+type serveFlags struct {
+	listen    string
+	token     string
+	provider  string
+	model     string
+	profile   string
+	allowExec bool
+}
+
+func newServeFlagSet(flags *serveFlags) *flag.FlagSet {
+	flagSet := flag.NewFlagSet("yai serve", flag.ContinueOnError)
+	flagSet.StringVar(&flags.listen, "listen", DefaultListen, "address to listen on")
+	flagSet.StringVar(&flags.token, "token", os.Getenv(TokenEnv), "bearer token required by every request, defaults to $"+TokenEnv)
+	flagSet.BoolVar(&flags.allowExec, "allow-exec", false, "let clients run the generated commands on this machine")
+	flagSet.StringVar(&flags.provider, "p", "", "AI provider (openai, claude, gemini)")
+	flagSet.StringVar(&flags.model, "model", "", "specific model to use")
+	flagSet.StringVar(&flags.profile, "profile", "", "config profile to use")
+
+	return flagSet
+}
+
+// IsServeCommand tells if the arguments, without the program name, run the
+// serve command rather than a prompt starting with serve, like "serve the
+// current folder over http"
+func IsServeCommand(args []string) bool {
+	return isSubcommand(args, "serve", newServeFlagSet(&serveFlags{}))
+}
+
+// isSubcommand tells if the arguments run the subcommand name: its flags
+// must parse, without any other argument
+func isSubcommand(args []string, name string, flagSet *flag.FlagSet) bool {
+	if len(args) == 0 || args[0] != name {
+		return false
+	}
+
+	flagSet.SetOutput(io.Discard)
+	err := flagSet.Parse(args[1:])
+
+	return (err == nil || errors.Is(err, flag.ErrHelp)) && flagSet.NArg() == 0
+}
+
+// Run parses the serve command flags, and serves until interrupted,
+// returning the exit code
+func Run(args []string) int {
+	var flags serveFlags
+	flagSet := newServeFlagSet(&flags)
+	if err := flagSet.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return cli.ExitSuccess
+		}
+		return cli.ExitUsage
+	}
+	if flagSet.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "[error] unexpected arguments: %s\n", strings.Join(flagSet.Args(), " "))
+		return cli.ExitUsage
+	}
+
+	listen, token, allowExec := flags.listen, flags.token, flags.allowExec
+	if allowExec && token == "" {
+		fmt.Fprintf(os.Stderr, "[error] %s\n", errExecNeedToken)
+		return cli.ExitUsage
+	}
+
+	overrides := config.Overrides{
+		Profile: flags.profile,
+		Model:   flags.model,
+	}
+	if flags.provider != "" {
+		providerType, err := provider.ParseProviderType(flags.provider)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[error] %s\n", err)
+			return cli.ExitUsage
+		}
+		overrides.ProviderType = providerType
+	}
+
+	cfg, err := config.NewConfigWithOverrides(overrides)
+	if err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
+			err = errors.New("no config file found, run yai once interactively to create it")
+		}
+		fmt.Fprintf(os.Stderr, "[error] %s\n", err)
+		return cli.ExitError
+	}
+
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[error] %s\n", err)
+		return cli.ExitError
+	}
+
+	if token == "" && !isLoopback(listen) {
+		fmt.Fprintf(os.Stderr, "[warning] %s is reachable from other machines and no token is set, anyone can use your AI provider\n", listen)
+	}
+	if allowExec {
+		fmt.Fprintln(os.Stderr, "[warning] clients can run the generated commands on this machine")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	httpServer := &http.Server{
+		Handler:           NewServer(cfg).SetToken(token).SetAllowExec(allowExec).SetListen(listener.Addr().String()).Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		// Requests are cancelled on shutdown, so chat streams end properly
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- httpServer.Serve(listener)
+	}()
+	fmt.Fprintf(os.Stderr, "listening on http://%s\n", listener.Addr())
+
+	select {
+	case err := <-served:
+		fmt.Fprintf(os.Stderr, "[error] %s\n", err)
+		return cli.ExitError
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		fmt.Fprintf(os.Stderr, "[error] %s\n", err)
+		return cli.ExitError
+	}
+
+	return cli.ExitSuccess
+}
+
+// guard refuses the requests a web page could send: carrying an Origin
+// header, with a body a form could send, or for another host
+func (s *Server) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Origin") != "" {
+			writeError(w, http.StatusForbidden, errCrossOrigin)
+			return
+		}
+		if r.ContentLength != 0 || r.Header.Get("Content-Type") != "" {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || mediaType != "application/json" {
+				writeError(w, http.StatusUnsupportedMediaType, errContentType)
+				return
+			}
+		}
+		if !isListenHost(r.Host, s.listen) {
+			writeError(w, http.StatusMisdirectedRequest, errInvalidHost)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeError(w, http.StatusUnauthorized, errors.New("invalid or missing bearer token"))
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status":     "ok",
+		"provider":   string(s.config.GetAiConfig().GetProviderType()),
+		"model":      s.config.GetAiConfig().GetModel(),
+		"allow_exec": s.allowExec,
+	})
+}
+
+func (s *Server) handleExec(w http.ResponseWriter, r *http.Request) {
+	var request ExecRequest
+	if !readRequest(w, r, &request) {
+		return
+	}
+	if request.Run && !s.allowExec {
+		writeError(w, http.StatusForbidden, errExecDisabled)
+		return
+	}
+	if request.Run && s.token == "" {
+		writeError(w, http.StatusForbidden, errExecNeedToken)
+		return
+	}
+
+	engine, release, err := s.acquireEngine(request.Session, ai.ExecEngineMode, request.Pipe)
+	if err != nil {
+		writeEngineError(w, err)
+		return
+	}
+	defer release()
+
+	output, err := engine.ExecCompletion(request.Prompt)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	verdict, pattern := s.config.GetCommandPolicy().Check(output.GetCommand())
+	response := ExecResponse{
+		ExecResult: cli.ExecResult{
+			Command:       output.GetCommand(),
+			Explanation:   output.GetExplanation(),
+			Executable:    output.IsExecutable(),
+			Provider:      string(s.config.GetAiConfig().GetProviderType()),
+			Model:         s.config.GetAiConfig().GetModel(),
+			Tokens:        engine.GetLastUsage(),
+			Risk:          run.AssessRisk(output.GetCommand()).String(),
+			Policy:        verdict.String(),
+			PolicyPattern: pattern,
+		},
+	}
+
+	// Without anyone to confirm, only the commands a policy does not deny
+	// nor ask to confirm are run
+	if request.Run && output.IsExecutable() && verdict != config.PolicyDeny && verdict != config.PolicyConfirm {
+		commandOutput, exitCode, err := runCommand(r.Context(), output.GetCommand())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Output = &commandOutput
+		response.ExitCode = &exitCode
+		engine.AddCommandRun(output.GetCommand(), commandOutput, exitCode)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	var request ChatRequest
+	if !readRequest(w, r, &request) {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+
+	engine, release, err := s.acquireEngine(request.Session, ai.ChatEngineMode, request.Pipe)
+	if err != nil {
+		writeEngineError(w, err)
+		return
+	}
+	defer release()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event cli.ChatEvent) {
+		// Values are plain structs, encoding them cannot fail
+		encoded, _ := json.Marshal(event)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, encoded)
+		flusher.Flush()
+	}
+
+	aiConfig := s.config.GetAiConfig()
+	send(cli.ChatEvent{Type: cli.StartEvent, Provider: string(aiConfig.GetProviderType()), Model: aiConfig.GetModel()})
+
+	stream := engine.ChatStreamCompletion(request.Prompt)
+	// A client going away interrupts the answer
+	stop := context.AfterFunc(r.Context(), stream.Cancel)
+	defer stop()
+
+	var content string
+	for {
+		output := stream.Next()
+		content += output.GetContent()
+
+		if output.GetContent() != "" {
+			send(cli.ChatEvent{Type: cli.DeltaEvent, Content: output.GetContent()})
+		}
+
+		if !output.IsLast() {
+			continue
+		}
+
+		switch {
+		case output.GetError() != nil:
+			send(cli.ChatEvent{Type: cli.ErrorEvent, Error: output.GetError().Error()})
+		case output.IsInterrupt():
+			send(cli.ChatEvent{Type: cli.InterruptEvent})
+		default:
+			usage := engine.GetLastUsage()
+			send(cli.ChatEvent{Type: cli.DoneEvent, Content: content, Tokens: &usage})
+		}
+		return
+	}
+}
+
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.sessions.list())
+}
+
+func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	engine, err := s.newEngine(ai.ChatEngineMode)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	created, err := s.sessions.create(engine)
+	if err != nil {
+		writeEngineError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created.info())
+}
+
+func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	deleted, err := s.sessions.delete(r.PathValue("id"))
+	if err != nil {
+		writeEngineError(w, err)
+		return
+	}
+	deleted.engine.Interrupt()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleResetSession(w http.ResponseWriter, r *http.Request) {
+	found, err := s.sessions.get(r.PathValue("id"))
+	if err != nil {
+		writeEngineError(w, err)
+		return
+	}
+
+	found.requestMu.Lock()
+	found.engine.Reset()
+	found.requestMu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleExportSession answers with the conversation of the session, as
+// markdown unless the format query parameter says otherwise, with the
+// context sent along with every request if the context one is set
+func (s *Server) handleExportSession(w http.ResponseWriter, r *http.Request) {
+	found, err := s.sessions.get(r.PathValue("id"))
+	if err != nil {
+		writeEngineError(w, err)
+		return
+	}
+
+	format := export.MarkdownFormat
+	if name := r.URL.Query().Get("format"); name != "" {
+		if format, err = export.ParseFormat(name); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	options := export.Options{}
+	if value := r.URL.Query().Get("context"); value != "" {
+		if options.Context, err = strconv.ParseBool(value); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid context: %s", value))
+			return
+		}
+	}
+
+	content, err := export.Render(found.engine.GetConversation(), format, options)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(content)
+}
+
+// acquireEngine returns the engine answering a request, set to mode, and the
+// function to call once the request is answered. Without a session, the
+// engine is a fresh one.
+func (s *Server) acquireEngine(sessionID string, mode ai.EngineMode, pipe string) (*ai.Engine, func(), error) {
+	if sessionID == "" {
+		engine, err := s.newEngine(mode)
+		if err != nil {
+			return nil, nil, err
+		}
+		if pipe != "" {
+			engine.SetPipe(pipe)
+		}
+		engine.SetMode(mode)
+
+		return engine, func() {}, nil
+	}
+
+	found, err := s.sessions.get(sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	found.requestMu.Lock()
+	if pipe != "" {
+		found.engine.SetPipe(pipe)
+	}
+	// The endpoint decides the mode, not the piped content
+	found.engine.SetMode(mode)
+
+	return found.engine, found.requestMu.Unlock, nil
+}
+
+// runCommand runs a generated command without input, returning its
+// combined output, cut after maxOutputBytes, and its exit code. The command
+// is killed if the request is cancelled or takes longer than execTimeout.
+func runCommand(ctx context.Context, command string) (string, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, execTimeout)
+	defer cancel()
+
+	var output limitedBuffer
+	output.limit = maxOutputBytes
+
+	cmd := run.PrepareCommandContext(ctx, command)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return "", 0, err
+		}
+		return output.String(), exitErr.ExitCode(), nil
+	}
+
+	return output.String(), 0, nil
+}
+
+// limitedBuffer keeps the first limit bytes written, dropping the others
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+
+	return len(p), nil
+}
+
+// readRequest decodes the JSON body of a prompt request, answering with an
+// error if it is invalid
+func readRequest(w http.ResponseWriter, r *http.Request, request any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return false
+	}
+
+	var prompt string
+	switch request := request.(type) {
+	case *ExecRequest:
+		prompt = request.Prompt
+	case *ChatRequest:
+		prompt = request.Prompt
+	}
+	if strings.TrimSpace(prompt) == "" {
+		writeError(w, http.StatusBadRequest, errEmptyPrompt)
+		return false
+	}
+
+	return true
+}
+
+func writeEngineError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errSessionNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, errTooManySessions):
+		writeError(w, http.StatusTooManyRequests, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	// Values are plain structs, encoding them cannot fail
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// isListenHost tells if the host of a request is the listen address. A server
+// listening on a loopback or any address also accepts localhost and the IP
+// addresses on its port, a rebound domain name never being one of them.
+func isListenHost(host string, listen string) bool {
+	if host == listen {
+		return true
+	}
+
+	hostName, hostPort, err := net.SplitHostPort(host)
+	if err != nil {
+		return false
+	}
+	listenName, listenPort, err := net.SplitHostPort(listen)
+	if err != nil || hostPort != listenPort {
+		return false
+	}
+	if hostName == listenName {
+		return true
+	}
+
+	ip := net.ParseIP(listenName)
+	if listenName != "" && listenName != "localhost" && (ip == nil || !ip.IsLoopback() && !ip.IsUnspecified()) {
+		return false
+	}
+
+	return hostName == "localhost" || net.ParseIP(hostName) != nil
+}
+
+func isLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,464 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xsikor/yai/ai"
+	"github.com/xsikor/yai/cli"
+	"github.com/xsikor/yai/config"
+	"github.com/xsikor/yai/export"
+	"github.com/xsikor/yai/internal/testprovider"
+)
+
+func TestServer(t *testing.T) {
+	t.Run("Exec", testExec)
+	t.Run("ExecRunDisabled", testExecRunDisabled)
+	t.Run("ExecRun", testExecRun)
+	t.Run("Chat", testChat)
+	t.Run("Token", testToken)
+	t.Run("Guard", testGuard)
+	t.Run("ExecRunNeedsToken", testExecRunNeedsToken)
+	t.Run("ExecRunConfirm", testExecRunConfirm)
+	t.Run("Sessions", testSessions)
+	t.Run("ExportSession", testExportSession)
+	t.Run("RunExport", testRunExport)
+	t.Run("RunExportLast", testRunExportLast)
+	t.Run("InvalidRequest", testInvalidRequest)
+	t.Run("IsLoopback", testIsLoopback)
+	t.Run("IsListenHost", testIsListenHost)
+	t.Run("IsServeCommand", testIsServeCommand)
+	t.Run("IsExportCommand", testIsExportCommand)
+}
+
+func newTestServer(chunks ...string) *Server {
+	s := NewServer(&config.Config{})
+	s.newEngine = func(mode ai.EngineMode) (*ai.Engine, error) {
+		return ai.NewEngineWithProvider(mode, &config.Config{}, &testprovider.Provider{Chunks: chunks}), nil
+	}
+
+	return s
+}
+
+// loadConfig loads the config from a config file with the given content
+func loadConfig(t *testing.T, content string) *config.Config {
+	t.Helper()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "yai.json"), []byte(content), 0o600))
+	viper.Reset()
+	viper.AddConfigPath(dir)
+	t.Cleanup(viper.Reset)
+	t.Setenv("OPENAI_API_KEY", "key")
+
+	cfg, err := config.NewConfig()
+	require.NoError(t, err)
+
+	return cfg
+}
+
+// serverToken is the token of the test servers allowing to run commands
+const serverToken = "secret"
+
+// newRequest returns a request as a client of the API sends it
+func newRequest(method string, path string, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Host = DefaultListen
+	req.Header.Set("Authorization", "Bearer "+serverToken)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return req
+}
+
+func request(t *testing.T, s *Server, method string, path string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, newRequest(method, path, body))
+
+	return recorder
+}
+
+func decode[T any](t *testing.T, recorder *httptest.ResponseRecorder) T {
+	t.Helper()
+
+	var value T
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &value))
+
+	return value
+}
+
+func testExec(t *testing.T) {
+	s := newTestServer(`{"cmd":"ls -la","exp":"list files","exec":true}`)
+
+	recorder := request(t, s, http.MethodPost, "/exec", `{"prompt":"list files"}`)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	response := decode[ExecResponse](t, recorder)
+	assert.Equal(t, "ls -la", response.Command)
+	assert.Equal(t, "list files", response.Explanation)
+	assert.True(t, response.Executable)
+	assert.Equal(t, "low", response.Risk)
+	assert.Nil(t, response.Output)
+	assert.Nil(t, response.ExitCode)
+}
+
+func testExecRunDisabled(t *testing.T) {
+	s := newTestServer(`{"cmd":"echo hi","exp":"say hi","exec":true}`)
+
+	recorder := request(t, s, http.MethodPost, "/exec", `{"prompt":"say hi","run":true}`)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	assert.Equal(t, errExecDisabled.Error(), decode[errorResponse](t, recorder).Error)
+}
+
+func testExecRun(t *testing.T) {
+	s := newTestServer(`{"cmd":"echo hi; exit 3","exp":"say hi","exec":true}`).SetToken(serverToken).SetAllowExec(true)
+
+	recorder := request(t, s, http.MethodPost, "/exec", `{"prompt":"say hi","run":true}`)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	response := decode[ExecResponse](t, recorder)
+	require.NotNil(t, response.Output)
+	require.NotNil(t, response.ExitCode)
+	assert.Equal(t, "hi\n", *response.Output)
+	assert.Equal(t, 3, *response.ExitCode)
+}
+
+func testChat(t *testing.T) {
+	s := newTestServer("Hello", " world")
+
+	recorder := request(t, s, http.MethodPost, "/chat", `{"prompt":"hi"}`)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/event-stream", recorder.Header().Get("Content-Type"))
+
+	var events []cli.ChatEvent
+	for _, block := range strings.Split(strings.TrimSpace(recorder.Body.String()), "\n\n") {
+		lines := strings.Split(block, "\n")
+		require.Len(t, lines, 2)
+
+		var event cli.ChatEvent
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &event))
+		assert.Equal(t, "event: "+event.Type, lines[0])
+		events = append(events, event)
+	}
+
+	require.Len(t, events, 4)
+	assert.Equal(t, cli.StartEvent, events[0].Type)
+	assert.Equal(t, "Hello", events[1].Content)
+	assert.Equal(t, " world", events[2].Content)
+	assert.Equal(t, cli.DoneEvent, events[3].Type)
+	assert.Equal(t, "Hello world", events[3].Content)
+	assert.NotNil(t, events[3].Tokens)
+}
+
+func testToken(t *testing.T) {
+	s := newTestServer().SetToken(serverToken)
+
+	req := newRequest(http.MethodGet, "/sessions", "")
+	req.Header.Del("Authorization")
+	recorder := httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	for _, authorization := range []string{"Bearer wrong", "secret", "Basic secret"} {
+		req := newRequest(http.MethodGet, "/sessions", "")
+		req.Header.Set("Authorization", authorization)
+		recorder = httptest.NewRecorder()
+		s.Handler().ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code, authorization)
+	}
+
+	req = newRequest(http.MethodGet, "/sessions", "")
+	recorder = httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func testGuard(t *testing.T) {
+	s := newTestServer(`{"cmd":"echo hi","exp":"say hi","exec":true}`).SetToken(serverToken).SetAllowExec(true)
+	body := `{"prompt":"say hi","run":true}`
+
+	// A page of another site posting to the server
+	req := newRequest(http.MethodPost, "/exec", body)
+	req.Header.Set("Origin", "https://example.com")
+	recorder := httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	assert.Equal(t, errCrossOrigin.Error(), decode[errorResponse](t, recorder).Error)
+
+	// A form, or a request without content type
+	for _, contentType := range []string{"text/plain", "application/x-www-form-urlencoded", ""} {
+		req = newRequest(http.MethodPost, "/exec", body)
+		req.Header.Set("Content-Type", contentType)
+		recorder = httptest.NewRecorder()
+		s.Handler().ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusUnsupportedMediaType, recorder.Code, contentType)
+	}
+
+	// A domain rebound to the listen address
+	for _, host := range []string{"attacker.example:8765", "127.0.0.1:9999", ""} {
+		req = newRequest(http.MethodPost, "/exec", body)
+		req.Host = host
+		recorder = httptest.NewRecorder()
+		s.Handler().ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusMisdirectedRequest, recorder.Code, host)
+	}
+
+	req = newRequest(http.MethodPost, "/exec", body)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Host = "localhost:8765"
+	recorder = httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func testExecRunNeedsToken(t *testing.T) {
+	s := newTestServer(`{"cmd":"echo hi","exp":"say hi","exec":true}`).SetAllowExec(true)
+
+	recorder := request(t, s, http.MethodPost, "/exec", `{"prompt":"say hi","run":true}`)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	assert.Equal(t, errExecNeedToken.Error(), decode[errorResponse](t, recorder).Error)
+
+	assert.Equal(t, cli.ExitUsage, Run([]string{"--allow-exec", "--token", ""}))
+}
+
+func testExecRunConfirm(t *testing.T) {
+	s := newTestServer(`{"cmd":"echo hi","exp":"say hi","exec":true}`).SetToken(serverToken).SetAllowExec(true)
+	s.config = loadConfig(t, `{"COMMAND_POLICIES": {"CONFIRM": ["echo"]}}`)
+
+	recorder := request(t, s, http.MethodPost, "/exec", `{"prompt":"say hi","run":true}`)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	response := decode[ExecResponse](t, recorder)
+	assert.Equal(t, "confirm", response.Policy)
+	assert.Nil(t, response.Output, "A command to confirm should not be run.")
+	assert.Nil(t, response.ExitCode)
+}
+
+func testSessions(t *testing.T) {
+	s := newTestServer("Hello")
+
+	recorder := request(t, s, http.MethodPost, "/sessions", "")
+	require.Equal(t, http.StatusCreated, recorder.Code)
+	created := decode[SessionInfo](t, recorder)
+	assert.Len(t, created.ID, 32)
+
+	recorder = request(t, s, http.MethodGet, "/sessions", "")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	listed := decode[[]SessionInfo](t, recorder)
+	require.Len(t, listed, 1)
+	assert.Equal(t, created.ID, listed[0].ID)
+
+	recorder = request(t, s, http.MethodPost, "/chat", `{"prompt":"hi","session":"`+created.ID+`"}`)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = request(t, s, http.MethodPost, "/sessions/"+created.ID+"/reset", "")
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	recorder = request(t, s, http.MethodDelete, "/sessions/"+created.ID, "")
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	recorder = request(t, s, http.MethodPost, "/chat", `{"prompt":"hi","session":"`+created.ID+`"}`)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = request(t, s, http.MethodDelete, "/sessions/"+created.ID, "")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func testExportSession(t *testing.T) {
+	s := newTestServer(`{"cmd":"echo hi; exit 3","exp":"say hi","exec":true}`).SetToken(serverToken).SetAllowExec(true)
+
+	recorder := request(t, s, http.MethodPost, "/sessions", "")
+	require.Equal(t, http.StatusCreated, recorder.Code)
+	id := decode[SessionInfo](t, recorder).ID
+
+	recorder = request(t, s, http.MethodPost, "/exec", `{"prompt":"say hi","run":true,"session":"`+id+`"}`)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = request(t, s, http.MethodGet, "/sessions/"+id+"/export", "")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/markdown; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Contains(t, recorder.Body.String(), "```sh\n$ echo hi; exit 3\n```\n\nExit code: 3\n\n```text\nhi\n```")
+	assert.NotContains(t, recorder.Body.String(), "System prompt")
+
+	recorder = request(t, s, http.MethodGet, "/sessions/"+id+"/export?format=json&context=true", "")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	conversation := decode[ai.Conversation](t, recorder)
+	require.Len(t, conversation.Entries, 3)
+	assert.Equal(t, ai.CommandRole, conversation.Entries[2].Role)
+	assert.NotEmpty(t, conversation.SystemPrompt)
+
+	for _, query := range []string{"?format=pdf", "?context=maybe"} {
+		recorder = request(t, s, http.MethodGet, "/sessions/"+id+"/export"+query, "")
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, query)
+	}
+
+	recorder = request(t, s, http.MethodGet, "/sessions/unknown/export", "")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func testRunExport(t *testing.T) {
+	s := newTestServer("Hello").SetToken("secret")
+	httpServer := httptest.NewServer(s.Handler())
+	defer httpServer.Close()
+	s.SetListen(httpServer.Listener.Addr().String())
+
+	engine, err := s.newEngine(ai.ChatEngineMode)
+	require.NoError(t, err)
+	created, err := s.sessions.create(engine)
+	require.NoError(t, err)
+	engine.AddCommandRun("uptime", "up 3 days\n", 0)
+
+	var stdout, stderr strings.Builder
+	code := runExport([]string{"-session", created.id, "-url", httpServer.URL, "-token", "secret", "-format", "json"}, &stdout, &stderr)
+	require.Equal(t, cli.ExitSuccess, code, stderr.String())
+	assert.Contains(t, stdout.String(), `"command": "uptime"`)
+
+	// The format follows the extension of the output file
+	output := filepath.Join(t.TempDir(), "session.html")
+	code = runExport([]string{"-session", created.id, "-url", httpServer.URL, "-token", "secret", "-o", output}, &stdout, &stderr)
+	require.Equal(t, cli.ExitSuccess, code, stderr.String())
+	content, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "<!DOCTYPE html>")
+
+	stderr.Reset()
+	code = runExport([]string{"-session", created.id, "-url", httpServer.URL}, &stdout, &stderr)
+	assert.Equal(t, cli.ExitError, code)
+	assert.Contains(t, stderr.String(), "invalid or missing bearer token")
+
+	assert.Equal(t, cli.ExitUsage, runExport([]string{"-url", httpServer.URL}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "the -session flag is required, or -last")
+	assert.Equal(t, cli.ExitUsage, runExport([]string{"-session", created.id, "-last"}, &stdout, &stderr))
+
+	// The usage tells the current REPL conversation is exported from the REPL
+	stderr.Reset()
+	assert.Equal(t, cli.ExitSuccess, runExport([]string{"-h"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), `running "yai serve" server`)
+	assert.Contains(t, stderr.String(), "/export <path>")
+	assert.Contains(t, stderr.String(), "-session")
+	assert.Contains(t, stderr.String(), "-last")
+	assert.Equal(t, cli.ExitUsage, runExport([]string{"-session", created.id, "-format", "pdf"}, &stdout, &stderr))
+}
+
+func testRunExportLast(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	homedir.Reset()
+	t.Cleanup(homedir.Reset)
+	require.True(t, strings.HasPrefix(export.GetLastConversationFile(), home))
+
+	var stdout, stderr strings.Builder
+	assert.Equal(t, cli.ExitError, runExport([]string{"-last"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "no REPL conversation was saved yet")
+
+	engine := ai.NewEngineWithProvider(ai.ExecEngineMode, &config.Config{}, &testprovider.Provider{})
+	engine.AddCommandRun("uptime", "up 3 days\n", 0)
+	require.NoError(t, export.SaveLastConversation(engine.GetConversation()))
+
+	stderr.Reset()
+	code := runExport([]string{"-last", "-format", "json"}, &stdout, &stderr)
+	require.Equal(t, cli.ExitSuccess, code, stderr.String())
+	assert.Contains(t, stdout.String(), `"command": "uptime"`)
+}
+
+func testInvalidRequest(t *testing.T) {
+	s := newTestServer()
+
+	for _, body := range []string{"", "{", `{"prompt":"  "}`, `{"prompt":"hi","unknown":1}`} {
+		recorder := request(t, s, http.MethodPost, "/exec", body)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, body)
+	}
+
+	recorder := request(t, s, http.MethodGet, "/exec", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+
+	recorder = request(t, s, http.MethodPost, "/chat", strings.Repeat("a", maxRequestBytes+1))
+	body, _ := io.ReadAll(recorder.Body)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code, string(body))
+}
+
+func testIsLoopback(t *testing.T) {
+	assert.True(t, isLoopback("127.0.0.1:8765"))
+	assert.True(t, isLoopback("localhost:8765"))
+	assert.True(t, isLoopback("[::1]:8765"))
+	assert.False(t, isLoopback(":8765"))
+	assert.False(t, isLoopback("0.0.0.0:8765"))
+	assert.False(t, isLoopback("192.168.1.10:8765"))
+}
+
+func testIsListenHost(t *testing.T) {
+	testCases := []struct {
+		host   string
+		listen string
+		want   bool
+	}{
+		{"127.0.0.1:8765", "127.0.0.1:8765", true},
+		{"localhost:8765", "127.0.0.1:8765", true},
+		{"[::1]:8765", "127.0.0.1:8765", true},
+		{"192.168.1.10:8765", ":8765", true},
+		{"yai.local:8765", "yai.local:8765", true},
+		{"rebound.example:8765", "127.0.0.1:8765", false},
+		{"rebound.example:8765", ":8765", false},
+		{"127.0.0.1:8765", "yai.local:8765", false},
+		{"127.0.0.1:80", "127.0.0.1:8765", false},
+		{"127.0.0.1", "127.0.0.1:8765", false},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.want, isListenHost(tc.host, tc.listen), tc.host+" on "+tc.listen)
+	}
+}
+
+func testIsServeCommand(t *testing.T) {
+	testCases := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"serve"}, true},
+		{[]string{"serve", "-listen", ":8080", "--allow-exec"}, true},
+		{[]string{"serve", "-h"}, true},
+		// Prompts starting with serve
+		{[]string{"serve", "the", "current", "folder", "over", "http"}, false},
+		{[]string{"serve", "-x", "files"}, false},
+		{[]string{"-e", "serve", "files"}, false},
+		{nil, false},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.want, IsServeCommand(tc.args), strings.Join(tc.args, " "))
+	}
+}
+
+func testIsExportCommand(t *testing.T) {
+	testCases := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"export", "-session", "abc", "-o", "session.md"}, true},
+		{[]string{"export", "--help"}, true},
+		{[]string{"export", "-last", "-o", "repl.md"}, true},
+		// Prompts starting with export
+		{[]string{"export", "PATH", "to", "include", "~/bin"}, false},
+		{[]string{"export", "-o", "session.md", "as", "markdown"}, false},
+		{[]string{"export"}, true},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.want, IsExportCommand(tc.args), strings.Join(tc.args, " "))
+	}
+}
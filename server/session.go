@@ -0,0 +1,134 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/xsikor/yai/ai"
+)
+
+// maxSessions bounds the memory used by the conversations kept by the server
+const maxSessions = 64
+
+var (
+	errSessionNotFound = errors.New("session not found")
+	errTooManySessions = errors.New("too many sessions, delete some first")
+)
+
+// session is a conversation kept between requests. Its requests are
+// answered one at a time, so the conversation stays in order.
+type session struct {
+	id        string
+	engine    *ai.Engine
+	created   time.Time
+	lastUsed  time.Time
+	requestMu sync.Mutex
+}
+
+// SessionInfo is the JSON description of a session
+type SessionInfo struct {
+	ID       string    `json:"id"`
+	Created  time.Time `json:"created"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// sessions holds the sessions by id
+type sessions struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+func newSessions() *sessions {
+	return &sessions{
+		sessions: make(map[string]*session),
+	}
+}
+
+func (s *sessions) create(engine *ai.Engine) (*session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.sessions) >= maxSessions {
+		return nil, errTooManySessions
+	}
+
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	created := &session{
+		id:       id,
+		engine:   engine,
+		created:  now,
+		lastUsed: now,
+	}
+	s.sessions[id] = created
+
+	return created, nil
+}
+
+func (s *sessions) get(id string) (*session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found, ok := s.sessions[id]
+	if !ok {
+		return nil, errSessionNotFound
+	}
+	found.lastUsed = time.Now()
+
+	return found, nil
+}
+
+func (s *sessions) delete(id string) (*session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found, ok := s.sessions[id]
+	if !ok {
+		return nil, errSessionNotFound
+	}
+	delete(s.sessions, id)
+
+	return found, nil
+}
+
+// list returns the sessions, the oldest first
+func (s *sessions) list() []SessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	infos := make([]SessionInfo, 0, len(s.sessions))
+	for _, found := range s.sessions {
+		infos = append(infos, found.info())
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Created.Before(infos[j].Created)
+	})
+
+	return infos
+}
+
+func (s *session) info() SessionInfo {
+	return SessionInfo{
+		ID:       s.id,
+		Created:  s.created,
+		LastUsed: s.lastUsed,
+	}
+}
+
+func newSessionID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(bytes), nil
+}
@@ -21,6 +21,7 @@ type Analysis struct {
 	username        string
 	editor          string
 	configFile      string
+	configDirectory string
 }
 
 func (a *Analysis) GetApplicationName() string {
@@ -55,6 +56,10 @@ func (a *Analysis) GetConfigFile() string {
 	return a.configFile
 }
 
+func (a *Analysis) GetConfigDirectory() string {
+	return a.configDirectory
+}
+
 func Analyse() *Analysis {
 	return &Analysis{
 		operatingSystem: GetOperatingSystem(),
@@ -64,6 +69,7 @@ func Analyse() *Analysis {
 		username:        GetUsername(),
 		editor:          GetEditor(),
 		configFile:      GetConfigFile(),
+		configDirectory: GetConfigDirectory(),
 	}
 }
 
@@ -134,3 +140,13 @@ func GetConfigFile() string {
 		strings.ToLower(APPLICATION_NAME),
 	)
 }
+
+// GetConfigDirectory returns the directory holding the files besides the
+// config file, like custom commands
+func GetConfigDirectory() string {
+	return fmt.Sprintf(
+		"%s/.config/%s",
+		GetHomeDirectory(),
+		strings.ToLower(APPLICATION_NAME),
+	)
+}
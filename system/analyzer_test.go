@@ -26,4 +26,5 @@ func testAnalyse(t *testing.T) {
 	assert.NotEmpty(t, analysis.GetHomeDirectory(), "Home directory should not be empty.")
 	assert.NotEmpty(t, analysis.GetUsername(), "Username should not be empty.")
 	assert.NotEmpty(t, analysis.GetConfigFile(), "Config file should not be empty.")
+	assert.NotEmpty(t, analysis.GetConfigDirectory(), "Config directory should not be empty.")
 }
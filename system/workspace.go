@@ -0,0 +1,308 @@
+package system
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// toolVersionTimeout bounds how long a tool can take to print its version
+const toolVersionTimeout = 2 * time.Second
+
+// knownTools are the CLIs looked for on PATH, with the arguments printing
+// their version without contacting any server
+var knownTools = []struct {
+	name string
+	args []string
+}{
+	{"docker", []string{"--version"}},
+	{"docker-compose", []string{"--version"}},
+	{"kubectl", []string{"version", "--client"}},
+	{"helm", []string{"version", "--short"}},
+	{"terraform", []string{"-version"}},
+	{"aws", []string{"--version"}},
+	{"gcloud", []string{"--version"}},
+	{"az", []string{"version"}},
+	{"go", []string{"version"}},
+	{"node", []string{"--version"}},
+	{"python3", []string{"--version"}},
+	{"cargo", []string{"--version"}},
+	{"make", []string{"--version"}},
+}
+
+// projectMarkers are the files telling the type of a project
+var projectMarkers = []struct {
+	file        string
+	projectType string
+}{
+	{"go.mod", "Go"},
+	{"package.json", "Node.js"},
+	{"Cargo.toml", "Rust"},
+	{"pyproject.toml", "Python"},
+	{"requirements.txt", "Python"},
+	{"pom.xml", "Java (Maven)"},
+	{"build.gradle", "Java (Gradle)"},
+	{"Gemfile", "Ruby"},
+	{"composer.json", "PHP"},
+	{"Makefile", "Make"},
+	{"Dockerfile", "Docker"},
+}
+
+var composeFiles = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+var makeTargetRegexp = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9_./-]*)\s*:([^=]|$)`)
+
+// Tool is a CLI found on PATH
+type Tool struct {
+	name    string
+	version string
+}
+
+func (t Tool) GetName() string {
+	return t.name
+}
+
+func (t Tool) GetVersion() string {
+	return t.version
+}
+
+// Workspace describes the project of the working directory
+type Workspace struct {
+	directory       string
+	gitRoot         string
+	gitBranch       string
+	gitDirty        bool
+	projectTypes    []string
+	makeTargets     []string
+	composeServices []string
+	tools           []Tool
+}
+
+func (w *Workspace) GetDirectory() string {
+	return w.directory
+}
+
+func (w *Workspace) GetGitRoot() string {
+	return w.gitRoot
+}
+
+func (w *Workspace) GetGitBranch() string {
+	return w.gitBranch
+}
+
+func (w *Workspace) IsGitDirty() bool {
+	return w.gitDirty
+}
+
+func (w *Workspace) GetProjectTypes() []string {
+	return w.projectTypes
+}
+
+func (w *Workspace) GetMakeTargets() []string {
+	return w.makeTargets
+}
+
+func (w *Workspace) GetComposeServices() []string {
+	return w.composeServices
+}
+
+func (w *Workspace) GetTools() []Tool {
+	return w.tools
+}
+
+// AnalyseWorkspace detects the git repository, the project type and the
+// tools available from a directory. Project files are looked for in the
+// directory, then in its parents up to the root of the git repository.
+func AnalyseWorkspace(directory string) *Workspace {
+	workspace := &Workspace{directory: directory}
+	workspace.readGitStatus()
+
+	projectDirectory := findProjectDirectory(directory, workspace.gitRoot)
+	if projectDirectory != "" {
+		workspace.projectTypes = detectProjectTypes(projectDirectory)
+		workspace.makeTargets = readMakeTargets(filepath.Join(projectDirectory, "Makefile"))
+		workspace.composeServices = readComposeServices(projectDirectory)
+	}
+
+	workspace.tools = detectTools()
+
+	return workspace
+}
+
+// RefreshGitStatus returns a copy of the workspace with the current branch
+// and state of its git repository, which change as commands are run
+func (w *Workspace) RefreshGitStatus() *Workspace {
+	refreshed := *w
+	refreshed.readGitStatus()
+
+	return &refreshed
+}
+
+func (w *Workspace) readGitStatus() {
+	w.gitRoot = gitOutput(w.directory, "rev-parse", "--show-toplevel")
+	w.gitBranch = ""
+	w.gitDirty = false
+	if w.gitRoot != "" {
+		w.gitBranch = gitOutput(w.directory, "rev-parse", "--abbrev-ref", "HEAD")
+		w.gitDirty = gitOutput(w.directory, "status", "--porcelain") != ""
+	}
+}
+
+func gitOutput(directory string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-C", directory}, args...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// findProjectDirectory returns the closest directory holding a project file,
+// not going above the git root, or empty if there is none
+func findProjectDirectory(directory string, gitRoot string) string {
+	dir := directory
+	for {
+		for _, marker := range projectMarkers {
+			if fileExists(filepath.Join(dir, marker.file)) {
+				return dir
+			}
+		}
+		for _, file := range composeFiles {
+			if fileExists(filepath.Join(dir, file)) {
+				return dir
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if gitRoot == "" || dir == gitRoot || parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func detectProjectTypes(directory string) []string {
+	var types []string
+	for _, marker := range projectMarkers {
+		if fileExists(filepath.Join(directory, marker.file)) && !slices.Contains(types, marker.projectType) {
+			types = append(types, marker.projectType)
+		}
+	}
+
+	return types
+}
+
+// readMakeTargets returns the explicit targets of a Makefile, leaving out
+// special targets like .PHONY and pattern rules
+func readMakeTargets(path string) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var targets []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		matches := makeTargetRegexp.FindStringSubmatch(scanner.Text())
+		if matches == nil || strings.Contains(matches[1], "%") || slices.Contains(targets, matches[1]) {
+			continue
+		}
+		targets = append(targets, matches[1])
+	}
+
+	return targets
+}
+
+func readComposeServices(directory string) []string {
+	for _, name := range composeFiles {
+		content, err := os.ReadFile(filepath.Join(directory, name))
+		if err != nil {
+			continue
+		}
+
+		var compose struct {
+			Services map[string]any `yaml:"services"`
+		}
+		if err := yaml.Unmarshal(content, &compose); err != nil {
+			return nil
+		}
+
+		services := make([]string, 0, len(compose.Services))
+		for service := range compose.Services {
+			services = append(services, service)
+		}
+		sort.Strings(services)
+
+		return services
+	}
+
+	return nil
+}
+
+// detectTools looks for the known tools on PATH, and asks them their
+// version concurrently since some are slow to start
+func detectTools() []Tool {
+	tools := make([]Tool, len(knownTools))
+
+	var wg sync.WaitGroup
+	for i, known := range knownTools {
+		path, err := exec.LookPath(known.name)
+		if err != nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, name string, path string, args []string) {
+			defer wg.Done()
+			tools[i] = Tool{name: name, version: toolVersion(path, args)}
+		}(i, known.name, path, known.args)
+	}
+	wg.Wait()
+
+	var found []Tool
+	for _, tool := range tools {
+		if tool.name != "" {
+			found = append(found, tool)
+		}
+	}
+
+	return found
+}
+
+// toolVersion returns the first line printed by the version command of a
+// tool, or empty if it failed or took too long
+func toolVersion(path string, args []string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), toolVersionTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, args...)
+	// Keep terraform from checking for updates
+	cmd.Env = append(os.Environ(), "CHECKPOINT_DISABLE=1")
+
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+
+	return strings.TrimSpace(line)
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,137 @@
+package system
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspace(t *testing.T) {
+	t.Run("AnalyseWorkspace", testAnalyseWorkspace)
+	t.Run("AnalyseWorkspaceOutsideGit", testAnalyseWorkspaceOutsideGit)
+	t.Run("RefreshGitStatus", testRefreshGitStatus)
+	t.Run("ReadMakeTargets", testReadMakeTargets)
+	t.Run("ReadComposeServices", testReadComposeServices)
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+}
+
+func testAnalyseWorkspace(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	root := t.TempDir()
+	git(t, root, "init", "-q", "-b", "main")
+	git(t, root, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "init")
+	writeFile(t, filepath.Join(root, "go.mod"), "module example.com/test\n")
+	writeFile(t, filepath.Join(root, "Makefile"), "build:\n\tgo build\n")
+
+	sub := filepath.Join(root, "cmd", "app")
+	require.NoError(t, os.MkdirAll(sub, 0o755))
+
+	workspace := AnalyseWorkspace(sub)
+
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	require.NoError(t, err)
+
+	assert.Equal(t, sub, workspace.GetDirectory())
+	assert.Equal(t, resolvedRoot, workspace.GetGitRoot())
+	assert.Equal(t, "main", workspace.GetGitBranch())
+	assert.True(t, workspace.IsGitDirty(), "Untracked files should make the workspace dirty.")
+	assert.Equal(t, []string{"Go", "Make"}, workspace.GetProjectTypes())
+	assert.Equal(t, []string{"build"}, workspace.GetMakeTargets())
+	assert.Empty(t, workspace.GetComposeServices())
+}
+
+func testAnalyseWorkspaceOutsideGit(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "package.json"), "{}")
+
+	sub := filepath.Join(root, "src")
+	require.NoError(t, os.MkdirAll(sub, 0o755))
+
+	workspace := AnalyseWorkspace(sub)
+	assert.Empty(t, workspace.GetGitRoot())
+	assert.Empty(t, workspace.GetProjectTypes(), "Project files above the directory should only be looked for in a git repository.")
+
+	workspace = AnalyseWorkspace(root)
+	assert.Equal(t, []string{"Node.js"}, workspace.GetProjectTypes())
+}
+
+func testRefreshGitStatus(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	root := t.TempDir()
+	git(t, root, "init", "-q", "-b", "main")
+	git(t, root, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "init")
+
+	workspace := AnalyseWorkspace(root)
+	assert.False(t, workspace.IsGitDirty())
+
+	writeFile(t, filepath.Join(root, "go.mod"), "module example.com/test\n")
+	git(t, root, "checkout", "-q", "-b", "feature")
+
+	refreshed := workspace.RefreshGitStatus()
+	assert.Equal(t, "feature", refreshed.GetGitBranch())
+	assert.True(t, refreshed.IsGitDirty())
+	assert.Equal(t, workspace.GetTools(), refreshed.GetTools())
+
+	// The analysis refreshed is left as is
+	assert.Equal(t, "main", workspace.GetGitBranch())
+	assert.False(t, workspace.IsGitDirty())
+}
+
+func testReadMakeTargets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Makefile")
+	writeFile(t, path, `.PHONY: build test
+VERSION := 1.0
+CC ?= gcc
+
+build: deps
+	go build
+
+test:
+	go test ./...
+
+%.o: %.c
+	$(CC) -c $<
+
+bin/app: build
+test: lint
+`)
+
+	assert.Equal(t, []string{"build", "test", "bin/app"}, readMakeTargets(path))
+	assert.Nil(t, readMakeTargets(filepath.Join(t.TempDir(), "Makefile")))
+}
+
+func testReadComposeServices(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, readComposeServices(dir))
+
+	writeFile(t, filepath.Join(dir, "docker-compose.yml"), `services:
+  web:
+    image: nginx
+  db:
+    image: postgres
+`)
+
+	assert.Equal(t, []string{"db", "web"}, readComposeServices(dir))
+}
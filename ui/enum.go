@@ -7,6 +7,7 @@ const (
 	ConfigPromptMode
 	ProviderPromptMode
 	ModelPromptMode
+	StoreKeyPromptMode
 	ChatPromptMode
 	DefaultPromptMode
 )
@@ -21,6 +22,8 @@ func (m PromptMode) String() string {
 		return "provider"
 	case ModelPromptMode:
 		return "model"
+	case StoreKeyPromptMode:
+		return "store_key"
 	case ChatPromptMode:
 		return "chat"
 	default:
@@ -38,6 +41,8 @@ func GetPromptModeFromString(s string) PromptMode {
 		return ProviderPromptMode
 	case "model":
 		return ModelPromptMode
+	case "store_key":
+		return StoreKeyPromptMode
 	case "chat":
 		return ChatPromptMode
 	default:
@@ -59,3 +64,27 @@ func (m RunMode) String() string {
 		return "repl"
 	}
 }
+
+// OutputFormat is how a CLI mode run prints its result
+type OutputFormat int
+
+const (
+	// TextOutputFormat renders markdown in the terminal UI, or prints it as
+	// is when the output is not a terminal
+	TextOutputFormat OutputFormat = iota
+	// JsonOutputFormat prints JSON, as NDJSON events for chat
+	JsonOutputFormat
+	// RawOutputFormat prints the answer as is, or the bare command in exec mode
+	RawOutputFormat
+)
+
+func (f OutputFormat) String() string {
+	switch f {
+	case JsonOutputFormat:
+		return "json"
+	case RawOutputFormat:
+		return "raw"
+	default:
+		return "text"
+	}
+}
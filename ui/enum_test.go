@@ -10,6 +10,7 @@ func TestUI(t *testing.T) {
 	t.Run("PromptModeString", testPromptModeString)
 	t.Run("GetPromptModeFromString", testGetPromptModeFromString)
 	t.Run("RunModeString", testRunModeString)
+	t.Run("OutputFormatString", testOutputFormatString)
 }
 
 func testPromptModeString(t *testing.T) {
@@ -20,6 +21,7 @@ func testPromptModeString(t *testing.T) {
 	}{
 		{"Exec", ExecPromptMode, "exec"},
 		{"Config", ConfigPromptMode, "config"},
+		{"StoreKey", StoreKeyPromptMode, "store_key"},
 		{"Chat", ChatPromptMode, "chat"},
 		{"Default", DefaultPromptMode, "default"},
 	}
@@ -39,6 +41,7 @@ func testGetPromptModeFromString(t *testing.T) {
 	}{
 		{"Exec", "exec", ExecPromptMode},
 		{"Config", "config", ConfigPromptMode},
+		{"StoreKey", "store_key", StoreKeyPromptMode},
 		{"Chat", "chat", ChatPromptMode},
 		{"Default", "unknown", DefaultPromptMode},
 	}
@@ -66,3 +69,21 @@ func testRunModeString(t *testing.T) {
 		})
 	}
 }
+
+func testOutputFormatString(t *testing.T) {
+	testCases := []struct {
+		name         string
+		outputFormat OutputFormat
+		expected     string
+	}{
+		{"Text", TextOutputFormat, "text"},
+		{"JSON", JsonOutputFormat, "json"},
+		{"Raw", RawOutputFormat, "raw"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.outputFormat.String(), "The string representation should match the expected value.")
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package ui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/xsikor/yai/codeblock"
+)
+
+// Fullscreen shows the whole transcript in a scrollable viewport, above the
+// prompt. Once browsing, the keys move in the transcript instead of going
+// to the prompt: search, jumps between messages, and copies.
+type Fullscreen struct {
+	transcript *Transcript
+	viewport   viewport.Model
+	search     textinput.Model
+	pending    string
+	width      int
+	browsing   bool
+	searching  bool
+	follow     bool
+	query      string
+	matches    []int
+	match      int
+	selected   int
+	status     string
+	copy       func(string) error
+}
+
+func NewFullscreen() *Fullscreen {
+	search := textinput.New()
+	search.Prompt = "/"
+	search.Placeholder = "search"
+
+	return &Fullscreen{
+		transcript: NewTranscript(),
+		viewport:   viewport.New(0, 0),
+		search:     search,
+		follow:     true,
+		selected:   -1,
+		copy:       clipboard.WriteAll,
+	}
+}
+
+func (f *Fullscreen) SetWidth(width int) *Fullscreen {
+	f.width = width
+	f.viewport.Width = width
+	f.search.Width = max(width-2, 1)
+
+	return f
+}
+
+// Add appends what was printed to the transcript
+func (f *Fullscreen) Add(entry transcriptEntry) {
+	f.transcript.Add(entry)
+	if f.query != "" {
+		f.matches = f.transcript.Search(f.query)
+	}
+	f.refresh()
+}
+
+// Clear empties the transcript, like clearing the terminal
+func (f *Fullscreen) Clear() {
+	f.transcript.Clear()
+	f.pending = ""
+	f.matches = nil
+	f.match = 0
+	f.selected = -1
+	f.follow = true
+	f.refresh()
+}
+
+// SetPending shows the answer being streamed after the transcript, until it
+// is added for good
+func (f *Fullscreen) SetPending(pending string) {
+	f.pending = pending
+	f.refresh()
+}
+
+func (f *Fullscreen) IsBrowsing() bool {
+	return f.browsing
+}
+
+func (f *Fullscreen) IsSearching() bool {
+	return f.searching
+}
+
+// Browse gives the keys to the transcript, selecting the last message in
+// view
+func (f *Fullscreen) Browse() {
+	f.browsing = true
+	f.status = ""
+	f.selected = -1
+
+	bottom := f.viewport.YOffset + f.viewport.Height
+	for i, index := range f.transcript.GetMessages() {
+		if f.transcript.GetEntryLine(index) < bottom {
+			f.selected = i
+		}
+	}
+}
+
+// ScrollPage scrolls the transcript by one page
+func (f *Fullscreen) ScrollPage(up bool) {
+	if up {
+		f.viewport.ViewUp()
+	} else {
+		f.viewport.ViewDown()
+	}
+	f.follow = f.viewport.AtBottom()
+}
+
+// Update handles the keys while browsing
+func (f *Fullscreen) Update(msg tea.KeyMsg) tea.Cmd {
+	if f.searching {
+		return f.updateSearch(msg)
+	}
+
+	f.status = ""
+	switch msg.String() {
+	case "esc":
+		if f.query != "" {
+			f.setQuery("")
+		} else {
+			f.browsing = false
+		}
+	case "i", "q", "enter":
+		f.browsing = false
+	case "up", "k":
+		f.viewport.LineUp(1)
+	case "down", "j":
+		f.viewport.LineDown(1)
+	case "pgup", "b":
+		f.viewport.ViewUp()
+	case "pgdown", " ", "f":
+		f.viewport.ViewDown()
+	case "home", "g":
+		f.viewport.GotoTop()
+	case "end", "G":
+		f.viewport.GotoBottom()
+	case "[":
+		f.selectMessage(f.selected - 1)
+	case "]":
+		f.selectMessage(f.selected + 1)
+	case "/":
+		f.searching = true
+		f.search.SetValue("")
+		return f.search.Focus()
+	case "n":
+		f.jumpToMatch(f.match + 1)
+	case "N":
+		f.jumpToMatch(f.match - 1)
+	case "y":
+		f.copyMessage()
+	case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+		f.copyBlock(int(msg.String()[0] - '0'))
+	}
+	f.follow = f.viewport.AtBottom()
+
+	return nil
+}
+
+func (f *Fullscreen) updateSearch(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyEsc:
+		f.searching = false
+		f.search.Blur()
+		return nil
+	case tea.KeyEnter:
+		f.searching = false
+		f.search.Blur()
+		f.setQuery(f.search.Value())
+		if len(f.matches) == 0 {
+			return nil
+		}
+
+		// The first match from the top of the view, or the first one
+		f.match = 0
+		for i, line := range f.matches {
+			if line >= f.viewport.YOffset {
+				f.match = i
+				break
+			}
+		}
+		f.jumpToMatch(f.match)
+		return nil
+	}
+
+	var cmd tea.Cmd
+	f.search, cmd = f.search.Update(msg)
+
+	return cmd
+}
+
+func (f *Fullscreen) setQuery(query string) {
+	f.query = query
+	f.matches = f.transcript.Search(query)
+	f.match = 0
+	f.refresh()
+}
+
+// jumpToMatch scrolls to the match, going around at both ends
+func (f *Fullscreen) jumpToMatch(match int) {
+	if len(f.matches) == 0 {
+		return
+	}
+
+	f.match = (match + len(f.matches)) % len(f.matches)
+	f.refresh()
+	// Some context above the match
+	f.viewport.SetYOffset(f.matches[f.match] - min(3, f.viewport.Height/4))
+}
+
+func (f *Fullscreen) selectMessage(selected int) {
+	messages := f.transcript.GetMessages()
+	if selected < 0 || selected >= len(messages) {
+		return
+	}
+
+	f.selected = selected
+	f.viewport.SetYOffset(f.transcript.GetEntryLine(messages[selected]))
+}
+
+// selectedEntry returns the selected message, if any
+func (f *Fullscreen) selectedEntry() (transcriptEntry, bool) {
+	messages := f.transcript.GetMessages()
+	if f.selected < 0 || f.selected >= len(messages) {
+		return transcriptEntry{}, false
+	}
+
+	return f.transcript.GetEntry(messages[f.selected]), true
+}
+
+func (f *Fullscreen) copyMessage() {
+	entry, ok := f.selectedEntry()
+	if !ok {
+		f.status = "no message selected"
+		return
+	}
+
+	if err := f.copy(entry.content); err != nil {
+		f.status = fmt.Sprintf("copy error: %s", err)
+		return
+	}
+	f.status = fmt.Sprintf("copied message %d", f.selected+1)
+}
+
+func (f *Fullscreen) copyBlock(number int) {
+	entry, ok := f.selectedEntry()
+	if !ok {
+		f.status = "no message selected"
+		return
+	}
+
+	blocks := codeblock.Extract(entry.content)
+	if number > len(blocks) {
+		f.status = fmt.Sprintf("message %d has %d code block(s)", f.selected+1, len(blocks))
+		return
+	}
+
+	if err := f.copy(blocks[number-1].GetCode()); err != nil {
+		f.status = fmt.Sprintf("copy error: %s", err)
+		return
+	}
+	f.status = fmt.Sprintf("copied block %d of message %d", number, f.selected+1)
+}
+
+// refresh sets the viewport content, with the matches of the search
+// highlighted, keeping the bottom in view while following
+func (f *Fullscreen) refresh() {
+	lines := f.transcript.GetLines()
+	if len(f.matches) > 0 {
+		lines = append([]string(nil), lines...)
+		pattern := regexp.MustCompile("(?i)" + regexp.QuoteMeta(f.query))
+		colors := activeTheme.GetColors()
+		for i, line := range f.matches {
+			style := background(colors.Match, lipgloss.NewStyle().Underline(true))
+			if i == f.match {
+				style = background(colors.CurrentMatch, lipgloss.NewStyle().Reverse(true)).Bold(true)
+			}
+			lines[line] = highlight(lines[line], pattern, style)
+		}
+	}
+
+	content := strings.Join(lines, "\n")
+	if f.pending != "" {
+		content += "\n" + f.pending
+	}
+	f.viewport.SetContent(content)
+
+	if f.follow {
+		f.viewport.GotoBottom()
+	}
+}
+
+// highlight styles the parts of the line matching the pattern. The styles of
+// the rendered line are dropped, as they cannot be nested.
+func highlight(line string, pattern *regexp.Regexp, style lipgloss.Style) string {
+	return pattern.ReplaceAllStringFunc(ansiPattern.ReplaceAllString(line, ""), func(match string) string {
+		return style.Render(match)
+	})
+}
+
+// View renders the transcript in the given height, with the status line
+// under it
+func (f *Fullscreen) View(height int) string {
+	f.viewport.Height = max(height-1, 1)
+	if f.follow {
+		f.viewport.GotoBottom()
+	}
+
+	return fmt.Sprintf("%s\n%s", f.viewport.View(), f.statusView())
+}
+
+func (f *Fullscreen) statusView() string {
+	if f.searching {
+		return f.search.View()
+	}
+
+	var parts []string
+	if f.browsing {
+		if count := len(f.transcript.GetMessages()); f.selected >= 0 {
+			parts = append(parts, fmt.Sprintf("message %d/%d", f.selected+1, count))
+		}
+	}
+	if f.query != "" {
+		if len(f.matches) > 0 {
+			parts = append(parts, fmt.Sprintf("%q %d/%d", f.query, f.match+1, len(f.matches)))
+		} else {
+			parts = append(parts, fmt.Sprintf("%q no match", f.query))
+		}
+	}
+	if f.status != "" {
+		parts = append(parts, f.status)
+	}
+
+	if f.browsing {
+		parts = append(parts, "/ search, n/N match, [/] message, y copy, 1-9 copy block, esc back")
+	} else {
+		parts = append(parts, fmt.Sprintf("%3.f%%", f.viewport.ScrollPercent()*100), "pgup/pgdn scroll, esc browse")
+	}
+
+	return foreground(activeTheme.GetColors().Help).Italic(true).MaxWidth(max(f.width, 1)).Render(strings.Join(parts, " · "))
+}
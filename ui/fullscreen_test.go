@@ -0,0 +1,158 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFullscreen(t *testing.T) {
+	t.Run("Follow", testFullscreenFollow)
+	t.Run("Pending", testFullscreenPending)
+	t.Run("Browse", testFullscreenBrowse)
+	t.Run("Search", testFullscreenSearch)
+	t.Run("Copy", testFullscreenCopy)
+}
+
+func newTestFullscreen(copied *string) *Fullscreen {
+	fullscreen := NewFullscreen().SetWidth(80)
+	fullscreen.copy = func(text string) error {
+		*copied = text
+		return nil
+	}
+
+	for i := 1; i <= 5; i++ {
+		fullscreen.Add(transcriptEntry{kind: promptEntry, content: fmt.Sprintf("question %d", i), rendered: fmt.Sprintf("> question %d", i)})
+		content := fmt.Sprintf("Answer %d:\n\n```sh\necho %d\n```\n\n```yaml\nanswer: %d\n```", i, i, i)
+		rendered := fmt.Sprintf("Answer %d\n%s", i, strings.Repeat("...\n", 8))
+		fullscreen.Add(transcriptEntry{kind: answerEntry, content: content, rendered: rendered})
+	}
+	fullscreen.View(10)
+
+	return fullscreen
+}
+
+func keys(fullscreen *Fullscreen, keys ...string) {
+	for _, key := range keys {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+		switch key {
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		}
+		fullscreen.Update(msg)
+	}
+}
+
+func testFullscreenFollow(t *testing.T) {
+	var copied string
+	fullscreen := newTestFullscreen(&copied)
+
+	// The bottom stays in view as content is added
+	assert.True(t, fullscreen.viewport.AtBottom())
+	fullscreen.Add(transcriptEntry{kind: noticeEntry, rendered: "[ok]"})
+	assert.True(t, fullscreen.viewport.AtBottom())
+	assert.Contains(t, fullscreen.View(10), "[ok]")
+
+	// Until scrolled up
+	fullscreen.ScrollPage(true)
+	offset := fullscreen.viewport.YOffset
+	fullscreen.Add(transcriptEntry{kind: noticeEntry, rendered: "[ok]"})
+	fullscreen.View(10)
+	assert.Equal(t, offset, fullscreen.viewport.YOffset)
+
+	fullscreen.ScrollPage(false)
+	fullscreen.ScrollPage(false)
+	assert.True(t, fullscreen.follow)
+}
+
+func testFullscreenPending(t *testing.T) {
+	fullscreen := NewFullscreen().SetWidth(80)
+
+	fullscreen.Add(transcriptEntry{kind: answerEntry, rendered: "first block", open: true})
+	fullscreen.SetPending("block in progress")
+	assert.Equal(t, []string{"first block", "block in progress"}, visibleLines(fullscreen.View(10))[:2])
+
+	fullscreen.Add(transcriptEntry{kind: answerEntry, content: "first block\n\nlast block", rendered: "last block"})
+	fullscreen.SetPending("")
+	view := fullscreen.View(10)
+	assert.Equal(t, []string{"first block", "last block", ""}, visibleLines(view)[:3])
+	assert.NotContains(t, view, "in progress")
+}
+
+func testFullscreenBrowse(t *testing.T) {
+	var copied string
+	fullscreen := newTestFullscreen(&copied)
+
+	// The last message in view is selected
+	fullscreen.Browse()
+	require.True(t, fullscreen.IsBrowsing())
+	assert.Equal(t, 9, fullscreen.selected)
+
+	keys(fullscreen, "[", "[")
+	assert.Equal(t, 7, fullscreen.selected)
+	assert.Equal(t, fullscreen.transcript.GetEntryLine(7), fullscreen.viewport.YOffset)
+	assert.Contains(t, fullscreen.View(10), "message 8/10")
+
+	// Not past the first or the last message
+	keys(fullscreen, "]", "]", "]")
+	assert.Equal(t, 9, fullscreen.selected)
+
+	keys(fullscreen, "esc")
+	assert.False(t, fullscreen.IsBrowsing())
+}
+
+func testFullscreenSearch(t *testing.T) {
+	var copied string
+	fullscreen := newTestFullscreen(&copied)
+	fullscreen.Browse()
+
+	keys(fullscreen, "g", "/")
+	require.True(t, fullscreen.IsSearching())
+	keys(fullscreen, "q", "u", "e", "s", "t", "i", "o", "n", "enter")
+	require.False(t, fullscreen.IsSearching())
+	assert.Equal(t, "question", fullscreen.query)
+	assert.Len(t, fullscreen.matches, 5)
+	assert.Equal(t, 0, fullscreen.match)
+	assert.Contains(t, fullscreen.View(10), `"question" 1/5`)
+
+	keys(fullscreen, "n", "n")
+	assert.Equal(t, 2, fullscreen.match)
+	assert.Equal(t, fullscreen.matches[2]-2, fullscreen.viewport.YOffset)
+
+	// Around at both ends
+	keys(fullscreen, "N", "N", "N")
+	assert.Equal(t, 4, fullscreen.match)
+
+	// Esc clears the search before leaving
+	keys(fullscreen, "esc")
+	assert.Empty(t, fullscreen.query)
+	assert.True(t, fullscreen.IsBrowsing())
+
+	keys(fullscreen, "/", "n", "o", "p", "e", "enter")
+	assert.Contains(t, fullscreen.View(10), `"nope" no match`)
+}
+
+func testFullscreenCopy(t *testing.T) {
+	var copied string
+	fullscreen := newTestFullscreen(&copied)
+	fullscreen.Browse()
+
+	keys(fullscreen, "2")
+	assert.Equal(t, "answer: 5", copied)
+
+	keys(fullscreen, "[", "y")
+	assert.Equal(t, "question 5", copied)
+
+	keys(fullscreen, "[", "1")
+	assert.Equal(t, "echo 4", copied)
+	assert.Contains(t, fullscreen.View(10), "copied block 1 of message 8")
+
+	keys(fullscreen, "3")
+	assert.Contains(t, fullscreen.View(10), "message 8 has 2 code block(s)")
+}
@@ -1,15 +1,17 @@
 package ui
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
-	"io"
 	"os"
-	"regexp"
 	"strings"
 
+	"golang.org/x/term"
+
+	"github.com/xsikor/yai/ai"
 	"github.com/xsikor/yai/ai/provider"
+	"github.com/xsikor/yai/attachment"
+	"github.com/xsikor/yai/config"
 )
 
 type UiInput struct {
@@ -17,27 +19,62 @@ type UiInput struct {
 	promptMode   PromptMode
 	providerType provider.ProviderType
 	modelName    string
+	overrides    config.Overrides
+	outputFormat OutputFormat
+	terminal     bool
+	yes          bool
+	fullscreen   bool
 	showModel    bool
 	args         string
 	pipe         string
+	pipeImages   []attachment.Image
+	pipeChunks   []attachment.PipeChunk
+	concurrency  int
+	truncation   string
 }
 
 func NewUIInput() (*UiInput, error) {
 	flagSet := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 
-	var exec, chat, showModel bool
-	var providerFlag, modelFlag string
+	var exec, chat, showModel, jsonOutput, rawOutput, yes, fullscreen, analyze bool
+	var providerFlag, modelFlag, profileFlag string
+	var temperatureFlag float64
+	var maxTokensFlag, pipeLimitFlag, concurrencyFlag int
 	flagSet.BoolVar(&exec, "e", false, "exec prompt mode")
 	flagSet.BoolVar(&chat, "c", false, "chat prompt mode")
 	flagSet.BoolVar(&showModel, "m", false, "show current AI model and provider")
 	flagSet.StringVar(&providerFlag, "p", "", "AI provider (openai, claude, gemini)")
 	flagSet.StringVar(&modelFlag, "model", "", "specific model to use")
+	flagSet.StringVar(&profileFlag, "profile", "", "config profile to use")
+	flagSet.Float64Var(&temperatureFlag, "temperature", 0, "AI temperature for this run")
+	flagSet.IntVar(&maxTokensFlag, "max-tokens", 0, "AI max tokens for this run")
+	flagSet.BoolVar(&jsonOutput, "json", false, "print the result as JSON, without the terminal UI")
+	flagSet.BoolVar(&rawOutput, "raw", false, "print the raw answer, or the bare command, without the terminal UI")
+	flagSet.BoolVar(&yes, "yes", false, "run the generated command without asking for confirmation")
+	flagSet.BoolVar(&fullscreen, "fullscreen", false, "show the REPL transcript in a scrollable full screen view")
+	flagSet.IntVar(&pipeLimitFlag, "pipe-limit", attachment.MaxPipeSize/1024, "size in KiB of piped text sent as is, a larger input being truncated")
+	flagSet.BoolVar(&analyze, "analyze", false, "analyze the piped input in parts, for large logs, and answer with a report")
+	flagSet.IntVar(&concurrencyFlag, "concurrency", ai.DefaultAnalyzeConcurrency, "parts of the input analyzed at once with -analyze")
 	err := flagSet.Parse(os.Args[1:])
 	if err != nil {
 		fmt.Println("Error parsing flags:", err)
 		return nil, err
 	}
 
+	// Only flags given explicitly override the config
+	overrides := config.Overrides{
+		Profile: profileFlag,
+		Model:   modelFlag,
+	}
+	flagSet.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "temperature":
+			overrides.Temperature = &temperatureFlag
+		case "max-tokens":
+			overrides.MaxTokens = &maxTokensFlag
+		}
+	})
+
 	args := flagSet.Args()
 
 	stat, err := os.Stdin.Stat()
@@ -46,32 +83,60 @@ func NewUIInput() (*UiInput, error) {
 		return nil, err
 	}
 
+	if pipeLimitFlag <= 0 {
+		return nil, fmt.Errorf("-pipe-limit must be a positive size in KiB")
+	}
+	if concurrencyFlag <= 0 {
+		return nil, fmt.Errorf("-concurrency must be a positive number")
+	}
+
 	pipe := ""
+	truncation := ""
+	var pipeImages []attachment.Image
+	var pipeChunks []attachment.PipeChunk
 	hasPipe := !(stat.Mode()&os.ModeNamedPipe == 0 && stat.Size() == 0)
 
-	if hasPipe {
-		reader := bufio.NewReader(os.Stdin)
-		var builder strings.Builder
-
-		for {
-			r, _, err := reader.ReadRune()
-			if err != nil && err == io.EOF {
-				break
-			}
-			_, err = builder.WriteRune(r)
-			if err != nil {
-				fmt.Println("Error getting input:", err)
-				return nil, err
-			}
+	if analyze {
+		if !hasPipe {
+			return nil, fmt.Errorf("-analyze needs piped input, like kubectl logs my-pod | yai -analyze why is it crashing")
+		}
+		if exec {
+			return nil, fmt.Errorf("-analyze answers with a report, it cannot be used with -e")
 		}
 
-		pipe = strings.TrimSpace(builder.String())
+		// The whole input is searched in parts, only the end of a huge one
+		pipeChunks, err = attachment.ReadPipeChunks(os.Stdin, attachment.PipeChunkSize, attachment.MaxPipeChunks)
+		if err != nil {
+			return nil, err
+		}
+		if len(pipeChunks) == 0 {
+			return nil, fmt.Errorf("-analyze needs piped input, the input is empty")
+		}
+		if skipped := pipeChunks[0].FirstLine - 1; skipped > 0 {
+			truncation = fmt.Sprintf("input too large to be analyzed whole, only its last %d lines are, in %d parts", pipeChunks[len(pipeChunks)-1].LastLine-skipped, len(pipeChunks))
+		}
+		chat = true
+	} else if hasPipe {
+		// Only the first and last lines of a large input are kept, so piping
+		// a huge log does not exceed the model context
+		input, err := attachment.ReadPipe(os.Stdin, pipeLimitFlag*1024)
+		if err != nil {
+			return nil, err
+		}
+
+		// A piped image is sent as such, to ask about a screenshot
+		if image, ok := input.GetImage(); ok {
+			pipeImages = append(pipeImages, image)
+		} else {
+			pipe = input.GetContent()
+			truncation = input.GetTruncation()
+		}
 	}
 
 	runMode := ReplMode
 	if len(args) > 0 {
 		runMode = CliMode
-	} else if hasPipe && pipe != "" {
+	} else if hasPipe && (pipe != "" || len(pipeImages) > 0 || len(pipeChunks) > 0) {
 		runMode = CliMode // If we have piped input, run in CLI mode
 	}
 
@@ -90,28 +155,49 @@ func NewUIInput() (*UiInput, error) {
 		promptMode = DefaultPromptMode
 	}
 
-	// Set provider type based on flag
+	outputFormat := TextOutputFormat
+	switch {
+	case jsonOutput && rawOutput:
+		return nil, fmt.Errorf("-json and -raw cannot be used together")
+	case jsonOutput:
+		outputFormat = JsonOutputFormat
+	case rawOutput:
+		outputFormat = RawOutputFormat
+	}
+	if outputFormat != TextOutputFormat && runMode != CliMode {
+		return nil, fmt.Errorf("-%s needs a prompt, given as arguments or piped", outputFormat)
+	}
+	if yes && outputFormat == JsonOutputFormat {
+		return nil, fmt.Errorf("-yes cannot be used with -json, the command output would break the JSON")
+	}
+
+	// Set provider type based on flag, if not specified the config decides
 	var providerType provider.ProviderType
-	switch strings.ToLower(providerFlag) {
-	case "openai":
-		providerType = provider.ProviderOpenAI
-	case "claude":
-		providerType = provider.ProviderClaude
-	case "gemini":
-		providerType = provider.ProviderGemini
-	default:
-		// Default to OpenAI if not specified
-		providerType = provider.ProviderOpenAI
+	if providerFlag != "" {
+		providerType, err = provider.ParseProviderType(providerFlag)
+		if err != nil {
+			return nil, err
+		}
 	}
+	overrides.ProviderType = providerType
 
 	return &UiInput{
 		runMode:      runMode,
 		promptMode:   promptMode,
 		providerType: providerType,
 		modelName:    modelFlag,
+		overrides:    overrides,
+		outputFormat: outputFormat,
+		terminal:     term.IsTerminal(int(os.Stdout.Fd())),
+		yes:          yes,
+		fullscreen:   fullscreen,
 		showModel:    showModel,
 		args:         strings.Join(args, " "),
 		pipe:         pipe,
+		pipeImages:   pipeImages,
+		pipeChunks:   pipeChunks,
+		concurrency:  concurrencyFlag,
+		truncation:   truncation,
 	}, nil
 }
 
@@ -131,6 +217,39 @@ func (i *UiInput) GetPipe() string {
 	return i.pipe
 }
 
+// GetModeInput returns what the mode is detected from without -e nor -c:
+// the prompt, or the piped content without one
+func (i *UiInput) GetModeInput() string {
+	if i.args != "" {
+		return i.args
+	}
+
+	return i.pipe
+}
+
+// GetPipeImages returns the image piped, if any, sent with the prompt
+func (i *UiInput) GetPipeImages() []attachment.Image {
+	return i.pipeImages
+}
+
+// GetPipeChunks returns the parts of the piped input to analyze, with
+// -analyze
+func (i *UiInput) GetPipeChunks() []attachment.PipeChunk {
+	return i.pipeChunks
+}
+
+// GetConcurrency returns how many parts of the piped input are analyzed at
+// once
+func (i *UiInput) GetConcurrency() int {
+	return i.concurrency
+}
+
+// GetPipeTruncation tells what was kept of a piped input too large to be
+// sent as is, empty if it was sent whole
+func (i *UiInput) GetPipeTruncation() string {
+	return i.truncation
+}
+
 func (i *UiInput) GetProviderType() provider.ProviderType {
 	return i.providerType
 }
@@ -143,38 +262,39 @@ func (i *UiInput) GetModelName() string {
 	return i.modelName
 }
 
-// isProbablyCommand determines if the input text is likely a shell command
-// It uses heuristics to detect command patterns
-func isProbablyCommand(input string) bool {
-	// If empty, can't tell
-	if len(input) == 0 {
-		return false
-	}
+func (i *UiInput) GetProfile() string {
+	return i.overrides.Profile
+}
 
-	// Common command patterns
-	commandPatterns := []*regexp.Regexp{
-		regexp.MustCompile(`^(ls|cd|grep|find|git|docker|kubectl|npm|go|python|pip)\s`),
-		regexp.MustCompile(`^[./]`),      // Starts with ./ or /
-		regexp.MustCompile(`\|\s*(\w+)`), // Contains pipe operator
-		regexp.MustCompile(`sudo\s`),     // Contains sudo
-		regexp.MustCompile(`^(cat|less|more|head|tail|vim|nano|mkdir|rmdir|touch|chmod|chown)\s`),
-		regexp.MustCompile(`^(apt|yum|brew)\s`),
-		regexp.MustCompile(`\s(>|>>|<)\s`), // Contains redirection operators
-	}
+// GetOutputFormat returns how a CLI mode run prints its result
+func (i *UiInput) GetOutputFormat() OutputFormat {
+	return i.outputFormat
+}
 
-	// Check if input matches any command patterns
-	for _, pattern := range commandPatterns {
-		if pattern.MatchString(input) {
-			return true
-		}
-	}
+// IsTerminal tells if the output is a terminal, the terminal UI being
+// unusable otherwise, like when piping yai into another command
+func (i *UiInput) IsTerminal() bool {
+	return i.terminal
+}
 
-	// If text is very short (1-3 words) and doesn't contain question mark, likely a command
-	words := strings.Fields(input)
-	if len(words) <= 3 && !strings.Contains(input, "?") {
-		return true
-	}
+// IsHeadless tells if the run goes without the terminal UI: a CLI mode run
+// asking for a script friendly output, or whose output is not a terminal
+func (i *UiInput) IsHeadless() bool {
+	return i.runMode == CliMode && (i.outputFormat != TextOutputFormat || !i.terminal)
+}
+
+// GetYes tells if the generated command runs without asking for confirmation
+func (i *UiInput) GetYes() bool {
+	return i.yes
+}
+
+// IsFullscreen tells if the REPL shows the whole transcript in a scrollable
+// full screen view
+func (i *UiInput) IsFullscreen() bool {
+	return i.fullscreen
+}
 
-	// Default to false if no command patterns matched
-	return false
+// GetOverrides returns the config overrides given by CLI flags
+func (i *UiInput) GetOverrides() config.Overrides {
+	return i.overrides
 }
@@ -5,6 +5,9 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xsikor/yai/ai/provider"
 )
 
 func TestUiInput(t *testing.T) {
@@ -12,6 +15,10 @@ func TestUiInput(t *testing.T) {
 	t.Run("GetRunMode", testGetRunMode)
 	t.Run("GetPromptMode", testGetPromptMode)
 	t.Run("GetArgs", testGetArgs)
+	t.Run("GetProfile", testGetProfile)
+	t.Run("GetOverrides", testGetOverrides)
+	t.Run("GetOutputFormat", testGetOutputFormat)
+	t.Run("IsHeadless", testIsHeadless)
 }
 
 func testNewUIInput(t *testing.T) {
@@ -49,4 +56,89 @@ func testGetArgs(t *testing.T) {
 	os.Args = []string{"cmd", "arg1", "arg2"}
 	uiInput, _ := NewUIInput()
 	assert.Equal(t, "arg1 arg2", uiInput.GetArgs(), "Args should be 'arg1 arg2'.")
+	assert.Equal(t, "arg1 arg2", uiInput.GetModeInput(), "The mode should be detected from the prompt.")
+}
+
+func testGetProfile(t *testing.T) {
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{"cmd", "--profile", "work-claude", "arg1"}
+	uiInput, _ := NewUIInput()
+	assert.Equal(t, "work-claude", uiInput.GetProfile(), "Profile should be 'work-claude'.")
+	assert.Equal(t, "arg1", uiInput.GetArgs(), "Args should be 'arg1'.")
+}
+
+func testGetOverrides(t *testing.T) {
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{"cmd", "-p", "claude", "-model", "claude-3-opus", "-temperature", "0", "arg1"}
+	uiInput, err := NewUIInput()
+	require.NoError(t, err)
+
+	overrides := uiInput.GetOverrides()
+	assert.Equal(t, provider.ProviderClaude, overrides.ProviderType)
+	assert.Equal(t, "claude-3-opus", overrides.Model)
+	require.NotNil(t, overrides.Temperature, "Temperature given explicitly should override, even if zero.")
+	assert.Equal(t, 0.0, *overrides.Temperature)
+	assert.Nil(t, overrides.MaxTokens, "Max tokens not given should not override.")
+
+	os.Args = []string{"cmd", "arg1"}
+	uiInput, err = NewUIInput()
+	require.NoError(t, err)
+	assert.Empty(t, uiInput.GetOverrides().ProviderType, "Provider not given should not override.")
+
+	os.Args = []string{"cmd", "-p", "unknown", "arg1"}
+	_, err = NewUIInput()
+	assert.Error(t, err)
+}
+
+func testGetOutputFormat(t *testing.T) {
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{"cmd", "arg1"}
+	uiInput, err := NewUIInput()
+	require.NoError(t, err)
+	assert.Equal(t, TextOutputFormat, uiInput.GetOutputFormat())
+
+	os.Args = []string{"cmd", "--json", "-e", "list files"}
+	uiInput, err = NewUIInput()
+	require.NoError(t, err)
+	assert.Equal(t, JsonOutputFormat, uiInput.GetOutputFormat())
+
+	os.Args = []string{"cmd", "-raw", "list files"}
+	uiInput, err = NewUIInput()
+	require.NoError(t, err)
+	assert.Equal(t, RawOutputFormat, uiInput.GetOutputFormat())
+
+	os.Args = []string{"cmd", "-json", "-raw", "list files"}
+	_, err = NewUIInput()
+	assert.ErrorContains(t, err, "cannot be used together")
+
+	os.Args = []string{"cmd", "-json"}
+	_, err = NewUIInput()
+	assert.ErrorContains(t, err, "needs a prompt")
+}
+
+func testIsHeadless(t *testing.T) {
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{"cmd", "-yes", "-e", "list files"}
+	uiInput, err := NewUIInput()
+	require.NoError(t, err)
+	assert.True(t, uiInput.GetYes())
+	assert.False(t, uiInput.IsTerminal(), "Tests should not run with a terminal output.")
+	assert.True(t, uiInput.IsHeadless(), "A CLI mode run without a terminal should be headless.")
+
+	os.Args = []string{"cmd"}
+	uiInput, err = NewUIInput()
+	require.NoError(t, err)
+	assert.False(t, uiInput.IsHeadless(), "The REPL should never be headless.")
+
+	os.Args = []string{"cmd", "-yes", "-json", "list files"}
+	_, err = NewUIInput()
+	assert.ErrorContains(t, err, "-yes cannot be used with -json")
 }
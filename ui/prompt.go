@@ -4,52 +4,95 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 
-	"github.com/xsikor/yai/config"
 	"github.com/xsikor/yai/ui/slash"
 )
 
 const (
-	exec_icon            = "🚀 > "
-	exec_placeholder     = "Execute something..."
-	config_icon          = "🔒 > "
-	config_placeholder   = "Enter your API key..."
-	provider_icon        = "🤖 > "
-	provider_placeholder = "Select provider (openai, claude, gemini)..."
-	model_icon           = "📦 > "
-	model_placeholder    = "Select model (press Enter for default)..."
-	chat_icon            = "💬 > "
-	chat_placeholder     = "Ask me something..."
+	exec_icon             = "🚀 > "
+	exec_placeholder      = "Execute something..."
+	config_icon           = "🔒 > "
+	config_placeholder    = "Enter your API key..."
+	provider_icon         = "🤖 > "
+	provider_placeholder  = "Select provider (openai, claude, gemini)..."
+	model_icon            = "📦 > "
+	model_placeholder     = "Select model (press Enter for default)..."
+	store_key_icon        = "🔒 > "
+	store_key_placeholder = "Store the API key in the config file? [Y/n]"
+	chat_icon             = "💬 > "
+	chat_placeholder      = "Ask me something..."
+
+	// max_editor_height is the number of lines above which the editor
+	// scrolls instead of growing
+	max_editor_height = 10
 )
 
+// Prompt reads the user input: a multi-line editor for chat and exec
+// prompts, a single line input for the setup ones, as the API key has to be
+// masked.
 type Prompt struct {
 	mode         PromptMode
 	input        textinput.Model
+	editor       textarea.Model
 	autocomplete *slash.AutocompleteState
 }
 
 func NewPrompt(mode PromptMode) *Prompt {
-	input := textinput.New()
-	input.Placeholder = getPromptPlaceholder(mode)
-	input.TextStyle = getPromptStyle(mode)
-	input.Prompt = getPromptIcon(mode)
+	p := &Prompt{
+		mode:         mode,
+		autocomplete: slash.NewAutocompleteState(),
+	}
+
+	if p.isEditor() {
+		p.editor = newEditor()
+		p.SetMode(mode)
+		p.editor.Focus()
+
+		return p
+	}
+
+	p.input = textinput.New()
+	p.input.Placeholder = getPromptPlaceholder(mode)
+	p.input.TextStyle = getPromptStyle(mode)
+	p.input.Prompt = getPromptIcon(mode)
 
 	if mode == ConfigPromptMode {
-		input.EchoMode = textinput.EchoPassword
+		p.input.EchoMode = textinput.EchoPassword
 	}
 
-	input.Focus()
+	p.input.Focus()
 
-	// Initialize slash commands
-	slash.InitSlashCommands()
+	return p
+}
 
-	return &Prompt{
-		mode:         mode,
-		input:        input,
-		autocomplete: slash.NewAutocompleteState(),
+// newEditor returns a textarea growing with its content, enter being left
+// to submit the prompt
+func newEditor() textarea.Model {
+	editor := textarea.New()
+	editor.ShowLineNumbers = false
+	editor.CharLimit = 0
+	editor.MaxHeight = 0
+	editor.KeyMap.InsertNewline = key.NewBinding(key.WithKeys("alt+enter", "ctrl+j"))
+	editor.FocusedStyle.CursorLine = lipgloss.NewStyle()
+	editor.FocusedStyle.Base = lipgloss.NewStyle()
+	editor.BlurredStyle = editor.FocusedStyle
+	editor.SetHeight(1)
+
+	return editor
+}
+
+// isEditor tells if the prompt is a multi-line editor
+func (p *Prompt) isEditor() bool {
+	switch p.mode {
+	case ConfigPromptMode, ProviderPromptMode, ModelPromptMode, StoreKeyPromptMode:
+		return false
+	default:
+		return true
 	}
 }
 
@@ -60,6 +103,23 @@ func (p *Prompt) GetMode() PromptMode {
 func (p *Prompt) SetMode(mode PromptMode) *Prompt {
 	p.mode = mode
 
+	if p.isEditor() {
+		// The icon only starts the first line, the others being aligned
+		icon := getPromptIcon(mode)
+		width := lipgloss.Width(icon)
+		p.editor.SetPromptFunc(width, func(line int) string {
+			if line == 0 {
+				return icon
+			}
+			return strings.Repeat(" ", width)
+		})
+		p.editor.FocusedStyle.Text = getPromptStyle(mode)
+		p.editor.BlurredStyle.Text = getPromptStyle(mode)
+		p.editor.Placeholder = getPromptPlaceholder(mode)
+
+		return p
+	}
+
 	p.input.TextStyle = getPromptStyle(mode)
 	p.input.Prompt = getPromptIcon(mode)
 	p.input.Placeholder = getPromptPlaceholder(mode)
@@ -67,24 +127,63 @@ func (p *Prompt) SetMode(mode PromptMode) *Prompt {
 	return p
 }
 
+// SetWidth sets the width of the editor, to wrap long lines
+func (p *Prompt) SetWidth(width int) *Prompt {
+	if p.isEditor() {
+		p.editor.SetWidth(width)
+		p.resize()
+	}
+
+	return p
+}
+
 func (p *Prompt) SetValue(value string) *Prompt {
-	p.input.SetValue(value)
+	if p.isEditor() {
+		p.editor.SetValue(value)
+		p.resize()
+	} else {
+		p.input.SetValue(value)
+	}
 
 	return p
 }
 
 func (p *Prompt) GetValue() string {
+	if p.isEditor() {
+		return p.editor.Value()
+	}
+
 	return p.input.Value()
 }
 
+// IsOnFirstLine tells if the cursor is on the first line, up moving in the
+// history rather than in the input
+func (p *Prompt) IsOnFirstLine() bool {
+	return !p.isEditor() || p.editor.Line() == 0
+}
+
+// IsOnLastLine tells if the cursor is on the last line, down moving in the
+// history rather than in the input
+func (p *Prompt) IsOnLastLine() bool {
+	return !p.isEditor() || p.editor.Line() == p.editor.LineCount()-1
+}
+
 func (p *Prompt) Blur() *Prompt {
-	p.input.Blur()
+	if p.isEditor() {
+		p.editor.Blur()
+	} else {
+		p.input.Blur()
+	}
 
 	return p
 }
 
 func (p *Prompt) Focus() *Prompt {
-	p.input.Focus()
+	if p.isEditor() {
+		p.editor.Focus()
+	} else {
+		p.input.Focus()
+	}
 
 	return p
 }
@@ -97,7 +196,7 @@ func (p *Prompt) Update(msg tea.Msg) (*Prompt, tea.Cmd) {
 		switch msg.Type {
 		case tea.KeyTab:
 			// Handle autocomplete
-			currentValue := p.input.Value()
+			currentValue := p.GetValue()
 			if strings.HasPrefix(currentValue, "/") {
 				if !p.autocomplete.Active {
 					p.autocomplete.StartAutocomplete(currentValue)
@@ -106,7 +205,7 @@ func (p *Prompt) Update(msg tea.Msg) (*Prompt, tea.Cmd) {
 				if p.autocomplete.Active {
 					suggestion := p.autocomplete.GetCurrentSuggestion()
 					if suggestion != "" {
-						p.input.SetValue(suggestion)
+						p.SetValue(suggestion)
 						return p, nil
 					}
 				}
@@ -116,7 +215,7 @@ func (p *Prompt) Update(msg tea.Msg) (*Prompt, tea.Cmd) {
 			if p.autocomplete.Active {
 				suggestion := p.autocomplete.PrevSuggestion()
 				if suggestion != "" {
-					p.input.SetValue(suggestion)
+					p.SetValue(suggestion)
 					return p, nil
 				}
 			}
@@ -126,7 +225,7 @@ func (p *Prompt) Update(msg tea.Msg) (*Prompt, tea.Cmd) {
 		default:
 			// If the user types, update autocomplete suggestions
 			if p.mode == ChatPromptMode || p.mode == ExecPromptMode {
-				currentValue := p.input.Value()
+				currentValue := p.GetValue()
 
 				// Special handling for the first slash character
 				if currentValue == "/" {
@@ -140,10 +239,34 @@ func (p *Prompt) Update(msg tea.Msg) (*Prompt, tea.Cmd) {
 		}
 	}
 
+	if p.isEditor() {
+		p.editor, updateCmd = p.editor.Update(msg)
+		p.resize()
+		return p, updateCmd
+	}
+
 	p.input, updateCmd = p.input.Update(msg)
 	return p, updateCmd
 }
 
+// resize makes the editor as high as its content, wrapped lines included,
+// up to max_editor_height lines
+func (p *Prompt) resize() {
+	width := p.editor.Width()
+
+	height := 0
+	for _, line := range strings.Split(p.editor.Value(), "\n") {
+		lineWidth := lipgloss.Width(line)
+		if width <= 0 || lineWidth < width {
+			height++
+		} else {
+			height += lineWidth/width + 1
+		}
+	}
+
+	p.editor.SetHeight(min(height, max_editor_height))
+}
+
 // HasActiveAutocomplete returns true if autocomplete is active
 func (p *Prompt) HasActiveAutocomplete() bool {
 	return p.autocomplete.Active
@@ -154,34 +277,56 @@ func (p *Prompt) GetAutocompleteSuggestions() string {
 	return p.autocomplete.FormatSuggestions()
 }
 
+// SetSlashContext sets the session slash command arguments are completed against
+func (p *Prompt) SetSlashContext(ctx slash.Context) *Prompt {
+	p.autocomplete.SetContext(ctx)
+
+	return p
+}
+
 // IsSlashCommand checks if the current input is a slash command
 func (p *Prompt) IsSlashCommand() bool {
-	return slash.IsSlashCommand(p.input.Value())
+	return slash.IsSlashCommand(p.GetValue())
 }
 
-// ExecuteSlashCommand executes the current slash command with the given config
-func (p *Prompt) ExecuteSlashCommand(config *config.Config) string {
-	return slash.ExecuteCommand(config, p.input.Value())
+// ExecuteSlashCommand executes the current slash command within the given session
+func (p *Prompt) ExecuteSlashCommand(ctx slash.Context) slash.Action {
+	return slash.DefaultRegistry.Execute(ctx, p.GetValue())
 }
 
 func (p *Prompt) View() string {
+	if p.isEditor() {
+		return p.editor.View()
+	}
+
 	return p.input.View()
 }
 
+// AsString returns the prompt as printed once submitted, the lines after
+// the first one being aligned with it
 func (p *Prompt) AsString() string {
 	style := getPromptStyle(p.mode)
+	icon := getPromptIcon(p.mode)
+
+	lines := strings.Split(p.GetValue(), "\n")
+	for i, line := range lines {
+		lines[i] = style.Render(line)
+	}
+	indent := "\n" + strings.Repeat(" ", lipgloss.Width(icon))
 
-	return fmt.Sprintf("%s%s", style.Render(getPromptIcon(p.mode)), style.Render(p.input.Value()))
+	return fmt.Sprintf("%s%s", style.Render(icon), strings.Join(lines, indent))
 }
 
 func getPromptStyle(mode PromptMode) lipgloss.Style {
+	colors := activeTheme.GetColors()
+
 	switch mode {
 	case ExecPromptMode:
-		return lipgloss.NewStyle().Foreground(lipgloss.Color(exec_color))
-	case ConfigPromptMode:
-		return lipgloss.NewStyle().Foreground(lipgloss.Color(config_color))
+		return foreground(colors.Exec)
+	case ConfigPromptMode, StoreKeyPromptMode:
+		return foreground(colors.Config)
 	default:
-		return lipgloss.NewStyle().Foreground(lipgloss.Color(chat_color))
+		return foreground(colors.Chat)
 	}
 }
 
@@ -197,6 +342,8 @@ func getPromptIcon(mode PromptMode) string {
 		return style.Render(provider_icon)
 	case ModelPromptMode:
 		return style.Render(model_icon)
+	case StoreKeyPromptMode:
+		return style.Render(store_key_icon)
 	default:
 		return style.Render(chat_icon)
 	}
@@ -212,6 +359,8 @@ func getPromptPlaceholder(mode PromptMode) string {
 		return provider_placeholder
 	case ModelPromptMode:
 		return model_placeholder
+	case StoreKeyPromptMode:
+		return store_key_placeholder
 	default:
 		return chat_placeholder
 	}
@@ -1,8 +1,10 @@
 package ui
 
 import (
+	"strings"
 	"testing"
 
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/stretchr/testify/assert"
 )
@@ -12,6 +14,9 @@ func TestUIPrompt(t *testing.T) {
 	t.Run("PromptStyle", testPromptStyle)
 	t.Run("PromptIcon", testPromptIcon)
 	t.Run("PromptPlaceholder", testPromptPlaceholder)
+	t.Run("PromptMultiline", testPromptMultiline)
+	t.Run("PromptPaste", testPromptPaste)
+	t.Run("PromptHeight", testPromptHeight)
 }
 
 func testPrompt(t *testing.T) {
@@ -90,3 +95,57 @@ func testPromptPlaceholder(t *testing.T) {
 		})
 	}
 }
+
+func testPromptMultiline(t *testing.T) {
+	p := NewPrompt(ChatPromptMode).SetWidth(80)
+
+	p, _ = p.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("first")})
+	p, _ = p.Update(tea.KeyMsg{Type: tea.KeyEnter, Alt: true})
+	p, _ = p.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("second")})
+	p, _ = p.Update(tea.KeyMsg{Type: tea.KeyCtrlJ})
+	p, _ = p.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("third")})
+	assert.Equal(t, "first\nsecond\nthird", p.GetValue())
+
+	assert.False(t, p.IsOnFirstLine())
+	assert.True(t, p.IsOnLastLine())
+	p, _ = p.Update(tea.KeyMsg{Type: tea.KeyUp})
+	p, _ = p.Update(tea.KeyMsg{Type: tea.KeyUp})
+	assert.True(t, p.IsOnFirstLine())
+	assert.False(t, p.IsOnLastLine())
+
+	printed := p.AsString()
+	assert.Contains(t, printed, "first")
+	assert.Len(t, strings.Split(printed, "\n"), 3, "The prompt should be printed on as many lines as it has.")
+
+	// The setup prompts stay on a single line
+	config := NewPrompt(ConfigPromptMode)
+	config.SetValue("secret")
+	assert.True(t, config.IsOnFirstLine())
+	assert.True(t, config.IsOnLastLine())
+	assert.NotContains(t, config.View(), "secret", "The API key should be masked.")
+}
+
+func testPromptPaste(t *testing.T) {
+	p := NewPrompt(ChatPromptMode).SetWidth(80)
+
+	trace := "panic: runtime error\n\ngoroutine 1 [running]:\nmain.main()\n\t/app/main.go:12"
+	p, _ = p.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("why? "), Paste: false})
+	p, _ = p.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(trace), Paste: true})
+
+	// Only the tabs are replaced, by spaces, as the editor does
+	assert.Equal(t, "why? "+strings.ReplaceAll(trace, "\t", "    "), p.GetValue(), "A pasted text should be kept intact.")
+}
+
+func testPromptHeight(t *testing.T) {
+	p := NewPrompt(ExecPromptMode).SetWidth(40)
+	assert.Equal(t, 1, strings.Count(p.View(), "\n")+1)
+
+	p.SetValue("one\ntwo\nthree")
+	assert.Equal(t, 3, strings.Count(p.View(), "\n")+1, "The editor should grow with its content.")
+
+	p.SetValue(strings.Repeat("line\n", 50))
+	assert.Equal(t, max_editor_height, strings.Count(p.View(), "\n")+1, "The editor should not grow past its maximum height.")
+
+	p.SetValue("")
+	assert.Equal(t, 1, strings.Count(p.View(), "\n")+1)
+}
@@ -1,20 +1,18 @@
 package ui
 
 import (
+	"regexp"
+	"strings"
+
 	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/lipgloss"
-)
 
-const (
-	exec_color    = "#ffa657"
-	config_color  = "#ffffff"
-	chat_color    = "#66b3ff"
-	help_color    = "#aaaaaa"
-	error_color   = "#cc3333"
-	warning_color = "#ffcc00"
-	success_color = "#46b946"
+	"github.com/xsikor/yai/codeblock"
 )
 
+// ansiPattern matches the escape sequences styling rendered content
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
 type Renderer struct {
 	contentRenderer *glamour.TermRenderer
 	successRenderer lipgloss.Style
@@ -23,16 +21,19 @@ type Renderer struct {
 	helpRenderer    lipgloss.Style
 }
 
+// NewRenderer returns a renderer with the colors of the active theme, the
+// options setting the markdown style
 func NewRenderer(options ...glamour.TermRendererOption) *Renderer {
 	contentRenderer, err := glamour.NewTermRenderer(options...)
 	if err != nil {
 		return nil
 	}
 
-	successRenderer := lipgloss.NewStyle().Foreground(lipgloss.Color(success_color))
-	warningRenderer := lipgloss.NewStyle().Foreground(lipgloss.Color(warning_color))
-	errorRenderer := lipgloss.NewStyle().Foreground(lipgloss.Color(error_color))
-	helpRenderer := lipgloss.NewStyle().Foreground(lipgloss.Color(help_color)).Italic(true)
+	colors := activeTheme.GetColors()
+	successRenderer := foreground(colors.Success)
+	warningRenderer := foreground(colors.Warning)
+	errorRenderer := foreground(colors.Error)
+	helpRenderer := foreground(colors.Help).Italic(true)
 
 	return &Renderer{
 		contentRenderer: contentRenderer,
@@ -49,6 +50,142 @@ func (r *Renderer) RenderContent(in string) string {
 	return out
 }
 
+// MarkdownStream renders a markdown answer while it is streamed. Complete
+// blocks are rendered once, to be printed for good, and only the block in
+// progress is rendered again as content arrives.
+type MarkdownStream struct {
+	renderer  *Renderer
+	content   strings.Builder
+	committed int
+}
+
+func NewMarkdownStream(renderer *Renderer) *MarkdownStream {
+	return &MarkdownStream{
+		renderer: renderer,
+	}
+}
+
+// SetRenderer changes the renderer of the blocks to come, like when the
+// terminal is resized
+func (s *MarkdownStream) SetRenderer(renderer *Renderer) {
+	s.renderer = renderer
+}
+
+// Write adds streamed content
+func (s *MarkdownStream) Write(content string) {
+	s.content.WriteString(content)
+}
+
+// Commit returns the rendering of the blocks completed since the last
+// commit, or an empty string if none is
+func (s *MarkdownStream) Commit() string {
+	pending := s.pending()
+
+	end := completeBlocksEnd(pending)
+	if end == 0 {
+		return ""
+	}
+	s.committed += end
+
+	// Blocks rendered apart get their own margins, the blocks rendered
+	// together being separated by a single blank line
+	return "\n" + trimBlankLines(s.renderer.RenderContent(pending[:end]))
+}
+
+// Flush returns the rendering of the content not committed yet
+func (s *MarkdownStream) Flush() string {
+	pending := s.pending()
+	s.committed += len(pending)
+	if strings.TrimSpace(pending) == "" {
+		return ""
+	}
+
+	return "\n" + trimBlankLines(s.renderer.RenderContent(pending)) + "\n\n"
+}
+
+// View returns the rendering of the block in progress
+func (s *MarkdownStream) View() string {
+	pending := s.pending()
+	if strings.TrimSpace(pending) == "" {
+		return ""
+	}
+
+	return s.renderer.RenderContent(pending)
+}
+
+// Content returns the whole streamed content
+func (s *MarkdownStream) Content() string {
+	return s.content.String()
+}
+
+func (s *MarkdownStream) pending() string {
+	return s.content.String()[s.committed:]
+}
+
+// trimBlankLines removes the lines without visible characters around
+// rendered content
+func trimBlankLines(rendered string) string {
+	lines := strings.Split(rendered, "\n")
+
+	start, end := 0, len(lines)
+	for start < end && isBlankLine(lines[start]) {
+		start++
+	}
+	for end > start && isBlankLine(lines[end-1]) {
+		end--
+	}
+
+	return strings.Join(lines[start:end], "\n")
+}
+
+// isBlankLine tells if a rendered line only has spaces and styles
+func isBlankLine(line string) bool {
+	return strings.TrimSpace(ansiPattern.ReplaceAllString(line, "")) == ""
+}
+
+// completeBlocksEnd returns the length of the complete blocks at the start
+// of markdown content: the blocks followed by a blank line outside of a code
+// fence, and by a line that does not continue them with an indentation.
+func completeBlocksEnd(content string) int {
+	end := 0
+	fence := ""
+	blank := false
+	hasBlock := false
+
+	for offset := 0; offset < len(content); {
+		length := strings.IndexByte(content[offset:], '\n')
+		if length < 0 {
+			// The last line is not complete, but its first character tells
+			// if it starts a new block
+			if blank && content[offset] != ' ' && content[offset] != '\t' {
+				end = offset
+			}
+			break
+		}
+		line := content[offset : offset+length]
+
+		switch {
+		case fence != "":
+			if codeblock.IsClosingFence(line, fence) {
+				fence = ""
+			}
+		case strings.TrimSpace(line) == "":
+			blank = hasBlock
+		default:
+			if blank && line[0] != ' ' && line[0] != '\t' {
+				end = offset
+			}
+			blank = false
+			hasBlock = true
+			fence = codeblock.OpeningFence(line)
+		}
+
+		offset += length + 1
+	}
+
+	return end
+}
+
 func (r *Renderer) RenderSuccess(in string) string {
 	return r.successRenderer.Render(in)
 }
@@ -76,15 +213,29 @@ func (r *Renderer) RenderConfigMessage() string {
 	return welcome
 }
 
-func (r *Renderer) RenderApiKeyMessage() string {
+func (r *Renderer) RenderApiKeyMessage(envVar string) string {
 	welcome := "Please enter an API key for your selected provider.\n\n"
 	welcome += "For OpenAI, get a key from https://platform.openai.com/account/api-keys\n"
 	welcome += "For Google Gemini, get a key from https://ai.google.dev/\n"
-	welcome += "For Anthropic Claude, get a key from https://console.anthropic.com/\n"
+	welcome += "For Anthropic Claude, get a key from https://console.anthropic.com/\n\n"
+	welcome += "If `" + envVar + "` is already set in your environment, just press Enter to use it.\n"
 
 	return welcome
 }
 
+func (r *Renderer) RenderStoreKeyMessage(configFile string, envVar string) string {
+	message := "Where do you want to store the API key?\n\n"
+	message += "- `k`: in the OS keyring (default)\n"
+	message += "- `f`: in plaintext in `" + configFile + "`\n"
+	message += "- `n`: nowhere, to use it for this session only. Next time, yai will read it from:\n"
+	message += "   - `AI_KEY_COMMAND`: a command printing the key, for example `pass show openai`\n"
+	message += "   - `AI_KEY_FILE`: a file only you can read (`chmod 600`)\n"
+	message += "   - the `" + envVar + "` environment variable\n\n"
+	message += "Store the key? [K/f/n]: "
+
+	return message
+}
+
 func (r *Renderer) RenderModelMessage(provider string) string {
 	message := "Select a model for " + provider + ":\n\n"
 	
@@ -114,19 +265,32 @@ func (r *Renderer) RenderModelMessage(provider string) string {
 
 func (r *Renderer) RenderHelpMessage() string {
 	help := "**Keyboard Shortcuts**\n"
-	help += "- `↑`/`↓` : navigate in history\n"
+	help += "- `↑`/`↓` : navigate in history, or between the lines of the prompt\n"
+	help += "- `alt+enter`/`ctrl+j`: insert a new line\n"
+	help += "- `ctrl+x ctrl+e`: compose the prompt in your editor\n"
 	help += "- `tab`   : switch between `🚀 exec` and `💬 chat` prompt modes\n"
 	help += "- `ctrl+h`: show help\n"
 	help += "- `ctrl+s`: edit settings\n"
 	help += "- `ctrl+r`: clear terminal and reset discussion history\n"
 	help += "- `ctrl+l`: clear terminal but keep discussion history\n"
-	help += "- `ctrl+c`: exit or interrupt command execution\n\n"
+	help += "- `ctrl+c`: exit or interrupt command execution\n"
+	help += "- `pgup`/`pgdn`, `esc`: scroll, or browse the transcript in full screen mode\n\n"
 	
 	help += "**Slash Commands**\n"
 	help += "- `/help`: show available slash commands\n" 
 	help += "- `/config`: show current configuration\n"
 	help += "- `/models`: show available AI models\n"
 	help += "- `/providers`: show available AI providers\n"
+	help += "- `/model <name> [--save]`: switch model, keeping the conversation\n"
+	help += "- `/provider <name> [--save]`: switch provider, keeping the conversation\n"
+	help += "- `/profile`: list profiles, `/profile switch <name>` to switch\n"
+	help += "- `/theme`: list themes, `/theme <name>` to switch\n"
+	help += "- `/add <path>`: attach files, directories or globs to the conversation (or mention them as `@path`)\n"
+	help += "- `/image <path>`: send images with the next prompt, for models accepting images\n"
+	help += "- `/drop [path]`: detach files, all of them without argument\n"
+	help += "- `/context`: show the system context and attached files sent with every request\n"
+	help += "- `/run <N>`, `/copy <N>`, `/save <N> <path>`: run, copy or save a code block of the last answer\n"
+	help += "- `/export <path> [--format md|json|html] [--context]`: write the conversation and the commands run to a file\n"
 	help += "- `/mode`: toggle between chat and execute modes\n"
 	help += "- `/clear`: clear the screen\n"
 	help += "- `/reset`: reset conversation history\n\n"
@@ -137,7 +301,18 @@ func (r *Renderer) RenderHelpMessage() string {
 	help += "- `-c`: use chat prompt mode\n"
 	help += "- `-p`: select AI provider (openai, claude, gemini)\n"
 	help += "- `-model`: specify AI model to use\n"
+	help += "- `-profile`: select config profile (or set `YAI_PROFILE`)\n"
+	help += "- `-temperature`: AI temperature for this run\n"
+	help += "- `-max-tokens`: AI max tokens for this run\n"
+	help += "- `-json`: print the result as JSON, for scripts\n"
+	help += "- `-raw`: print the raw answer, or the bare command\n"
+	help += "- `-yes`: run the generated command without confirmation\n"
+	help += "- `-fullscreen`: show the REPL transcript in a scrollable full screen view\n"
+	help += "- `-pipe-limit`: size in KiB of piped text sent as is, a larger input being truncated\n"
+	help += "- `-analyze`: analyze a large piped input in parts and answer with a report citing line numbers\n"
+	help += "- `-concurrency`: parts analyzed at once with `-analyze`\n"
 	help += "- `-m`: show current AI model and provider\n"
+	help += "- `yai serve`: expose the engine over a local HTTP API\n"
 
 	return help
 }
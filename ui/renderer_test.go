@@ -1,12 +1,39 @@
 package ui
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/charmbracelet/glamour"
 	"github.com/stretchr/testify/assert"
 )
 
+// streamedAnswer is a long answer mixing the blocks a stream can split
+var streamedAnswer = strings.Repeat(`## Debugging the pod
+
+First check the state of the pods, then describe the one failing:
+
+`+"```bash"+`
+kubectl get pods
+
+kubectl describe pod web
+`+"```"+`
+
+| Name | Status  |
+|------|---------|
+| web  | Running |
+| db   | Pending |
+
+1. Check the events
+2. Check the logs
+
+- The image may be missing
+
+  or the registry unreachable
+- The node may be full
+
+`, 10)
+
 func TestUIRenderer(t *testing.T) {
 	t.Run("Renderer", testRenderer)
 	t.Run("RenderContent", testRenderContent)
@@ -16,6 +43,8 @@ func TestUIRenderer(t *testing.T) {
 	t.Run("RenderHelp", testRenderHelp)
 	t.Run("RenderConfigMessage", testRenderConfigMessage)
 	t.Run("RenderHelpMessage", testRenderHelpMessage)
+	t.Run("MarkdownStream", testMarkdownStream)
+	t.Run("CompleteBlocksEnd", testCompleteBlocksEnd)
 }
 
 func testRenderer(t *testing.T) {
@@ -69,3 +98,106 @@ func testRenderHelpMessage(t *testing.T) {
 	output := r.RenderHelpMessage()
 	assert.NotEmpty(t, output, "Rendered help message should not be empty.")
 }
+
+// streamRender renders content as the UI does while streaming it in chunks
+// of the given size: the committed blocks printed one per line, then the
+// rest
+func streamRender(r *Renderer, content string, size int) string {
+	var sb strings.Builder
+
+	stream := NewMarkdownStream(r)
+	for start := 0; start < len(content); start += size {
+		stream.Write(content[start:min(start+size, len(content))])
+		if committed := stream.Commit(); committed != "" {
+			sb.WriteString(committed + "\n")
+		}
+	}
+	sb.WriteString(stream.Flush())
+
+	return sb.String()
+}
+
+// visibleLines removes the spaces padding rendered lines
+func visibleLines(rendered string) []string {
+	lines := strings.Split(rendered, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+
+	return lines
+}
+
+func testMarkdownStream(t *testing.T) {
+	r := NewRenderer(glamour.WithStandardStyle("notty"), glamour.WithWordWrap(80))
+
+	expected := visibleLines(r.RenderContent(streamedAnswer))
+	for _, size := range []int{1, 7, 64, len(streamedAnswer)} {
+		assert.Equal(t, expected, visibleLines(streamRender(r, streamedAnswer, size)), "chunks of %d bytes", size)
+	}
+
+	stream := NewMarkdownStream(r)
+	stream.Write("First paragraph.\n\n```sh\nls\n\n")
+	committed := stream.Commit()
+	assert.Contains(t, committed, "First paragraph.")
+	assert.Empty(t, stream.Commit(), "Nothing should be committed twice.")
+	assert.Contains(t, stream.View(), "ls", "The code block in progress should be viewed.")
+	assert.NotContains(t, stream.View(), "First paragraph.")
+
+	stream.Write("pwd\n```\n")
+	assert.Empty(t, stream.Commit(), "A block should not be committed before what follows it is known.")
+	assert.Contains(t, stream.Flush(), "pwd")
+	assert.Empty(t, stream.View())
+	assert.Equal(t, "First paragraph.\n\n```sh\nls\n\npwd\n```\n", stream.Content())
+}
+
+func testCompleteBlocksEnd(t *testing.T) {
+	testCases := []struct {
+		content string
+		end     int
+	}{
+		{"", 0},
+		{"A paragraph", 0},
+		{"A paragraph\n\n", 0},
+		{"A paragraph\n\nNext", 13},
+		{"\n\nA paragraph\n\nNext", 15},
+		{"One\n\nTwo\n\nThree", 10},
+		{"```sh\nls\n\npwd\n", 0},
+		{"```sh\nls\n\npwd\n```\n\nNext", 19},
+		{"~~~\n```\n\n~~~\n\nNext", 14},
+		{"- item\n\n  continued", 0},
+		{"- item\n\n\tcontinued", 0},
+		{"- item\n\n- other", 8},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.end, completeBlocksEnd(tc.content), "%q", tc.content)
+	}
+}
+
+// BenchmarkRenderBuffer renders the whole answer on every chunk, as done
+// before rendering streams block by block
+func BenchmarkRenderBuffer(b *testing.B) {
+	r := NewRenderer(glamour.WithStandardStyle("dark"), glamour.WithWordWrap(80))
+
+	for i := 0; i < b.N; i++ {
+		for end := 16; end < len(streamedAnswer); end += 16 {
+			r.RenderContent(streamedAnswer[:end])
+		}
+	}
+}
+
+// BenchmarkMarkdownStream renders the answer block by block, viewing the
+// block in progress on every chunk
+func BenchmarkMarkdownStream(b *testing.B) {
+	r := NewRenderer(glamour.WithStandardStyle("dark"), glamour.WithWordWrap(80))
+
+	for i := 0; i < b.N; i++ {
+		stream := NewMarkdownStream(r)
+		for start := 0; start < len(streamedAnswer); start += 16 {
+			stream.Write(streamedAnswer[start:min(start+16, len(streamedAnswer))])
+			stream.Commit()
+			stream.View()
+		}
+		stream.Flush()
+	}
+}
@@ -0,0 +1,255 @@
+package slash
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/xsikor/yai/ai"
+	"github.com/xsikor/yai/attachment"
+	"github.com/xsikor/yai/codeblock"
+	"github.com/xsikor/yai/export"
+)
+
+// Action is what the UI has to do once a slash command ran. Commands return
+// one of the types below, or their own, instead of output the UI would have
+// to parse, and the UI applies it.
+type Action interface {
+	Apply(ui UiContext) tea.Cmd
+}
+
+// UiContext is what actions can do with the UI running the slash command
+type UiContext interface {
+	// Engine returns the engine of the session
+	Engine() *ai.Engine
+	// Echo prints the slash command as typed
+	Echo() tea.Cmd
+	// PrintContent prints markdown content
+	PrintContent(content string) tea.Cmd
+	// PrintSuccess prints a confirmation, shown as "[message]"
+	PrintSuccess(message string) tea.Cmd
+	// PrintError prints an error, shown as "[label] err"
+	PrintError(label string, err error) tea.Cmd
+	// PrintAttached tells which files were attached, and which could not be
+	PrintAttached(files []attachment.File, warnings []string) tea.Cmd
+	// PrintAttachedImages tells which images go with the next prompt, and
+	// which could not be loaded
+	PrintAttachedImages(images []attachment.Image, warnings []string) tea.Cmd
+	ClearScreen() tea.Cmd
+	// ResetHistory clears the history of the inputs
+	ResetHistory()
+	ToggleMode() tea.Cmd
+	SwitchProfile(name string) tea.Cmd
+	SwitchModel(name string, save bool) tea.Cmd
+	SwitchProvider(name string, save bool) tea.Cmd
+	SetTheme(name string) error
+	// Query sends a prompt to the AI, in the given mode, "chat" or "exec",
+	// or in the current one if empty
+	Query(prompt string, mode string) tea.Cmd
+	// Propose offers to run a command, going through the confirmation and
+	// command policies as a generated command does
+	Propose(command string, explanation string) tea.Cmd
+}
+
+// PrintAction prints markdown content
+type PrintAction struct {
+	Content string
+}
+
+// ClearAction clears the screen
+type ClearAction struct{}
+
+// ResetAction resets the conversation history
+type ResetAction struct{}
+
+// ToggleModeAction switches between chat and exec modes
+type ToggleModeAction struct{}
+
+// PromptAction sends a prompt to the AI, in the given mode, "chat" or
+// "exec", or in the current one if empty
+type PromptAction struct {
+	Prompt string
+	Mode   string
+}
+
+// AttachAction attaches files to the conversation, the warnings telling
+// which files could not be attached
+type AttachAction struct {
+	Files    []attachment.File
+	Warnings []string
+}
+
+// AttachImagesAction sends images with the next prompt, the warnings
+// telling which images could not be loaded
+type AttachImagesAction struct {
+	Images   []attachment.Image
+	Warnings []string
+}
+
+// DetachAction removes the attached files matching the pattern, or all of
+// them if empty
+type DetachAction struct {
+	Pattern string
+}
+
+// SwitchProfileAction switches to another config profile
+type SwitchProfileAction struct {
+	Name string
+}
+
+// SwitchModelAction switches to another model of the current provider,
+// writing it to the config file if Save is set
+type SwitchModelAction struct {
+	Name string
+	Save bool
+}
+
+// SwitchProviderAction switches to another provider, writing it to the
+// config file if Save is set
+type SwitchProviderAction struct {
+	Name string
+	Save bool
+}
+
+// SwitchThemeAction switches the terminal UI to another theme
+type SwitchThemeAction struct {
+	Name string
+}
+
+// RunBlockAction runs a code block of the last answer, going through the
+// confirmation and command policies as a generated command does
+type RunBlockAction struct {
+	Number int
+	Block  codeblock.Block
+}
+
+// CopyBlockAction copies a code block of the last answer to the clipboard
+type CopyBlockAction struct {
+	Number int
+	Block  codeblock.Block
+}
+
+// SaveBlockAction writes a code block of the last answer to a file
+type SaveBlockAction struct {
+	Number int
+	Block  codeblock.Block
+	Path   string
+}
+
+// ExportAction writes the conversation to a file, with the context sent
+// along with every request if Context is set
+type ExportAction struct {
+	Path    string
+	Format  export.Format
+	Context bool
+}
+
+func (a PrintAction) Apply(ui UiContext) tea.Cmd {
+	return tea.Sequence(ui.Echo(), ui.PrintContent(a.Content))
+}
+
+func (ClearAction) Apply(ui UiContext) tea.Cmd {
+	return ui.ClearScreen()
+}
+
+func (ResetAction) Apply(ui UiContext) tea.Cmd {
+	ui.Engine().Reset()
+	ui.ResetHistory()
+
+	return ui.PrintSuccess("History cleared")
+}
+
+func (ToggleModeAction) Apply(ui UiContext) tea.Cmd {
+	return ui.ToggleMode()
+}
+
+func (a PromptAction) Apply(ui UiContext) tea.Cmd {
+	return ui.Query(a.Prompt, a.Mode)
+}
+
+func (a AttachAction) Apply(ui UiContext) tea.Cmd {
+	ui.Engine().Attach(a.Files...)
+
+	return ui.PrintAttached(a.Files, a.Warnings)
+}
+
+func (a AttachImagesAction) Apply(ui UiContext) tea.Cmd {
+	ui.Engine().AttachImages(a.Images...)
+
+	return ui.PrintAttachedImages(a.Images, a.Warnings)
+}
+
+func (a DetachAction) Apply(ui UiContext) tea.Cmd {
+	count := ui.Engine().Detach(a.Pattern)
+
+	return ui.PrintSuccess(fmt.Sprintf("Detached %d file(s)", count))
+}
+
+func (a SwitchProfileAction) Apply(ui UiContext) tea.Cmd {
+	return ui.SwitchProfile(a.Name)
+}
+
+func (a SwitchModelAction) Apply(ui UiContext) tea.Cmd {
+	return ui.SwitchModel(a.Name, a.Save)
+}
+
+func (a SwitchProviderAction) Apply(ui UiContext) tea.Cmd {
+	return ui.SwitchProvider(a.Name, a.Save)
+}
+
+func (a SwitchThemeAction) Apply(ui UiContext) tea.Cmd {
+	if err := ui.SetTheme(a.Name); err != nil {
+		return ui.PrintError("theme error", err)
+	}
+
+	return ui.PrintSuccess(fmt.Sprintf("Switched to theme %s", a.Name))
+}
+
+func (a RunBlockAction) Apply(ui UiContext) tea.Cmd {
+	return ui.Propose(a.Block.GetCommand(), fmt.Sprintf("block %d of the last answer", a.Number))
+}
+
+func (a CopyBlockAction) Apply(ui UiContext) tea.Cmd {
+	if err := clipboard.WriteAll(a.Block.GetCode()); err != nil {
+		return ui.PrintError("copy error", err)
+	}
+
+	return ui.PrintSuccess(fmt.Sprintf("Copied block %d", a.Number))
+}
+
+func (a SaveBlockAction) Apply(ui UiContext) tea.Cmd {
+	if err := saveFile(a.Path, []byte(a.Block.GetCode()+"\n")); err != nil {
+		return ui.PrintError("save error", err)
+	}
+
+	return ui.PrintSuccess(fmt.Sprintf("Saved block %d to %s", a.Number, a.Path))
+}
+
+func (a ExportAction) Apply(ui UiContext) tea.Cmd {
+	content, err := export.Render(ui.Engine().GetConversation(), a.Format, export.Options{Context: a.Context})
+	if err == nil {
+		err = saveFile(a.Path, content)
+	}
+	if err != nil {
+		return ui.PrintError("export error", err)
+	}
+
+	return ui.PrintSuccess(fmt.Sprintf("Exported conversation to %s", a.Path))
+}
+
+// saveFile writes a new file, never overwriting an existing one
+func saveFile(path string, content []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
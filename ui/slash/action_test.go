@@ -0,0 +1,137 @@
+package slash
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xsikor/yai/ai"
+	"github.com/xsikor/yai/attachment"
+	"github.com/xsikor/yai/codeblock"
+)
+
+// fakeUi records what the actions ask the UI for
+type fakeUi struct {
+	calls    []string
+	themeErr error
+}
+
+func (f *fakeUi) record(format string, args ...any) tea.Cmd {
+	f.calls = append(f.calls, fmt.Sprintf(format, args...))
+	return nil
+}
+
+func (f *fakeUi) Engine() *ai.Engine                  { return nil }
+func (f *fakeUi) Echo() tea.Cmd                       { return f.record("echo") }
+func (f *fakeUi) PrintContent(content string) tea.Cmd { return f.record("content %s", content) }
+func (f *fakeUi) PrintSuccess(message string) tea.Cmd { return f.record("success %s", message) }
+func (f *fakeUi) PrintError(label string, err error) tea.Cmd {
+	return f.record("error %s: %s", label, err)
+}
+func (f *fakeUi) PrintAttached(files []attachment.File, warnings []string) tea.Cmd {
+	return f.record("attached %d", len(files))
+}
+func (f *fakeUi) PrintAttachedImages(images []attachment.Image, warnings []string) tea.Cmd {
+	return f.record("attached images %d", len(images))
+}
+func (f *fakeUi) ClearScreen() tea.Cmd              { return f.record("clear") }
+func (f *fakeUi) ResetHistory()                     { f.record("reset history") }
+func (f *fakeUi) ToggleMode() tea.Cmd               { return f.record("toggle mode") }
+func (f *fakeUi) SwitchProfile(name string) tea.Cmd { return f.record("profile %s", name) }
+func (f *fakeUi) SwitchModel(name string, save bool) tea.Cmd {
+	return f.record("model %s %t", name, save)
+}
+func (f *fakeUi) SwitchProvider(name string, save bool) tea.Cmd {
+	return f.record("provider %s %t", name, save)
+}
+func (f *fakeUi) SetTheme(name string) error {
+	f.record("theme %s", name)
+	return f.themeErr
+}
+func (f *fakeUi) Query(prompt string, mode string) tea.Cmd {
+	return f.record("query %s in %q", prompt, mode)
+}
+func (f *fakeUi) Propose(command string, explanation string) tea.Cmd {
+	return f.record("propose %s: %s", command, explanation)
+}
+
+// upperAction is an action defined outside of the built-in ones
+type upperAction struct {
+	text string
+}
+
+func (a upperAction) Apply(ui UiContext) tea.Cmd {
+	return ui.PrintContent(strings.ToUpper(a.text))
+}
+
+func TestActions(t *testing.T) {
+	t.Run("Apply", testApply)
+	t.Run("ApplyCustom", testApplyCustom)
+	t.Run("SaveBlock", testSaveBlock)
+}
+
+func testApply(t *testing.T) {
+	shell := codeblock.NewBlock("bash", "ls -la")
+
+	testCases := []struct {
+		action Action
+		calls  []string
+	}{
+		{PrintAction{Content: "hello"}, []string{"echo", "content hello"}},
+		{ClearAction{}, []string{"clear"}},
+		{ToggleModeAction{}, []string{"toggle mode"}},
+		{PromptAction{Prompt: "list pods", Mode: "exec"}, []string{`query list pods in "exec"`}},
+		{SwitchProfileAction{Name: "work"}, []string{"profile work"}},
+		{SwitchModelAction{Name: "gpt-4", Save: true}, []string{"model gpt-4 true"}},
+		{SwitchProviderAction{Name: "claude"}, []string{"provider claude false"}},
+		{SwitchThemeAction{Name: "light"}, []string{"theme light", "success Switched to theme light"}},
+		{RunBlockAction{Number: 1, Block: shell}, []string{"propose ls -la: block 1 of the last answer"}},
+	}
+
+	for _, testCase := range testCases {
+		ui := &fakeUi{}
+		testCase.action.Apply(ui)
+		assert.Equal(t, testCase.calls, ui.calls, "%T", testCase.action)
+	}
+
+	ui := &fakeUi{themeErr: errors.New("unknown theme")}
+	SwitchThemeAction{Name: "neon"}.Apply(ui)
+	assert.Equal(t, []string{"theme neon", "error theme error: unknown theme"}, ui.calls)
+}
+
+func testApplyCustom(t *testing.T) {
+	registry := NewRegistry()
+	require.NoError(t, registry.Register(NewSlashCommand("shout", "Shout the text", func(ctx Context, args string) Action {
+		return upperAction{text: args}
+	})))
+
+	ui := &fakeUi{}
+	registry.Execute(Context{}, "/shout hello").Apply(ui)
+	assert.Equal(t, []string{"content HELLO"}, ui.calls)
+}
+
+func testSaveBlock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hello.py")
+	action := SaveBlockAction{Number: 2, Block: codeblock.NewBlock("python", "print('hello')"), Path: path}
+
+	ui := &fakeUi{}
+	action.Apply(ui)
+	assert.Equal(t, []string{"success Saved block 2 to " + path}, ui.calls)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "print('hello')\n", string(content))
+
+	// An existing file is never overwritten
+	ui = &fakeUi{}
+	action.Apply(ui)
+	require.Len(t, ui.calls, 1)
+	assert.Contains(t, ui.calls[0], "error save error")
+}
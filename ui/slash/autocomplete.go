@@ -1,54 +1,40 @@
 package slash
 
 import (
-	"sort"
 	"strings"
 )
 
 // AutocompleteState represents the current state of autocompletion
 type AutocompleteState struct {
-	Active      bool
-	Suggestions []string
-	Index       int
+	Active        bool
+	Suggestions   []string
+	Index         int
 	OriginalInput string
+	registry      *Registry
+	context       Context
 }
 
 // NewAutocompleteState creates a new autocomplete state
 func NewAutocompleteState() *AutocompleteState {
 	return &AutocompleteState{
-		Active:      false,
-		Suggestions: []string{},
-		Index:       0,
+		Active:        false,
+		Suggestions:   []string{},
+		Index:         0,
 		OriginalInput: "",
+		registry:      DefaultRegistry,
+		context:       Context{},
 	}
 }
 
+// SetContext sets the session the command arguments are completed against
+func (a *AutocompleteState) SetContext(ctx Context) {
+	a.context = ctx
+}
+
 // StartAutocomplete initiates autocompletion for the given input
 func (a *AutocompleteState) StartAutocomplete(input string) bool {
-	if !IsSlashCommand(input) {
-		a.Reset()
-		return false
-	}
-
-	// Special case: if it's just a slash, show all commands
-	if input == "/" {
-		var allCommands []string
-		for _, cmd := range SlashCommands {
-			allCommands = append(allCommands, "/"+cmd.Name)
-		}
-		
-		// Sort commands alphabetically
-		sort.Strings(allCommands)
-		
-		a.Active = true
-		a.Suggestions = allCommands
-		a.Index = 0
-		a.OriginalInput = input
-		return true
-	}
-
-	// Get potential completions for partial commands
-	suggestions := GetCompletions(input)
+	// Just a slash shows all commands
+	suggestions := a.registry.Complete(a.context, input)
 	if len(suggestions) == 0 {
 		a.Reset()
 		return false
@@ -106,12 +92,12 @@ func (a *AutocompleteState) FormatSuggestions() string {
 	}
 
 	var sb strings.Builder
-	
+
 	// Add heading for slash commands
 	if a.OriginalInput == "/" {
 		sb.WriteString("Available commands:\n")
 	}
-	
+
 	// Format all suggestions
 	for i, suggestion := range a.Suggestions {
 		if i == a.Index {
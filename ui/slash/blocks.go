@@ -0,0 +1,100 @@
+package slash
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xsikor/yai/codeblock"
+)
+
+// CompleteCodeBlocks completes a single argument to the number of a code
+// block of the last answer
+var CompleteCodeBlocks = CompleteValues(func(ctx Context) []string {
+	numbers := make([]string, 0, len(ctx.CodeBlocks))
+	for i := range ctx.CodeBlocks {
+		numbers = append(numbers, strconv.Itoa(i+1))
+	}
+	return numbers
+})
+
+func executeRunCommand(ctx Context, args string) Action {
+	number, block, message := selectCodeBlock(ctx, args, "/run <N>")
+	if message != "" {
+		return PrintAction{Content: message}
+	}
+
+	if !block.IsShell() {
+		return PrintAction{Content: fmt.Sprintf(
+			"Block %d is `%s` code, only shell blocks can be run. Use `/save %d <path>` to write it to a file.",
+			number,
+			block.GetLanguage(),
+			number,
+		)}
+	}
+
+	return RunBlockAction{Number: number, Block: block}
+}
+
+func executeCopyCommand(ctx Context, args string) Action {
+	number, block, message := selectCodeBlock(ctx, args, "/copy <N>")
+	if message != "" {
+		return PrintAction{Content: message}
+	}
+
+	return CopyBlockAction{Number: number, Block: block}
+}
+
+func executeSaveCommand(ctx Context, args string) Action {
+	numberArg, path, _ := strings.Cut(strings.TrimSpace(args), " ")
+	path = strings.TrimSpace(path)
+
+	number, block, message := selectCodeBlock(ctx, numberArg, "/save <N> <path>")
+	if message != "" {
+		return PrintAction{Content: message}
+	}
+	if path == "" {
+		return PrintAction{Content: "Usage: `/save <N> <path>`"}
+	}
+
+	return SaveBlockAction{Number: number, Block: block, Path: path}
+}
+
+// selectCodeBlock returns the code block numbered by the argument, or the
+// message telling why there is none
+func selectCodeBlock(ctx Context, arg string, usage string) (int, codeblock.Block, string) {
+	if len(ctx.CodeBlocks) == 0 {
+		return 0, codeblock.Block{}, "No code blocks in the last answer."
+	}
+
+	number, err := strconv.Atoi(strings.TrimSpace(arg))
+	if err != nil || number < 1 || number > len(ctx.CodeBlocks) {
+		return 0, codeblock.Block{}, fmt.Sprintf("Usage: `%s`, N being from 1 to %d.\n\n%s", usage, len(ctx.CodeBlocks), formatCodeBlocks(ctx.CodeBlocks))
+	}
+
+	return number, ctx.CodeBlocks[number-1], ""
+}
+
+// completeSaveArgs completes the block number, then the path
+func completeSaveArgs(ctx Context, args string) []string {
+	if !strings.Contains(args, " ") {
+		return CompleteCodeBlocks(ctx, args)
+	}
+
+	return CompleteFiles(ctx, args)
+}
+
+// formatCodeBlocks lists the code blocks by number, as markdown
+func formatCodeBlocks(blocks []codeblock.Block) string {
+	var sb strings.Builder
+
+	for i, block := range blocks {
+		language := block.GetLanguage()
+		if language == "" {
+			language = "code"
+		}
+		sb.WriteString(fmt.Sprintf("%d. _%s_ `%s`\n", i+1, language, block.GetSummary()))
+	}
+
+	return sb.String()
+}
@@ -2,154 +2,242 @@ package slash
 
 import (
 	"fmt"
-	"sort"
 	"strings"
 
 	"github.com/xsikor/yai/ai/provider"
+	"github.com/xsikor/yai/attachment"
 	"github.com/xsikor/yai/config"
 )
 
-// SlashCommand represents a command that can be executed with a / prefix
+// SlashCommand is a command made of plain functions, the way the built-in
+// commands are defined
 type SlashCommand struct {
-	Name        string
-	Description string
-	Execute     func(config *config.Config, args string) string
-}
-
-// SlashCommands is a collection of all available slash commands
-var SlashCommands []SlashCommand
-
-// InitSlashCommands initializes all slash commands
-func InitSlashCommands() {
-	SlashCommands = []SlashCommand{
-		{
-			Name:        "help",
-			Description: "Show available slash commands",
-			Execute: func(config *config.Config, args string) string {
-				return formatHelpOutput()
-			},
-		},
-		{
-			Name:        "config",
-			Description: "Show current configuration",
-			Execute: func(config *config.Config, args string) string {
-				return formatConfigOutput(config)
-			},
-		},
-		{
-			Name:        "models",
-			Description: "Show available AI models for current provider",
-			Execute: func(config *config.Config, args string) string {
-				return formatModelsOutput(config)
-			},
-		},
-		{
-			Name:        "providers",
-			Description: "Show available AI providers",
-			Execute: func(config *config.Config, args string) string {
-				return formatProvidersOutput()
-			},
-		},
-		{
-			Name:        "clear",
-			Description: "Clear the screen",
-			Execute: func(config *config.Config, args string) string {
-				return "[clear]"
-			},
-		},
-		{
-			Name:        "reset",
-			Description: "Reset conversation history",
-			Execute: func(config *config.Config, args string) string {
-				return "[reset]"
-			},
-		},
-		{
-			Name:        "mode",
-			Description: "Switch between chat and exec modes",
-			Execute: func(config *config.Config, args string) string {
-				return "[mode]"
-			},
-		},
-	}
-}
-
-// IsSlashCommand checks if the input is a slash command
-func IsSlashCommand(input string) bool {
-	return strings.HasPrefix(input, "/")
-}
-
-// GetCommand returns the slash command if found
-func GetCommand(input string) *SlashCommand {
-	if !IsSlashCommand(input) {
+	name        string
+	description string
+	execute     func(ctx Context, args string) Action
+	completer   Completer
+}
+
+func NewSlashCommand(name string, description string, execute func(ctx Context, args string) Action) *SlashCommand {
+	return &SlashCommand{
+		name:        name,
+		description: description,
+		execute:     execute,
+		completer:   nil,
+	}
+}
+
+// WithCompleter sets the completer of the command's arguments
+func (c *SlashCommand) WithCompleter(completer Completer) *SlashCommand {
+	c.completer = completer
+
+	return c
+}
+
+func (c *SlashCommand) Name() string {
+	return c.name
+}
+
+func (c *SlashCommand) Description() string {
+	return c.description
+}
+
+func (c *SlashCommand) Execute(ctx Context, args string) Action {
+	return c.execute(ctx, args)
+}
+
+func (c *SlashCommand) Complete(ctx Context, args string) []string {
+	if c.completer == nil {
 		return nil
 	}
 
-	// Extract command name and arguments
-	parts := strings.SplitN(strings.TrimPrefix(input, "/"), " ", 2)
-	cmdName := parts[0]
+	return c.completer(ctx, args)
+}
 
-	for _, cmd := range SlashCommands {
-		if cmd.Name == cmdName {
-			return &cmd
+func init() {
+	for _, command := range builtinCommands() {
+		if err := Register(command); err != nil {
+			panic(err)
 		}
 	}
+}
 
-	return nil
+func builtinCommands() []Command {
+	return []Command{
+		NewSlashCommand("help", "Show available slash commands", func(ctx Context, args string) Action {
+			return PrintAction{Content: formatHelpOutput(DefaultRegistry)}
+		}),
+		NewSlashCommand("config", "Show current configuration", func(ctx Context, args string) Action {
+			return PrintAction{Content: formatConfigOutput(ctx.Config)}
+		}),
+		NewSlashCommand("models", "Show available AI models for current provider", func(ctx Context, args string) Action {
+			return PrintAction{Content: formatModelsOutput(ctx)}
+		}),
+		NewSlashCommand("providers", "Show available AI providers", func(ctx Context, args string) Action {
+			return PrintAction{Content: formatProvidersOutput()}
+		}),
+		NewSlashCommand("model", "Switch model with `/model <name> [--save]`", executeModelCommand).
+			WithCompleter(CompleteModels),
+		NewSlashCommand("provider", "Switch provider with `/provider <name> [--save]`", executeProviderCommand).
+			WithCompleter(CompleteValues(func(ctx Context) []string {
+				var names []string
+				for _, providerType := range provider.ProviderTypes() {
+					names = append(names, string(providerType))
+				}
+				return names
+			})),
+		NewSlashCommand("profile", "List profiles, or switch with `/profile switch <name>`", executeProfileCommand).
+			WithCompleter(CompleteSubcommands(map[string]Completer{
+				"list":   nil,
+				"switch": CompleteProfiles,
+			})),
+		NewSlashCommand("theme", "List themes, or switch with `/theme <name>`", executeThemeCommand).
+			WithCompleter(CompleteValues(func(ctx Context) []string {
+				return ctx.Themes
+			})),
+		NewSlashCommand("add", "Attach files to the conversation with `/add <path|dir|glob>...`", executeAddCommand).
+			WithCompleter(CompleteFiles),
+		NewSlashCommand("image", "Send images with the next prompt with `/image <path>...`", executeImageCommand).
+			WithCompleter(CompleteFiles),
+		NewSlashCommand("drop", "Detach files with `/drop [path|dir|glob]`, all of them without argument", func(ctx Context, args string) Action {
+			return DetachAction{Pattern: strings.TrimSpace(args)}
+		}).WithCompleter(CompleteAttachments),
+		NewSlashCommand("context", "Show the context sent with every request, and its token cost", func(ctx Context, args string) Action {
+			return PrintAction{Content: formatContextOutput(ctx)}
+		}),
+		NewSlashCommand("run", "Run a code block of the last answer with `/run <N>`", executeRunCommand).
+			WithCompleter(CompleteCodeBlocks),
+		NewSlashCommand("copy", "Copy a code block of the last answer with `/copy <N>`", executeCopyCommand).
+			WithCompleter(CompleteCodeBlocks),
+		NewSlashCommand("save", "Save a code block of the last answer with `/save <N> <path>`", executeSaveCommand).
+			WithCompleter(completeSaveArgs),
+		NewSlashCommand("export", "Export the conversation with `/export <path> [--format md|json|html] [--context]`", executeExportCommand).
+			WithCompleter(completeExportArgs),
+		NewSlashCommand("clear", "Clear the screen", func(ctx Context, args string) Action {
+			return ClearAction{}
+		}),
+		NewSlashCommand("reset", "Reset conversation history", func(ctx Context, args string) Action {
+			return ResetAction{}
+		}),
+		NewSlashCommand("mode", "Switch between chat and exec modes", func(ctx Context, args string) Action {
+			return ToggleModeAction{}
+		}),
+	}
 }
 
-// ExecuteCommand executes a slash command and returns the output
-func ExecuteCommand(config *config.Config, input string) string {
-	cmd := GetCommand(input)
-	if cmd == nil {
-		return fmt.Sprintf("Unknown command: %s\nType /help for available commands.", input)
+func executeModelCommand(ctx Context, args string) Action {
+	name, save, ok := parseSwitchArgs(args)
+	if !ok {
+		return PrintAction{Content: "Usage: `/model <name> [--save]`"}
+	}
+	if name == "" {
+		return PrintAction{Content: formatModelsOutput(ctx)}
 	}
 
-	// Extract arguments if any
-	parts := strings.SplitN(strings.TrimPrefix(input, "/"), " ", 2)
-	args := ""
-	if len(parts) > 1 {
-		args = parts[1]
+	return SwitchModelAction{Name: name, Save: save}
+}
+
+func executeProviderCommand(ctx Context, args string) Action {
+	name, save, ok := parseSwitchArgs(args)
+	if !ok {
+		return PrintAction{Content: "Usage: `/provider <name> [--save]`"}
+	}
+	if name == "" {
+		return PrintAction{Content: formatProvidersOutput()}
 	}
 
-	return cmd.Execute(config, args)
+	return SwitchProviderAction{Name: name, Save: save}
 }
 
-// GetCompletions returns possible command completions for the current input
-func GetCompletions(input string) []string {
-	if !IsSlashCommand(input) {
-		return nil
+func executeThemeCommand(ctx Context, args string) Action {
+	fields := strings.Fields(args)
+
+	switch len(fields) {
+	case 0:
+		return PrintAction{Content: formatThemesOutput(ctx)}
+	case 1:
+		return SwitchThemeAction{Name: fields[0]}
+	default:
+		return PrintAction{Content: "Usage: `/theme <name>`"}
 	}
+}
 
-	// Get the command name being typed
-	prefix := strings.TrimPrefix(input, "/")
+func executeAddCommand(ctx Context, args string) Action {
+	patterns := strings.Fields(args)
+	if len(patterns) == 0 {
+		return PrintAction{Content: "Usage: `/add <path|dir|glob>...`"}
+	}
 
-	// If the user is typing arguments, return empty
-	if strings.Contains(prefix, " ") {
-		return nil
+	action := AttachAction{}
+	for _, pattern := range patterns {
+		files, warnings, err := attachment.Load(pattern)
+		if err != nil {
+			warnings = append(warnings, err.Error())
+		}
+		action.Files = append(action.Files, files...)
+		action.Warnings = append(action.Warnings, warnings...)
+	}
+
+	return action
+}
+
+func executeImageCommand(ctx Context, args string) Action {
+	paths := strings.Fields(args)
+	if len(paths) == 0 {
+		return PrintAction{Content: "Usage: `/image <path>...`, PNG, JPEG, GIF or WebP images sent with the next prompt"}
 	}
 
-	// Find matching commands
-	var matches []string
-	for _, cmd := range SlashCommands {
-		if strings.HasPrefix(cmd.Name, prefix) {
-			matches = append(matches, "/"+cmd.Name)
+	action := AttachImagesAction{}
+	for _, path := range paths {
+		image, err := attachment.LoadImage(path)
+		if err != nil {
+			action.Warnings = append(action.Warnings, err.Error())
+			continue
 		}
+		action.Images = append(action.Images, image)
 	}
 
-	// Sort alphabetically
-	sort.Strings(matches)
-	return matches
+	return action
+}
+
+// parseSwitchArgs parses `<name> [--save]`, the name being empty if omitted
+func parseSwitchArgs(args string) (string, bool, bool) {
+	fields := strings.Fields(args)
+
+	switch {
+	case len(fields) == 0:
+		return "", false, true
+	case len(fields) == 1:
+		return fields[0], false, true
+	case len(fields) == 2 && fields[1] == "--save":
+		return fields[0], true, true
+	default:
+		return "", false, false
+	}
+}
+
+func executeProfileCommand(ctx Context, args string) Action {
+	fields := strings.Fields(args)
+
+	if len(fields) == 0 || fields[0] == "list" {
+		return PrintAction{Content: formatProfilesOutput(ctx.Config)}
+	}
+
+	if fields[0] == "switch" && len(fields) == 2 {
+		return SwitchProfileAction{Name: fields[1]}
+	}
+
+	return PrintAction{Content: "Usage: `/profile [list]` or `/profile switch <name>`"}
 }
 
 // Format helpers
-func formatHelpOutput() string {
+func formatHelpOutput(registry *Registry) string {
 	var sb strings.Builder
 
 	sb.WriteString("## Available Commands\n\n")
 
-	for _, cmd := range SlashCommands {
-		sb.WriteString(fmt.Sprintf("- `/%s`: %s\n", cmd.Name, cmd.Description))
+	for _, cmd := range registry.Commands() {
+		sb.WriteString(fmt.Sprintf("- `/%s`: %s\n", cmd.Name(), cmd.Description()))
 	}
 
 	sb.WriteString("\nType any command to execute it.")
@@ -162,11 +250,20 @@ func formatConfigOutput(cfg *config.Config) string {
 
 	sb.WriteString("## Current Configuration\n\n")
 
-	// AI Provider Info
-	sb.WriteString(fmt.Sprintf("**Provider**: %s\n", cfg.GetAiConfig().GetProviderType()))
-	sb.WriteString(fmt.Sprintf("**Model**: %s\n", cfg.GetAiConfig().GetModel()))
-	sb.WriteString(fmt.Sprintf("**Temperature**: %.2f\n", cfg.GetAiConfig().GetTemperature()))
-	sb.WriteString(fmt.Sprintf("**Max Tokens**: %d\n", cfg.GetAiConfig().GetMaxTokens()))
+	// AI Provider Info, each value with where it came from
+	ai := cfg.GetAiConfig()
+	sb.WriteString(fmt.Sprintf("**Profile**: %s\n", cfg.GetProfile()))
+	if cfg.GetProjectFile() != "" {
+		sb.WriteString(fmt.Sprintf("**Project Config**: %s\n", cfg.GetProjectFile()))
+	}
+	sb.WriteString(fmt.Sprintf("**Provider**: %s _(%s)_\n", ai.GetProviderType(), ai.GetSource(config.SettingProvider)))
+	sb.WriteString(fmt.Sprintf("**Model**: %s _(%s)_\n", ai.GetModel(), ai.GetSource(config.SettingModel)))
+	sb.WriteString(fmt.Sprintf("**API Key**: %s\n", formatKeySource(ai)))
+	sb.WriteString(fmt.Sprintf("**Temperature**: %.2f _(%s)_\n", ai.GetTemperature(), ai.GetSource(config.SettingTemperature)))
+	sb.WriteString(fmt.Sprintf("**Max Tokens**: %d _(%s)_\n", ai.GetMaxTokens(), ai.GetSource(config.SettingMaxTokens)))
+	if ai.GetProxy() != "" {
+		sb.WriteString(fmt.Sprintf("**Proxy**: %s _(%s)_\n", ai.GetProxy(), ai.GetSource(config.SettingProxy)))
+	}
 
 	// System Info
 	sb.WriteString("\n**System Information**\n")
@@ -182,30 +279,104 @@ func formatConfigOutput(cfg *config.Config) string {
 	if cfg.GetUserConfig().GetPreferences() != "" {
 		sb.WriteString(fmt.Sprintf("- Custom Preferences: %s\n", cfg.GetUserConfig().GetPreferences()))
 	}
+	if cfg.GetUserConfig().GetSystemPrompt() != "" {
+		sb.WriteString(fmt.Sprintf("- System Prompt: %s\n", cfg.GetUserConfig().GetSystemPrompt()))
+	}
+	if policy := cfg.GetCommandPolicy(); len(policy.GetDeny()) > 0 || len(policy.GetConfirm()) > 0 {
+		sb.WriteString(fmt.Sprintf("- Denied Commands: %s\n", formatPatterns(policy.GetDeny())))
+		sb.WriteString(fmt.Sprintf("- Confirmed Commands: %s\n", formatPatterns(policy.GetConfirm())))
+	}
 
 	return sb.String()
 }
 
-func formatModelsOutput(cfg *config.Config) string {
+func formatPatterns(patterns []string) string {
+	if len(patterns) == 0 {
+		return "none"
+	}
+
+	quoted := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		quoted = append(quoted, "`"+pattern+"`")
+	}
+
+	return strings.Join(quoted, ", ")
+}
+
+// formatKeySource tells where the API key comes from, never the key itself
+func formatKeySource(aiConfig config.AiConfig) string {
+	if aiConfig.GetKey() == "" {
+		return "not set"
+	}
+
+	return fmt.Sprintf("set (from %s)", aiConfig.GetKeySource())
+}
+
+func formatContextOutput(ctx Context) string {
 	var sb strings.Builder
 
-	providerType := cfg.GetAiConfig().GetProviderType()
-	currentModel := cfg.GetAiConfig().GetModel()
+	sb.WriteString("## System Context\n\n")
+	sb.WriteString("Sent in the system prompt of every request:\n\n")
+	sb.WriteString(fmt.Sprintf("```\n%s\n```\n\n", strings.TrimSpace(ctx.SystemContext)))
+	if ctx.Config != nil && !ctx.Config.GetUserConfig().IsWorkspaceContext() {
+		sb.WriteString("Set `USER_WORKSPACE_CONTEXT: true` in the settings to also send the git repository, project type and tools of the working directory.\n\n")
+	}
 
-	sb.WriteString(fmt.Sprintf("## Available Models for %s\n\n", providerType))
+	if len(ctx.Images) > 0 {
+		sb.WriteString("## Images\n\n")
+		sb.WriteString("Sent with the next prompt only:\n\n")
+		for _, image := range ctx.Images {
+			sb.WriteString(fmt.Sprintf("- `%s`: %s, %d KiB\n", image.GetPath(), image.GetMimeType(), image.GetSize()))
+		}
+		sb.WriteString("\n")
+	}
 
-	// Create provider instance to get available models
-	p, err := provider.CreateProvider(
-		providerType,
-		cfg.GetAiConfig().GetKey(),
-		cfg.GetAiConfig().GetProxy(),
-	)
+	sb.WriteString("## Attached Files\n\n")
 
-	if err != nil {
-		return fmt.Sprintf("Error getting models: %s", err.Error())
+	if len(ctx.Attachments) == 0 {
+		sb.WriteString("No files attached, use `/add <path>` or mention them as `@path` to attach some.")
+		return sb.String()
 	}
 
-	models := p.AvailableModels()
+	total := 0
+	for _, file := range ctx.Attachments {
+		sb.WriteString(fmt.Sprintf("- `%s`: ~%d tokens\n", file.GetPath(), file.GetTokens()))
+		total += file.GetTokens()
+	}
+
+	sb.WriteString(fmt.Sprintf("\n**Total**: ~%d tokens sent with every request\n", total))
+	sb.WriteString("\nUse `/drop <path>` to detach a file, or `/drop` to detach all of them.")
+
+	return sb.String()
+}
+
+func formatProfilesOutput(cfg *config.Config) string {
+	var sb strings.Builder
+
+	sb.WriteString("## Profiles\n\n")
+
+	for _, name := range config.GetProfileNames() {
+		if name == cfg.GetProfile() {
+			sb.WriteString(fmt.Sprintf("- **%s** (current)\n", name))
+		} else {
+			sb.WriteString(fmt.Sprintf("- %s\n", name))
+		}
+	}
+
+	sb.WriteString("\nUse `/profile switch <name>` to switch.")
+
+	return sb.String()
+}
+
+func formatModelsOutput(ctx Context) string {
+	var sb strings.Builder
+
+	providerType := ctx.Config.GetAiConfig().GetProviderType()
+	currentModel := ctx.Config.GetAiConfig().GetModel()
+
+	sb.WriteString(fmt.Sprintf("## Available Models for %s\n\n", providerType))
+
+	models := ctx.Models
 
 	for i, model := range models {
 		if model == currentModel {
@@ -220,6 +391,26 @@ func formatModelsOutput(cfg *config.Config) string {
 		}
 	}
 
+	sb.WriteString("\nUse `/model <name>` to switch, add `--save` to keep it.")
+
+	return sb.String()
+}
+
+func formatThemesOutput(ctx Context) string {
+	var sb strings.Builder
+
+	sb.WriteString("## Available Themes\n\n")
+
+	for _, theme := range ctx.Themes {
+		if theme == ctx.Theme {
+			sb.WriteString(fmt.Sprintf("- **%s** (current)\n", theme))
+		} else {
+			sb.WriteString(fmt.Sprintf("- %s\n", theme))
+		}
+	}
+
+	sb.WriteString("\nUse `/theme <name>` to switch, or set `USER_THEME` in the settings to keep it.")
+
 	return sb.String()
 }
 
@@ -241,5 +432,7 @@ func formatProvidersOutput() string {
 	sb.WriteString("  - Use `-p claude` flag to select\n")
 	sb.WriteString("  - Default model: claude-3-haiku-20240307\n")
 
+	sb.WriteString("\nUse `/provider <name>` to switch, add `--save` to keep it.")
+
 	return sb.String()
 }
@@ -0,0 +1,86 @@
+package slash
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/xsikor/yai/config"
+)
+
+// customCommandNames tracks the custom commands of the default registry, so
+// they can be replaced when the config is reloaded
+var customCommandNames []string
+
+// LoadCustomCommands registers the user-defined commands in the default
+// registry, replacing the ones loaded before. A custom command cannot shadow
+// a built-in one: it is skipped, the others being registered all the same.
+func LoadCustomCommands(customCommands []config.CustomCommand) error {
+	for _, name := range customCommandNames {
+		DefaultRegistry.Unregister(name)
+	}
+	customCommandNames = nil
+
+	var errs []error
+	for _, customCommand := range customCommands {
+		if err := Register(NewTemplateCommand(customCommand)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		customCommandNames = append(customCommandNames, customCommand.GetName())
+	}
+
+	return errors.Join(errs...)
+}
+
+// NewTemplateCommand returns a command expanding the template of a custom
+// command into a prompt, run in the custom command's mode if it has one.
+func NewTemplateCommand(customCommand config.CustomCommand) Command {
+	return NewSlashCommand(
+		customCommand.GetName(),
+		customCommand.GetDescription(),
+		func(ctx Context, args string) Action {
+			return PromptAction{
+				Prompt: ExpandTemplate(customCommand.GetTemplate(), ctx, args),
+				Mode:   customCommand.GetMode(),
+			}
+		},
+	).WithCompleter(CompleteFiles)
+}
+
+// UsesLastOutput tells if a custom command expands the {{last_output}}
+// placeholder, the output of the commands run having to be captured for it
+func UsesLastOutput(customCommands []config.CustomCommand) bool {
+	for _, customCommand := range customCommands {
+		if strings.Contains(customCommand.GetTemplate(), "{{last_output}}") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ExpandTemplate replaces the {{input}}, {{pipe}}, {{cwd}} and {{last_output}}
+// placeholders of a template. The input is appended to templates without an
+// {{input}} placeholder, so it is never lost.
+func ExpandTemplate(template string, ctx Context, input string) string {
+	input = strings.TrimSpace(input)
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = ""
+	}
+
+	expanded := strings.NewReplacer(
+		"{{input}}", input,
+		"{{pipe}}", ctx.Pipe,
+		"{{cwd}}", cwd,
+		"{{last_output}}", ctx.LastOutput,
+	).Replace(template)
+
+	if input != "" && !strings.Contains(template, "{{input}}") {
+		expanded += "\n\n" + input
+	}
+
+	return expanded
+}
@@ -0,0 +1,68 @@
+package slash
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xsikor/yai/config"
+)
+
+func TestCustomCommands(t *testing.T) {
+	t.Run("ExpandTemplate", testExpandTemplate)
+	t.Run("LoadCustomCommands", testLoadCustomCommands)
+}
+
+func testExpandTemplate(t *testing.T) {
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
+	ctx := Context{Pipe: "piped logs", LastOutput: "$ kubectl get pods"}
+
+	assert.Equal(
+		t,
+		"Triage web-1 in "+cwd+" given piped logs and $ kubectl get pods",
+		ExpandTemplate("Triage {{input}} in {{cwd}} given {{pipe}} and {{last_output}}", ctx, " web-1 "),
+	)
+	assert.Equal(t, "Review this\n\nweb-1", ExpandTemplate("Review this", ctx, "web-1"), "The input should be appended without placeholder.")
+	assert.Equal(t, "Review this", ExpandTemplate("Review this", ctx, ""))
+}
+
+func testLoadCustomCommands(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, LoadCustomCommands(nil)) })
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "yai.json"), []byte(`{
+		"COMMANDS": {
+			"triage": {"TEMPLATE": "Triage {{input}}", "MODE": "exec"},
+			"explain": {"TEMPLATE": "Explain {{last_output}}"},
+			"help": {"TEMPLATE": "Shadowing a built-in"}
+		}
+	}`), 0o600))
+	viper.Reset()
+	viper.AddConfigPath(dir)
+	t.Cleanup(viper.Reset)
+
+	cfg, err := config.NewConfig()
+	require.NoError(t, err)
+
+	assert.True(t, UsesLastOutput(cfg.GetCustomCommands()))
+	assert.False(t, UsesLastOutput(cfg.GetCustomCommands()[:0]))
+
+	err = LoadCustomCommands(cfg.GetCustomCommands())
+	assert.ErrorContains(t, err, "/help", "A custom command should not shadow a built-in one.")
+
+	action := DefaultRegistry.Execute(Context{}, "/triage web-1")
+	assert.Equal(t, PromptAction{Prompt: "Triage web-1", Mode: "exec"}, action)
+
+	// Reloading replaces the custom commands
+	require.NoError(t, LoadCustomCommands(nil))
+	_, ok := DefaultRegistry.Get("triage")
+	assert.False(t, ok)
+	_, ok = DefaultRegistry.Get("help")
+	assert.True(t, ok)
+}
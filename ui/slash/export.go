@@ -0,0 +1,69 @@
+package slash
+
+import (
+	"strings"
+
+	"github.com/xsikor/yai/export"
+)
+
+const exportUsage = "Usage: `/export <path> [--format md|json|html] [--context]`"
+
+func executeExportCommand(ctx Context, args string) Action {
+	fields := strings.Fields(args)
+
+	action := ExportAction{}
+	format := ""
+	for i := 0; i < len(fields); i++ {
+		switch {
+		case fields[i] == "--context":
+			action.Context = true
+		case fields[i] == "--format" && i+1 < len(fields):
+			i++
+			format = fields[i]
+		case strings.HasPrefix(fields[i], "--format="):
+			format = strings.TrimPrefix(fields[i], "--format=")
+		case strings.HasPrefix(fields[i], "--") || action.Path != "":
+			return PrintAction{Content: exportUsage}
+		default:
+			action.Path = fields[i]
+		}
+	}
+	if action.Path == "" {
+		return PrintAction{Content: exportUsage}
+	}
+
+	action.Format = export.FormatOf(action.Path)
+	if format != "" {
+		parsed, err := export.ParseFormat(format)
+		if err != nil {
+			return PrintAction{Content: exportUsage}
+		}
+		action.Format = parsed
+	}
+
+	return action
+}
+
+// completeExportArgs completes the path, then the options and formats
+func completeExportArgs(ctx Context, args string) []string {
+	i := strings.LastIndex(args, " ")
+	if i < 0 {
+		return CompleteFiles(ctx, args)
+	}
+
+	head, partial := args[:i+1], args[i+1:]
+	values := []string{"--format", "--context"}
+	if strings.HasSuffix(head, "--format ") {
+		values = nil
+		for _, format := range export.Formats {
+			values = append(values, string(format))
+		}
+	}
+
+	var matches []string
+	for _, value := range filterPrefix(values, partial) {
+		matches = append(matches, head+value)
+	}
+
+	return matches
+}
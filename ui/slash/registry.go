@@ -0,0 +1,253 @@
+package slash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xsikor/yai/attachment"
+	"github.com/xsikor/yai/codeblock"
+	"github.com/xsikor/yai/config"
+)
+
+// Context is what commands can see of the current session
+type Context struct {
+	Config        *config.Config
+	Models        []string
+	Attachments   []attachment.File
+	Images        []attachment.Image
+	SystemContext string
+	CodeBlocks    []codeblock.Block
+	Themes        []string
+	Theme         string
+	Pipe          string
+	LastOutput    string
+}
+
+// Command is a slash command. Commands register themselves in a Registry,
+// so adding one does not require changing the UI.
+type Command interface {
+	Name() string
+	Description() string
+	// Execute runs the command with the text following its name
+	Execute(ctx Context, args string) Action
+	// Complete returns the completions of the partially typed arguments
+	Complete(ctx Context, args string) []string
+}
+
+// Registry holds the available slash commands by name
+type Registry struct {
+	commands map[string]Command
+}
+
+// DefaultRegistry holds the built-in commands, and the ones registered by
+// other packages with Register
+var DefaultRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{
+		commands: make(map[string]Command),
+	}
+}
+
+// Register adds a command to the default registry
+func Register(command Command) error {
+	return DefaultRegistry.Register(command)
+}
+
+// Register adds a command, refusing to shadow an existing one
+func (r *Registry) Register(command Command) error {
+	if _, ok := r.commands[command.Name()]; ok {
+		return fmt.Errorf("slash command already registered: /%s", command.Name())
+	}
+
+	r.commands[command.Name()] = command
+
+	return nil
+}
+
+// Unregister removes a command, if registered
+func (r *Registry) Unregister(name string) {
+	delete(r.commands, name)
+}
+
+// Get returns the command with the given name
+func (r *Registry) Get(name string) (Command, bool) {
+	command, ok := r.commands[name]
+
+	return command, ok
+}
+
+// Commands returns all commands sorted by name
+func (r *Registry) Commands() []Command {
+	commands := make([]Command, 0, len(r.commands))
+	for _, command := range r.commands {
+		commands = append(commands, command)
+	}
+
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Name() < commands[j].Name()
+	})
+
+	return commands
+}
+
+// Execute runs the command of the input, like "/model gpt-4"
+func (r *Registry) Execute(ctx Context, input string) Action {
+	name, args := splitInput(input)
+
+	command, ok := r.Get(name)
+	if !ok {
+		return PrintAction{
+			Content: fmt.Sprintf("Unknown command: %s\nType /help for available commands.", input),
+		}
+	}
+
+	return command.Execute(ctx, args)
+}
+
+// Complete returns the completions of the input: the command names while
+// typing the name, then the completions of the command's arguments.
+func (r *Registry) Complete(ctx Context, input string) []string {
+	if !IsSlashCommand(input) {
+		return nil
+	}
+
+	name, args := splitInput(input)
+
+	if !strings.Contains(input, " ") {
+		var matches []string
+		for _, command := range r.Commands() {
+			if strings.HasPrefix(command.Name(), name) {
+				matches = append(matches, "/"+command.Name())
+			}
+		}
+
+		return matches
+	}
+
+	command, ok := r.Get(name)
+	if !ok {
+		return nil
+	}
+
+	var matches []string
+	for _, completion := range command.Complete(ctx, args) {
+		matches = append(matches, fmt.Sprintf("/%s %s", name, completion))
+	}
+
+	return matches
+}
+
+// IsSlashCommand checks if the input is a slash command
+func IsSlashCommand(input string) bool {
+	return strings.HasPrefix(input, "/")
+}
+
+func splitInput(input string) (string, string) {
+	parts := strings.SplitN(strings.TrimPrefix(input, "/"), " ", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+
+	return parts[0], parts[1]
+}
+
+// Completer returns the completions of the partially typed arguments of a
+// command, each completion being the whole arguments.
+type Completer func(ctx Context, args string) []string
+
+// CompleteValues completes a single argument to one of the values
+func CompleteValues(values func(ctx Context) []string) Completer {
+	return func(ctx Context, args string) []string {
+		if strings.Contains(args, " ") {
+			return nil
+		}
+
+		return filterPrefix(values(ctx), args)
+	}
+}
+
+// CompleteModels completes a single argument to a model of the current provider
+var CompleteModels = CompleteValues(func(ctx Context) []string {
+	return ctx.Models
+})
+
+// CompleteProfiles completes a single argument to a config profile name
+var CompleteProfiles = CompleteValues(func(ctx Context) []string {
+	return config.GetProfileNames()
+})
+
+// CompleteAttachments completes a single argument to the path of an attached file
+var CompleteAttachments = CompleteValues(func(ctx Context) []string {
+	var paths []string
+	for _, file := range ctx.Attachments {
+		paths = append(paths, file.GetPath())
+	}
+	return paths
+})
+
+// CompleteFiles completes the last argument to a file path
+func CompleteFiles(ctx Context, args string) []string {
+	head, partial := "", args
+	if i := strings.LastIndex(args, " "); i >= 0 {
+		head, partial = args[:i+1], args[i+1:]
+	}
+
+	paths, err := filepath.Glob(partial + "*")
+	if err != nil {
+		return nil
+	}
+
+	var matches []string
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			path += string(filepath.Separator)
+		}
+		matches = append(matches, head+path)
+	}
+
+	return matches
+}
+
+// CompleteSubcommands completes the first argument to a subcommand, then
+// hands the rest over to the subcommand's completer, if any.
+func CompleteSubcommands(subcommands map[string]Completer) Completer {
+	return func(ctx Context, args string) []string {
+		name, rest, found := strings.Cut(args, " ")
+		if !found {
+			names := make([]string, 0, len(subcommands))
+			for name := range subcommands {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			return filterPrefix(names, args)
+		}
+
+		completer := subcommands[name]
+		if completer == nil {
+			return nil
+		}
+
+		var matches []string
+		for _, completion := range completer(ctx, rest) {
+			matches = append(matches, name+" "+completion)
+		}
+
+		return matches
+	}
+}
+
+func filterPrefix(values []string, prefix string) []string {
+	var matches []string
+	for _, value := range values {
+		if strings.HasPrefix(value, prefix) {
+			matches = append(matches, value)
+		}
+	}
+
+	return matches
+}
@@ -0,0 +1,193 @@
+package slash
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xsikor/yai/codeblock"
+	"github.com/xsikor/yai/export"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Run("Register", testRegister)
+	t.Run("Execute", testExecute)
+	t.Run("CompleteNames", testCompleteNames)
+	t.Run("CompleteArguments", testCompleteArguments)
+	t.Run("CompleteFiles", testCompleteFiles)
+	t.Run("Attachments", testAttachments)
+	t.Run("CodeBlocks", testCodeBlocks)
+	t.Run("Themes", testThemes)
+	t.Run("Export", testExport)
+	t.Run("Images", testImages)
+}
+
+func testRegister(t *testing.T) {
+	registry := NewRegistry()
+
+	command := NewSlashCommand("triage", "Triage an incident", func(ctx Context, args string) Action {
+		return PrintAction{Content: "triaging " + args}
+	})
+	require.NoError(t, registry.Register(command))
+	assert.Error(t, registry.Register(command), "A command should not be registered twice.")
+
+	got, ok := registry.Get("triage")
+	require.True(t, ok)
+	assert.Equal(t, "Triage an incident", got.Description())
+	assert.Equal(t, PrintAction{Content: "triaging pod crash"}, registry.Execute(Context{}, "/triage pod crash"))
+}
+
+func testExecute(t *testing.T) {
+	testCases := []struct {
+		input  string
+		action Action
+	}{
+		{"/clear", ClearAction{}},
+		{"/reset", ResetAction{}},
+		{"/mode", ToggleModeAction{}},
+		{"/model gpt-4", SwitchModelAction{Name: "gpt-4"}},
+		{"/model gpt-4 --save", SwitchModelAction{Name: "gpt-4", Save: true}},
+		{"/provider claude", SwitchProviderAction{Name: "claude"}},
+		{"/profile switch work", SwitchProfileAction{Name: "work"}},
+		{"/drop ui/", DetachAction{Pattern: "ui/"}},
+		{"/drop", DetachAction{Pattern: ""}},
+		{"/theme light", SwitchThemeAction{Name: "light"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			assert.Equal(t, tc.action, DefaultRegistry.Execute(Context{}, tc.input))
+		})
+	}
+
+	action := DefaultRegistry.Execute(Context{}, "/unknown")
+	require.IsType(t, PrintAction{}, action)
+	assert.Contains(t, action.(PrintAction).Content, "Unknown command: /unknown")
+}
+
+func testCompleteNames(t *testing.T) {
+	assert.Equal(t, []string{"/models"}, DefaultRegistry.Complete(Context{}, "/models"))
+	assert.Equal(t, []string{"/mode", "/model", "/models"}, DefaultRegistry.Complete(Context{}, "/mod"))
+	assert.Len(t, DefaultRegistry.Complete(Context{}, "/"), len(DefaultRegistry.Commands()), "A slash alone should complete to all commands.")
+	assert.Nil(t, DefaultRegistry.Complete(Context{}, "list"))
+}
+
+func testCompleteArguments(t *testing.T) {
+	ctx := Context{Models: []string{"gpt-4", "gpt-4-turbo", "gpt-3.5-turbo"}}
+
+	assert.Equal(t, []string{"/model gpt-4", "/model gpt-4-turbo"}, DefaultRegistry.Complete(ctx, "/model gpt-4"))
+	assert.Equal(t, []string{"/provider claude"}, DefaultRegistry.Complete(ctx, "/provider cl"))
+	assert.Equal(t, []string{"/profile list", "/profile switch"}, DefaultRegistry.Complete(ctx, "/profile "))
+	assert.Nil(t, DefaultRegistry.Complete(ctx, "/clear "), "Commands without arguments should not complete any.")
+	assert.Nil(t, DefaultRegistry.Complete(ctx, "/unknown "))
+}
+
+func testCompleteFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), nil, 0o600))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "manifests"), 0o700))
+
+	prefix := filepath.Join(dir, "ma")
+	assert.Equal(
+		t,
+		[]string{
+			"logs " + filepath.Join(dir, "main.go"),
+			"logs " + filepath.Join(dir, "manifests") + string(filepath.Separator),
+		},
+		CompleteFiles(Context{}, "logs "+prefix),
+	)
+}
+
+func testAttachments(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o600))
+
+	action := DefaultRegistry.Execute(Context{}, "/add "+filepath.Join(dir, "main.go")+" "+filepath.Join(dir, "missing.go"))
+	require.IsType(t, AttachAction{}, action)
+	attach := action.(AttachAction)
+	assert.Equal(t, []string{filepath.Join(dir, "main.go")}, []string{attach.Files[0].GetPath()})
+	require.Len(t, attach.Warnings, 1)
+	assert.Contains(t, attach.Warnings[0], "missing.go")
+
+	ctx := Context{Attachments: attach.Files, SystemContext: "My context: my shell is zsh, "}
+	output := DefaultRegistry.Execute(ctx, "/context").(PrintAction).Content
+	assert.Contains(t, output, "```\nMy context: my shell is zsh,\n```")
+	assert.Contains(t, output, filepath.Join(dir, "main.go")+"`: ~4 tokens")
+	assert.Contains(t, output, "**Total**: ~4 tokens")
+
+	assert.Equal(t, []string{"/drop " + filepath.Join(dir, "main.go")}, DefaultRegistry.Complete(ctx, "/drop "+dir))
+}
+
+func testCodeBlocks(t *testing.T) {
+	shell := codeblock.NewBlock("bash", "kubectl get pods")
+	python := codeblock.NewBlock("python", "print('hello')")
+	ctx := Context{CodeBlocks: []codeblock.Block{shell, python}}
+
+	assert.Equal(t, RunBlockAction{Number: 1, Block: shell}, DefaultRegistry.Execute(ctx, "/run 1"))
+	assert.Equal(t, CopyBlockAction{Number: 2, Block: python}, DefaultRegistry.Execute(ctx, "/copy 2"))
+	assert.Equal(t, SaveBlockAction{Number: 2, Block: python, Path: "hello.py"}, DefaultRegistry.Execute(ctx, "/save 2 hello.py"))
+
+	output := DefaultRegistry.Execute(ctx, "/run 2").(PrintAction).Content
+	assert.Contains(t, output, "only shell blocks can be run")
+
+	for _, input := range []string{"/run", "/run 3", "/copy zero", "/save 0 out.txt"} {
+		output = DefaultRegistry.Execute(ctx, input).(PrintAction).Content
+		assert.Contains(t, output, "N being from 1 to 2", input)
+		assert.Contains(t, output, "1. _bash_ `kubectl get pods`", input)
+	}
+	assert.Equal(t, PrintAction{Content: "Usage: `/save <N> <path>`"}, DefaultRegistry.Execute(ctx, "/save 1"))
+
+	output = DefaultRegistry.Execute(Context{}, "/run 1").(PrintAction).Content
+	assert.Equal(t, "No code blocks in the last answer.", output)
+
+	assert.Equal(t, []string{"/run 1", "/run 2"}, DefaultRegistry.Complete(ctx, "/run "))
+	assert.Equal(t, []string{"/save 2"}, DefaultRegistry.Complete(ctx, "/save 2"))
+}
+
+func testThemes(t *testing.T) {
+	ctx := Context{Themes: []string{"auto", "dark", "light", "solarized"}, Theme: "dark"}
+
+	output := DefaultRegistry.Execute(ctx, "/theme").(PrintAction).Content
+	assert.Contains(t, output, "- **dark** (current)")
+	assert.Contains(t, output, "- solarized")
+
+	assert.Equal(t, PrintAction{Content: "Usage: `/theme <name>`"}, DefaultRegistry.Execute(ctx, "/theme dark light"))
+	assert.Equal(t, []string{"/theme solarized"}, DefaultRegistry.Complete(ctx, "/theme s"))
+}
+
+func testExport(t *testing.T) {
+	ctx := Context{}
+
+	assert.Equal(t, ExportAction{Path: "incident.md", Format: export.MarkdownFormat}, DefaultRegistry.Execute(ctx, "/export incident.md"))
+	assert.Equal(t, ExportAction{Path: "incident.html", Format: export.HtmlFormat, Context: true}, DefaultRegistry.Execute(ctx, "/export incident.html --context"))
+	assert.Equal(t, ExportAction{Path: "incident.txt", Format: export.JsonFormat}, DefaultRegistry.Execute(ctx, "/export incident.txt --format json"))
+	assert.Equal(t, ExportAction{Path: "incident", Format: export.HtmlFormat}, DefaultRegistry.Execute(ctx, "/export --format=html incident"))
+
+	for _, input := range []string{"/export", "/export a.md b.md", "/export a.md --format pdf", "/export a.md --force"} {
+		assert.Equal(t, PrintAction{Content: exportUsage}, DefaultRegistry.Execute(ctx, input), input)
+	}
+
+	assert.Equal(t, []string{"/export a.md --format", "/export a.md --context"}, DefaultRegistry.Complete(ctx, "/export a.md "))
+	assert.Equal(t, []string{"/export a.md --format html"}, DefaultRegistry.Complete(ctx, "/export a.md --format h"))
+}
+
+func testImages(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "error.png"), []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes\n"), 0o600))
+
+	action := DefaultRegistry.Execute(Context{}, "/image "+filepath.Join(dir, "error.png")+" "+filepath.Join(dir, "notes.txt"))
+	require.IsType(t, AttachImagesAction{}, action)
+	attach := action.(AttachImagesAction)
+	require.Len(t, attach.Images, 1)
+	assert.Equal(t, "image/png", attach.Images[0].GetMimeType())
+	assert.Equal(t, []string{filepath.Join(dir, "notes.txt") + " is not a PNG, JPEG, GIF or WebP image"}, attach.Warnings)
+
+	output := DefaultRegistry.Execute(Context{Images: attach.Images}, "/context").(PrintAction).Content
+	assert.Contains(t, output, "## Images\n\nSent with the next prompt only:\n\n- `"+filepath.Join(dir, "error.png")+"`: image/png, 1 KiB\n")
+
+	assert.Contains(t, DefaultRegistry.Execute(Context{}, "/image").(PrintAction).Content, "Usage: `/image <path>...`")
+}
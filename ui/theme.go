@@ -0,0 +1,332 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+	"gopkg.in/yaml.v3"
+
+	"github.com/xsikor/yai/system"
+)
+
+const (
+	auto_theme          = "auto"
+	dark_theme          = "dark"
+	light_theme         = "light"
+	high_contrast_theme = "high-contrast"
+	no_color_theme      = "no-color"
+)
+
+// themeExtensions are the extensions of the theme files, YAML or JSON
+var themeExtensions = []string{".yaml", ".yml", ".json"}
+
+// ThemeColors are the colors of the terminal UI, as lipgloss colors. An
+// empty color leaves the terminal's default.
+type ThemeColors struct {
+	Exec         string `json:"exec"`
+	Chat         string `json:"chat"`
+	Config       string `json:"config"`
+	Help         string `json:"help"`
+	Error        string `json:"error"`
+	Warning      string `json:"warning"`
+	Success      string `json:"success"`
+	Match        string `json:"match"`
+	CurrentMatch string `json:"current_match"`
+}
+
+// Theme styles the terminal UI: the glamour style of the markdown, and the
+// colors of the prompts and messages
+type Theme struct {
+	name string
+	// markdown is a glamour standard style, like "dark", or the path of a
+	// glamour JSON style, "auto" following the terminal background
+	markdown string
+	// markdownStyle is a glamour JSON style given in the theme file
+	markdownStyle []byte
+	colors        ThemeColors
+}
+
+var builtinThemes = map[string]Theme{
+	auto_theme: {
+		name:     auto_theme,
+		markdown: auto_theme,
+		colors: ThemeColors{
+			Exec:         "#ffa657",
+			Chat:         "#66b3ff",
+			Config:       "#ffffff",
+			Help:         "#aaaaaa",
+			Error:        "#cc3333",
+			Warning:      "#ffcc00",
+			Success:      "#46b946",
+			Match:        "#3d3d00",
+			CurrentMatch: "#806600",
+		},
+	},
+	dark_theme: {
+		name:     dark_theme,
+		markdown: dark_theme,
+		colors: ThemeColors{
+			Exec:         "#ffa657",
+			Chat:         "#66b3ff",
+			Config:       "#ffffff",
+			Help:         "#aaaaaa",
+			Error:        "#cc3333",
+			Warning:      "#ffcc00",
+			Success:      "#46b946",
+			Match:        "#3d3d00",
+			CurrentMatch: "#806600",
+		},
+	},
+	light_theme: {
+		name:     light_theme,
+		markdown: light_theme,
+		colors: ThemeColors{
+			Exec:         "#b35900",
+			Chat:         "#0057b3",
+			Config:       "#333333",
+			Help:         "#666666",
+			Error:        "#b30000",
+			Warning:      "#8a6d00",
+			Success:      "#1e7b1e",
+			Match:        "#fff3a0",
+			CurrentMatch: "#ffd24d",
+		},
+	},
+	high_contrast_theme: {
+		name:     high_contrast_theme,
+		markdown: dark_theme,
+		colors: ThemeColors{
+			Exec:         "#ffaf00",
+			Chat:         "#00ffff",
+			Config:       "#ffffff",
+			Help:         "#ffffff",
+			Error:        "#ff5f5f",
+			Warning:      "#ffff00",
+			Success:      "#00ff00",
+			Match:        "#0000ff",
+			CurrentMatch: "#af00af",
+		},
+	},
+	no_color_theme: {
+		name:     no_color_theme,
+		markdown: "notty",
+	},
+}
+
+// activeTheme styles the terminal UI, set once the config is loaded and by
+// /theme
+var activeTheme = defaultTheme()
+
+// defaultTheme returns the theme used unless the config sets one: without
+// colors if NO_COLOR is set, following the terminal background otherwise
+func defaultTheme() Theme {
+	if os.Getenv("NO_COLOR") != "" {
+		return builtinThemes[no_color_theme]
+	}
+
+	return builtinThemes[auto_theme]
+}
+
+// SetTheme changes the theme of the renderers and prompts created next
+func SetTheme(theme Theme) {
+	activeTheme = theme
+}
+
+func (t Theme) GetName() string {
+	return t.name
+}
+
+func (t Theme) GetColors() ThemeColors {
+	return t.colors
+}
+
+// markdownOption returns the glamour option rendering the markdown with the
+// style of the theme
+func (t Theme) markdownOption() glamour.TermRendererOption {
+	switch {
+	case len(t.markdownStyle) > 0:
+		return glamour.WithStylesFromJSONBytes(t.markdownStyle)
+	case t.markdown == auto_theme:
+		return glamour.WithAutoStyle()
+	default:
+		return glamour.WithStylePath(t.markdown)
+	}
+}
+
+// foreground returns a style writing in the color
+func foreground(color string) lipgloss.Style {
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(color))
+}
+
+// background returns a style highlighting text with the background color,
+// or the fallback style without color
+func background(color string, fallback lipgloss.Style) lipgloss.Style {
+	if color == "" {
+		return fallback
+	}
+
+	return lipgloss.NewStyle().Background(lipgloss.Color(color))
+}
+
+// GetThemesDirectory returns the directory of the custom theme files
+func GetThemesDirectory() string {
+	return filepath.Join(system.GetConfigDirectory(), "themes")
+}
+
+// ResolveTheme returns the theme of the name: a built-in theme, a theme file
+// of the themes directory without its extension, or the path of a theme
+// file. An empty name is the default theme.
+func ResolveTheme(name string) (Theme, error) {
+	return resolveTheme(name, GetThemesDirectory())
+}
+
+func resolveTheme(name string, directory string) (Theme, error) {
+	if name == "" {
+		return defaultTheme(), nil
+	}
+
+	if theme, ok := builtinThemes[name]; ok {
+		return theme, nil
+	}
+
+	if strings.ContainsRune(name, os.PathSeparator) || isThemeFile(name) {
+		return loadThemeFile(name)
+	}
+
+	for _, extension := range themeExtensions {
+		path := filepath.Join(directory, name+extension)
+		if _, err := os.Stat(path); err == nil {
+			return loadThemeFile(path)
+		}
+	}
+
+	return Theme{}, fmt.Errorf("unknown theme: %s, see /theme", name)
+}
+
+// ThemeNames returns the names of the built-in themes, then of the theme
+// files of the themes directory
+func ThemeNames() []string {
+	return themeNames(GetThemesDirectory())
+}
+
+func themeNames(directory string) []string {
+	names := []string{auto_theme, dark_theme, light_theme, high_contrast_theme, no_color_theme}
+
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return names
+	}
+
+	var custom []string
+	for _, entry := range entries {
+		if entry.IsDir() || !isThemeFile(entry.Name()) {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if _, ok := builtinThemes[name]; !ok && !slices.Contains(custom, name) {
+			custom = append(custom, name)
+		}
+	}
+	sort.Strings(custom)
+
+	return append(names, custom...)
+}
+
+func isThemeFile(path string) bool {
+	return slices.Contains(themeExtensions, strings.ToLower(filepath.Ext(path)))
+}
+
+// themeFile is the content of a theme file. The colors left empty are the
+// ones of the base theme, as well as the markdown style if not set.
+type themeFile struct {
+	Base          string          `json:"base"`
+	Markdown      string          `json:"markdown"`
+	MarkdownStyle json.RawMessage `json:"markdown_style"`
+	Colors        ThemeColors     `json:"colors"`
+}
+
+// loadThemeFile reads a YAML or JSON theme file, named after the file
+func loadThemeFile(path string) (Theme, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Theme{}, fmt.Errorf("theme %s: %w", path, err)
+	}
+
+	// YAML being a superset of JSON, both are read as YAML, then converted
+	// to JSON for the glamour style to be read with its JSON field names
+	var value any
+	if err := yaml.Unmarshal(content, &value); err != nil {
+		return Theme{}, fmt.Errorf("theme %s: %w", path, err)
+	}
+	content, err = json.Marshal(value)
+	if err != nil {
+		return Theme{}, fmt.Errorf("theme %s: %w", path, err)
+	}
+
+	var file themeFile
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return Theme{}, fmt.Errorf("theme %s: %w", path, err)
+	}
+
+	if file.Base == "" {
+		file.Base = auto_theme
+	}
+	base, ok := builtinThemes[file.Base]
+	if !ok {
+		return Theme{}, fmt.Errorf("theme %s: unknown base theme: %s", path, file.Base)
+	}
+
+	theme := Theme{
+		name:     strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		markdown: base.markdown,
+		colors:   mergeColors(base.colors, file.Colors),
+	}
+	switch {
+	case len(file.MarkdownStyle) > 0 && string(file.MarkdownStyle) != "null":
+		theme.markdownStyle = file.MarkdownStyle
+	case file.Markdown != "":
+		theme.markdown = file.Markdown
+		// A style file is relative to the theme file
+		if strings.HasSuffix(strings.ToLower(file.Markdown), ".json") && !filepath.IsAbs(file.Markdown) {
+			theme.markdown = filepath.Join(filepath.Dir(path), file.Markdown)
+		}
+	}
+
+	if _, err := glamour.NewTermRenderer(theme.markdownOption()); err != nil {
+		return Theme{}, fmt.Errorf("theme %s: markdown style: %w", path, err)
+	}
+
+	return theme, nil
+}
+
+// mergeColors returns the colors, the empty ones being the base ones
+func mergeColors(base ThemeColors, colors ThemeColors) ThemeColors {
+	pick := func(color string, fallback string) string {
+		if color != "" {
+			return color
+		}
+		return fallback
+	}
+
+	return ThemeColors{
+		Exec:         pick(colors.Exec, base.Exec),
+		Chat:         pick(colors.Chat, base.Chat),
+		Config:       pick(colors.Config, base.Config),
+		Help:         pick(colors.Help, base.Help),
+		Error:        pick(colors.Error, base.Error),
+		Warning:      pick(colors.Warning, base.Warning),
+		Success:      pick(colors.Success, base.Success),
+		Match:        pick(colors.Match, base.Match),
+		CurrentMatch: pick(colors.CurrentMatch, base.CurrentMatch),
+	}
+}
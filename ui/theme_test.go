@@ -0,0 +1,139 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTheme(t *testing.T) {
+	t.Run("Builtin", testThemeBuiltin)
+	t.Run("NoColor", testThemeNoColor)
+	t.Run("File", testThemeFile)
+	t.Run("FileErrors", testThemeFileErrors)
+	t.Run("Names", testThemeNames)
+	t.Run("Renderer", testThemeRenderer)
+}
+
+func writeTheme(t *testing.T, directory string, name string, content string) string {
+	t.Helper()
+
+	path := filepath.Join(directory, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	return path
+}
+
+func testThemeBuiltin(t *testing.T) {
+	for _, name := range []string{"auto", "dark", "light", "high-contrast", "no-color"} {
+		theme, err := resolveTheme(name, t.TempDir())
+		require.NoError(t, err, name)
+		assert.Equal(t, name, theme.GetName())
+		assert.NotNil(t, NewRenderer(theme.markdownOption()), name)
+	}
+
+	assert.Empty(t, builtinThemes[no_color_theme].GetColors())
+
+	_, err := resolveTheme("unknown", t.TempDir())
+	assert.EqualError(t, err, "unknown theme: unknown, see /theme")
+}
+
+func testThemeNoColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	theme, err := resolveTheme("", t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, no_color_theme, theme.GetName())
+
+	// A theme set explicitly wins
+	theme, err = resolveTheme("dark", t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, dark_theme, theme.GetName())
+}
+
+func testThemeFile(t *testing.T) {
+	directory := t.TempDir()
+
+	writeTheme(t, directory, "solarized.yaml", `
+base: light
+markdown: dracula
+colors:
+  exec: "#cb4b16"
+  chat: "#268bd2"
+`)
+	theme, err := resolveTheme("solarized", directory)
+	require.NoError(t, err)
+	assert.Equal(t, "solarized", theme.GetName())
+	assert.Equal(t, "dracula", theme.markdown)
+	assert.Equal(t, "#cb4b16", theme.GetColors().Exec)
+	assert.Equal(t, "#268bd2", theme.GetColors().Chat)
+	// From the base theme
+	assert.Equal(t, builtinThemes[light_theme].GetColors().Error, theme.GetColors().Error)
+
+	// JSON, with an inline glamour style, given by path
+	path := writeTheme(t, directory, "mono.json", `{
+  "base": "no-color",
+  "markdown_style": {"document": {"margin": 1}, "code_block": {"margin": 2}},
+  "colors": {"error": "1"}
+}`)
+	theme, err = resolveTheme(path, t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, "mono", theme.GetName())
+	assert.JSONEq(t, `{"document": {"margin": 1}, "code_block": {"margin": 2}}`, string(theme.markdownStyle))
+	assert.Equal(t, ThemeColors{Error: "1"}, theme.GetColors())
+	assert.NotNil(t, NewRenderer(theme.markdownOption()))
+
+	// A glamour style file, relative to the theme file
+	writeTheme(t, directory, "style.json", `{"document": {"margin": 3}}`)
+	writeTheme(t, directory, "styled.yml", "markdown: style.json\n")
+	theme, err = resolveTheme("styled", directory)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(directory, "style.json"), theme.markdown)
+}
+
+func testThemeFileErrors(t *testing.T) {
+	directory := t.TempDir()
+
+	testCases := map[string]string{
+		"typo.yaml":     "colours:\n  exec: red\n",
+		"base.yaml":     "base: solarized\n",
+		"markdown.yaml": "markdown: missing.json\n",
+		"invalid.json":  "{",
+	}
+
+	for file, content := range testCases {
+		t.Run(file, func(t *testing.T) {
+			path := writeTheme(t, directory, file, content)
+			_, err := resolveTheme(path, directory)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), path)
+		})
+	}
+}
+
+func testThemeNames(t *testing.T) {
+	directory := t.TempDir()
+	writeTheme(t, directory, "solarized.yaml", "base: dark\n")
+	writeTheme(t, directory, "mono.json", "{}")
+	writeTheme(t, directory, "dark.yaml", "base: light\n")
+	writeTheme(t, directory, "notes.txt", "")
+
+	assert.Equal(t, []string{"auto", "dark", "light", "high-contrast", "no-color", "mono", "solarized"}, themeNames(directory))
+	assert.Equal(t, []string{"auto", "dark", "light", "high-contrast", "no-color"}, themeNames(filepath.Join(directory, "missing")))
+}
+
+func testThemeRenderer(t *testing.T) {
+	previous := activeTheme
+	t.Cleanup(func() {
+		SetTheme(previous)
+	})
+
+	SetTheme(builtinThemes[no_color_theme])
+	r := NewRenderer(activeTheme.markdownOption())
+	assert.Equal(t, "[ok]", r.RenderSuccess("[ok]"))
+	assert.Equal(t, "> ", getPromptStyle(ExecPromptMode).Render("> "))
+	assert.NotContains(t, r.RenderContent("# Title\n\n`code`"), "\x1b[")
+}
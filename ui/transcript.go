@@ -0,0 +1,107 @@
+package ui
+
+import (
+	"strings"
+)
+
+type entryKind int
+
+const (
+	// noticeEntry is anything printed that is not a message, like the help,
+	// the mode switches or the errors
+	noticeEntry entryKind = iota
+	promptEntry
+	answerEntry
+)
+
+// transcriptEntry is something printed to the terminal, kept by the full
+// screen transcript
+type transcriptEntry struct {
+	kind entryKind
+	// content is the markdown of a message, to copy it
+	content  string
+	rendered string
+	// open tells that more parts of the answer are to come, while it is
+	// streamed
+	open bool
+}
+
+// Transcript holds what was printed during the session, as the lines of the
+// full screen view
+type Transcript struct {
+	entries []transcriptEntry
+	starts  []int
+	lines   []string
+}
+
+func NewTranscript() *Transcript {
+	return &Transcript{}
+}
+
+// Add appends the entry, as printed. A part of an answer extends the answer
+// being streamed, if any.
+func (t *Transcript) Add(entry transcriptEntry) {
+	lines := strings.Split(entry.rendered, "\n")
+
+	if last := len(t.entries) - 1; last >= 0 && entry.kind == answerEntry && t.entries[last].kind == answerEntry && t.entries[last].open {
+		t.entries[last].rendered += "\n" + entry.rendered
+		t.entries[last].content += entry.content
+		t.entries[last].open = entry.open
+		t.lines = append(t.lines, lines...)
+
+		return
+	}
+
+	t.entries = append(t.entries, entry)
+	t.starts = append(t.starts, len(t.lines))
+	t.lines = append(t.lines, lines...)
+}
+
+// Clear forgets everything printed so far
+func (t *Transcript) Clear() {
+	t.entries = nil
+	t.starts = nil
+	t.lines = nil
+}
+
+func (t *Transcript) GetLines() []string {
+	return t.lines
+}
+
+// GetMessages returns the indexes of the entries that are prompts or answers
+func (t *Transcript) GetMessages() []int {
+	var messages []int
+	for i, entry := range t.entries {
+		if entry.kind != noticeEntry {
+			messages = append(messages, i)
+		}
+	}
+
+	return messages
+}
+
+func (t *Transcript) GetEntry(index int) transcriptEntry {
+	return t.entries[index]
+}
+
+// GetEntryLine returns the first line of the entry
+func (t *Transcript) GetEntryLine(index int) int {
+	return t.starts[index]
+}
+
+// Search returns the lines holding the query, ignoring the case
+func (t *Transcript) Search(query string) []int {
+	query = strings.ToLower(query)
+	if query == "" {
+		return nil
+	}
+
+	var matches []int
+	for i, line := range t.lines {
+		if strings.Contains(strings.ToLower(ansiPattern.ReplaceAllString(line, "")), query) {
+			matches = append(matches, i)
+		}
+	}
+
+	return matches
+}
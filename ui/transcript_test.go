@@ -0,0 +1,81 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranscript(t *testing.T) {
+	t.Run("Add", testTranscriptAdd)
+	t.Run("AddStreamed", testTranscriptAddStreamed)
+	t.Run("Search", testTranscriptSearch)
+	t.Run("Clear", testTranscriptClear)
+}
+
+func newTestTranscript() *Transcript {
+	transcript := NewTranscript()
+	transcript.Add(transcriptEntry{kind: noticeEntry, rendered: "help\n"})
+	transcript.Add(transcriptEntry{kind: promptEntry, content: "list pods", rendered: "> list pods"})
+	transcript.Add(transcriptEntry{kind: answerEntry, content: "Run `kubectl get pods`", rendered: "\nRun \x1b[1mkubectl get pods\x1b[0m\n"})
+	transcript.Add(transcriptEntry{kind: noticeEntry, rendered: "[Switched to command mode]"})
+	transcript.Add(transcriptEntry{kind: promptEntry, content: "and the logs?", rendered: "> and the logs?"})
+
+	return transcript
+}
+
+func testTranscriptAdd(t *testing.T) {
+	transcript := newTestTranscript()
+
+	// Printed lines end with a new line, as with tea.Println
+	assert.Equal(t, []string{
+		"help",
+		"",
+		"> list pods",
+		"",
+		"Run \x1b[1mkubectl get pods\x1b[0m",
+		"",
+		"[Switched to command mode]",
+		"> and the logs?",
+	}, transcript.GetLines())
+
+	messages := transcript.GetMessages()
+	require.Equal(t, []int{1, 2, 4}, messages)
+	assert.Equal(t, 2, transcript.GetEntryLine(messages[0]))
+	assert.Equal(t, 3, transcript.GetEntryLine(messages[1]))
+	assert.Equal(t, 7, transcript.GetEntryLine(messages[2]))
+	assert.Equal(t, "Run `kubectl get pods`", transcript.GetEntry(messages[1]).content)
+}
+
+func testTranscriptAddStreamed(t *testing.T) {
+	transcript := NewTranscript()
+	transcript.Add(transcriptEntry{kind: answerEntry, rendered: "first block", open: true})
+	transcript.Add(transcriptEntry{kind: answerEntry, rendered: "second block", open: true})
+	transcript.Add(transcriptEntry{kind: answerEntry, content: "first block\n\nsecond block\n\nlast", rendered: "last\n"})
+	transcript.Add(transcriptEntry{kind: answerEntry, content: "another answer", rendered: "another answer"})
+
+	require.Equal(t, []int{0, 1}, transcript.GetMessages())
+	assert.Equal(t, "first block\n\nsecond block\n\nlast", transcript.GetEntry(0).content)
+	assert.Equal(t, "first block\nsecond block\nlast\n", transcript.GetEntry(0).rendered)
+	assert.False(t, transcript.GetEntry(0).open)
+	assert.Equal(t, 4, transcript.GetEntryLine(1))
+}
+
+func testTranscriptSearch(t *testing.T) {
+	transcript := newTestTranscript()
+
+	// The styles do not split the words, and the case does not matter
+	assert.Equal(t, []int{4}, transcript.Search("KUBECTL get"))
+	assert.Equal(t, []int{2, 7}, transcript.Search("> "))
+	assert.Empty(t, transcript.Search("1m"))
+	assert.Empty(t, transcript.Search(""))
+}
+
+func testTranscriptClear(t *testing.T) {
+	transcript := newTestTranscript()
+	transcript.Clear()
+
+	assert.Empty(t, transcript.GetLines())
+	assert.Empty(t, transcript.GetMessages())
+}
@@ -2,19 +2,30 @@ package ui
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/spf13/viper"
 
 	"github.com/xsikor/yai/ai"
+	"github.com/xsikor/yai/ai/intent"
 	"github.com/xsikor/yai/ai/provider"
+	"github.com/xsikor/yai/attachment"
+	"github.com/xsikor/yai/codeblock"
 	"github.com/xsikor/yai/config"
+	"github.com/xsikor/yai/export"
 	"github.com/xsikor/yai/history"
 	"github.com/xsikor/yai/run"
+	"github.com/xsikor/yai/system"
+	"github.com/xsikor/yai/ui/slash"
 )
 
 type UiState struct {
@@ -23,14 +34,24 @@ type UiState struct {
 	promptMode   PromptMode
 	providerType provider.ProviderType
 	modelName    string
+	apiKey       string
+	overrides    config.Overrides
+	yes          bool
+	fullscreen   bool
+	ctrlX        bool
 	configuring  bool
 	querying     bool
 	confirming   bool
 	executing    bool
 	args         string
 	pipe         string
+	pipeImages   []attachment.Image
+	pipeChunks   []attachment.PipeChunk
+	concurrency  int
+	truncation   string
 	buffer       string
 	command      string
+	codeBlocks   []codeblock.Block
 }
 
 type UiDimensions struct {
@@ -39,9 +60,11 @@ type UiDimensions struct {
 }
 
 type UiComponents struct {
-	prompt   *Prompt
-	renderer *Renderer
-	spinner  *Spinner
+	prompt     *Prompt
+	renderer   *Renderer
+	markdown   *MarkdownStream
+	fullscreen *Fullscreen
+	spinner    *Spinner
 }
 
 type Ui struct {
@@ -50,23 +73,32 @@ type Ui struct {
 	components UiComponents
 	config     *config.Config
 	engine     *ai.Engine
+	stream     *ai.ChatStream
 	history    *history.History
 }
 
 func NewUi(input *UiInput) *Ui {
-	return &Ui{
+	ui := &Ui{
 		state: UiState{
 			error:        nil,
 			runMode:      input.GetRunMode(),
 			promptMode:   input.GetPromptMode(),
 			providerType: input.GetProviderType(),
 			modelName:    input.GetModelName(),
+			apiKey:       "",
+			overrides:    input.GetOverrides(),
+			yes:          input.GetYes(),
+			fullscreen:   input.IsFullscreen() && input.GetRunMode() == ReplMode,
 			configuring:  false,
 			querying:     false,
 			confirming:   false,
 			executing:    false,
 			args:         input.GetArgs(),
 			pipe:         input.GetPipe(),
+			pipeImages:   input.GetPipeImages(),
+			pipeChunks:   input.GetPipeChunks(),
+			concurrency:  input.GetConcurrency(),
+			truncation:   input.GetPipeTruncation(),
 			buffer:       "",
 			command:      "",
 		},
@@ -77,17 +109,23 @@ func NewUi(input *UiInput) *Ui {
 		components: UiComponents{
 			prompt: NewPrompt(input.GetPromptMode()),
 			renderer: NewRenderer(
-				glamour.WithAutoStyle(),
+				activeTheme.markdownOption(),
 				glamour.WithWordWrap(150),
 			),
-			spinner: NewSpinner(),
+			fullscreen: NewFullscreen(),
+			spinner:    NewSpinner(),
 		},
 		history: history.NewHistory(),
 	}
+	ui.components.markdown = NewMarkdownStream(ui.components.renderer)
+	ui.components.prompt.SetWidth(ui.dimensions.width)
+	ui.components.fullscreen.SetWidth(ui.dimensions.width)
+
+	return ui
 }
 
 func (u *Ui) Init() tea.Cmd {
-	config, err := config.NewConfig()
+	config, err := config.NewConfigWithOverrides(u.state.overrides)
 	if err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
 			if u.state.runMode == ReplMode {
@@ -100,16 +138,19 @@ func (u *Ui) Init() tea.Cmd {
 			}
 		} else {
 			return tea.Sequence(
-				tea.Println(u.components.renderer.RenderError(err.Error())),
+				u.println(u.components.renderer.RenderError(err.Error())),
 				tea.Quit,
 			)
 		}
 	}
 
+	themeErr := u.setTheme(config.GetUserConfig().GetTheme())
+
 	if u.state.runMode == ReplMode {
-		return u.startRepl(config)
+		start := u.startRepl(config)
+		return tea.Sequence(start, u.themeWarning(themeErr), u.pipeWarning())
 	} else {
-		return u.startCli(config)
+		return tea.Sequence(u.themeWarning(themeErr), u.pipeWarning(), u.startCli(config))
 	}
 }
 
@@ -135,18 +176,52 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		u.dimensions.width = msg.Width
 		u.dimensions.height = msg.Height
 		u.components.renderer = NewRenderer(
-			glamour.WithAutoStyle(),
+			activeTheme.markdownOption(),
 			glamour.WithWordWrap(u.dimensions.width),
 		)
+		u.components.markdown.SetRenderer(u.components.renderer)
+		u.components.prompt.SetWidth(u.dimensions.width)
+		u.components.fullscreen.SetWidth(u.dimensions.width)
+	// full screen transcript
+	case transcriptEntry:
+		u.components.fullscreen.Add(msg)
+	case clearTranscriptMsg:
+		u.components.fullscreen.Clear()
 	// keyboard
 	case tea.KeyMsg:
+		if u.state.fullscreen {
+			if cmd, ok := u.updateFullscreen(msg); ok {
+				return u, cmd
+			}
+		}
+
+		// ctrl+x ctrl+e: compose the prompt in the editor, ctrl+x only
+		// mattering if ctrl+e follows
+		idle := !u.state.querying && !u.state.confirming && !u.state.configuring && !u.state.executing
+		if msg.Type == tea.KeyCtrlE && u.state.ctrlX && idle {
+			u.state.ctrlX = false
+			u.state.executing = true
+			u.components.prompt.Blur()
+			return u, u.editPrompt()
+		}
+		u.state.ctrlX = msg.Type == tea.KeyCtrlX && idle
+
 		switch msg.Type {
-		// quit
+		// quit, or interrupt a running chat stream
 		case tea.KeyCtrlC:
+			if u.state.runMode == ReplMode && u.stream != nil {
+				u.engine.Interrupt()
+				return u, nil
+			}
 			return u, tea.Quit
-		// history
+		// history, unless moving between the lines of the input
 		case tea.KeyUp, tea.KeyDown:
-			if !u.state.querying && !u.state.confirming {
+			inInput := msg.Type == tea.KeyUp && !u.components.prompt.IsOnFirstLine() ||
+				msg.Type == tea.KeyDown && !u.components.prompt.IsOnLastLine()
+			if inInput && !u.state.querying && !u.state.confirming {
+				u.components.prompt, promptCmd = u.components.prompt.Update(msg)
+				cmds = append(cmds, promptCmd)
+			} else if !u.state.querying && !u.state.confirming {
 				var input *string
 				if msg.Type == tea.KeyUp {
 					input = u.history.GetPrevious()
@@ -162,48 +237,34 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					)
 				}
 			}
-		// switch mode
+		// switch mode, unless completing a slash command
 		case tea.KeyTab:
-			if !u.state.querying && !u.state.confirming {
-				var modeChangeMessage string
-
-				if u.state.promptMode == ChatPromptMode {
-					u.state.promptMode = ExecPromptMode
-					u.components.prompt.SetMode(ExecPromptMode)
-					u.engine.SetMode(ai.ExecEngineMode)
-					modeChangeMessage = u.components.renderer.RenderSuccess("\n[Switched to command mode with context preservation]\n")
-				} else {
-					u.state.promptMode = ChatPromptMode
-					u.components.prompt.SetMode(ChatPromptMode)
-					u.engine.SetMode(ai.ChatEngineMode)
-					modeChangeMessage = u.components.renderer.RenderSuccess("\n[Switched to chat mode with context preservation]\n")
-				}
-
+			if !u.state.querying && !u.state.confirming && u.components.prompt.IsSlashCommand() {
+				u.components.prompt, promptCmd = u.components.prompt.Update(msg)
+				cmds = append(cmds, promptCmd)
+			} else if !u.state.querying && !u.state.confirming {
 				// Don't call engine.Reset() to preserve context between modes
+				toggleCmd := u.toggleMode()
 				u.components.prompt, promptCmd = u.components.prompt.Update(msg)
 
-				// Add the mode switch information to terminal outputs for better context
-				var oldMode, newMode string
-				if u.state.promptMode == ChatPromptMode {
-					oldMode = "command"
-					newMode = "chat"
-				} else {
-					oldMode = "chat"
-					newMode = "command"
-				}
-				u.engine.AddTerminalOutput(fmt.Sprintf("Switched from %s mode to %s mode. Context from previous conversation was preserved.", oldMode, newMode))
-
 				cmds = append(
 					cmds,
 					promptCmd,
-					tea.Println(modeChangeMessage),
+					toggleCmd,
 					textinput.Blink,
 				)
 			}
-		// enter
+		// enter, alt+enter inserting a new line
 		case tea.KeyEnter:
+			if msg.Alt && !u.state.querying && !u.state.confirming && !u.state.configuring {
+				u.components.prompt, promptCmd = u.components.prompt.Update(msg)
+				return u, promptCmd
+			}
 			return u.handleEnterKey(msg)
 
+		// first key of ctrl+x ctrl+e
+		case tea.KeyCtrlX:
+
 		// help
 		case tea.KeyCtrlH:
 			if !u.state.configuring && !u.state.querying && !u.state.confirming {
@@ -211,7 +272,7 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				cmds = append(
 					cmds,
 					promptCmd,
-					tea.Println(u.components.renderer.RenderContent(u.components.renderer.RenderHelpMessage())),
+					u.println(u.components.renderer.RenderContent(u.components.renderer.RenderHelpMessage())),
 					textinput.Blink,
 				)
 			}
@@ -223,7 +284,7 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				cmds = append(
 					cmds,
 					promptCmd,
-					tea.ClearScreen,
+					u.clearScreen(),
 					textinput.Blink,
 				)
 			}
@@ -238,7 +299,7 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				cmds = append(
 					cmds,
 					promptCmd,
-					tea.ClearScreen,
+					u.clearScreen(),
 					textinput.Blink,
 				)
 			}
@@ -280,13 +341,13 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						cmds = append(
 							cmds,
 							promptCmd,
-							tea.Println(fmt.Sprintf("\n%s\n", u.components.renderer.RenderWarning("[cancel]"))),
+							u.println(fmt.Sprintf("\n%s\n", u.components.renderer.RenderWarning("[cancel]"))),
 							textinput.Blink,
 						)
 					} else {
 						return u, tea.Sequence(
 							promptCmd,
-							tea.Println(fmt.Sprintf("\n%s\n", u.components.renderer.RenderWarning("[cancel]"))),
+							u.println(fmt.Sprintf("\n%s\n", u.components.renderer.RenderWarning("[cancel]"))),
 							tea.Quit,
 						)
 					}
@@ -305,13 +366,26 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	// engine exec feedback
 	case ai.EngineExecOutput:
 		var output string
-		if msg.IsExecutable() {
-			// Check for information queries that should run automatically
-			if strings.Contains(strings.ToLower(u.state.args), "what") ||
-				strings.Contains(strings.ToLower(u.state.args), "how") ||
-				strings.Contains(strings.ToLower(u.state.args), "show") ||
-				strings.Contains(strings.ToLower(u.state.args), "display") ||
-				strings.Contains(strings.ToLower(u.state.args), "print") {
+		content := formatCommand(msg.GetCommand())
+		verdict, pattern := u.config.GetCommandPolicy().Check(msg.GetCommand())
+		if msg.IsExecutable() && verdict == config.PolicyDeny {
+			output = u.components.renderer.RenderContent(formatCommand(msg.GetCommand()))
+			output += u.components.renderer.RenderError(fmt.Sprintf("  [denied] the command matches the policy `%s`\n", pattern))
+			u.components.prompt.Focus()
+			if u.state.runMode == CliMode {
+				u.engine.AddTerminalOutput(output)
+				return u, tea.Sequence(
+					u.printMessage(answerEntry, content, output),
+					tea.Quit,
+				)
+			}
+		} else if msg.IsExecutable() {
+			// Run without confirmation the commands allowed by a policy or
+			// -yes, and the low risk commands answering information queries,
+			// unless a policy asks for confirmation
+			autoRun := verdict.SkipsConfirmation(u.state.yes) ||
+				verdict == config.PolicyAllow && isInformationQuery(u.state.args, msg.GetCommand(), u.config.GetUserConfig().GetIntentThreshold())
+			if autoRun {
 				// Auto-execute basic info commands
 				u.state.confirming = false
 				u.state.executing = true
@@ -321,7 +395,7 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				u.components.prompt, promptCmd = u.components.prompt.Update(msg)
 				return u, tea.Sequence(
 					promptCmd,
-					tea.Println(output),
+					u.printMessage(answerEntry, content, output),
 					u.execCommand(msg.GetCommand()),
 				)
 			}
@@ -329,17 +403,18 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Regular confirmation flow
 			u.state.confirming = true
 			u.state.command = msg.GetCommand()
-			output = u.components.renderer.RenderContent(fmt.Sprintf("`%s`", u.state.command))
+			output = u.components.renderer.RenderContent(formatCommand(u.state.command))
 			output += fmt.Sprintf("  %s\n\n  confirm execution? [y/N]", u.components.renderer.RenderHelp(msg.GetExplanation()))
 			u.components.prompt.Blur()
 		} else {
-			output = u.components.renderer.RenderContent(msg.GetExplanation())
+			content = msg.GetExplanation()
+			output = u.components.renderer.RenderContent(content)
 			u.components.prompt.Focus()
 			if u.state.runMode == CliMode {
 				// Save output to engine context before quitting
 				u.engine.AddTerminalOutput(output)
 				return u, tea.Sequence(
-					tea.Println(output),
+					u.printMessage(answerEntry, content, output),
 					tea.Quit,
 				)
 			}
@@ -350,28 +425,65 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return u, tea.Sequence(
 			promptCmd,
 			textinput.Blink,
-			tea.Println(output),
+			u.printMessage(answerEntry, content, output),
 		)
 	// engine chat stream feedback
 	case ai.EngineChatStreamOutput:
+		u.components.markdown.Write(msg.GetContent())
+		u.state.querying = !msg.IsLast()
 		if msg.IsLast() {
-			output := u.components.renderer.RenderContent(u.state.buffer)
-			u.state.buffer = ""
+			content := u.components.markdown.Content()
+			output := u.components.markdown.Flush()
+			if msg.IsInterrupt() {
+				output += u.components.renderer.RenderWarning("[interrupt]\n")
+			}
+			if err := msg.GetError(); err != nil {
+				if content != "" {
+					output += u.components.renderer.RenderError(fmt.Sprintf("[stream interrupted] %s\n", err))
+				} else {
+					output += u.components.renderer.RenderError(fmt.Sprintf("[error] %s\n", err))
+				}
+			}
+			u.stream = nil
+			u.state.codeBlocks = codeblock.Extract(content)
 			u.components.prompt.Focus()
+			if u.state.runMode == ReplMode && len(u.state.codeBlocks) > 0 {
+				output += u.components.renderer.RenderHelp(formatCodeBlocksHint(u.state.codeBlocks))
+			}
 			if u.state.runMode == CliMode {
 				return u, tea.Sequence(
-					tea.Println(output),
+					u.printStreamed(content, output, false),
 					tea.Quit,
 				)
 			} else {
 				return u, tea.Sequence(
-					tea.Println(output),
+					u.printStreamed(content, output, false),
 					textinput.Blink,
 				)
 			}
 		} else {
-			return u, u.awaitChatStream()
+			// The completed blocks are printed for good, the view only
+			// renders the block in progress
+			if committed := u.components.markdown.Commit(); committed != "" || u.state.fullscreen {
+				return u, tea.Sequence(
+					u.printStreamed("", committed, true),
+					u.awaitChatStream(u.stream),
+				)
+			}
+			return u, u.awaitChatStream(u.stream)
 		}
+	// prompt composed in the editor
+	case promptEditorOutput:
+		u.state.executing = false
+		u.components.prompt.Focus()
+		if msg.err != nil {
+			return u, tea.Sequence(
+				u.println(u.components.renderer.RenderError(fmt.Sprintf("\n[editor error] %s\n", msg.err))),
+				textinput.Blink,
+			)
+		}
+		u.components.prompt.SetValue(msg.prompt)
+		return u, textinput.Blink
 	// runner feedback
 	case run.RunOutput:
 		u.state.querying = false
@@ -383,12 +495,12 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		if u.state.runMode == CliMode {
 			return u, tea.Sequence(
-				tea.Println(output),
+				u.println(output),
 				tea.Quit,
 			)
 		} else {
 			return u, tea.Sequence(
-				tea.Println(output),
+				u.println(output),
 				promptCmd,
 				textinput.Blink,
 			)
@@ -415,6 +527,22 @@ func (u *Ui) View() string {
 		)
 	}
 
+	if u.state.fullscreen {
+		// The transcript takes the height left by the prompt
+		bottom := u.bottomView()
+		return fmt.Sprintf(
+			"%s\n%s",
+			u.components.fullscreen.View(u.dimensions.height-lipgloss.Height(bottom)),
+			bottom,
+		)
+	}
+
+	return u.bottomView()
+}
+
+// bottomView renders what is under the printed content: the prompt, or the
+// query in progress
+func (u *Ui) bottomView() string {
 	if !u.state.querying && !u.state.confirming && !u.state.executing {
 		// If we have active autocomplete, show suggestions
 		if u.components.prompt.HasActiveAutocomplete() {
@@ -428,7 +556,11 @@ func (u *Ui) View() string {
 	}
 
 	if u.state.promptMode == ChatPromptMode {
-		return u.components.renderer.RenderContent(u.state.buffer)
+		if u.state.fullscreen {
+			// In the transcript, after what was printed
+			return ""
+		}
+		return u.components.markdown.View()
 	} else {
 		if u.state.querying {
 			return u.components.spinner.View()
@@ -442,10 +574,22 @@ func (u *Ui) View() string {
 	return ""
 }
 
+// SaveConversation keeps the conversation of the REPL once it ended, for
+// yai export -last
+func (u *Ui) SaveConversation() error {
+	if u.state.runMode != ReplMode || u.engine == nil {
+		return nil
+	}
+
+	return export.SaveLastConversation(u.engine.GetConversation())
+}
+
 func (u *Ui) startRepl(config *config.Config) tea.Cmd {
+	u.state.fullscreen = u.state.fullscreen || config.GetUserConfig().IsFullscreen()
+
 	return tea.Sequence(
-		tea.ClearScreen,
-		tea.Println(u.components.renderer.RenderContent(u.components.renderer.RenderHelpMessage())),
+		u.startScreen(),
+		u.println(u.components.renderer.RenderContent(u.components.renderer.RenderHelpMessage())),
 		textinput.Blink,
 		func() tea.Msg {
 			u.config = config
@@ -473,19 +617,33 @@ func (u *Ui) startRepl(config *config.Config) tea.Cmd {
 			if u.state.pipe != "" {
 				engine.SetPipe(u.state.pipe)
 			}
+			engine.AttachImages(u.state.pipeImages...)
+			engine.SetPipeChunks(u.state.pipeChunks, u.state.concurrency)
 
 			u.engine = engine
 			u.state.buffer = "Welcome \n\n"
 			u.state.command = ""
-			u.components.prompt = NewPrompt(u.state.promptMode)
+			u.components.prompt = u.newPrompt(u.state.promptMode)
+			u.refreshCompletions()
 
-			return nil
+			return loadCustomCommands(config)
 		},
 	)
 }
 
+// loadCustomCommands registers the custom commands of the config, telling
+// about the ones skipped for shadowing a built-in command
+func loadCustomCommands(config *config.Config) tea.Msg {
+	if err := slash.LoadCustomCommands(config.GetCustomCommands()); err != nil {
+		return run.NewRunOutput(err, "[skipped custom commands]", "")
+	}
+
+	return nil
+}
+
 func (u *Ui) startCli(config *config.Config) tea.Cmd {
 	u.config = config
+	detect := u.state.promptMode == DefaultPromptMode
 
 	// Use chat mode as default if no preference in config
 	if u.state.promptMode == DefaultPromptMode {
@@ -511,8 +669,14 @@ func (u *Ui) startCli(config *config.Config) tea.Cmd {
 	if u.state.pipe != "" {
 		engine.SetPipe(u.state.pipe)
 	}
+	engine.AttachImages(u.state.pipeImages...)
+	engine.SetPipeChunks(u.state.pipeChunks, u.state.concurrency)
 
 	u.engine = engine
+	// Without -e nor -c, the intent of the prompt decides the mode if clear
+	if detect {
+		u.detectPromptMode()
+	}
 	u.state.querying = true
 	u.state.confirming = false
 	u.state.buffer = ""
@@ -532,10 +696,7 @@ func (u *Ui) startCli(config *config.Config) tea.Cmd {
 			},
 		)
 	} else {
-		return tea.Batch(
-			u.startChatStream(u.state.args),
-			u.awaitChatStream(),
-		)
+		return u.startChatStream(u.state.args)
 	}
 }
 
@@ -548,7 +709,7 @@ func (u *Ui) startConfig() tea.Cmd {
 
 		u.state.buffer = u.components.renderer.RenderConfigMessage()
 		u.state.command = ""
-		u.components.prompt = NewPrompt(ProviderPromptMode)
+		u.components.prompt = u.newPrompt(ProviderPromptMode)
 
 		return nil
 	}
@@ -558,7 +719,7 @@ func (u *Ui) startModelConfig(providerType provider.ProviderType) tea.Cmd {
 	return func() tea.Msg {
 		u.state.providerType = providerType
 		u.state.buffer = u.components.renderer.RenderModelMessage(string(providerType))
-		u.components.prompt = NewPrompt(ModelPromptMode)
+		u.components.prompt = u.newPrompt(ModelPromptMode)
 
 		return nil
 	}
@@ -573,8 +734,21 @@ func (u *Ui) startApiKeyConfig(model string) tea.Cmd {
 			u.state.modelName = config.GetDefaultModelForProvider(u.state.providerType)
 		}
 
-		u.state.buffer = u.components.renderer.RenderApiKeyMessage()
-		u.components.prompt = NewPrompt(ConfigPromptMode)
+		u.state.buffer = u.components.renderer.RenderApiKeyMessage(config.GetKeyEnvVar(u.state.providerType))
+		u.components.prompt = u.newPrompt(ConfigPromptMode)
+
+		return nil
+	}
+}
+
+func (u *Ui) startStoreKeyConfig(key string) tea.Cmd {
+	return func() tea.Msg {
+		u.state.apiKey = key
+		u.state.buffer = u.components.renderer.RenderStoreKeyMessage(
+			system.GetConfigFile(),
+			config.GetKeyEnvVar(u.state.providerType),
+		)
+		u.components.prompt = u.newPrompt(StoreKeyPromptMode)
 
 		return nil
 	}
@@ -666,15 +840,51 @@ func (u *Ui) finishConfig(input string) tea.Cmd {
 	}
 
 	// Step 3: API key input
-	u.state.configuring = false
+	if u.components.prompt.GetMode() == ConfigPromptMode {
+		if input != "" {
+			return u.startStoreKeyConfig(input)
+		}
 
-	// API Key validation - don't allow empty key
-	if input == "" {
+		// An empty key is fine if the provider's env var already holds one
+		if os.Getenv(config.GetKeyEnvVar(u.state.providerType)) != "" {
+			u.state.apiKey = ""
+			return u.saveConfig(sessionKeyStorage)
+		}
+
+		// API Key validation - don't allow empty key
 		u.state.error = fmt.Errorf("API key cannot be empty. Please provide a valid API key.")
 		// Go back to the API key input
 		return u.startApiKeyConfig(u.state.modelName)
 	}
 
+	// Step 4: API key storage
+	switch strings.ToLower(strings.TrimSpace(input)) {
+	case "f", "file":
+		return u.saveConfig(fileKeyStorage)
+	case "n", "no":
+		return u.saveConfig(sessionKeyStorage)
+	default:
+		return u.saveConfig(keyringKeyStorage)
+	}
+}
+
+// keyStorage is where the setup stores the API key
+type keyStorage int
+
+const (
+	// keyringKeyStorage stores the key in the OS keyring
+	keyringKeyStorage keyStorage = iota
+	// fileKeyStorage stores the key in plaintext in the config file
+	fileKeyStorage
+	// sessionKeyStorage keeps the key for this session only
+	sessionKeyStorage
+)
+
+// saveConfig writes the config gathered by the setup steps, with the API key
+// stored as asked, and starts the engine.
+func (u *Ui) saveConfig(storage keyStorage) tea.Cmd {
+	u.state.configuring = false
+
 	// Model already selected in previous step
 	model := u.state.modelName
 	if model == "" {
@@ -682,14 +892,30 @@ func (u *Ui) finishConfig(input string) tea.Cmd {
 		model = config.GetDefaultModelForProvider(u.state.providerType)
 	}
 
-	config, err := config.WriteConfig(u.state.providerType, input, model, true)
+	var cfg *config.Config
+	var err error
+	switch storage {
+	case keyringKeyStorage:
+		cfg, err = config.WriteConfigWithKeyring(u.state.providerType, u.state.apiKey, model, true)
+		if err != nil {
+			// No keyring on this system: ask again, to store the key elsewhere
+			u.state.configuring = true
+			u.state.error = err
+			return u.startStoreKeyConfig(u.state.apiKey)
+		}
+	case fileKeyStorage:
+		cfg, err = config.WriteConfig(u.state.providerType, u.state.apiKey, model, true)
+	default:
+		cfg, err = config.WriteConfigWithoutKey(u.state.providerType, u.state.apiKey, model, true)
+	}
+	u.state.apiKey = ""
 	if err != nil {
 		u.state.error = err
 		return nil
 	}
 
-	u.config = config
-	engine, err := ai.NewEngine(ai.ExecEngineMode, config)
+	u.config = cfg
+	engine, err := ai.NewEngine(ai.ExecEngineMode, cfg)
 	if err != nil {
 		u.state.error = err
 		return nil
@@ -698,29 +924,37 @@ func (u *Ui) finishConfig(input string) tea.Cmd {
 	if u.state.pipe != "" {
 		engine.SetPipe(u.state.pipe)
 	}
+	engine.AttachImages(u.state.pipeImages...)
+	engine.SetPipeChunks(u.state.pipeChunks, u.state.concurrency)
 
 	u.engine = engine
 
 	if u.state.runMode == ReplMode {
 		return tea.Sequence(
-			tea.ClearScreen,
-			tea.Println(u.components.renderer.RenderSuccess("\n[settings ok]\n")),
+			u.startScreen(),
+			u.println(u.components.renderer.RenderSuccess("\n[settings ok]\n")),
 			textinput.Blink,
 			func() tea.Msg {
 				u.state.buffer = ""
 				u.state.command = ""
-				u.components.prompt = NewPrompt(ExecPromptMode)
+				u.components.prompt = u.newPrompt(ExecPromptMode)
+				u.refreshCompletions()
 
 				return nil
 			},
 		)
 	} else {
+		// The new config defaults to chat mode
+		if u.state.promptMode == DefaultPromptMode {
+			u.engine.SetMode(ai.ChatEngineMode)
+			u.detectPromptMode()
+		}
 		if u.state.promptMode == ExecPromptMode {
 			u.state.querying = true
 			u.state.configuring = false
 			u.state.buffer = ""
 			return tea.Sequence(
-				tea.Println(u.components.renderer.RenderSuccess("\n[settings ok]")),
+				u.println(u.components.renderer.RenderSuccess("\n[settings ok]")),
 				u.components.spinner.Tick,
 				func() tea.Msg {
 					output, err := u.engine.ExecCompletion(u.state.args)
@@ -733,10 +967,7 @@ func (u *Ui) finishConfig(input string) tea.Cmd {
 				},
 			)
 		} else {
-			return tea.Batch(
-				u.startChatStream(u.state.args),
-				u.awaitChatStream(),
-			)
+			return u.startChatStream(u.state.args)
 		}
 	}
 }
@@ -759,29 +990,21 @@ func (u *Ui) startExec(input string) tea.Cmd {
 }
 
 func (u *Ui) startChatStream(input string) tea.Cmd {
-	return func() tea.Msg {
-		u.state.querying = true
-		u.state.executing = false
-		u.state.confirming = false
-		u.state.buffer = ""
-		u.state.command = ""
+	u.state.querying = true
+	u.state.executing = false
+	u.state.confirming = false
+	u.state.buffer = ""
+	u.state.command = ""
+	u.components.markdown = NewMarkdownStream(u.components.renderer)
 
-		err := u.engine.ChatStreamCompletion(input)
-		if err != nil {
-			return err
-		}
+	u.stream = u.engine.ChatStreamCompletion(input)
 
-		return nil
-	}
+	return u.awaitChatStream(u.stream)
 }
 
-func (u *Ui) awaitChatStream() tea.Cmd {
+func (u *Ui) awaitChatStream(stream *ai.ChatStream) tea.Cmd {
 	return func() tea.Msg {
-		output := <-u.engine.GetChannel()
-		u.state.buffer += output.GetContent()
-		u.state.querying = !output.IsLast()
-
-		return output
+		return stream.Next()
 	}
 }
 
@@ -790,7 +1013,13 @@ func (u *Ui) execCommand(input string) tea.Cmd {
 	u.state.confirming = false
 	u.state.executing = true
 
-	c := run.PrepareInteractiveCommand(input)
+	// Keep what the command prints only when a custom command expands it
+	// with {{last_output}}, the command losing its terminal otherwise
+	var captured *run.CapturedOutput
+	if slash.UsesLastOutput(u.config.GetCustomCommands()) {
+		captured = &run.CapturedOutput{}
+	}
+	c := prepareExecCommand(input, captured)
 
 	return tea.ExecProcess(c, func(error error) tea.Msg {
 		u.state.executing = false
@@ -804,11 +1033,29 @@ func (u *Ui) execCommand(input string) tea.Cmd {
 		// Capture command execution result to engine context
 		result := run.NewRunOutput(error, "[error]", "[ok]")
 		u.engine.AddTerminalOutput(fmt.Sprintf("$ %s\n%s", input, output))
+		commandOutput := ""
+		if captured != nil {
+			commandOutput = captured.String()
+		}
+		u.engine.AddCommandRun(input, commandOutput, run.ExitCode(error))
 
 		return result
 	})
 }
 
+// prepareExecCommand prepares a command to run in the terminal, writing its
+// output to captured as well if not nil, unless the command draws on the
+// terminal
+func prepareExecCommand(input string, captured *run.CapturedOutput) *exec.Cmd {
+	c := run.PrepareInteractiveCommand(input)
+	if captured != nil && !run.NeedsTerminal(input) {
+		c.Stdout = io.MultiWriter(os.Stdout, captured)
+		c.Stderr = io.MultiWriter(os.Stderr, captured)
+	}
+
+	return c
+}
+
 func (u *Ui) editSettings() tea.Cmd {
 	u.state.querying = false
 	u.state.confirming = false
@@ -828,21 +1075,397 @@ func (u *Ui) editSettings() tea.Cmd {
 			return run.NewRunOutput(error, "[settings error]", "")
 		}
 
-		config, error := config.NewConfig()
+		config, error := config.NewConfigWithOverrides(u.config.GetOverrides())
 		if error != nil {
 			return run.NewRunOutput(error, "[settings error]", "")
 		}
 
+		// Swap the config in place to keep the conversation
+		if error := u.engine.SetConfig(config); error != nil {
+			return run.NewRunOutput(error, "[settings error]", "")
+		}
 		u.config = config
-		engine, error := ai.NewEngine(ai.ExecEngineMode, config)
-		if u.state.pipe != "" {
-			engine.SetPipe(u.state.pipe)
+		u.refreshCompletions()
+
+		if msg := loadCustomCommands(config); msg != nil {
+			return msg
+		}
+
+		return run.NewRunOutput(nil, "", "[settings ok]")
+	})
+}
+
+// promptEditorOutput is the prompt composed in the editor
+type promptEditorOutput struct {
+	prompt string
+	err    error
+}
+
+// editPrompt opens the editor on the current prompt, to compose it
+func (u *Ui) editPrompt() tea.Cmd {
+	file, err := os.CreateTemp("", "yai-prompt-*.md")
+	if err == nil {
+		_, err = file.WriteString(u.components.prompt.GetValue())
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return func() tea.Msg {
+			return promptEditorOutput{err: err}
+		}
+	}
+
+	c := run.PrepareCommand(fmt.Sprintf("%s '%s'", u.config.GetSystemConfig().GetEditor(), file.Name()))
+
+	return tea.ExecProcess(c, func(error error) tea.Msg {
+		defer os.Remove(file.Name())
+
+		if error != nil {
+			return promptEditorOutput{err: error}
 		}
+
+		content, error := os.ReadFile(file.Name())
 		if error != nil {
-			return run.NewRunOutput(error, "[settings error]", "")
+			return promptEditorOutput{err: error}
 		}
-		u.engine = engine
 
-		return run.NewRunOutput(nil, "", "[settings ok]")
+		return promptEditorOutput{prompt: strings.TrimRight(string(content), "\n")}
 	})
 }
+
+// clearTranscriptMsg clears the full screen transcript
+type clearTranscriptMsg struct{}
+
+// println prints rendered content above the prompt, or to the transcript in
+// full screen mode
+func (u *Ui) println(rendered string) tea.Cmd {
+	return u.printMessage(noticeEntry, "", rendered)
+}
+
+// printMessage prints a prompt or an answer, the transcript keeping its
+// markdown content to copy it
+func (u *Ui) printMessage(kind entryKind, content string, rendered string) tea.Cmd {
+	if !u.state.fullscreen {
+		return tea.Println(rendered)
+	}
+
+	return func() tea.Msg {
+		return transcriptEntry{
+			kind:     kind,
+			content:  content,
+			rendered: rendered,
+		}
+	}
+}
+
+// printStreamed prints a part of the answer being streamed, open until the
+// last one. The transcript gets it right away, to show the block in progress
+// after it without a gap.
+func (u *Ui) printStreamed(content string, rendered string, open bool) tea.Cmd {
+	if !u.state.fullscreen {
+		return tea.Println(rendered)
+	}
+
+	if rendered != "" || !open {
+		u.components.fullscreen.Add(transcriptEntry{
+			kind:     answerEntry,
+			content:  content,
+			rendered: rendered,
+			open:     open,
+		})
+	}
+
+	pending := ""
+	if open {
+		pending = u.components.markdown.View()
+	}
+	u.components.fullscreen.SetPending(pending)
+
+	return nil
+}
+
+// clearScreen clears the terminal, or the transcript in full screen mode
+func (u *Ui) clearScreen() tea.Cmd {
+	if !u.state.fullscreen {
+		return tea.ClearScreen
+	}
+
+	return func() tea.Msg {
+		return clearTranscriptMsg{}
+	}
+}
+
+// startScreen clears the terminal for the REPL, using the alternate screen
+// in full screen mode
+func (u *Ui) startScreen() tea.Cmd {
+	if !u.state.fullscreen {
+		return tea.ClearScreen
+	}
+
+	return tea.Sequence(
+		tea.EnterAltScreen,
+		u.clearScreen(),
+	)
+}
+
+// updateFullscreen handles the keys moving in the transcript, telling if the
+// key was for it
+func (u *Ui) updateFullscreen(msg tea.KeyMsg) (tea.Cmd, bool) {
+	fullscreen := u.components.fullscreen
+
+	if fullscreen.IsBrowsing() {
+		if msg.Type == tea.KeyCtrlC {
+			return nil, false
+		}
+
+		cmd := fullscreen.Update(msg)
+		if !fullscreen.IsBrowsing() {
+			u.components.prompt.Focus()
+			return tea.Batch(cmd, textinput.Blink), true
+		}
+		return cmd, true
+	}
+
+	switch msg.Type {
+	case tea.KeyPgUp, tea.KeyPgDown:
+		fullscreen.ScrollPage(msg.Type == tea.KeyPgUp)
+		return nil, true
+	case tea.KeyEsc:
+		idle := !u.state.querying && !u.state.confirming && !u.state.configuring && !u.state.executing
+		if idle && !u.components.prompt.HasActiveAutocomplete() {
+			fullscreen.Browse()
+			u.components.prompt.Blur()
+			return nil, true
+		}
+	}
+
+	return nil, false
+}
+
+// newPrompt returns a prompt as wide as the terminal
+func (u *Ui) newPrompt(mode PromptMode) *Prompt {
+	return NewPrompt(mode).SetWidth(u.dimensions.width)
+}
+
+func (u *Ui) switchProfile(name string) tea.Cmd {
+	overrides := u.config.GetOverrides()
+	overrides.Profile = name
+
+	config, err := config.NewConfigWithOverrides(overrides)
+	if err == nil {
+		err = u.engine.SetConfig(config)
+	}
+	if err != nil {
+		return u.println(u.components.renderer.RenderError(fmt.Sprintf("\n[profile error] %s\n", err)))
+	}
+
+	u.config = config
+	u.state.overrides = overrides
+	u.refreshCompletions()
+
+	return u.println(u.components.renderer.RenderSuccess(fmt.Sprintf(
+		"\n[Switched to profile %s: %s %s, conversation preserved]\n",
+		config.GetProfile(),
+		config.GetAiConfig().GetProviderType(),
+		config.GetAiConfig().GetModel(),
+	)))
+}
+
+func (u *Ui) switchModel(model string, save bool) tea.Cmd {
+	if !slices.Contains(u.engine.GetAvailableModels(), model) {
+		return u.println(u.components.renderer.RenderError(fmt.Sprintf(
+			"\n[model error] unknown %s model: %s, see /models\n",
+			u.config.GetAiConfig().GetProviderType(),
+			model,
+		)))
+	}
+
+	cfg, err := u.config.WithModel(model)
+	if err != nil {
+		return u.println(u.components.renderer.RenderError(fmt.Sprintf("\n[model error] %s\n", err)))
+	}
+
+	return u.applySwitch(cfg, save, fmt.Sprintf("model %s", model))
+}
+
+func (u *Ui) switchProvider(name string, save bool) tea.Cmd {
+	providerType, err := provider.ParseProviderType(name)
+	if err != nil {
+		return u.println(u.components.renderer.RenderError(fmt.Sprintf("\n[provider error] %s, see /providers\n", err)))
+	}
+
+	cfg, err := u.config.WithProvider(providerType)
+	if err == nil && cfg.GetAiConfig().GetKey() == "" {
+		err = fmt.Errorf("no API key for %s, set %s", providerType, config.GetKeyEnvVar(providerType))
+	}
+	if err != nil {
+		return u.println(u.components.renderer.RenderError(fmt.Sprintf("\n[provider error] %s\n", err)))
+	}
+
+	return u.applySwitch(cfg, save, fmt.Sprintf("%s %s", providerType, cfg.GetAiConfig().GetModel()))
+}
+
+// applySwitch swaps the engine's config in place, keeping the conversation,
+// and writes the new provider and model to the config file if asked to.
+func (u *Ui) applySwitch(cfg *config.Config, save bool, description string) tea.Cmd {
+	if save {
+		saved, err := cfg.Persist()
+		if err != nil {
+			return u.println(u.components.renderer.RenderError(fmt.Sprintf("\n[save error] %s\n", err)))
+		}
+		cfg = saved
+		description += ", saved"
+	}
+
+	if err := u.engine.SetConfig(cfg); err != nil {
+		return u.println(u.components.renderer.RenderError(fmt.Sprintf("\n[switch error] %s\n", err)))
+	}
+
+	u.config = cfg
+	u.state.overrides = cfg.GetOverrides()
+	u.refreshCompletions()
+
+	return u.println(u.components.renderer.RenderSuccess(fmt.Sprintf(
+		"\n[Switched to %s, conversation preserved]\n",
+		description,
+	)))
+}
+
+// setTheme switches to the theme of the name, restyling the renderer and
+// the prompt. What was printed keeps its colors.
+func (u *Ui) setTheme(name string) error {
+	theme, err := ResolveTheme(name)
+	if err != nil {
+		return err
+	}
+
+	SetTheme(theme)
+	u.components.renderer = NewRenderer(
+		activeTheme.markdownOption(),
+		glamour.WithWordWrap(u.dimensions.width),
+	)
+	u.components.markdown.SetRenderer(u.components.renderer)
+	u.components.prompt.SetMode(u.components.prompt.GetMode())
+
+	return nil
+}
+
+// themeWarning tells that the configured theme could not be used, if so
+func (u *Ui) themeWarning(err error) tea.Cmd {
+	if err == nil {
+		return nil
+	}
+
+	return u.println(u.components.renderer.RenderWarning(fmt.Sprintf("\n[theme] %s, using the default theme\n", err)))
+}
+
+// pipeWarning tells that the piped input was truncated, if so
+func (u *Ui) pipeWarning() tea.Cmd {
+	if u.state.truncation == "" {
+		return nil
+	}
+
+	return u.println(u.components.renderer.RenderWarning(fmt.Sprintf("\n[pipe] %s\n", u.state.truncation)))
+}
+
+// toggleMode switches between chat and exec modes, keeping the context
+func (u *Ui) toggleMode() tea.Cmd {
+	var modeChangeMessage string
+
+	if u.state.promptMode == ChatPromptMode {
+		u.state.promptMode = ExecPromptMode
+		u.components.prompt.SetMode(ExecPromptMode)
+		u.engine.SetMode(ai.ExecEngineMode)
+		modeChangeMessage = u.components.renderer.RenderSuccess("\n[Switched to command mode with context preservation]\n")
+	} else {
+		u.state.promptMode = ChatPromptMode
+		u.components.prompt.SetMode(ChatPromptMode)
+		u.engine.SetMode(ai.ChatEngineMode)
+		modeChangeMessage = u.components.renderer.RenderSuccess("\n[Switched to chat mode with context preservation]\n")
+	}
+
+	// Add the mode switch information to terminal outputs for better context
+	var oldMode, newMode string
+	if u.state.promptMode == ChatPromptMode {
+		oldMode = "command"
+		newMode = "chat"
+	} else {
+		oldMode = "chat"
+		newMode = "command"
+	}
+	u.engine.AddTerminalOutput(fmt.Sprintf("Switched from %s mode to %s mode. Context from previous conversation was preserved.", oldMode, newMode))
+
+	return u.println(modeChangeMessage)
+}
+
+// slashContext returns what slash commands can see of the session
+func (u *Ui) slashContext() slash.Context {
+	return slash.Context{
+		Config:        u.config,
+		Models:        u.engine.GetAvailableModels(),
+		Attachments:   u.engine.GetAttachments(),
+		Images:        u.engine.GetImages(),
+		SystemContext: u.engine.GetSystemContext(),
+		CodeBlocks:    u.state.codeBlocks,
+		Themes:        ThemeNames(),
+		Theme:         activeTheme.GetName(),
+		Pipe:          u.state.pipe,
+		LastOutput:    u.engine.GetLastCommandOutput(),
+	}
+}
+
+// refreshCompletions updates the slash command completions depending on the
+// provider in use
+func (u *Ui) refreshCompletions() {
+	u.components.prompt.SetSlashContext(u.slashContext())
+}
+
+// detectPromptMode sets the prompt mode to the one the engine detects from
+// the intent of the prompt, or of the piped content without one
+func (u *Ui) detectPromptMode() {
+	input := u.state.args
+	if input == "" {
+		input = u.state.pipe
+	}
+
+	u.state.promptMode = ChatPromptMode
+	if u.engine.DetectMode(input) == ai.ExecEngineMode {
+		u.state.promptMode = ExecPromptMode
+	}
+}
+
+// isInformationQuery tells if the input clearly asks for information, like
+// "what is using port 8080", rather than for changes, and if the generated
+// command can only read. Only the rules decide, so the same prompt is always
+// run the same way.
+func isInformationQuery(input string, command string, threshold float64) bool {
+	return intent.NewClassifier(threshold).IsQuery(input) && run.AssessRisk(command) == run.LowRisk
+}
+
+// formatCommand returns the markdown of a command, as a code block if it
+// spans several lines
+func formatCommand(command string) string {
+	if strings.Contains(command, "\n") {
+		return fmt.Sprintf("```sh\n%s\n```", command)
+	}
+
+	return fmt.Sprintf("`%s`", command)
+}
+
+// formatCodeBlocksHint lists the code blocks of an answer by number, with
+// the commands acting on them
+func formatCodeBlocksHint(blocks []codeblock.Block) string {
+	var sb strings.Builder
+
+	for i, block := range blocks {
+		language := block.GetLanguage()
+		if language == "" {
+			language = "code"
+		}
+		sb.WriteString(fmt.Sprintf("  [%d] %s: %s\n", i+1, language, block.GetSummary()))
+	}
+	sb.WriteString("  /run <N>, /copy <N> or /save <N> <path>\n")
+
+	return sb.String()
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 
 	"github.com/xsikor/yai/ai"
+	"github.com/xsikor/yai/attachment"
+	"github.com/xsikor/yai/ui/slash"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -27,62 +29,15 @@ func (u *Ui) handleEnterKey(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if input != "" {
 			// Check if this is a slash command
 			if u.components.prompt.IsSlashCommand() {
-				cmdOutput := u.components.prompt.ExecuteSlashCommand(u.config)
+				action := u.components.prompt.ExecuteSlashCommand(u.slashContext())
 				inputPrint := u.components.prompt.AsString()
 				u.history.Add(input)
 				u.components.prompt.SetValue("")
 				u.components.prompt, promptCmd = u.components.prompt.Update(msg)
 
-				// Handle special commands
-				if cmdOutput == "[clear]" {
-					return u, tea.Sequence(
-						promptCmd,
-						tea.ClearScreen,
-						textinput.Blink,
-					)
-				} else if cmdOutput == "[reset]" {
-					u.engine.Reset()
-					u.history.Reset()
-					return u, tea.Sequence(
-						promptCmd,
-						tea.Println(u.components.renderer.RenderSuccess("\n[History cleared]\n")),
-						textinput.Blink,
-					)
-				} else if cmdOutput == "[mode]" {
-					// Toggle mode with context preservation
-					var modeChangeMessage string
-
-					if u.state.promptMode == ChatPromptMode {
-						u.state.promptMode = ExecPromptMode
-						u.components.prompt.SetMode(ExecPromptMode)
-						u.engine.SetMode(ai.ExecEngineMode)
-						modeChangeMessage = fmt.Sprintf("\n[Switched to %s mode with context preservation]\n", u.state.promptMode.String())
-						// Add the mode switch information to terminal outputs for better context
-						u.engine.AddTerminalOutput("Switched from chat mode to command mode. Context from previous conversation was preserved.")
-
-						modeChangeMessage = fmt.Sprintf("\n[Switched to %s mode with context preservation]\n", u.state.promptMode.String())
-						u.engine.SetMode(ai.ChatEngineMode)
-						modeChangeMessage = fmt.Sprintf("\n[Switched to %s mode with context preservation]\n", u.state.promptMode.String())
-						// Add the mode switch information to terminal outputs for better context
-						u.engine.AddTerminalOutput("Switched from command mode to chat mode. Context from previous conversation was preserved.")
-
-						u.state.promptMode = ChatPromptMode
-						u.components.prompt.SetMode(ChatPromptMode)
-						u.engine.SetMode(ai.ChatEngineMode)
-						modeChangeMessage = fmt.Sprintf("\n[Switched to %s mode with context preservation]\n", u.state.promptMode.String())
-					}
-					return u, tea.Sequence(
-						promptCmd,
-						tea.Println(u.components.renderer.RenderSuccess(modeChangeMessage)),
-						textinput.Blink,
-					)
-				}
-
-				// Regular command output
 				return u, tea.Sequence(
 					promptCmd,
-					tea.Println(inputPrint),
-					tea.Println(u.components.renderer.RenderContent(cmdOutput)),
+					u.applySlashAction(action, inputPrint),
 					textinput.Blink,
 				)
 			}
@@ -94,27 +49,201 @@ func (u *Ui) handleEnterKey(msg tea.Msg) (tea.Model, tea.Cmd) {
 			u.components.prompt.Blur()
 			u.components.prompt, promptCmd = u.components.prompt.Update(msg)
 
-			// Store the input as args for auto-execution detection
-			u.state.args = input
-			if u.state.promptMode == ChatPromptMode {
-				cmds = append(
-					cmds,
-					promptCmd,
-					tea.Println(inputPrint),
-					u.startChatStream(input),
-					u.awaitChatStream(),
-				)
-			} else {
-				cmds = append(
-					cmds,
-					promptCmd,
-					tea.Println(inputPrint),
-					u.startExec(input),
-					u.components.spinner.Tick,
-				)
-			}
+			cmds = append(
+				cmds,
+				promptCmd,
+				tea.Sequence(
+					u.printMessage(promptEntry, input, inputPrint),
+					u.attachMentions(input),
+					u.startQuery(input),
+				),
+			)
 		}
 	}
 
 	return u, tea.Batch(cmds...)
 }
+
+// applySlashAction performs what a slash command asked for
+func (u *Ui) applySlashAction(action slash.Action, inputPrint string) tea.Cmd {
+	if action == nil {
+		return nil
+	}
+
+	return action.Apply(slashUi{u: u, inputPrint: inputPrint})
+}
+
+// slashUi is what slash command actions can do with the UI, inputPrint being
+// the command as printed once submitted
+type slashUi struct {
+	u          *Ui
+	inputPrint string
+}
+
+func (s slashUi) Engine() *ai.Engine {
+	return s.u.engine
+}
+
+func (s slashUi) Echo() tea.Cmd {
+	return s.u.println(s.inputPrint)
+}
+
+func (s slashUi) PrintContent(content string) tea.Cmd {
+	return s.u.println(s.u.components.renderer.RenderContent(content))
+}
+
+func (s slashUi) PrintSuccess(message string) tea.Cmd {
+	return s.u.println(s.u.components.renderer.RenderSuccess(fmt.Sprintf("\n[%s]\n", message)))
+}
+
+func (s slashUi) PrintError(label string, err error) tea.Cmd {
+	return s.u.println(s.u.components.renderer.RenderError(fmt.Sprintf("\n[%s] %s\n", label, err)))
+}
+
+func (s slashUi) PrintAttached(files []attachment.File, warnings []string) tea.Cmd {
+	return s.u.renderAttached(files, warnings)
+}
+
+func (s slashUi) PrintAttachedImages(images []attachment.Image, warnings []string) tea.Cmd {
+	return s.u.renderAttachedImages(images, warnings)
+}
+
+func (s slashUi) ClearScreen() tea.Cmd {
+	return s.u.clearScreen()
+}
+
+func (s slashUi) ResetHistory() {
+	s.u.history.Reset()
+}
+
+func (s slashUi) ToggleMode() tea.Cmd {
+	return s.u.toggleMode()
+}
+
+func (s slashUi) SwitchProfile(name string) tea.Cmd {
+	return s.u.switchProfile(name)
+}
+
+func (s slashUi) SwitchModel(name string, save bool) tea.Cmd {
+	return s.u.switchModel(name, save)
+}
+
+func (s slashUi) SwitchProvider(name string, save bool) tea.Cmd {
+	return s.u.switchProvider(name, save)
+}
+
+func (s slashUi) SetTheme(name string) error {
+	return s.u.setTheme(name)
+}
+
+func (s slashUi) Query(prompt string, mode string) tea.Cmd {
+	cmds := []tea.Cmd{}
+	if mode != "" && GetPromptModeFromString(mode) != s.u.state.promptMode {
+		cmds = append(cmds, s.u.toggleMode())
+	}
+	s.u.components.prompt.Blur()
+
+	return tea.Sequence(append(cmds, s.u.printMessage(promptEntry, prompt, s.inputPrint), s.u.startQuery(prompt))...)
+}
+
+func (s slashUi) Propose(command string, explanation string) tea.Cmd {
+	// The command goes through the same checks as a generated one, without
+	// the auto-run of information queries
+	s.u.state.args = ""
+	s.u.components.prompt.Blur()
+
+	return tea.Sequence(
+		s.u.println(s.inputPrint),
+		func() tea.Msg {
+			return ai.EngineExecOutput{
+				Command:     command,
+				Explanation: explanation,
+				Executable:  true,
+			}
+		},
+	)
+}
+
+// startQuery sends the input to the AI in the current mode
+func (u *Ui) startQuery(input string) tea.Cmd {
+	// Store the input as args for auto-execution detection
+	u.state.args = input
+	if u.state.promptMode == ChatPromptMode {
+		return u.startChatStream(input)
+	}
+
+	return tea.Batch(
+		u.startExec(input),
+		u.components.spinner.Tick,
+	)
+}
+
+// attachMentions attaches the files mentioned in the input as @path
+func (u *Ui) attachMentions(input string) tea.Cmd {
+	mentions := attachment.ParseMentions(input)
+	if len(mentions) == 0 {
+		return nil
+	}
+
+	var files []attachment.File
+	var warnings []string
+	for _, mention := range mentions {
+		loaded, loadWarnings, err := attachment.Load(mention)
+		if err != nil {
+			loadWarnings = append(loadWarnings, err.Error())
+		}
+		files = append(files, loaded...)
+		warnings = append(warnings, loadWarnings...)
+	}
+
+	u.engine.Attach(files...)
+
+	return u.renderAttached(files, warnings)
+}
+
+// renderAttached tells which files were attached, and which could not be
+func (u *Ui) renderAttached(files []attachment.File, warnings []string) tea.Cmd {
+	var cmds []tea.Cmd
+
+	if len(files) > 0 {
+		tokens := 0
+		for _, file := range files {
+			tokens += file.GetTokens()
+		}
+		cmds = append(cmds, u.println(u.components.renderer.RenderSuccess(fmt.Sprintf(
+			"\n[Attached %d file(s), ~%d tokens, see /context]\n",
+			len(files),
+			tokens,
+		))))
+	}
+
+	for _, warning := range warnings {
+		cmds = append(cmds, u.println(u.components.renderer.RenderWarning(fmt.Sprintf("[attach] %s", warning))))
+	}
+
+	return tea.Sequence(cmds...)
+}
+
+// renderAttachedImages tells which images go with the next prompt, and which
+// could not be loaded
+func (u *Ui) renderAttachedImages(images []attachment.Image, warnings []string) tea.Cmd {
+	var cmds []tea.Cmd
+
+	if len(images) > 0 {
+		size := 0
+		for _, image := range images {
+			size += image.GetSize()
+		}
+		cmds = append(cmds, u.println(u.components.renderer.RenderSuccess(fmt.Sprintf(
+			"\n[Attached %d image(s), %d KiB, sent with the next prompt]\n",
+			len(images),
+			size,
+		))))
+	}
+
+	for _, warning := range warnings {
+		cmds = append(cmds, u.println(u.components.renderer.RenderWarning(fmt.Sprintf("[image] %s", warning))))
+	}
+
+	return tea.Sequence(cmds...)
+}
@@ -0,0 +1,131 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xsikor/yai/ai"
+	"github.com/xsikor/yai/config"
+	"github.com/xsikor/yai/internal/testprovider"
+	"github.com/xsikor/yai/run"
+	"github.com/xsikor/yai/ui/slash"
+)
+
+func TestIsInformationQuery(t *testing.T) {
+	testCases := []struct {
+		input   string
+		command string
+		query   bool
+	}{
+		{"what is using port 8080", "lsof -i :8080", true},
+		{"show the disk usage of my home folder", "du -sh ~", true},
+		// A query asking for changes as well
+		{"find all .log files and delete them", "find . -name '*.log' -delete", false},
+		{"get all pods and delete the failing ones", "kubectl get pods | grep Error", false},
+		{"search for node_modules folders and rm them", "find . -name node_modules -exec rm -rf {} +", false},
+		// A query answered with a command changing things
+		{"what is using port 8080", "kill $(lsof -t -i :8080)", false},
+		{"show the disk usage of my home folder", "du -sh ~ > usage.txt", false},
+		{"delete all stopped containers", "docker container prune -f", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input+": "+tc.command, func(t *testing.T) {
+			assert.Equal(t, tc.query, isInformationQuery(tc.input, tc.command, 0))
+		})
+	}
+}
+
+// loadConfig loads the config from a config file with the given content
+func loadConfig(t *testing.T, content string) *config.Config {
+	t.Helper()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "yai.json"), []byte(content), 0o600))
+	viper.Reset()
+	viper.AddConfigPath(dir)
+	t.Cleanup(viper.Reset)
+	t.Setenv("OPENAI_API_KEY", "key")
+
+	cfg, err := config.NewConfig()
+	require.NoError(t, err)
+
+	return cfg
+}
+
+func TestPolicyConfirmation(t *testing.T) {
+	cfg := loadConfig(t, `{"COMMAND_POLICIES": {"CONFIRM": ["kubectl delete *"]}}`)
+
+	testCases := []struct {
+		command    string
+		confirming bool
+	}{
+		// -yes never skips the confirmation asked by a policy
+		{"kubectl delete pod web", true},
+		{"kubectl scale deployment web --replicas 2", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.command, func(t *testing.T) {
+			u := NewUi(&UiInput{runMode: ReplMode, promptMode: ExecPromptMode, yes: true})
+			u.config = cfg
+			u.engine = ai.NewEngineWithProvider(ai.ExecEngineMode, cfg, &testprovider.Provider{})
+
+			u.Update(ai.EngineExecOutput{Command: tc.command, Executable: true})
+
+			assert.Equal(t, tc.confirming, u.state.confirming)
+			assert.Equal(t, !tc.confirming, u.state.executing)
+		})
+	}
+}
+
+func TestPrepareExecCommand(t *testing.T) {
+	// Without capture, every command keeps the terminal, set by tea.ExecProcess
+	for _, command := range []string{"ls -la", "docker run -it ubuntu bash", "psql", "git log"} {
+		c := prepareExecCommand(command, nil)
+		assert.Nil(t, c.Stdout, command)
+		assert.Nil(t, c.Stderr, command)
+	}
+
+	var captured run.CapturedOutput
+	c := prepareExecCommand("ls -la", &captured)
+	assert.NotNil(t, c.Stdout)
+	assert.NotNil(t, c.Stderr)
+
+	// The programs drawing on the terminal are never captured
+	c = prepareExecCommand("vim main.go", &captured)
+	assert.Nil(t, c.Stdout)
+	assert.Nil(t, c.Stderr)
+}
+
+func TestLoadCustomCommands(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, slash.LoadCustomCommands(nil)) })
+
+	cfg := loadConfig(t, `{
+		"COMMANDS": {
+			"triage": {"TEMPLATE": "Triage {{input}}"},
+			"help": {"TEMPLATE": "Shadowing a built-in"}
+		}
+	}`)
+
+	// A custom command shadowing a built-in one is skipped with a warning,
+	// leaving the REPL usable
+	msg := loadCustomCommands(cfg)
+	require.IsType(t, run.RunOutput{}, msg)
+	assert.Contains(t, msg.(run.RunOutput).GetErrorMessage(), "/help")
+
+	u := NewUi(&UiInput{runMode: ReplMode, promptMode: ChatPromptMode})
+	u.config = cfg
+	u.Update(msg)
+	assert.NoError(t, u.state.error)
+
+	_, ok := slash.DefaultRegistry.Get("triage")
+	assert.True(t, ok)
+
+	assert.Nil(t, loadCustomCommands(loadConfig(t, `{"COMMANDS": {"triage": {"TEMPLATE": "Triage {{input}}"}}}`)))
+}